@@ -0,0 +1,48 @@
+package command
+
+import (
+	"errors"
+	"md/middleware"
+	"md/service"
+	"os"
+)
+
+func init() {
+	register("backup", "backup <输出文件.zip>  备份数据库、图片及附件，服务运行时也可执行", backup)
+	register("restore", "restore <备份文件.zip>  从备份恢复数据，会覆盖当前数据，需先停止服务（服务运行时拒绝执行）", restore)
+}
+
+// 备份数据库及图片
+func backup(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法：md backup <输出文件.zip>")
+	}
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			file.Close()
+			os.Remove(args[0])
+			panic(r)
+		}
+	}()
+	service.Backup(file)
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	middleware.Log.Info("已备份至：", args[0])
+	return nil
+}
+
+// 从备份恢复数据
+func restore(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法：md restore <备份文件.zip>")
+	}
+	service.Restore(args[0])
+	return nil
+}
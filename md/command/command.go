@@ -0,0 +1,60 @@
+// 命令行子命令，用法：md [参数] <命令> [命令参数]
+package command
+
+import (
+	"errors"
+	"fmt"
+	"md/model/common"
+	"sort"
+	"strings"
+)
+
+// 子命令
+type subcommand struct {
+	Usage string                    // 用法说明
+	Run   func(args []string) error // 执行函数
+}
+
+// 已注册的子命令
+var subcommands = map[string]subcommand{}
+
+// 注册子命令
+func register(name, usage string, run func(args []string) error) {
+	subcommands[name] = subcommand{Usage: usage, Run: run}
+}
+
+// 执行子命令，service层主动抛出的异常转为error返回
+func Run(name string, args []string) (err error) {
+	cmd, ok := subcommands[name]
+	if !ok {
+		return fmt.Errorf("未知命令：%s，可用命令：\n%s", name, Usage())
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(common.ErrorResponse)
+			if !ok {
+				panic(r)
+			}
+			if e.Err != nil {
+				err = fmt.Errorf("%s：%w", e.Message, e.Err)
+			} else {
+				err = errors.New(e.Message)
+			}
+		}
+	}()
+	return cmd.Run(args)
+}
+
+// 全部子命令的用法说明
+func Usage() string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString("  " + subcommands[name].Usage + "\n")
+	}
+	return builder.String()
+}
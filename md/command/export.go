@@ -0,0 +1,66 @@
+package command
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/service"
+	"md/util"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	register("export", "export <文档ID或文集ID> <输出文件.pdf|.docx|.epub>  导出文档或文集为PDF、Word、EPUB", export)
+}
+
+// 导出文档或文集，格式由输出文件扩展名决定
+func export(args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法：md export <文档ID或文集ID> <输出文件.pdf|.docx|.epub>")
+	}
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(args[1]), "."))
+	if _, ok := util.ExportContentTypes[format]; !ok {
+		return errors.New("不支持的导出格式，输出文件扩展名可选：.pdf、.docx、.epub")
+	}
+
+	// 先按文档查找，不存在时按文集查找
+	var exportFunc func(w io.Writer)
+	document, err := dao.DocumentGetByIdAnyUser(middleware.Db, args[0])
+	if err == nil {
+		exportFunc = func(w io.Writer) { service.DocumentExport(document.Id, document.UserId, format, w) }
+	} else if err == sql.ErrNoRows {
+		book, err := dao.BookGetByIdAnyUser(middleware.Db, args[0])
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errors.New("文档或文集不存在")
+			}
+			return err
+		}
+		exportFunc = func(w io.Writer) { service.BookExport(book.Id, book.UserId, format, w) }
+	} else {
+		return err
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			file.Close()
+			os.Remove(args[1])
+			panic(r)
+		}
+	}()
+	exportFunc(file)
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	middleware.Log.Info("已导出至：", args[1])
+	return nil
+}
@@ -0,0 +1,37 @@
+package command
+
+import (
+	"database/sql"
+	"errors"
+	"md/dao"
+	"md/middleware"
+	"md/service"
+	"os"
+)
+
+func init() {
+	register("export-site", "export-site <文集ID> <输出文件.zip>  导出文集为静态网站", exportSite)
+}
+
+// 导出文集为静态网站
+func exportSite(args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法：md export-site <文集ID> <输出文件.zip>")
+	}
+	book, err := dao.BookGetByIdAnyUser(middleware.Db, args[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("文集不存在")
+		}
+		return err
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	service.BookExportSite(book.Id, book.UserId, file)
+	middleware.Log.Info("文集已导出至：", args[1])
+	return file.Close()
+}
@@ -0,0 +1,34 @@
+package command
+
+import (
+	"database/sql"
+	"errors"
+	"md/dao"
+	"md/middleware"
+	"md/service"
+)
+
+func init() {
+	register("import-markdown", "import-markdown <用户名> <zip文件或目录>  导入Markdown（如Obsidian仓库），第一层文件夹作为文集", importMarkdown)
+}
+
+// 导入Markdown
+func importMarkdown(args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法：md import-markdown <用户名> <zip文件或目录>")
+	}
+	user, err := dao.UserGetByName(middleware.Db, args[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+
+	result := service.MarkdownImportPath(args[1], user.Id)
+	for _, v := range result.Warnings {
+		middleware.Log.Warn(v)
+	}
+	middleware.Log.Infof("已导入%d个文集、%d个文件夹、%d个文档、%d张图片", len(result.Books), result.FolderCount, result.DocumentCount, result.PictureCount)
+	return nil
+}
@@ -0,0 +1,19 @@
+package command
+
+import (
+	"errors"
+	"md/service"
+)
+
+func init() {
+	register("migrate-db", "migrate-db <postgres|sqlite>  将数据迁移到目标数据库，需填写postgres参数并先停止服务", migrateDB)
+}
+
+// 在sqlite和postgres之间迁移数据
+func migrateDB(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法：md migrate-db <postgres|sqlite>")
+	}
+	service.MigrateDB(args[0])
+	return nil
+}
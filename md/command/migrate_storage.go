@@ -0,0 +1,19 @@
+package command
+
+import (
+	"errors"
+	"md/service"
+)
+
+func init() {
+	register("migrate-storage", "migrate-storage <local|s3>  将图片等文件从当前存储复制到目标存储，需填写s3参数", migrateStorage)
+}
+
+// 在本地磁盘和对象存储之间迁移文件
+func migrateStorage(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法：md migrate-storage <local|s3>")
+	}
+	service.MigrateStorage(args[0])
+	return nil
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"errors"
+	"md/middleware"
+	"md/model/entity"
+	"md/service"
+	"strconv"
+	"time"
+)
+
+func init() {
+	register("picture-scan", "picture-scan [purge [宽限天数]]  扫描孤立的图片文件、文件不存在的记录和未被引用的图片，purge时清理早于宽限天数（默认7）的文件和记录", pictureScan)
+}
+
+// 扫描并清理图片
+func pictureScan(args []string) error {
+	condition := entity.PictureScanCondition{GraceDays: service.PictureScanGraceDays}
+	if len(args) > 2 || len(args) > 0 && args[0] != "purge" {
+		return errors.New("用法：md picture-scan [purge [宽限天数]]")
+	}
+	condition.Purge = len(args) > 0
+	if len(args) == 2 {
+		graceDays, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errors.New("宽限天数格式错误")
+		}
+		condition.GraceDays = graceDays
+	}
+
+	result := service.PictureScan(condition)
+	for _, v := range result.OrphanFiles {
+		middleware.Log.Infof("孤立文件：%s/%s，%d字节，修改于%s", v.Dir, v.Name, v.Size, time.UnixMilli(v.ModTime).Format(time.DateTime))
+	}
+	for _, v := range result.MissingFiles {
+		middleware.Log.Infof("文件不存在：%s（%s，id：%s）", v.Path, v.Name, v.Id)
+	}
+	for _, v := range result.MissingThumbnails {
+		middleware.Log.Infof("缩略图不存在：%s（%s，id：%s）", v.Path, v.Name, v.Id)
+	}
+	for _, v := range result.Unreferenced {
+		middleware.Log.Infof("未被引用：%s（%s，id：%s），上传于%s", v.Path, v.Name, v.Id, time.UnixMilli(v.CreateTime).Format(time.DateTime))
+	}
+	middleware.Log.Infof("孤立文件%d个，文件不存在的记录%d条，缺少缩略图%d条，未被引用的图片%d条", len(result.OrphanFiles), len(result.MissingFiles), len(result.MissingThumbnails), len(result.Unreferenced))
+	if result.Purged {
+		middleware.Log.Infof("已删除%d个文件、%d条记录，重新生成%d张缩略图", result.PurgedFiles, result.PurgedRecords, result.RepairedThumbnails)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+	"os"
+	"time"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 下载全部数据的备份
+func AdminBackup(ctx iris.Context) {
+	path := service.BackupTempFile()
+	defer os.Remove(path)
+	ctx.SendFile(path, "md-backup-"+time.Now().Format("20060102150405")+".zip")
+}
+
+// 扫描图片，可选清理孤立文件和未被引用的图片，宽限天数默认为7
+func AdminPictureScan(ctx iris.Context) {
+	condition := entity.PictureScanCondition{GraceDays: service.PictureScanGraceDays}
+	resolveParam(ctx, &condition)
+	ctx.JSON(common.NewSuccessData("扫描完成", service.PictureScan(condition)))
+}
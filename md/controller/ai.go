@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"md/middleware"
 	"md/model/common"
 	"md/model/entity"
@@ -38,8 +39,50 @@ func AIConfigExists(ctx iris.Context) {
 	ctx.JSON(common.NewSuccessData("查询成功", map[string]bool{"exists": exists}))
 }
 
-// 获取AI配置（完整版，用于同步）
-func AIConfigGetFull(ctx iris.Context) {
+// 查询模型列表，未填写API Key时使用已保存的Key
+func AIModels(ctx iris.Context) {
+	condition := entity.AIConfigCondition{}
+	resolveParam(ctx, &condition)
 	userId := middleware.CurrentUserId(ctx)
-	ctx.JSON(common.NewSuccessData("查询成功", service.AIConfigGetFull(userId)))
+	ctx.JSON(common.NewSuccessData("查询成功", service.AIModels(condition, userId)))
+}
+
+// AI对话（服务端代理，SSE流式返回）
+func AIChat(ctx iris.Context) {
+	condition := entity.AIChatCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+
+	// 首个片段到达前不写响应头，之前的异常仍按json返回
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		ctx.CompressWriter(false)
+		ctx.ContentType("text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+	}
+
+	err := service.AIChat(ctx.Request().Context(), userId, condition, func(delta entity.AIChatDelta) error {
+		start()
+		data, _ := json.Marshal(delta)
+		if _, err := ctx.Writef("data: %s\n\n", data); err != nil {
+			return err
+		}
+		ctx.ResponseWriter().Flush()
+		return nil
+	})
+
+	start()
+	if err != nil {
+		data, _ := json.Marshal(map[string]string{"message": err.Error()})
+		ctx.Writef("event: error\ndata: %s\n\n", data)
+	} else {
+		ctx.Writef("data: [DONE]\n\n")
+	}
+	ctx.ResponseWriter().Flush()
 }
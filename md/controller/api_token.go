@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 创建个人访问令牌
+func ApiTokenAdd(ctx iris.Context) {
+	condition := entity.ApiTokenAddCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("创建成功", service.ApiTokenAdd(condition, userId)))
+}
+
+// 撤销个人访问令牌
+func ApiTokenDelete(ctx iris.Context) {
+	apiToken := entity.ApiToken{}
+	resolveParam(ctx, &apiToken)
+	userId := middleware.CurrentUserId(ctx)
+	service.ApiTokenDelete(apiToken.Id, userId)
+	ctx.JSON(common.NewSuccess("撤销成功"))
+}
+
+// 查询个人访问令牌列表
+func ApiTokenList(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.ApiTokenList(userId)))
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 分页查询附件记录
+func AttachmentPage(ctx iris.Context) {
+	pageCondition := common.PageCondition[interface{}]{}
+	resolveParam(ctx, &pageCondition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.AttachmentPage(pageCondition, userId)))
+}
+
+// 删除附件
+func AttachmentDelete(ctx iris.Context) {
+	attachment := entity.Attachment{}
+	resolveParam(ctx, &attachment)
+	userId := middleware.CurrentUserId(ctx)
+	service.AttachmentDelete(attachment.Id, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 上传附件
+func AttachmentUpload(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	file, info, err := ctx.FormFile("file")
+	if err != nil {
+		panic(common.NewErr("附件解析失败", err))
+	}
+	defer file.Close()
+	path, message := service.AttachmentUpload(file, info, userId)
+	ctx.JSON(common.NewSuccessData(message, path))
+}
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"bytes"
 	"md/middleware"
 	"md/model/common"
 	"md/model/entity"
 	"md/service"
+	"md/util"
+	"net/url"
 
 	"github.com/kataras/iris/v12"
 )
@@ -41,3 +44,27 @@ func BookList(ctx iris.Context) {
 	userId := middleware.CurrentUserId(ctx)
 	ctx.JSON(common.NewSuccessData("查询成功", service.BookList(userId)))
 }
+
+// 导出文集为静态网站
+func BookExportSite(ctx iris.Context) {
+	book := entity.Book{}
+	resolveParam(ctx, &book)
+	userId := middleware.CurrentUserId(ctx)
+	buffer := bytes.Buffer{}
+	name := service.BookExportSite(book.Id, userId, &buffer)
+	ctx.ContentType("application/zip")
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name)+".zip")
+	ctx.Write(buffer.Bytes())
+}
+
+// 导出文集为PDF、DOCX或EPUB
+func BookExport(ctx iris.Context) {
+	condition := entity.DocumentExportCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	buffer := bytes.Buffer{}
+	name := service.BookExport(condition.Id, userId, condition.Format, &buffer)
+	ctx.ContentType(util.ExportContentTypes[condition.Format])
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name)+"."+condition.Format)
+	ctx.Write(buffer.Bytes())
+}
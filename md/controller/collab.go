@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/service"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kataras/iris/v12"
+)
+
+const (
+	collabWriteTimeout = 10 * time.Second // 发送消息超时时间
+	collabPongTimeout  = 60 * time.Second // 未收到客户端响应时断开连接
+	collabPingInterval = 30 * time.Second // 心跳间隔
+	collabReadLimit    = 32 << 20         // 单条消息最大字节数
+)
+
+var collabUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// 已通过票据认证，允许跨域
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// 文档协同编辑（WebSocket）
+func CollabDocument(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	// 升级前加入，无权限时按普通接口返回错误
+	session := service.CollabJoin(ctx.Params().Get("id"), userId)
+
+	ctx.CompressWriter(false)
+	conn, err := collabUpgrader.Upgrade(ctx.ResponseWriter().Naive(), ctx.Request(), nil)
+	if err != nil {
+		session.Leave()
+		middleware.Log.Error("WebSocket连接失败：", err)
+		return
+	}
+	defer conn.Close()
+	defer session.Leave()
+
+	// 发送消息和心跳
+	go func() {
+		ticker := time.NewTicker(collabPingInterval)
+		defer ticker.Stop()
+		defer conn.Close()
+		for {
+			select {
+			case message, ok := <-session.Messages():
+				_ = conn.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+				if !ok {
+					_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
+					return
+				}
+				if conn.WriteMessage(websocket.TextMessage, message) != nil {
+					return
+				}
+			case <-ticker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+				if conn.WriteMessage(websocket.PingMessage, nil) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// 接收消息
+	conn.SetReadLimit(collabReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	})
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(collabPongTimeout))
+		session.Handle(message)
+	}
+}
+
+// 创建协同编辑连接票据，连接时通过ticket参数传递，30秒内有效且只能使用一次
+func CollabTicket(ctx iris.Context) {
+	ctx.JSON(common.NewSuccessData("创建成功", middleware.WebSocketTicket(ctx)))
+}
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"bytes"
 	"md/middleware"
 	"md/model/common"
 	"md/model/entity"
 	"md/service"
+	"md/util"
+	"mime/multipart"
+	"net/url"
+	"strings"
 
 	"github.com/kataras/iris/v12"
 )
@@ -28,10 +33,63 @@ func DocumentUpdate(ctx iris.Context) {
 
 // 修改文档内容
 func DocumentUpdateContent(ctx iris.Context) {
-	document := entity.Document{}
-	resolveParam(ctx, &document)
-	document.UserId = middleware.CurrentUserId(ctx)
-	ctx.JSON(common.NewSuccessData("更新成功", service.DocumentUpdateContent(document)))
+	condition := entity.DocumentContentCondition{}
+	resolveParam(ctx, &condition)
+	condition.UserId = middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("更新成功", service.DocumentUpdateContent(condition)))
+}
+
+// 校验OpenAPI文档
+func DocumentValidate(ctx iris.Context) {
+	condition := entity.DocumentValidateCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("校验完成", service.DocumentValidate(condition, userId)))
+}
+
+// 导出文档为PDF、DOCX或EPUB
+func DocumentExport(ctx iris.Context) {
+	condition := entity.DocumentExportCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	buffer := bytes.Buffer{}
+	name := service.DocumentExport(condition.Id, userId, condition.Format, &buffer)
+	ctx.ContentType(util.ExportContentTypes[condition.Format])
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name)+"."+condition.Format)
+	ctx.Write(buffer.Bytes())
+}
+
+// 导入OpenAPI文档，上传文件时使用multipart表单，从URL导入时也可使用json
+func DocumentImportOpenApi(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	condition := entity.DocumentImportCondition{}
+	var files []*multipart.FileHeader
+	if strings.HasPrefix(ctx.GetContentTypeRequested(), "multipart/") {
+		err := ctx.Request().ParseMultipartForm(32 << 20)
+		if err != nil {
+			panic(common.NewErr("参数解析失败", err))
+		}
+		condition.BookId = ctx.FormValue("bookId")
+		condition.ParentId = ctx.FormValue("parentId")
+		condition.Name = ctx.FormValue("name")
+		condition.Main = ctx.FormValue("main")
+		condition.Url = ctx.FormValue("url")
+		files = ctx.Request().MultipartForm.File["file"]
+	} else {
+		resolveParam(ctx, &condition)
+	}
+	ctx.JSON(common.NewSuccessData("导入成功", service.DocumentImportOpenApi(condition, files, userId)))
+}
+
+// 导入Markdown，上传包含Markdown文件和图片的zip，第一层文件夹作为文集
+func DocumentImportMarkdown(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	file, info, err := ctx.FormFile("file")
+	if err != nil {
+		panic(common.NewErr("文件解析失败", err))
+	}
+	file.Close()
+	ctx.JSON(common.NewSuccessData("导入成功", service.MarkdownImportUpload(info, ctx.FormValue("name"), userId)))
 }
 
 // 删除文档
@@ -71,3 +129,11 @@ func DocumentPagePublished(ctx iris.Context) {
 	resolveParam(ctx, &pageCondition)
 	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentPagePublished(pageCondition)))
 }
+
+// 全文检索文档
+func DocumentSearch(ctx iris.Context) {
+	pageCondition := common.PageCondition[entity.DocumentSearchCondition]{}
+	resolveParam(ctx, &pageCondition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentSearch(pageCondition, userId)))
+}
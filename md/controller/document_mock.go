@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"io"
+	"md/model/common"
+	"md/service"
+	"md/util"
+
+	"github.com/kataras/iris/v12"
+)
+
+const documentMockMaxBody = 10 << 20 // 模拟接口请求体最大字节数
+
+// 按OpenAPI文档模拟接口，返回文档中定义的状态码和响应内容
+func DocumentMock(ctx iris.Context) {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request().Body, documentMockMaxBody+1))
+	if err != nil {
+		panic(common.NewErr("读取请求体失败", err))
+	}
+	if len(body) > documentMockMaxBody {
+		panic(common.NewError("请求体过大，请小于10MB"))
+	}
+
+	response := service.DocumentMock(ctx.Params().Get("id"), util.OpenApiMockRequest{
+		Method: ctx.Method(),
+		Path:   "/" + ctx.Params().Get("path"),
+		Query:  ctx.Request().URL.Query(),
+		Header: ctx.Request().Header,
+		Body:   body,
+	})
+	for key, value := range response.Header {
+		ctx.Header(key, value)
+	}
+	// 响应内容来自文档，禁止浏览器猜测类型，作为页面打开时放入沙箱，不可执行脚本、访问本站存储
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Content-Security-Policy", "sandbox")
+	ctx.StatusCode(response.Status)
+	_, _ = ctx.Write(response.Body)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 查询文档历史版本列表
+func DocumentRevisionList(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionList(condition.DocumentId, userId)))
+}
+
+// 查询文档历史版本
+func DocumentRevisionGet(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionGet(condition.Id, userId)))
+}
+
+// 恢复文档历史版本
+func DocumentRevisionRestore(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("恢复成功", service.DocumentRevisionRestore(condition.Id, userId)))
+}
+
+// 比较文档历史版本
+func DocumentRevisionDiff(ctx iris.Context) {
+	condition := entity.DocumentRevisionCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.DocumentRevisionDiff(condition.FromId, condition.ToId, userId)))
+}
+
+// 比较OpenAPI文档的两个版本，返回变更列表和变更日志
+func DocumentOpenApiDiff(ctx iris.Context) {
+	condition := entity.DocumentOpenApiDiffCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("比较完成", service.DocumentOpenApiDiff(condition, userId)))
+}
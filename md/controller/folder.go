@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 添加文件夹
+func FolderAdd(ctx iris.Context) {
+	folder := entity.Folder{}
+	resolveParam(ctx, &folder)
+	folder.UserId = middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("添加成功", service.FolderAdd(folder)))
+}
+
+// 修改文件夹名称
+func FolderUpdate(ctx iris.Context) {
+	folder := entity.Folder{}
+	resolveParam(ctx, &folder)
+	folder.UserId = middleware.CurrentUserId(ctx)
+	service.FolderUpdate(folder)
+	ctx.JSON(common.NewSuccess("更新成功"))
+}
+
+// 删除文件夹
+func FolderDelete(ctx iris.Context) {
+	folder := entity.Folder{}
+	resolveParam(ctx, &folder)
+	userId := middleware.CurrentUserId(ctx)
+	service.FolderDelete(folder.Id, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 移动文件夹或文档
+func FolderMove(ctx iris.Context) {
+	condition := entity.FolderMoveCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.FolderMove(condition, userId)
+	ctx.JSON(common.NewSuccess("移动成功"))
+}
+
+// 查询文集的目录树
+func FolderTree(ctx iris.Context) {
+	folder := entity.Folder{}
+	resolveParam(ctx, &folder)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.FolderTree(folder.BookId, userId)))
+}
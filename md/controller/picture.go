@@ -5,13 +5,14 @@ import (
 	"md/model/common"
 	"md/model/entity"
 	"md/service"
+	"net/http"
 
 	"github.com/kataras/iris/v12"
 )
 
 // 分页查询图片记录
 func PicturePage(ctx iris.Context) {
-	pageCondition := common.PageCondition[interface{}]{}
+	pageCondition := common.PageCondition[entity.PicturePageCondition]{}
 	resolveParam(ctx, &pageCondition)
 	userId := middleware.CurrentUserId(ctx)
 	ctx.JSON(common.NewSuccessData("查询成功", service.PicturePage(pageCondition, userId)))
@@ -19,11 +20,19 @@ func PicturePage(ctx iris.Context) {
 
 // 删除图片
 func PictureDelete(ctx iris.Context) {
+	condition := entity.PictureDeleteCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.PictureDelete(condition, userId)
+	ctx.JSON(common.NewSuccess("删除成功"))
+}
+
+// 查询引用图片的文档
+func PictureReferences(ctx iris.Context) {
 	picture := entity.Picture{}
 	resolveParam(ctx, &picture)
 	userId := middleware.CurrentUserId(ctx)
-	service.PictureDelete(picture.Id, userId)
-	ctx.JSON(common.NewSuccess("删除成功"))
+	ctx.JSON(common.NewSuccessData("查询成功", service.PictureReferences(picture.Id, userId)))
 }
 
 // 上传图片
@@ -34,11 +43,15 @@ func PictureUpload(ctx iris.Context) {
 		panic(common.NewErr("图片解析失败", err))
 	}
 	defer pictureFile.Close()
+	// 缩略图可选，未上传时由服务端生成
 	thumbnailFile, thumbnailInfo, err := ctx.FormFile("thumbnail")
-	if err != nil {
+	if err == http.ErrMissingFile {
+		thumbnailFile, thumbnailInfo = nil, nil
+	} else if err != nil {
 		panic(common.NewErr("图片解析失败", err))
+	} else {
+		defer thumbnailFile.Close()
 	}
-	defer thumbnailFile.Close()
 	path, message := service.PictureUpload(pictureFile, thumbnailFile, pictureInfo, thumbnailInfo, userId)
 	ctx.JSON(common.NewSuccessData(message, path))
 }
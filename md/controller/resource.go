@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/service"
+	"strings"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 静态资源（图片、缩略图、附件），从文件存储中读取
+func Resource(ctx iris.Context) {
+	// 图片已压缩，且需支持Range请求，不再使用gzip
+	ctx.CompressWriter(false)
+	key := ctx.Params().Get("key")
+
+	// 附件按上传时识别的类型下载，使用地址中的文件名
+	options := middleware.StorageServeOptions{}
+	if path, ok := strings.CutPrefix(key, common.AttachmentName+"/"); ok {
+		options, ok = service.AttachmentServeOptions(path, ctx.URLParam("name"))
+		if !ok {
+			ctx.StatusCode(iris.StatusNotFound)
+			return
+		}
+		ctx.Header("X-Content-Type-Options", "nosniff")
+	}
+	middleware.Storage.Serve(ctx.ResponseWriter(), ctx.Request(), key, options)
+}
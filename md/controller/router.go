@@ -20,6 +20,12 @@ func InitRouter(app *iris.Application) {
 			open.Post("/doc/page", DocumentPagePublished)
 		})
 
+		// 模拟接口，按公开发布的OpenAPI文档返回响应
+		api.PartyFunc("/mock", func(mock iris.Party) {
+			mock.Any("/{id}", DocumentMock)
+			mock.Any("/{id}/{path:path}", DocumentMock)
+		})
+
 		// token相关接口
 		api.PartyFunc("/token", func(token iris.Party) {
 			token.Use(middleware.TokenAuth)
@@ -30,12 +36,26 @@ func InitRouter(app *iris.Application) {
 			token.Post("/refresh", TokenRefresh)
 		})
 
+		// WebSocket接口
+		api.PartyFunc("/ws", func(ws iris.Party) {
+			ws.Use(middleware.WebSocketAuth)
+
+			ws.Get("/doc/{id}", CollabDocument)
+		})
+
 		// 数据接口
 		api.PartyFunc("/data", func(data iris.Party) {
 			data.Use(middleware.DataAuth)
 
 			data.PartyFunc("/user", func(user iris.Party) {
 				user.Post("/update-password", UserUpdatePassword)
+				user.Post("/usage", UserUsage)
+			})
+
+			data.PartyFunc("/api-token", func(apiToken iris.Party) {
+				apiToken.Post("/add", ApiTokenAdd)
+				apiToken.Post("/delete", ApiTokenDelete)
+				apiToken.Post("/list", ApiTokenList)
 			})
 
 			data.PartyFunc("/book", func(book iris.Party) {
@@ -43,6 +63,16 @@ func InitRouter(app *iris.Application) {
 				book.Post("/update", BookUpdate)
 				book.Post("/delete", BookDelete)
 				book.Post("/list", BookList)
+				book.Post("/export-site", BookExportSite)
+				book.Post("/export", BookExport)
+			})
+
+			data.PartyFunc("/folder", func(folder iris.Party) {
+				folder.Post("/add", FolderAdd)
+				folder.Post("/update", FolderUpdate)
+				folder.Post("/delete", FolderDelete)
+				folder.Post("/move", FolderMove)
+				folder.Post("/tree", FolderTree)
 			})
 
 			data.PartyFunc("/doc", func(doc iris.Party) {
@@ -52,14 +82,39 @@ func InitRouter(app *iris.Application) {
 				doc.Post("/delete", DocumentDelete)
 				doc.Post("/list", DocumentList)
 				doc.Post("/get", DocumentGet)
+				doc.Post("/search", DocumentSearch)
+				doc.Post("/validate", DocumentValidate)
+				doc.Post("/import-openapi", DocumentImportOpenApi)
+				doc.Post("/import-markdown", DocumentImportMarkdown)
+				doc.Post("/revision/list", DocumentRevisionList)
+				doc.Post("/revision/get", DocumentRevisionGet)
+				doc.Post("/revision/restore", DocumentRevisionRestore)
+				doc.Post("/revision/diff", DocumentRevisionDiff)
+				doc.Post("/openapi-diff", DocumentOpenApiDiff)
+				doc.Post("/export", DocumentExport)
+				doc.Post("/collab-ticket", CollabTicket)
+			})
+
+			data.PartyFunc("/share", func(share iris.Party) {
+				share.Post("/add", ShareAdd)
+				share.Post("/delete", ShareDelete)
+				share.Post("/list", ShareList)
+				share.Post("/with-me", ShareListWithMe)
 			})
 
 			data.PartyFunc("/pic", func(pic iris.Party) {
 				pic.Post("/page", PicturePage)
 				pic.Post("/delete", PictureDelete)
+				pic.Post("/references", PictureReferences)
 				pic.Post("/upload", PictureUpload)
 			})
 
+			data.PartyFunc("/attachment", func(attachment iris.Party) {
+				attachment.Post("/page", AttachmentPage)
+				attachment.Post("/delete", AttachmentDelete)
+				attachment.Post("/upload", AttachmentUpload)
+			})
+
 			data.PartyFunc("/rsa", func(rsa iris.Party) {
 				rsa.Post("/generate", RSAGenerateKey)
 				rsa.Post("/encrypt", RSAEncrypt)
@@ -68,12 +123,20 @@ func InitRouter(app *iris.Application) {
 				rsa.Post("/verify", RSAVerify)
 			})
 
+			data.PartyFunc("/admin", func(admin iris.Party) {
+				admin.Use(middleware.AdminAuth)
+
+				admin.Post("/backup", AdminBackup)
+				admin.Post("/picture-scan", AdminPictureScan)
+			})
+
 			data.PartyFunc("/ai", func(ai iris.Party) {
 				ai.Get("/config", AIConfigGet)
 				ai.Post("/config", AIConfigSave)
 				ai.Post("/config/delete", AIConfigDelete)
 				ai.Get("/config/exists", AIConfigExists)
-				ai.Get("/config/full", AIConfigGetFull)
+				ai.Post("/models", AIModels)
+				ai.Post("/chat", AIChat)
 			})
 
 			data.PartyFunc("/conversation", func(conv iris.Party) {
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/service"
+
+	"github.com/kataras/iris/v12"
+)
+
+// 共享文集或文档
+func ShareAdd(ctx iris.Context) {
+	condition := entity.ShareAddCondition{}
+	resolveParam(ctx, &condition)
+	userId := middleware.CurrentUserId(ctx)
+	service.ShareAdd(condition, userId)
+	ctx.JSON(common.NewSuccess("共享成功"))
+}
+
+// 取消共享
+func ShareDelete(ctx iris.Context) {
+	share := entity.Share{}
+	resolveParam(ctx, &share)
+	userId := middleware.CurrentUserId(ctx)
+	service.ShareDelete(share.Id, userId)
+	ctx.JSON(common.NewSuccess("取消共享成功"))
+}
+
+// 查询文集或文档的共享列表
+func ShareList(ctx iris.Context) {
+	share := entity.Share{}
+	resolveParam(ctx, &share)
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.ShareList(share.ResourceType, share.ResourceId, userId)))
+}
+
+// 查询共享给我的文集和文档
+func ShareListWithMe(ctx iris.Context) {
+	userId := middleware.CurrentUserId(ctx)
+	ctx.JSON(common.NewSuccessData("查询成功", service.ShareListWithMe(userId)))
+}
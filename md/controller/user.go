@@ -17,3 +17,8 @@ func UserUpdatePassword(ctx iris.Context) {
 	service.UserUpdatePassword(userCondition)
 	ctx.JSON(common.NewSuccess("更新成功"))
 }
+
+// 查询用户的存储用量和配额
+func UserUsage(ctx iris.Context) {
+	ctx.JSON(common.NewSuccessData("查询成功", service.UserUsage(middleware.CurrentUserId(ctx))))
+}
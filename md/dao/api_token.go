@@ -0,0 +1,52 @@
+package dao
+
+import (
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加个人访问令牌
+func ApiTokenAdd(tx *sqlx.Tx, apiToken entity.ApiToken) error {
+	sql := `insert into t_api_token (id,name,token,prefix,scopes,create_time,last_used_time,expire_time,user_id) values (:id,:name,:token,:prefix,:scopes,:create_time,:last_used_time,:expire_time,:user_id)`
+	_, err := tx.NamedExec(sql, apiToken)
+	return err
+}
+
+// 根据id删除个人访问令牌
+func ApiTokenDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_api_token where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据令牌查询未过期的个人访问令牌
+func ApiTokenGetByToken(db *sqlx.DB, token string, now int64) (entity.ApiToken, error) {
+	sql := `select * from t_api_token where token=$1 and (expire_time=0 or expire_time>$2)`
+	result := entity.ApiToken{}
+	err := db.Get(&result, sql, token, now)
+	return result, err
+}
+
+// 更新最后使用时间
+func ApiTokenUpdateLastUsed(db *sqlx.DB, id string, lastUsedTime int64) error {
+	sql := `update t_api_token set last_used_time=$1 where id=$2`
+	_, err := db.Exec(sql, lastUsedTime, id)
+	return err
+}
+
+// 查询个人访问令牌列表
+func ApiTokenList(db *sqlx.DB, userId string) ([]entity.ApiToken, error) {
+	sql := `select * from t_api_token where user_id=$1 order by create_time desc`
+	result := []entity.ApiToken{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
+
+// 查询个人访问令牌数量
+func ApiTokenCount(tx *sqlx.Tx, userId string) (int, error) {
+	sql := `select count(*) from t_api_token where user_id=$1`
+	count := 0
+	err := tx.Get(&count, sql, userId)
+	return count, err
+}
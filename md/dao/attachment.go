@@ -0,0 +1,133 @@
+package dao
+
+import (
+	"errors"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 分页查询附件记录
+func AttachmentPage(db *sqlx.DB, page common.Page, userId string) ([]entity.Attachment, int, error) {
+	sqlCompletion := util.SqlCompletion{}
+	sqlCompletion.InitSql(`select id,name,path,size,mime,create_time from t_attachment`)
+	sqlCompletion.Eq("user_id", userId, true)
+	sqlCompletion.Order("create_time", false)
+	sqlCompletion.Limit(page.Current, page.Size)
+
+	// 查询分页数据
+	result := []entity.Attachment{}
+	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
+	if err != nil {
+		return result, 0, err
+	}
+
+	// 查询总记录数
+	countResult := common.CountResult{}
+	err = db.Get(&countResult, sqlCompletion.GetCountSql(), sqlCompletion.GetCountParams()...)
+	if err != nil {
+		return result, 0, err
+	}
+
+	return result, countResult.Count, nil
+}
+
+// 根据id删除附件
+func AttachmentDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_attachment where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 根据id查询附件
+func AttachmentGetById(tx *sqlx.Tx, id, userId string) (entity.Attachment, error) {
+	sql := `select * from t_attachment where id=$1 and user_id=$2`
+	result := entity.Attachment{}
+	err := tx.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 根据文件路径查询附件的类型，相同文件有多条记录时使用最早上传的记录
+func AttachmentMimeByPath(db *sqlx.DB, path string) (string, error) {
+	sql := `select mime from t_attachment where path=$1 order by create_time limit 1`
+	var result string
+	err := db.Get(&result, sql, path)
+	return result, err
+}
+
+// 根据文件大小、hash值查询相同附件
+func AttachmentBySizeHash(db *sqlx.DB, size int64, hash string) ([]entity.Attachment, error) {
+	sql := `select * from t_attachment where size=$1 and hash=$2`
+	result := []entity.Attachment{}
+	err := db.Select(&result, sql, size, hash)
+	return result, err
+}
+
+// 查询使用指定文件的附件记录数量
+func AttachmentCountByPath(db *sqlx.DB, path string) (common.CountResult, error) {
+	sql := `select count(*) as count from t_attachment where path=$1`
+	result := common.CountResult{}
+	err := db.Get(&result, sql, path)
+	return result, err
+}
+
+// 添加附件
+func AttachmentAdd(tx *sqlx.Tx, attachment entity.Attachment) error {
+	sql := `insert into t_attachment (id,name,path,hash,size,mime,create_time,user_id) values (:id,:name,:path,:hash,:size,:mime,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, attachment)
+	return err
+}
+
+// 查询全部附件文件路径
+func AttachmentPathList(db interface{}) ([]string, error) {
+	sql := `select distinct path from t_attachment order by path`
+	result := []string{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Select(&result, sql)
+	case *sqlx.DB:
+		err = db.Select(&result, sql)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询用户的附件用量，共用的文件按使用的用户数分摊
+func AttachmentUsage(db interface{}, userId string) (entity.FileUsage, error) {
+	sql := `select count(*) as count, cast(coalesce(sum(t.size),0) as bigint) as size,
+		cast(coalesce(sum(case when s.users>1 then t.size else 0 end),0) as bigint) as shared_size,
+		cast(round(coalesce(sum(t.size*1.0/s.users),0)) as bigint) as fair_size
+		from t_attachment t join (select path, count(distinct user_id) as users from t_attachment group by path) s on s.path=t.path
+		where t.user_id=$1`
+	result := entity.FileUsage{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, userId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, userId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询全部附件文件的大小，相同文件只计算一次
+func AttachmentSizeTotal(db interface{}) (int64, error) {
+	sql := `select cast(coalesce(sum(size),0) as bigint) from (select path, max(size) as size from t_attachment group by path) t`
+	var result int64
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql)
+	case *sqlx.DB:
+		err = db.Get(&result, sql)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
@@ -48,3 +48,19 @@ func BookListByName(tx *sqlx.Tx, name, userId string) ([]entity.Book, error) {
 	err := tx.Select(&result, sql, userId, name)
 	return result, err
 }
+
+// 根据id查询文集
+func BookGetById(db *sqlx.DB, id, userId string) (entity.Book, error) {
+	sql := `select * from t_book where id=$1 and user_id=$2`
+	result := entity.Book{}
+	err := db.Get(&result, sql, id, userId)
+	return result, err
+}
+
+// 根据id查询文集（不限用户）
+func BookGetByIdAnyUser(db *sqlx.DB, id string) (entity.Book, error) {
+	sql := `select * from t_book where id=$1`
+	result := entity.Book{}
+	err := db.Get(&result, sql, id)
+	return result, err
+}
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"errors"
 	"md/model/common"
 	"md/model/entity"
 	"md/util"
@@ -11,25 +12,41 @@ import (
 
 // 添加文档
 func DocumentAdd(tx *sqlx.Tx, document entity.Document) error {
-	sql := `insert into t_document (id,name,content,type,published,create_time,update_time,book_id,user_id) values (:id,:name,:content,:type,:published,:create_time,:update_time,:book_id,:user_id)`
+	sql := `insert into t_document (id,name,content,type,published,create_time,update_time,book_id,user_id,parent_id,sort) values (:id,:name,:content,:type,:published,:create_time,:update_time,:book_id,:user_id,:parent_id,:sort)`
 	_, err := tx.NamedExec(sql, document)
 	return err
 }
 
-// 修改文档基础信息
+// 修改文档基础信息，更换文集时移动到新文集的根目录
 func DocumentUpdate(tx *sqlx.Tx, document entity.Document) error {
-	sql := `update t_document set name=:name,published=:published,book_id=:book_id where id=:id and user_id=:user_id`
+	sql := `update t_document set name=:name,published=:published,parent_id=case when book_id=:book_id then parent_id else '' end,book_id=:book_id where id=:id and user_id=:user_id`
 	_, err := tx.NamedExec(sql, document)
 	return err
 }
 
-// 修改文档内容
-func DocumentUpdateContent(tx *sqlx.Tx, document entity.Document) error {
-	sql := `update t_document set content=:content,update_time=:update_time where id=:id and user_id=:user_id`
-	_, err := tx.NamedExec(sql, document)
+// 锁定文档行直到事务结束，同一文档的内容修改和版本号分配按顺序进行
+func DocumentLock(tx *sqlx.Tx, id string) error {
+	sql := `update t_document set update_time=update_time where id=$1`
+	_, err := tx.Exec(sql, id)
 	return err
 }
 
+// 修改文档内容，updateTime不为0时仅在更新时间一致时修改，返回是否已修改
+func DocumentUpdateContent(tx *sqlx.Tx, document entity.Document, updateTime int64) (bool, error) {
+	sql := `update t_document set content=$1,update_time=$2 where id=$3 and user_id=$4`
+	args := []interface{}{document.Content, document.UpdateTime, document.Id, document.UserId}
+	if updateTime != 0 {
+		sql += ` and update_time=$5`
+		args = append(args, updateTime)
+	}
+	result, err := tx.Exec(sql, args...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
 // 根据id删除文档
 func DocumentDeleteById(tx *sqlx.Tx, id, userId string) error {
 	sql := `delete from t_document where id=$1 and user_id=$2`
@@ -40,31 +57,36 @@ func DocumentDeleteById(tx *sqlx.Tx, id, userId string) error {
 // 查询文档列表
 func DocumentList(db *sqlx.DB, bookId, userId string) ([]entity.Document, error) {
 	sqlCompletion := util.SqlCompletion{}
-	sqlCompletion.InitSql(`select id,name,type,published,create_time,update_time,book_id from t_document`)
+	sqlCompletion.InitSql(`select id,name,type,published,create_time,update_time,book_id,parent_id,sort from t_document`)
 	sqlCompletion.Eq("user_id", userId, true)
 	if bookId != "" {
 		sqlCompletion.Eq("book_id", bookId, true)
 	}
 	result := []entity.Document{}
 	err := db.Select(&result, sqlCompletion.GetSql(), sqlCompletion.GetParams()...)
-	// 按名称升序
-	sort.Slice(result, func(i, j int) bool {
-		return util.StringSort(result[i].Name, result[j].Name)
-	})
+	sortDocuments(result)
 	return result, err
 }
 
 // 根据id查询文档
-func DocumentGetById(db *sqlx.DB, id, userId string) (entity.Document, error) {
-	sql := `select id,name,content,type,published,create_time,update_time,book_id from t_document where id=$1 and user_id=$2`
+func DocumentGetById(db interface{}, id, userId string) (entity.Document, error) {
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,parent_id,sort from t_document where id=$1 and user_id=$2`
 	result := entity.Document{}
-	err := db.Get(&result, sql, id, userId)
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, id, userId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, id, userId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
 	return result, err
 }
 
 // 清空文档的bookId
 func DocumentClearBookId(tx *sqlx.Tx, bookId string) error {
-	sql := `update t_document set book_id='' where book_id=$1`
+	sql := `update t_document set book_id='',parent_id='' where book_id=$1`
 	_, err := tx.Exec(sql, bookId)
 	return err
 }
@@ -118,3 +140,128 @@ func DocumentPagePublished(db *sqlx.DB, pageCondition common.PageCondition[entit
 
 	return result, countResult.Count, err
 }
+
+// 查询全部文档id
+func DocumentIdList(db *sqlx.DB) ([]string, error) {
+	sql := `select id from t_document`
+	result := []string{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
+// 查询文档数量
+func DocumentCount(db interface{}) (common.CountResult, error) {
+	sql := `select count(*) as count from t_document`
+	result := common.CountResult{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql)
+	case *sqlx.DB:
+		err = db.Get(&result, sql)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 根据id查询文档（不限用户）
+func DocumentGetByIdAnyUser(db interface{}, id string) (entity.Document, error) {
+	sql := `select * from t_document where id=$1`
+	result := entity.Document{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, id)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, id)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询文集下的文档（含内容）
+func DocumentListWithContent(db *sqlx.DB, bookId, userId string) ([]entity.Document, error) {
+	sql := `select id,name,content,type,published,create_time,update_time,book_id,parent_id,sort from t_document where book_id=$1 and user_id=$2`
+	result := []entity.Document{}
+	err := db.Select(&result, sql, bookId, userId)
+	sortDocuments(result)
+	return result, err
+}
+
+// 查询文件夹下的文档列表
+func DocumentListByParent(tx *sqlx.Tx, bookId, parentId, userId string) ([]entity.Document, error) {
+	sql := `select id,name,sort from t_document where book_id=$1 and parent_id=$2 and user_id=$3`
+	result := []entity.Document{}
+	err := tx.Select(&result, sql, bookId, parentId, userId)
+	sortDocuments(result)
+	return result, err
+}
+
+// 查询文件夹下文档的最大排序值
+func DocumentMaxSort(tx *sqlx.Tx, bookId, parentId, userId string) (int, error) {
+	sql := `select coalesce(max(sort),-1) from t_document where book_id=$1 and parent_id=$2 and user_id=$3`
+	var result int
+	err := tx.Get(&result, sql, bookId, parentId, userId)
+	return result, err
+}
+
+// 修改文档位置
+func DocumentUpdateParent(tx *sqlx.Tx, id, bookId, parentId string, sort int, userId string) error {
+	sql := `update t_document set book_id=$1,parent_id=$2,sort=$3 where id=$4 and user_id=$5`
+	_, err := tx.Exec(sql, bookId, parentId, sort, id, userId)
+	return err
+}
+
+// 将文件夹下的文档移动到其他文件夹，排序值增加offset以排在原有文档之后
+func DocumentMoveChildren(tx *sqlx.Tx, fromParentId, toParentId string, offset int, userId string) error {
+	sql := `update t_document set parent_id=$1,sort=sort+$2 where parent_id=$3 and user_id=$4`
+	_, err := tx.Exec(sql, toParentId, offset, fromParentId, userId)
+	return err
+}
+
+// 按排序值、名称升序
+func sortDocuments(documents []entity.Document) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		if documents[i].Sort != documents[j].Sort {
+			return documents[i].Sort < documents[j].Sort
+		}
+		return util.StringSort(documents[i].Name, documents[j].Name)
+	})
+}
+
+// 逐条读取全部文档及历史版本的内容，避免一次加载到内存
+func DocumentContentEach(db *sqlx.DB, fn func(content string)) error {
+	sql := `select content from t_document union all select content from t_document_revision`
+	rows, err := db.Queryx(sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content string
+		err = rows.Scan(&content)
+		if err != nil {
+			return err
+		}
+		fn(content)
+	}
+	return rows.Err()
+}
+
+// 查询用户的文档数量
+func DocumentCountByUser(db interface{}, userId string) (common.CountResult, error) {
+	sql := `select count(*) as count from t_document where user_id=$1`
+	result := common.CountResult{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, userId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, userId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"errors"
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加文档历史版本
+func DocumentRevisionAdd(tx *sqlx.Tx, revision entity.DocumentRevision) error {
+	sql := `insert into t_document_revision (id,document_id,revision,content,create_time,user_id) values (:id,:document_id,:revision,:content,:create_time,:user_id)`
+	_, err := tx.NamedExec(sql, revision)
+	return err
+}
+
+// 查询文档最新的版本号，无版本时返回0
+func DocumentRevisionMaxNumber(db interface{}, documentId string) (int, error) {
+	sql := `select COALESCE(MAX(revision), 0) from t_document_revision where document_id=$1`
+	var result int
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, documentId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, documentId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询文档的历史版本列表（不含内容）
+func DocumentRevisionList(db *sqlx.DB, documentId, userId string) ([]entity.DocumentRevisionListItem, error) {
+	sql := `select id,document_id,revision,length(content) as size,create_time from t_document_revision where document_id=$1 and user_id=$2 order by revision desc`
+	result := []entity.DocumentRevisionListItem{}
+	err := db.Select(&result, sql, documentId, userId)
+	return result, err
+}
+
+// 根据id查询历史版本（不限用户，需另行校验文档权限）
+func DocumentRevisionGetById(db interface{}, id string) (entity.DocumentRevision, error) {
+	sql := `select * from t_document_revision where id=$1`
+	result := entity.DocumentRevision{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, id)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, id)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 删除文档中版本号不大于指定版本的历史版本
+func DocumentRevisionDeleteBefore(tx *sqlx.Tx, documentId string, revision int) error {
+	sql := `delete from t_document_revision where document_id=$1 and revision<=$2`
+	_, err := tx.Exec(sql, documentId, revision)
+	return err
+}
+
+// 删除文档的所有历史版本
+func DocumentRevisionDeleteByDocumentId(tx *sqlx.Tx, documentId, userId string) error {
+	sql := `delete from t_document_revision where document_id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, documentId, userId)
+	return err
+}
@@ -0,0 +1,174 @@
+package dao
+
+import (
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// tsvector单个值的大小上限为1MB，预留部分空间
+const maxTsvectorBytes = 900 * 1024
+
+// 检索结果每页最大数量
+const maxSearchPageSize = 100
+
+// 截取检索结果片段时最多定位的关键词数量
+const maxSnippetTerms = 10
+
+// 保存文档的全文检索索引
+func DocumentSearchIndexSave(tx *sqlx.Tx, documentId, userId string, nameTokens, contentTokens []string) error {
+	err := DocumentSearchIndexDelete(tx, documentId, userId)
+	if err != nil {
+		return err
+	}
+	if common.DbType == common.DbPostgres {
+		sql := `insert into t_document_fts (document_id,user_id,search) values ($1,$2,setweight(array_to_tsvector($3::text[]),'A') || setweight(array_to_tsvector($4::text[]),'B'))`
+		_, err = tx.Exec(sql, documentId, userId, pq.Array(limitTokens(nameTokens)), pq.Array(limitTokens(contentTokens)))
+		return err
+	}
+	sql := `insert into t_document_fts (document_id,user_id,name,content) values ($1,$2,$3,$4)`
+	_, err = tx.Exec(sql, documentId, userId, strings.Join(nameTokens, " "), strings.Join(contentTokens, " "))
+	return err
+}
+
+// 删除文档的全文检索索引
+func DocumentSearchIndexDelete(tx *sqlx.Tx, documentId, userId string) error {
+	sql := `delete from t_document_fts where document_id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, documentId, userId)
+	return err
+}
+
+// 查询全文检索索引数量
+func DocumentSearchIndexCount(db *sqlx.DB) (common.CountResult, error) {
+	sql := `select count(*) as count from t_document_fts`
+	result := common.CountResult{}
+	err := db.Get(&result, sql)
+	return result, err
+}
+
+// 全文检索文档，按相关度降序，
+// terms为生成片段的关键词，查询结果中的content只包含生成片段所需的部分，见documentSearchContentSql
+func DocumentSearchFts(db *sqlx.DB, tokens, terms []string, radius int, condition entity.DocumentSearchCondition, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error) {
+	var scoreSql, fromSql string
+	var params []interface{}
+	if common.DbType == common.DbPostgres {
+		// 分词仅包含字母、数字和中日韩文字，可直接作为tsquery的词素
+		query := make([]string, len(tokens))
+		for i, v := range tokens {
+			query[i] = "'" + v + "'"
+		}
+		query[len(query)-1] += prefixMatch(tokens[len(tokens)-1], ":*")
+		scoreSql = `ts_rank(f.search,$1::tsquery)`
+		fromSql = ` from t_document_fts f join t_document d on d.id=f.document_id where f.search @@ $1::tsquery and f.user_id=$2`
+		params = append(params, strings.Join(query, " & "), userId)
+	} else {
+		query := make([]string, len(tokens))
+		for i, v := range tokens {
+			query[i] = `"` + v + `"`
+		}
+		query[len(query)-1] += prefixMatch(tokens[len(tokens)-1], "*")
+		scoreSql = `-bm25(t_document_fts,0,0,10.0,1.0)`
+		fromSql = ` from t_document_fts join t_document d on d.id=t_document_fts.document_id where t_document_fts match $1 and t_document_fts.user_id=$2`
+		params = append(params, strings.Join(query, " "), userId)
+	}
+	fromSql, params = documentSearchFilter(fromSql, params, condition)
+	contentSql, selectParams := documentSearchContentSql(params, terms, radius)
+	selectSql := `select d.id,d.name,` + contentSql + ` as content,d.type,d.book_id,d.update_time,` + scoreSql + ` as score`
+	return documentSearchPage(db, selectSql+fromSql+` order by score desc`, `select count(*) as count`+fromSql, selectParams, params, page)
+}
+
+// 模糊查询文档，用于无法使用全文检索的关键词，按更新时间降序，关键词中的%、_按普通字符匹配
+func DocumentSearchLike(db *sqlx.DB, keyword string, terms []string, radius int, condition entity.DocumentSearchCondition, page common.Page, userId string) ([]entity.DocumentSearchResult, int, error) {
+	fromSql := ` from t_document d where d.user_id=$1 and (lower(d.name) like '%'||lower($2)||'%' escape '\' or lower(d.content) like '%'||lower($2)||'%' escape '\')`
+	params := []interface{}{userId, likeEscape(keyword)}
+	fromSql, params = documentSearchFilter(fromSql, params, condition)
+	contentSql, selectParams := documentSearchContentSql(params, terms, radius)
+	selectSql := `select d.id,d.name,` + contentSql + ` as content,d.type,d.book_id,d.update_time,0 as score`
+	return documentSearchPage(db, selectSql+fromSql+` order by d.update_time desc`, `select count(*) as count`+fromSql, selectParams, params, page)
+}
+
+// 转义like中的通配符，需配合escape '\'使用
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// 截取文档内容中生成片段所需的部分，避免查询完整内容，关键词作为参数添加在params之后：从第一个出现的关键词（不区分大小写）前radius*2个字符开始，
+// 共radius*5个字符，未找到关键词时从开头截取
+func documentSearchContentSql(params []interface{}, terms []string, radius int) (string, []interface{}) {
+	const notFound = "2147483647"
+	positionFunc, minFunc, maxFunc := "instr", "min", "max"
+	if common.DbType == common.DbPostgres {
+		positionFunc, minFunc, maxFunc = "strpos", "least", "greatest"
+	}
+	positions := []string{}
+	for _, term := range terms[:min(len(terms), maxSnippetTerms)] {
+		params = append(params, strings.ToLower(term))
+		positions = append(positions, "coalesce(nullif("+positionFunc+"(lower(d.content),$"+strconv.Itoa(len(params))+"),0),"+notFound+")")
+	}
+	position := "1"
+	switch len(positions) {
+	case 0:
+	case 1:
+		position = "coalesce(nullif(" + positions[0] + "," + notFound + "),1)"
+	default:
+		position = "coalesce(nullif(" + minFunc + "(" + strings.Join(positions, ",") + ")," + notFound + "),1)"
+	}
+	return "substr(d.content," + maxFunc + "(" + position + "-" + strconv.Itoa(radius*2) + ",1)," + strconv.Itoa(radius*5) + ")", params
+}
+
+// 添加文集、文档类型筛选条件
+func documentSearchFilter(fromSql string, params []interface{}, condition entity.DocumentSearchCondition) (string, []interface{}) {
+	if condition.BookId != "" {
+		params = append(params, condition.BookId)
+		fromSql += " and d.book_id=$" + strconv.Itoa(len(params))
+	}
+	if condition.Type != "" {
+		params = append(params, condition.Type)
+		fromSql += " and d.type=$" + strconv.Itoa(len(params))
+	}
+	return fromSql, params
+}
+
+// 分页查询检索结果，selectParams为查询数据的参数，countParams为查询总数的参数
+func documentSearchPage(db *sqlx.DB, sql, countSql string, selectParams, countParams []interface{}, page common.Page) ([]entity.DocumentSearchResult, int, error) {
+	result := []entity.DocumentSearchResult{}
+	size := min(max(page.Size, 1), maxSearchPageSize)
+	current := max(page.Current, 1)
+	limitSql := " limit $" + strconv.Itoa(len(selectParams)+1) + " offset $" + strconv.Itoa(len(selectParams)+2)
+	err := db.Select(&result, sql+limitSql, append(selectParams, size, size*(current-1))...)
+	if err != nil {
+		return result, 0, err
+	}
+	countResult := common.CountResult{}
+	err = db.Get(&countResult, countSql, countParams...)
+	return result, countResult.Count, err
+}
+
+// 英文、数字结尾的关键词按前缀匹配
+func prefixMatch(token, symbol string) string {
+	for _, r := range token {
+		if util.IsCJK(r) {
+			return ""
+		}
+	}
+	return symbol
+}
+
+// 去重并限制分词总大小
+func limitTokens(tokens []string) []string {
+	result := []string{}
+	size := 0
+	for _, v := range util.UniqueTokens(tokens) {
+		size += len(v) + 8
+		if size > maxTsvectorBytes {
+			break
+		}
+		result = append(result, v)
+	}
+	return result
+}
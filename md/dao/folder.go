@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"errors"
+	"md/model/entity"
+	"md/util"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加文件夹
+func FolderAdd(tx *sqlx.Tx, folder entity.Folder) error {
+	sql := `insert into t_folder (id,name,parent_id,sort,create_time,book_id,user_id) values (:id,:name,:parent_id,:sort,:create_time,:book_id,:user_id)`
+	_, err := tx.NamedExec(sql, folder)
+	return err
+}
+
+// 修改文件夹名称
+func FolderUpdate(tx *sqlx.Tx, folder entity.Folder) error {
+	sql := `update t_folder set name=:name where id=:id and user_id=:user_id`
+	_, err := tx.NamedExec(sql, folder)
+	return err
+}
+
+// 修改文件夹位置
+func FolderUpdateParent(tx *sqlx.Tx, id, parentId string, sort int, userId string) error {
+	sql := `update t_folder set parent_id=$1,sort=$2 where id=$3 and user_id=$4`
+	_, err := tx.Exec(sql, parentId, sort, id, userId)
+	return err
+}
+
+// 根据id删除文件夹
+func FolderDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_folder where id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 删除文集下的文件夹
+func FolderDeleteByBookId(tx *sqlx.Tx, bookId, userId string) error {
+	sql := `delete from t_folder where book_id=$1 and user_id=$2`
+	_, err := tx.Exec(sql, bookId, userId)
+	return err
+}
+
+// 根据id查询文件夹
+func FolderGetById(db interface{}, id, userId string) (entity.Folder, error) {
+	sql := `select * from t_folder where id=$1 and user_id=$2`
+	result := entity.Folder{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, id, userId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, id, userId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询文集下的文件夹列表
+func FolderList(db *sqlx.DB, bookId, userId string) ([]entity.Folder, error) {
+	sql := `select * from t_folder where book_id=$1 and user_id=$2`
+	result := []entity.Folder{}
+	err := db.Select(&result, sql, bookId, userId)
+	sortFolders(result)
+	return result, err
+}
+
+// 查询文件夹下的子文件夹列表
+func FolderListByParent(tx *sqlx.Tx, bookId, parentId, userId string) ([]entity.Folder, error) {
+	sql := `select * from t_folder where book_id=$1 and parent_id=$2 and user_id=$3`
+	result := []entity.Folder{}
+	err := tx.Select(&result, sql, bookId, parentId, userId)
+	sortFolders(result)
+	return result, err
+}
+
+// 查询文件夹下子文件夹的最大排序值
+func FolderMaxSort(tx *sqlx.Tx, bookId, parentId, userId string) (int, error) {
+	sql := `select coalesce(max(sort),-1) from t_folder where book_id=$1 and parent_id=$2 and user_id=$3`
+	var result int
+	err := tx.Get(&result, sql, bookId, parentId, userId)
+	return result, err
+}
+
+// 将子文件夹移动到其他文件夹，排序值增加offset以排在原有文件夹之后
+func FolderMoveChildren(tx *sqlx.Tx, fromParentId, toParentId string, offset int, userId string) error {
+	sql := `update t_folder set parent_id=$1,sort=sort+$2 where parent_id=$3 and user_id=$4`
+	_, err := tx.Exec(sql, toParentId, offset, fromParentId, userId)
+	return err
+}
+
+// 按排序值、名称升序
+func sortFolders(folders []entity.Folder) {
+	sort.SliceStable(folders, func(i, j int) bool {
+		if folders[i].Sort != folders[j].Sort {
+			return folders[i].Sort < folders[j].Sort
+		}
+		return util.StringSort(folders[i].Name, folders[j].Name)
+	})
+}
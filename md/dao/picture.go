@@ -1,20 +1,54 @@
 package dao
 
 import (
+	"errors"
 	"md/model/common"
 	"md/model/entity"
 	"md/util"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
 
 // 分页查询图片记录
-func PicturePage(db *sqlx.DB, page common.Page, userId string) ([]entity.Picture, int, error) {
+func PicturePage(db *sqlx.DB, pageCondition common.PageCondition[entity.PicturePageCondition], userId string) ([]entity.Picture, int, error) {
+	condition := pageCondition.Condition
 	sqlCompletion := util.SqlCompletion{}
 	sqlCompletion.InitSql(`select id,name,path,size,create_time from t_picture`)
 	sqlCompletion.Eq("user_id", userId, true)
-	sqlCompletion.Order("create_time", false)
-	sqlCompletion.Limit(page.Current, page.Size)
+	if condition.Name != "" {
+		sqlCompletion.Like("lower(name)", strings.ToLower(condition.Name), true)
+	}
+	if condition.StartTime > 0 {
+		sqlCompletion.Ge("create_time", condition.StartTime, true)
+	}
+	if condition.EndTime > 0 {
+		sqlCompletion.Le("create_time", condition.EndTime, true)
+	}
+	if condition.MinSize > 0 {
+		sqlCompletion.Ge("size", condition.MinSize, true)
+	}
+	if condition.MaxSize > 0 {
+		sqlCompletion.Le("size", condition.MaxSize, true)
+	}
+	if len(condition.Exts) > 0 {
+		// 文件名为数字加后缀，去除开头的数字即为后缀
+		exts := []interface{}{}
+		for _, v := range condition.Exts {
+			exts = append(exts, v)
+		}
+		sqlCompletion.In("ltrim(path,'0123456789')", exts, true)
+	}
+	sortField := "create_time"
+	switch condition.Sort {
+	case "name":
+		sortField = "name"
+	case "size":
+		sortField = "size"
+	}
+	sqlCompletion.Order(sortField, condition.Asc)
+	sqlCompletion.Order("id", condition.Asc)
+	sqlCompletion.Limit(pageCondition.Page.Current, pageCondition.Page.Size)
 
 	// 查询分页数据
 	result := []entity.Picture{}
@@ -48,14 +82,6 @@ func PictureGetById(tx *sqlx.Tx, id, userId string) (entity.Picture, error) {
 	return result, err
 }
 
-// 根据文件大小、hash值查询相同图片的数量
-func PictureCountBySizeHash(tx *sqlx.Tx, size int64, hash string) (common.CountResult, error) {
-	sql := `select count(*) as count from t_picture where size=$1 and hash=$2`
-	result := common.CountResult{}
-	err := tx.Get(&result, sql, size, hash)
-	return result, err
-}
-
 // 根据文件大小、hash值查询相同图片
 func PictureBySizeHash(db *sqlx.DB, size int64, hash string) ([]entity.Picture, error) {
 	sql := `select * from t_picture where size=$1 and hash=$2`
@@ -70,3 +96,98 @@ func PictureAdd(tx *sqlx.Tx, picture entity.Picture) error {
 	_, err := tx.NamedExec(sql, picture)
 	return err
 }
+
+// 查询使用指定文件的图片记录数量
+func PictureCountByPath(db interface{}, path string) (common.CountResult, error) {
+	sql := `select count(*) as count from t_picture where path=$1`
+	result := common.CountResult{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, path)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, path)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询全部图片文件路径
+func PicturePathList(db interface{}) ([]string, error) {
+	sql := `select distinct path from t_picture order by path`
+	result := []string{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Select(&result, sql)
+	case *sqlx.DB:
+		err = db.Select(&result, sql)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询全部图片记录
+func PictureList(db *sqlx.DB) ([]entity.Picture, error) {
+	sql := `select * from t_picture order by create_time`
+	result := []entity.Picture{}
+	err := db.Select(&result, sql)
+	return result, err
+}
+
+// 根据id删除图片（不限用户）
+func PictureDeleteByIdAnyUser(tx *sqlx.Tx, id string) error {
+	sql := `delete from t_picture where id=$1`
+	_, err := tx.Exec(sql, id)
+	return err
+}
+
+// 查询用户的图片用量，共用的文件按使用的用户数分摊
+func PictureUsage(db interface{}, userId string) (entity.FileUsage, error) {
+	sql := `select count(*) as count, cast(coalesce(sum(t.size),0) as bigint) as size,
+		cast(coalesce(sum(case when s.users>1 then t.size else 0 end),0) as bigint) as shared_size,
+		cast(round(coalesce(sum(t.size*1.0/s.users),0)) as bigint) as fair_size
+		from t_picture t join (select path, count(distinct user_id) as users from t_picture group by path) s on s.path=t.path
+		where t.user_id=$1`
+	result := entity.FileUsage{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql, userId)
+	case *sqlx.DB:
+		err = db.Get(&result, sql, userId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询全部图片文件的大小，相同文件只计算一次
+func PictureSizeTotal(db interface{}) (int64, error) {
+	sql := `select cast(coalesce(sum(size),0) as bigint) from (select path, max(size) as size from t_picture group by path) t`
+	var result int64
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Get(&result, sql)
+	case *sqlx.DB:
+		err = db.Get(&result, sql)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询内容中包含图片或缩略图地址的文档，userId为空时查询全部用户的文档
+func PictureReferenceList(tx *sqlx.Tx, pictureUrl, thumbnailUrl, userId string) ([]entity.PictureReference, error) {
+	sql := `select a.id, a.name, a.book_id, coalesce(b.name, '') as book_name, a.update_time, a.user_id
+		from t_document a
+		left join t_book b on a.book_id = b.id
+		where ($1='' or a.user_id=$1) and (a.content like '%'||$2||'%' or a.content like '%'||$3||'%')
+		order by a.update_time desc`
+	result := []entity.PictureReference{}
+	err := tx.Select(&result, sql, userId, pictureUrl, thumbnailUrl)
+	return result, err
+}
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加会话
+func SessionAdd(tx *sqlx.Tx, session entity.Session) error {
+	sql := `insert into t_session (id,user_id,name,access_token,refresh_token,access_expire_time,refresh_expire_time,create_time) values (:id,:user_id,:name,:access_token,:refresh_token,:access_expire_time,:refresh_expire_time,:create_time)`
+	_, err := tx.NamedExec(sql, session)
+	return err
+}
+
+// 根据access token查询未过期的会话
+func SessionGetByAccessToken(db *sqlx.DB, accessToken string, now int64) (entity.Session, error) {
+	sql := `select * from t_session where access_token=$1 and access_expire_time>$2`
+	result := entity.Session{}
+	err := db.Get(&result, sql, accessToken, now)
+	return result, err
+}
+
+// 根据refresh token查询未过期的会话
+func SessionGetByRefreshToken(db *sqlx.DB, refreshToken string, now int64) (entity.Session, error) {
+	sql := `select * from t_session where refresh_token=$1 and refresh_expire_time>$2`
+	result := entity.Session{}
+	err := db.Get(&result, sql, refreshToken, now)
+	return result, err
+}
+
+// 根据refresh token删除会话，返回删除的数量
+func SessionDeleteByRefreshToken(tx *sqlx.Tx, refreshToken string) (int64, error) {
+	sql := `delete from t_session where refresh_token=$1`
+	result, err := tx.Exec(sql, refreshToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// 删除已过期的会话
+func SessionDeleteExpired(tx *sqlx.Tx, now int64) error {
+	sql := `delete from t_session where refresh_expire_time<=$1`
+	_, err := tx.Exec(sql, now)
+	return err
+}
@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"errors"
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 添加共享
+func ShareAdd(tx *sqlx.Tx, share entity.Share) error {
+	sql := `insert into t_share (id,resource_type,resource_id,role,create_time,owner_id,user_id) values (:id,:resource_type,:resource_id,:role,:create_time,:owner_id,:user_id)`
+	_, err := tx.NamedExec(sql, share)
+	return err
+}
+
+// 修改共享权限
+func ShareUpdateRole(tx *sqlx.Tx, id string, role entity.ShareRole) error {
+	sql := `update t_share set role=$1 where id=$2`
+	_, err := tx.Exec(sql, role, id)
+	return err
+}
+
+// 根据id删除共享，所有者和被共享的用户均可删除
+func ShareDeleteById(tx *sqlx.Tx, id, userId string) error {
+	sql := `delete from t_share where id=$1 and (owner_id=$2 or user_id=$2)`
+	_, err := tx.Exec(sql, id, userId)
+	return err
+}
+
+// 删除文集或文档的全部共享
+func ShareDeleteByResource(tx *sqlx.Tx, resourceType entity.ShareResourceType, resourceId, ownerId string) error {
+	sql := `delete from t_share where resource_type=$1 and resource_id=$2 and owner_id=$3`
+	_, err := tx.Exec(sql, resourceType, resourceId, ownerId)
+	return err
+}
+
+// 查询共享给指定用户的记录
+func ShareGetByResourceUser(tx *sqlx.Tx, resourceType entity.ShareResourceType, resourceId, userId string) ([]entity.Share, error) {
+	sql := `select * from t_share where resource_type=$1 and resource_id=$2 and user_id=$3`
+	result := []entity.Share{}
+	err := tx.Select(&result, sql, resourceType, resourceId, userId)
+	return result, err
+}
+
+// 查询用户对文档的共享权限，包括文档所在文集的共享
+func ShareRoleList(db interface{}, userId, documentId, bookId string) ([]entity.ShareRole, error) {
+	sql := `select role from t_share where user_id=$1 and ((resource_type='doc' and resource_id=$2) or (resource_type='book' and resource_id=$3))`
+	result := []entity.ShareRole{}
+	var err error
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		err = db.Select(&result, sql, userId, documentId, bookId)
+	case *sqlx.DB:
+		err = db.Select(&result, sql, userId, documentId, bookId)
+	default:
+		err = errors.New("数据库事务异常")
+	}
+	return result, err
+}
+
+// 查询文集或文档的共享列表
+func ShareList(db *sqlx.DB, resourceType entity.ShareResourceType, resourceId, ownerId string) ([]entity.ShareListResult, error) {
+	sql := `select a.*,COALESCE(b.name,'') as user_name from t_share a left join t_user b on a.user_id=b.id where a.resource_type=$1 and a.resource_id=$2 and a.owner_id=$3 order by a.create_time`
+	result := []entity.ShareListResult{}
+	err := db.Select(&result, sql, resourceType, resourceId, ownerId)
+	return result, err
+}
+
+// 查询共享给我的文集和文档
+func ShareListByUser(db *sqlx.DB, userId string) ([]entity.SharedWithMeResult, error) {
+	sql := `select a.*,COALESCE(c.name,d.name,'') as name,COALESCE(c.type,'') as type,COALESCE(b.name,'') as owner_name 
+		from t_share a 
+		left join t_user b on a.owner_id=b.id 
+		left join t_document c on a.resource_type='doc' and a.resource_id=c.id 
+		left join t_book d on a.resource_type='book' and a.resource_id=d.id 
+		where a.user_id=$1 order by a.create_time desc`
+	result := []entity.SharedWithMeResult{}
+	err := db.Select(&result, sql, userId)
+	return result, err
+}
@@ -38,6 +38,13 @@ func UserGetById(tx interface{}, id string) (entity.User, error) {
 	return result, err
 }
 
+// 锁定用户行直到事务结束，同一用户的配额检查和添加记录按顺序进行
+func UserLock(tx *sqlx.Tx, id string) error {
+	sql := `update t_user set name=name where id=$1`
+	_, err := tx.Exec(sql, id)
+	return err
+}
+
 // 根据用户名查询用户
 func UserGetByName(db *sqlx.DB, name string) (entity.User, error) {
 	sql := `select * from t_user where name=$1`
@@ -61,3 +68,11 @@ func UserCount(tx *sqlx.Tx) (common.CountResult, error) {
 	err := tx.Get(&result, sql)
 	return result, err
 }
+
+// 查询最早注册的用户
+func UserGetFirst(db *sqlx.DB) (entity.User, error) {
+	sql := `select * from t_user order by create_time,id limit 1`
+	result := entity.User{}
+	err := db.Get(&result, sql)
+	return result, err
+}
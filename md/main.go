@@ -3,12 +3,16 @@ package main
 import (
 	"embed"
 	"flag"
+	"fmt"
 	"io/fs"
+	"md/command"
 	"md/controller"
 	"md/middleware"
 	"md/model/common"
+	"md/service"
 	"md/util"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/kataras/iris/v12"
@@ -29,14 +33,50 @@ func init() {
 	flag.StringVar(&common.PostgresPassword, "pg_password", "", "postgres密码")
 	flag.StringVar(&common.PostgresDB, "pg_db", "", "postgres数据库名")
 	flag.StringVar(&common.AIEncryptKey, "ai_key", "md-ai-encrypt-key-2024", "AI API Key加密密钥")
+	flag.BoolVar(&common.AIPrivate, "ai_private", false, "是否允许AI接口地址及导入OpenAPI文档的网络地址使用内网地址（如本地部署的模型），默认只允许公网地址")
+	flag.StringVar(&common.SessionStore, "session", "db", "会话存储方式：db（数据库，重启后仍保持登录）、memory（内存）")
+	flag.UintVar(&common.PasswordMemory, "pwd_memory", 64*1024, "密码哈希（argon2id）内存开销，单位KiB")
+	flag.UintVar(&common.PasswordTime, "pwd_time", 3, "密码哈希（argon2id）迭代次数")
+	flag.UintVar(&common.PasswordThreads, "pwd_threads", 2, "密码哈希（argon2id）并行度")
+	flag.StringVar(&common.Admin, "admin", "", "管理员用户名，多个用逗号分隔，为空时最早注册的用户为管理员")
+	flag.IntVar(&common.RevisionKeep, "revision_keep", 100, "每个文档保留的历史版本数量，超出时删除最早的版本，为0时全部保留")
+	flag.BoolVar(&common.OpenApiStrict, "openapi_strict", false, "严格模式，保存OpenAPI文档时拒绝未通过校验（存在错误）的内容")
+	flag.IntVar(&common.PictureWebPSize, "pic_webp", 0, "上传的图片（JPEG、PNG、BMP、WebP）超过此大小时转为WebP并压缩到此大小以内，单位KB，为0时不转换")
+	flag.StringVar(&common.AttachmentTypes, "attach_types", "application/pdf,application/zip,application/x-7z-compressed,application/vnd.rar,application/gzip,application/x-tar,text/plain,text/csv,text/markdown,application/json,application/msword,application/vnd.ms-excel,application/vnd.ms-powerpoint,application/vnd.openxmlformats-officedocument.wordprocessingml.document,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/vnd.openxmlformats-officedocument.presentationml.presentation,application/vnd.oasis.opendocument.text,application/vnd.oasis.opendocument.spreadsheet,application/vnd.oasis.opendocument.presentation,application/epub+zip,image/*,audio/*,video/*", "允许上传的附件MIME类型（根据文件内容识别），多个用逗号分隔，支持image/*形式")
+	flag.IntVar(&common.AttachmentSize, "attach_size", 100, "附件大小上限，单位MB")
+	flag.IntVar(&common.QuotaSize, "quota_size", 0, "每个用户的图片和附件总大小配额，单位MB，为0时不限制")
+	flag.IntVar(&common.QuotaDocuments, "quota_docs", 0, "每个用户的文档数量配额，为0时不限制")
+	flag.IntVar(&common.QuotaTotalSize, "quota_total_size", 0, "全部用户的图片和附件总大小配额（相同文件只计算一次），单位MB，为0时不限制")
+	flag.IntVar(&common.QuotaTotalDocs, "quota_total_docs", 0, "全部用户的文档数量配额，为0时不限制")
+	flag.StringVar(&common.StorageType, "storage", "local", "图片等文件的存储方式：local（数据目录）、s3（S3兼容的对象存储）")
+	flag.StringVar(&common.S3Endpoint, "s3_endpoint", "", "对象存储服务地址，如https://s3.us-east-1.amazonaws.com")
+	flag.StringVar(&common.S3Region, "s3_region", "us-east-1", "对象存储区域")
+	flag.StringVar(&common.S3Bucket, "s3_bucket", "", "对象存储桶")
+	flag.StringVar(&common.S3AccessKey, "s3_access_key", "", "对象存储Access Key")
+	flag.StringVar(&common.S3SecretKey, "s3_secret_key", "", "对象存储Secret Key")
+	flag.StringVar(&common.S3Prefix, "s3_prefix", "", "对象键前缀，多个服务共用存储桶时区分")
+	flag.BoolVar(&common.S3PathStyle, "s3_path_style", true, "使用路径形式（服务地址/存储桶/对象）访问，为false时使用虚拟主机形式（存储桶.服务地址/对象）")
+	flag.StringVar(&common.S3Serve, "s3_serve", "proxy", "对象存储中文件的访问方式：proxy（由服务读取后返回）、redirect（重定向到带签名的临时地址）")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法：%s [参数] [命令] [命令参数]\n\n命令（不指定时启动服务）：\n%s\n参数：\n", os.Args[0], command.Usage())
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	// 子命令，命令之后仍可使用参数
+	if flag.NArg() > 0 {
+		common.Command = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+		common.CommandArgs = flag.Args()
+	}
+
 	// 固定配置
 	common.DataPath = util.PathCompletion(common.DataPath)
 	common.BasicTokenKey = "md"
 	common.ResourceName = "resource"
 	common.PictureName = "picture"
 	common.ThumbnailName = "thumbnail"
+	common.AttachmentName = "attachment"
 }
 
 func main() {
@@ -52,8 +92,15 @@ func main() {
 	// gzip压缩
 	app.Use(iris.Compression)
 
+	// 校验密码哈希参数
+	err := service.CheckPasswordParams()
+	if err != nil {
+		middleware.Log.Error("密码哈希参数错误：", err)
+		return
+	}
+
 	// 初始化雪花算法节点
-	err := util.InitSnowflake(0)
+	err = util.InitSnowflake(0)
 	if err != nil {
 		middleware.Log.Error("初始化雪花算法节点失败：", err)
 		return
@@ -65,12 +112,48 @@ func main() {
 		return
 	}
 
+	// 初始化文件存储
+	err = middleware.InitStorage(common.StorageType)
+	if err != nil {
+		return
+	}
+
 	// 初始化数据库连接
 	err = middleware.InitDB()
 	if err != nil {
 		return
 	}
 
+	// 检查全文检索索引
+	err = service.DocumentSearchIndexInit()
+	if err != nil {
+		return
+	}
+
+	// 执行子命令
+	if common.Command != "" {
+		err = command.Run(common.Command, common.CommandArgs)
+		if err != nil {
+			middleware.Log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 获取运行锁，服务运行时不能恢复备份
+	err = middleware.LockRun(false)
+	if err != nil {
+		middleware.Log.Error("获取运行锁失败：", err)
+		return
+	}
+	defer middleware.UnlockRun()
+
+	// 初始化会话存储
+	err = middleware.InitTokenStore(common.SessionStore)
+	if err != nil {
+		return
+	}
+
 	// 初始化API路由
 	controller.InitRouter(app)
 
@@ -84,7 +167,8 @@ func main() {
 	app.HandleDir("/", http.FS(webFs))
 
 	// 静态资源路由
-	app.HandleDir("/"+common.ResourceName, common.DataPath+common.ResourceName)
+	app.Get("/"+common.ResourceName+"/{key:path}", controller.Resource)
+	app.Head("/"+common.ResourceName+"/{key:path}", controller.Resource)
 
 	// 启动服务
 	app.Logger().Error(app.Run(iris.Addr(":" + common.Port)))
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"md/dao"
 	"md/model/common"
 	"md/util"
 	"strconv"
@@ -11,18 +12,103 @@ import (
 	"github.com/muesli/cache2go"
 )
 
+// 请求上下文中保存认证信息的key
+const tokenCacheKey = "tokenCache"
+
 // 数据接口授权
 func DataAuth(ctx iris.Context) {
 	token := resolveHeader(ctx, "Bearer")
 
-	// 检验缓存中是否存在此token
-	if !cache2go.Cache(common.AccessTokenCache).Exists(token) {
+	// 个人访问令牌从数据库校验，仅可访问权限范围内的接口
+	if strings.HasPrefix(token, ApiTokenPrefix) {
+		tokenCache, err := apiTokenGet(token)
+		if err != nil {
+			panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+		}
+		if !apiTokenAllowed(ctx.Path(), tokenCache.Scopes) {
+			panic(common.NewError("令牌无权访问此接口"))
+		}
+		ctx.Values().Set(tokenCacheKey, tokenCache)
+		ctx.Next()
+		return
+	}
+
+	// 检验token存储中是否存在此token
+	tokenCache, err := Tokens.GetByAccessToken(token)
+	if err != nil {
 		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
 	}
+	ctx.Values().Set(tokenCacheKey, tokenCache)
+
+	ctx.Next()
+}
+
+// WebSocket连接票据有效期
+const wsTicketExpire = 30 * time.Second
+
+// 创建WebSocket连接票据：浏览器无法设置请求头，通过一次性的短期票据放在地址中，避免token出现在访问日志中
+func WebSocketTicket(ctx iris.Context) string {
+	tokenCache, ok := ctx.Values().Get(tokenCacheKey).(common.TokenCache)
+	if !ok || tokenCache.Id == "" {
+		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+	}
+	ticket, err := util.RandomSecureHex(32)
+	if err != nil {
+		panic(common.NewErr("创建票据失败", err))
+	}
+	cache2go.Cache(common.WsTicketCache).Add(ticket, wsTicketExpire, &tokenCache)
+	return ticket
+}
+
+// WebSocket接口授权，通过ticket参数传递连接票据，使用后即失效，也可使用Authorization请求头
+func WebSocketAuth(ctx iris.Context) {
+	var tokenCache common.TokenCache
+	if ticket := ctx.URLParam("ticket"); ticket != "" {
+		// 删除成功才视为有效，保证票据只能使用一次
+		item, err := cache2go.Cache(common.WsTicketCache).Delete(ticket)
+		if err != nil {
+			panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+		}
+		tokenCache = *item.Data().(*common.TokenCache)
+	} else {
+		var err error
+		tokenCache, err = Tokens.GetByAccessToken(resolveHeader(ctx, "Bearer"))
+		if err != nil {
+			panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+		}
+	}
+	ctx.Values().Set(tokenCacheKey, tokenCache)
+
+	ctx.Next()
+}
+
+// 管理接口授权，需在DataAuth之后使用
+func AdminAuth(ctx iris.Context) {
+	if !IsAdmin(CurrentUserId(ctx)) {
+		panic(common.NewError("无管理员权限"))
+	}
 
 	ctx.Next()
 }
 
+// 是否为管理员：未指定管理员时，最早注册的用户为管理员
+func IsAdmin(userId string) bool {
+	if common.Admin == "" {
+		user, err := dao.UserGetFirst(Db)
+		return err == nil && user.Id == userId
+	}
+	user, err := dao.UserGetById(Db, userId)
+	if err != nil {
+		return false
+	}
+	for _, name := range strings.Split(common.Admin, ",") {
+		if strings.TrimSpace(name) == user.Name {
+			return true
+		}
+	}
+	return false
+}
+
 // token相关接口认证授权
 func TokenAuth(ctx iris.Context) {
 	token := resolveHeader(ctx, "Basic")
@@ -42,12 +128,15 @@ func TokenAuth(ctx iris.Context) {
 
 // 获取当前登录用户id
 func CurrentUserId(ctx iris.Context) string {
-	token := resolveHeader(ctx, "Bearer")
-	res, err := cache2go.Cache(common.AccessTokenCache).Value(token)
-	if err != nil {
-		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+	// 优先使用认证时已查询的结果
+	tokenCache, ok := ctx.Values().Get(tokenCacheKey).(common.TokenCache)
+	if !ok {
+		var err error
+		tokenCache, err = Tokens.GetByAccessToken(resolveHeader(ctx, "Bearer"))
+		if err != nil {
+			panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
+		}
 	}
-	tokenCache := res.Data().(*common.TokenCache)
 	if tokenCache.Id == "" {
 		panic(common.NewErrorCode(common.HttpAuthFailure, "认证失败"))
 	}
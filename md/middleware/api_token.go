@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"md/dao"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+)
+
+// 个人访问令牌前缀，用于与登录令牌区分
+const ApiTokenPrefix = "mdp_"
+
+// 个人访问令牌可访问的接口及所需权限，未列出的接口不可访问
+var apiTokenRoutes = map[string]entity.ApiTokenScope{
+	"/api/data/book/list":            entity.ScopeDocRead,
+	"/api/data/book/export-site":     entity.ScopeDocRead,
+	"/api/data/book/export":          entity.ScopeDocRead,
+	"/api/data/folder/tree":          entity.ScopeDocRead,
+	"/api/data/doc/list":             entity.ScopeDocRead,
+	"/api/data/doc/get":              entity.ScopeDocRead,
+	"/api/data/doc/search":           entity.ScopeDocRead,
+	"/api/data/doc/validate":         entity.ScopeDocRead,
+	"/api/data/doc/revision/list":    entity.ScopeDocRead,
+	"/api/data/doc/revision/get":     entity.ScopeDocRead,
+	"/api/data/doc/revision/diff":    entity.ScopeDocRead,
+	"/api/data/doc/openapi-diff":     entity.ScopeDocRead,
+	"/api/data/doc/export":           entity.ScopeDocRead,
+	"/api/data/folder/add":           entity.ScopeDocWrite,
+	"/api/data/folder/move":          entity.ScopeDocWrite,
+	"/api/data/doc/add":              entity.ScopeDocWrite,
+	"/api/data/doc/update":           entity.ScopeDocWrite,
+	"/api/data/doc/update-content":   entity.ScopeDocWrite,
+	"/api/data/doc/import-openapi":   entity.ScopeDocWrite,
+	"/api/data/doc/import-markdown":  entity.ScopeDocWrite,
+	"/api/data/doc/revision/restore": entity.ScopeDocWrite,
+	"/api/data/pic/upload":           entity.ScopePictureUpload,
+	"/api/data/attachment/upload":    entity.ScopeAttachmentUpload,
+}
+
+// 最后使用时间的更新间隔，避免每次请求都写数据库
+const apiTokenUsedInterval = time.Minute
+
+// 根据个人访问令牌查询认证信息
+func apiTokenGet(token string) (common.TokenCache, error) {
+	now := time.Now().UnixMilli()
+	apiToken, err := dao.ApiTokenGetByToken(Db, util.EncryptSHA256([]byte(token)), now)
+	if err != nil {
+		return common.TokenCache{}, ErrTokenNotFound
+	}
+	if now-apiToken.LastUsedTime > apiTokenUsedInterval.Milliseconds() {
+		err = dao.ApiTokenUpdateLastUsed(DbW, apiToken.Id, now)
+		if err != nil {
+			Log.Error("更新令牌使用时间失败：", err)
+		}
+	}
+	tokenCache := common.TokenCache{Id: apiToken.UserId, Scopes: strings.Split(apiToken.Scopes, ",")}
+	tokenCache.Name = apiToken.Name
+	return tokenCache, nil
+}
+
+// 个人访问令牌是否可访问接口
+func apiTokenAllowed(path string, scopes []string) bool {
+	scope, ok := apiTokenRoutes[path]
+	if !ok {
+		return false
+	}
+	for _, v := range scopes {
+		if v == string(scope) {
+			return true
+		}
+	}
+	return false
+}
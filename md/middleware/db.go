@@ -15,6 +15,9 @@ var Db *sqlx.DB
 // 数据库写连接
 var DbW *sqlx.DB
 
+// 业务数据表，备份时导出，新增表时需同步添加（全文检索索引、会话可重建，不在其中）
+var DataTables = []string{"t_user", "t_book", "t_folder", "t_document", "t_document_revision", "t_picture", "t_ai_config", "t_ai_conversation", "t_share", "t_api_token", "t_attachment"}
+
 // 建表语句
 var createTableSql = `
 CREATE TABLE IF NOT EXISTS t_user
@@ -88,7 +91,7 @@ ON "t_user" (
 // 初始化数据库连接
 func InitDB() error {
 	var err error
-	if common.PostgresHost != "" && common.PostgresPort != "" && common.PostgresUser != "" && common.PostgresPassword != "" && common.PostgresDB != "" {
+	if PostgresConfigured() {
 		err = initPostgres()
 	} else {
 		err = initSqlite()
@@ -97,11 +100,20 @@ func InitDB() error {
 		return err
 	}
 
+	return InitSchema(Db, common.DbType)
+}
+
+// 创建表并执行迁移
+func InitSchema(db *sqlx.DB, dbType string) error {
 	// Create base tables
-	Db.MustExec(createTableSql)
+	_, err := db.Exec(createTableSql)
+	if err != nil {
+		Log.Error("创建数据库表失败：", err)
+		return err
+	}
 
 	// Run migrations for schema updates
-	if err = RunMigrations(); err != nil {
+	if err = RunMigrations(db, dbType); err != nil {
 		Log.Error("Database migration failed: ", err)
 		return err
 	}
@@ -109,23 +121,49 @@ func InitDB() error {
 	return nil
 }
 
+// 是否已填写全部postgres参数
+func PostgresConfigured() bool {
+	return common.PostgresHost != "" && common.PostgresPort != "" && common.PostgresUser != "" && common.PostgresPassword != "" && common.PostgresDB != ""
+}
+
+// 连接数据目录下的sqlite数据库文件
+func ConnectSqlite() (*sqlx.DB, error) {
+	return sqlx.Connect("sqlite", common.DataPath+"md.db")
+}
+
+// 连接postgres
+func ConnectPostgres() (*sqlx.DB, error) {
+	return sqlx.Connect("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", common.PostgresHost, common.PostgresPort, common.PostgresUser, common.PostgresPassword, common.PostgresDB))
+}
+
+// 关闭数据库连接
+func CloseDB() {
+	if DbW != nil && DbW != Db {
+		DbW.Close()
+	}
+	if Db != nil {
+		Db.Close()
+	}
+}
+
 // 初始化sqlite
 func initSqlite() error {
 	// 开启数据库文件
 	var err error
-	Db, err = sqlx.Connect("sqlite", common.DataPath+"md.db")
+	Db, err = ConnectSqlite()
 	if err != nil {
 		Log.Error("开启sqlite数据库文件失败：", err)
 		return err
 	}
 
-	DbW, err = sqlx.Connect("sqlite", common.DataPath+"md.db")
+	DbW, err = ConnectSqlite()
 	if err != nil {
 		Log.Error("开启sqlite数据库文件失败：", err)
 		return err
 	}
 	DbW.SetMaxOpenConns(1)
 
+	common.DbType = common.DbSqlite
 	Log.Info("已连接sqlite")
 	return nil
 }
@@ -133,7 +171,7 @@ func initSqlite() error {
 // 初始化postgres
 func initPostgres() error {
 	var err error
-	Db, err = sqlx.Connect("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", common.PostgresHost, common.PostgresPort, common.PostgresUser, common.PostgresPassword, common.PostgresDB))
+	Db, err = ConnectPostgres()
 	if err != nil {
 		Log.Error("postgres连接失败：", err)
 		return err
@@ -141,6 +179,7 @@ func initPostgres() error {
 
 	DbW = Db
 
+	common.DbType = common.DbPostgres
 	Log.Info("已连接postgres")
 	return nil
 }
@@ -2,7 +2,10 @@ package middleware
 
 import (
 	"fmt"
+	"md/model/common"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // Migration represents a database migration
@@ -10,6 +13,7 @@ type Migration struct {
 	Version     int
 	Description string
 	SQL         string
+	PostgresSQL string // Postgres-specific SQL, falls back to SQL when empty
 }
 
 // All migrations in order - append new migrations to this list
@@ -53,14 +57,214 @@ CREATE INDEX IF NOT EXISTS "ai_conversation_user_id"
 ON "t_ai_conversation" (
   "user_id" ASC
 );
+`,
+	},
+	{
+		Version:     2,
+		Description: "Add document revision table",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_document_revision
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	document_id varchar(50) NOT NULL,
+	revision int NOT NULL,
+	content text NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "document_revision_document_id_revision"
+ON "t_document_revision" (
+  "document_id" ASC,
+  "revision" ASC
+);
+`,
+	},
+	{
+		Version:     3,
+		Description: "Add session table",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_session
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	user_id varchar(50) NOT NULL,
+	name text NOT NULL,
+	access_token varchar(64) NOT NULL,
+	refresh_token varchar(64) NOT NULL,
+	access_expire_time bigint NOT NULL,
+	refresh_expire_time bigint NOT NULL,
+	create_time bigint NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "session_access_token"
+ON "t_session" (
+  "access_token" ASC
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "session_refresh_token"
+ON "t_session" (
+  "refresh_token" ASC
+);
+`,
+	},
+	{
+		Version:     4,
+		Description: "Add document full-text search index",
+		SQL: `
+CREATE VIRTUAL TABLE IF NOT EXISTS t_document_fts USING fts5
+(
+	document_id UNINDEXED,
+	user_id UNINDEXED,
+	name,
+	content,
+	tokenize = 'unicode61'
+);
+`,
+		PostgresSQL: `
+CREATE TABLE IF NOT EXISTS t_document_fts
+(
+	document_id varchar(50) PRIMARY KEY NOT NULL,
+	user_id varchar(50) NOT NULL,
+	search tsvector NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "document_fts_user_id"
+ON "t_document_fts" (
+  "user_id" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "document_fts_search"
+ON "t_document_fts" USING GIN (
+  "search"
+);
+`,
+	},
+	{
+		Version:     5,
+		Description: "Add folders and sort order",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_folder
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	parent_id varchar(50) NOT NULL DEFAULT '',
+	sort int NOT NULL DEFAULT 0,
+	create_time bigint NOT NULL,
+	book_id varchar(50) NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "folder_user_id_book_id"
+ON "t_folder" (
+  "user_id" ASC,
+  "book_id" ASC
+);
+
+ALTER TABLE t_document ADD COLUMN parent_id varchar(50) NOT NULL DEFAULT '';
+
+ALTER TABLE t_document ADD COLUMN sort int NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		Version:     6,
+		Description: "Add share table",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_share
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	resource_type varchar(10) NOT NULL,
+	resource_id varchar(50) NOT NULL,
+	role varchar(10) NOT NULL,
+	create_time bigint NOT NULL,
+	owner_id varchar(50) NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "share_resource_user_id"
+ON "t_share" (
+  "resource_type" ASC,
+  "resource_id" ASC,
+  "user_id" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "share_user_id"
+ON "t_share" (
+  "user_id" ASC
+);
+`,
+	},
+	{
+		Version:     7,
+		Description: "Add personal API token table",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_api_token
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	name varchar(100) NOT NULL,
+	token varchar(64) NOT NULL,
+	prefix varchar(20) NOT NULL,
+	scopes varchar(200) NOT NULL,
+	create_time bigint NOT NULL,
+	last_used_time bigint NOT NULL DEFAULT 0,
+	expire_time bigint NOT NULL DEFAULT 0,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "api_token_token"
+ON "t_api_token" (
+  "token" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "api_token_user_id"
+ON "t_api_token" (
+  "user_id" ASC
+);
+`,
+	},
+	{
+		Version:     8,
+		Description: "Add attachment table",
+		SQL: `
+CREATE TABLE IF NOT EXISTS t_attachment
+(
+	id varchar(50) PRIMARY KEY NOT NULL,
+	name text NOT NULL,
+	path text NOT NULL,
+	hash text NOT NULL,
+	size bigint NOT NULL,
+	mime varchar(200) NOT NULL,
+	create_time bigint NOT NULL,
+	user_id varchar(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "attachment_size_hash"
+ON "t_attachment" (
+  "size" ASC,
+  "hash" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "attachment_path"
+ON "t_attachment" (
+  "path" ASC
+);
+
+CREATE INDEX IF NOT EXISTS "attachment_user_id"
+ON "t_attachment" (
+  "user_id" ASC
+);
 `,
 	},
 }
 
+// LatestVersion returns the schema version after all migrations are applied
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
 // RunMigrations checks and applies pending database migrations
-func RunMigrations() error {
+func RunMigrations(db *sqlx.DB, dbType string) error {
 	// Ensure version table exists
-	_, err := Db.Exec(`
+	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS t_db_version (
 			version int PRIMARY KEY NOT NULL,
 			applied_at bigint NOT NULL
@@ -72,7 +276,7 @@ func RunMigrations() error {
 
 	// Get current version
 	var dbVersion int
-	err = Db.Get(&dbVersion, "SELECT COALESCE(MAX(version), 0) FROM t_db_version")
+	err = db.Get(&dbVersion, "SELECT COALESCE(MAX(version), 0) FROM t_db_version")
 	if err != nil {
 		return fmt.Errorf("failed to get db version: %w", err)
 	}
@@ -85,13 +289,17 @@ func RunMigrations() error {
 
 		Log.Info(fmt.Sprintf("Applying migration %d: %s", m.Version, m.Description))
 
-		_, err = Db.Exec(m.SQL)
+		migrationSql := m.SQL
+		if dbType == common.DbPostgres && m.PostgresSQL != "" {
+			migrationSql = m.PostgresSQL
+		}
+		_, err = db.Exec(migrationSql)
 		if err != nil {
 			return fmt.Errorf("migration %d failed: %w", m.Version, err)
 		}
 
 		// Record migration
-		_, err = Db.Exec(
+		_, err = db.Exec(
 			"INSERT INTO t_db_version (version, applied_at) VALUES ($1, $2)",
 			m.Version,
 			time.Now().UnixMilli(),
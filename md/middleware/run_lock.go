@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"md/model/common"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 运行锁：服务运行期间持有，恢复备份前以独占方式获取，避免覆盖正在使用的数据库，恢复期间也不能启动服务。
+// sqlite在数据目录下写入进程号文件；postgres使用会话级咨询锁，服务持有共享锁（多个服务可共用一个数据库），
+// 进程异常退出时postgres自动释放，遗留的进程号文件在进程不存在时忽略
+const (
+	runLockName = "md.pid" // 进程号文件
+	runLockKey  = 0x6d64   // 咨询锁的键
+)
+
+// 数据库正在被其他进程使用
+var ErrDbInUse = errors.New("数据库正在被其他进程使用，请先停止服务")
+
+var (
+	runLockFile string    // 已写入的进程号文件
+	runLockConn *sql.Conn // 持有咨询锁的连接
+)
+
+// 获取运行锁，exclusive为true时独占（恢复备份），否则与其他服务共享
+func LockRun(exclusive bool) error {
+	if common.DbType == common.DbPostgres {
+		return lockRunPostgres(exclusive)
+	}
+	return lockRunSqlite()
+}
+
+// 释放运行锁
+func UnlockRun() {
+	if runLockConn != nil {
+		_, err := runLockConn.ExecContext(context.Background(), `select pg_advisory_unlock_all()`)
+		if err != nil {
+			Log.Error("释放运行锁失败：", err)
+		}
+		runLockConn.Close()
+		runLockConn = nil
+	}
+	if runLockFile != "" {
+		os.Remove(runLockFile)
+		runLockFile = ""
+	}
+}
+
+// 创建进程号文件，已存在且进程仍在运行时返回ErrDbInUse
+func lockRunSqlite() error {
+	if runLockFile != "" {
+		return ErrDbInUse
+	}
+	path := common.DataPath + runLockName
+	for i := 0; i < 2; i++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return err
+			}
+			runLockFile = path
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		// 容器中每次启动的进程号可能相同，与当前进程相同时视为遗留的文件
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("%w（进程%d，如确认该进程不是本服务，可删除%s）", ErrDbInUse, pid, path)
+		}
+		// 进程已退出，删除遗留的文件后重试
+		os.Remove(path)
+	}
+	return ErrDbInUse
+}
+
+// 在单独的连接上获取咨询锁，连接保持到释放锁
+func lockRunPostgres(exclusive bool) error {
+	ctx := context.Background()
+	conn, err := Db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	function := "pg_try_advisory_lock_shared"
+	if exclusive {
+		function = "pg_try_advisory_lock"
+	}
+	locked := false
+	err = conn.QueryRowContext(ctx, `select `+function+`($1)`, runLockKey).Scan(&locked)
+	if err == nil && !locked {
+		err = ErrDbInUse
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	runLockConn = conn
+	return nil
+}
+
+// 进程是否仍在运行，Windows下FindProcess打开进程失败即不存在
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer process.Release()
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
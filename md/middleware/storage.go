@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"md/model/common"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// 文件存储方式
+const (
+	StorageLocal = "local" // 本地磁盘，保存在数据目录下
+	StorageS3    = "s3"    // S3兼容的对象存储
+)
+
+// 对象存储的访问方式
+const (
+	StorageServeProxy    = "proxy"    // 由服务端读取后返回
+	StorageServeRedirect = "redirect" // 重定向到带签名的临时地址
+)
+
+// 文件存储，保存图片、缩略图等静态资源
+var Storage FileStorage
+
+// 文件存储接口，key为静态资源目录下的相对路径，如picture/xxx.png
+type FileStorage interface {
+	// 保存文件，已存在时覆盖
+	Put(key string, reader io.Reader, size int64) error
+	// 读取文件，不存在时返回fs.ErrNotExist
+	Open(key string) (io.ReadCloser, error)
+	// 删除文件，不存在时不返回错误
+	Delete(key string) error
+	// 列出前缀下的全部文件
+	List(prefix string) ([]StorageFile, error)
+	// 响应文件请求，支持Range、缓存校验
+	Serve(w http.ResponseWriter, r *http.Request, key string, options StorageServeOptions)
+}
+
+// 响应文件请求时指定的响应头，为空时不指定
+type StorageServeOptions struct {
+	ContentType        string // Content-Type
+	ContentDisposition string // Content-Disposition
+}
+
+// 存储中的文件
+type StorageFile struct {
+	Key     string    // 静态资源目录下的相对路径
+	Size    int64     // 文件大小
+	ModTime time.Time // 修改时间
+}
+
+// 文件路径无效
+var errStorageKey = errors.New("文件路径无效")
+
+// 初始化文件存储
+func InitStorage(storageType string) error {
+	storage, err := NewStorage(storageType)
+	if err != nil {
+		Log.Error("初始化文件存储失败：", err)
+		return err
+	}
+	Storage = storage
+	Log.Info("文件存储方式：", storageType)
+	return nil
+}
+
+// 按存储方式创建文件存储，对象存储使用s3_开头的参数
+func NewStorage(storageType string) (FileStorage, error) {
+	switch storageType {
+	case StorageLocal:
+		return &localStorage{root: common.DataPath + common.ResourceName + "/"}, nil
+	case StorageS3:
+		return newS3Storage()
+	}
+	return nil, errors.New("不支持的文件存储方式：" + storageType)
+}
+
+// 保存字节数据
+func StoragePutBytes(key string, data []byte) error {
+	return Storage.Put(key, bytes.NewReader(data), int64(len(data)))
+}
+
+// 读取全部内容
+func StorageReadAll(key string) ([]byte, error) {
+	reader, err := Storage.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// 校验文件路径，只允许静态资源目录下不含..的相对路径
+func storageValidKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key && key != "." && !strings.HasPrefix(key, "../")
+}
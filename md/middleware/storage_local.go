@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 本地磁盘存储，保存在数据目录下的静态资源目录中
+type localStorage struct {
+	root string
+}
+
+func (s *localStorage) Put(key string, reader io.Reader, size int64) error {
+	if !storageValidKey(key) {
+		return errStorageKey
+	}
+	target := s.root + key
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件再替换，避免读取到不完整的文件
+	file, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), target)
+}
+
+func (s *localStorage) Open(key string) (io.ReadCloser, error) {
+	if !storageValidKey(key) {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(s.root + key)
+}
+
+func (s *localStorage) Delete(key string) error {
+	if !storageValidKey(key) {
+		return errStorageKey
+	}
+	err := os.Remove(s.root + key)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStorage) List(prefix string) ([]StorageFile, error) {
+	files := []StorageFile{}
+	err := filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		// 跳过上传中的临时文件
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		key, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, StorageFile{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+func (s *localStorage) Serve(w http.ResponseWriter, r *http.Request, key string, options StorageServeOptions) {
+	if !storageValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(s.root + key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if options.ContentType != "" {
+		w.Header().Set("Content-Type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", options.ContentDisposition)
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
@@ -0,0 +1,434 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"md/model/common"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重定向访问时临时地址的有效期
+const s3PresignExpire = time.Hour
+
+// 请求超时：连接、TLS握手和等待响应头各自受限，上传、读取、删除、列表请求另有总时长限制；
+// 代理访问时响应体按流返回，不设总时长，由浏览器请求的上下文控制
+var (
+	s3DialTimeout     = 10 * time.Second
+	s3ResponseTimeout = 30 * time.Second
+	s3RequestTimeout  = 10 * time.Minute
+)
+
+// S3兼容的对象存储，使用AWS Signature Version 4签名
+type s3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	prefix    string // 对象键前缀，为空或以/结尾
+	pathStyle bool   // 使用路径形式（endpoint/bucket/key）访问，否则使用虚拟主机形式（bucket.endpoint/key）
+	serve     string // 访问方式：proxy/redirect
+	client    *http.Client
+}
+
+// 根据s3_开头的参数创建对象存储
+func newS3Storage() (*s3Storage, error) {
+	if common.S3Endpoint == "" || common.S3Bucket == "" || common.S3AccessKey == "" || common.S3SecretKey == "" {
+		return nil, errors.New("使用对象存储时需填写s3_endpoint、s3_bucket、s3_access_key、s3_secret_key参数")
+	}
+	endpoint, err := url.Parse(common.S3Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, errors.New("s3_endpoint格式错误，示例：https://s3.us-east-1.amazonaws.com")
+	}
+	if common.S3Serve != StorageServeProxy && common.S3Serve != StorageServeRedirect {
+		return nil, errors.New("不支持的对象存储访问方式：" + common.S3Serve)
+	}
+	prefix := strings.Trim(common.S3Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3Storage{
+		endpoint:  &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host},
+		region:    common.S3Region,
+		bucket:    common.S3Bucket,
+		accessKey: common.S3AccessKey,
+		secretKey: common.S3SecretKey,
+		prefix:    prefix,
+		pathStyle: common.S3PathStyle,
+		serve:     common.S3Serve,
+		client:    &http.Client{Transport: s3Transport()},
+	}, nil
+}
+
+// 带超时设置的连接
+func s3Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: s3DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = s3DialTimeout
+	transport.ResponseHeaderTimeout = s3ResponseTimeout
+	return transport
+}
+
+func (s *s3Storage) Put(key string, reader io.Reader, size int64) error {
+	if !storageValidKey(key) {
+		return errStorageKey
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if size == 0 {
+		reader = http.NoBody
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), reader)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", contentType)
+	s.sign(request, "UNSIGNED-PAYLOAD", time.Now())
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return s3Error(response)
+}
+
+func (s *s3Storage) Open(key string) (io.ReadCloser, error) {
+	if !storageValidKey(key) {
+		return nil, fs.ErrNotExist
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	response, err := s.get(ctx, key, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		cancel()
+		return nil, fs.ErrNotExist
+	}
+	err = s3Error(response)
+	if err != nil {
+		response.Body.Close()
+		cancel()
+		return nil, err
+	}
+	return &s3Body{ReadCloser: response.Body, cancel: cancel}, nil
+}
+
+// 对象内容，关闭时释放请求的超时上下文
+type s3Body struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *s3Body) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (s *s3Storage) Delete(key string) error {
+	if !storageValidKey(key) {
+		return errStorageKey
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(request, s3EmptyHash, time.Now())
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return s3Error(response)
+}
+
+// 对象列表（ListObjectsV2）
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Storage) List(prefix string) ([]StorageFile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+	files := []StorageFile{}
+	token := ""
+	for {
+		bucketURL := s.objectURL("")
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		bucketURL.RawQuery = s3CanonicalQuery(query)
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, bucketURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		s.sign(request, s3EmptyHash, time.Now())
+		response, err := s.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		result := s3ListResult{}
+		err = s3Error(response)
+		if err == nil {
+			err = xml.NewDecoder(response.Body).Decode(&result)
+		}
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range result.Contents {
+			key := strings.TrimPrefix(v.Key, s.prefix)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			files = append(files, StorageFile{Key: key, Size: v.Size, ModTime: v.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *s3Storage) Serve(w http.ResponseWriter, r *http.Request, key string, options StorageServeOptions) {
+	if !storageValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// 重定向到临时地址，浏览器缓存时间不超过地址有效期
+	if s.serve == StorageServeRedirect {
+		w.Header().Del("Expires")
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(s3PresignExpire.Seconds())/2))
+		http.Redirect(w, r, s.presign(key, s3PresignExpire, time.Now(), options), http.StatusFound)
+		return
+	}
+
+	// 代理：转发Range和缓存校验请求头，返回对象存储的响应
+	header := http.Header{}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if value := r.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	response, err := s.get(r.Context(), key, header)
+	if err != nil {
+		Log.Error("读取对象存储失败：", err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		http.NotFound(w, r)
+		return
+	case response.StatusCode >= 300 && response.StatusCode != http.StatusNotModified && response.StatusCode != http.StatusRequestedRangeNotSatisfiable:
+		Log.Error("读取对象存储失败：", s3Error(response))
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if value := response.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	if options.ContentType != "" {
+		w.Header().Set("Content-Type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", options.ContentDisposition)
+	}
+	w.WriteHeader(response.StatusCode)
+	if r.Method != http.MethodHead {
+		io.Copy(w, response.Body)
+	}
+}
+
+// 读取对象，header为额外的请求头，ctx取消时中断读取
+func (s *s3Storage) get(ctx context.Context, key string, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	s.sign(request, s3EmptyHash, time.Now())
+	return s.client.Do(request)
+}
+
+// 对象地址，key为空时为存储桶地址
+func (s *s3Storage) objectURL(key string) *url.URL {
+	objectURL := *s.endpoint
+	objectKey := ""
+	if key != "" {
+		objectKey = s.prefix + key
+	}
+	if s.pathStyle {
+		objectURL.Path = "/" + s.bucket
+		if objectKey != "" {
+			objectURL.Path += "/" + objectKey
+		}
+	} else {
+		objectURL.Host = s.bucket + "." + objectURL.Host
+		objectURL.Path = "/" + objectKey
+	}
+	objectURL.RawPath = s3Encode(objectURL.Path, false)
+	return &objectURL
+}
+
+// 空请求体的sha256
+const s3EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// 为请求添加签名（Authorization请求头），签名host、x-amz-*及Content-Type、Range请求头
+func (s *s3Storage) sign(request *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	request.URL.RawQuery = s3CanonicalQuery(request.URL.Query())
+	canonicalRequest := strings.Join([]string{request.Method, request.URL.EscapedPath(), request.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+	signature := s.signature(amzDate, scope, canonicalRequest)
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// 生成带签名的临时访问地址，通过response-content-*参数指定响应头
+func (s *s3Storage) presign(key string, expire time.Duration, now time.Time, options StorageServeOptions) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+	objectURL := s.objectURL(key)
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expire.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if options.ContentType != "" {
+		query.Set("response-content-type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		query.Set("response-content-disposition", options.ContentDisposition)
+	}
+	objectURL.RawQuery = s3CanonicalQuery(query)
+	canonicalRequest := strings.Join([]string{http.MethodGet, objectURL.EscapedPath(), objectURL.RawQuery, "host:" + objectURL.Host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	objectURL.RawQuery += "&X-Amz-Signature=" + s.signature(amzDate, scope, canonicalRequest)
+	return objectURL.String()
+}
+
+// 计算签名
+func (s *s3Storage) signature(amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + s.secretKey)
+	for _, v := range []string{amzDate[:8], s.region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(v))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key)
+}
+
+// 按签名规则排序并编码查询参数
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Encode(key, true)+"="+s3Encode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// 按签名规则进行URI编码，除字母、数字和-_.~外全部编码，encodeSlash为false时保留/
+func s3Encode(s string, encodeSlash bool) string {
+	var builder strings.Builder
+	for _, b := range []byte(s) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' || b == '/' && !encodeSlash {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+// 对象存储返回的错误
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// 响应状态码不是2xx时返回错误
+func s3Error(response *http.Response) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	result := s3ErrorResponse{}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	xml.Unmarshal(body, &result)
+	if result.Code != "" {
+		return fmt.Errorf("对象存储请求失败：%s，%s：%s", response.Status, result.Code, result.Message)
+	}
+	return fmt.Errorf("对象存储请求失败：%s", response.Status)
+}
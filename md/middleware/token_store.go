@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"errors"
+	"md/dao"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"time"
+
+	"github.com/muesli/cache2go"
+)
+
+// token存储方式
+const (
+	TokenStoreDb     = "db"     // 数据库存储，重启后会话仍有效
+	TokenStoreMemory = "memory" // 内存存储，重启后需重新登录
+)
+
+// token不存在或已过期
+var ErrTokenNotFound = errors.New("token不存在或已过期")
+
+// token存储接口
+type TokenStore interface {
+	// 保存一组access token和refresh token
+	Save(tokenCache common.TokenCache, accessExpire, refreshExpire time.Duration) error
+	// 根据access token查询
+	GetByAccessToken(accessToken string) (common.TokenCache, error)
+	// 根据refresh token查询
+	GetByRefreshToken(refreshToken string) (common.TokenCache, error)
+	// 根据refresh token删除整组token，查询与删除为一个原子操作，token不存在或已被删除时返回ErrTokenNotFound，
+	// 同一refresh token并发刷新时只有一个请求成功
+	DeleteByRefreshToken(refreshToken string) error
+}
+
+// 当前使用的token存储
+var Tokens TokenStore
+
+// 初始化token存储
+func InitTokenStore(storeType string) error {
+	switch storeType {
+	case TokenStoreDb:
+		Tokens = &dbTokenStore{}
+		go cleanExpiredSession()
+	case TokenStoreMemory:
+		Tokens = &memoryTokenStore{}
+	default:
+		err := errors.New("不支持的会话存储方式：" + storeType)
+		Log.Error("初始化会话存储失败：", err)
+		return err
+	}
+	Log.Info("会话存储方式：", storeType)
+	return nil
+}
+
+// 内存存储
+type memoryTokenStore struct{}
+
+func (s *memoryTokenStore) Save(tokenCache common.TokenCache, accessExpire, refreshExpire time.Duration) error {
+	cache2go.Cache(common.AccessTokenCache).Add(tokenCache.AccessToken, accessExpire, &tokenCache)
+	cache2go.Cache(common.RefreshTokenCache).Add(tokenCache.RefreshToken, refreshExpire, &tokenCache)
+	return nil
+}
+
+func (s *memoryTokenStore) GetByAccessToken(accessToken string) (common.TokenCache, error) {
+	return s.get(common.AccessTokenCache, accessToken)
+}
+
+func (s *memoryTokenStore) GetByRefreshToken(refreshToken string) (common.TokenCache, error) {
+	return s.get(common.RefreshTokenCache, refreshToken)
+}
+
+func (s *memoryTokenStore) DeleteByRefreshToken(refreshToken string) error {
+	// 缓存表的Delete在同一把锁内查询并删除，只有一个调用方能删除成功
+	item, err := cache2go.Cache(common.RefreshTokenCache).Delete(refreshToken)
+	if err != nil {
+		return ErrTokenNotFound
+	}
+	tokenCache := item.Data().(*common.TokenCache)
+	if tokenCache.AccessToken != "" {
+		cache2go.Cache(common.AccessTokenCache).Delete(tokenCache.AccessToken)
+	}
+	if tokenCache.Id == "" {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) get(table, token string) (common.TokenCache, error) {
+	res, err := cache2go.Cache(table).Value(token)
+	if err != nil {
+		return common.TokenCache{}, ErrTokenNotFound
+	}
+	tokenCache := res.Data().(*common.TokenCache)
+	if tokenCache.Id == "" {
+		return common.TokenCache{}, ErrTokenNotFound
+	}
+	return *tokenCache, nil
+}
+
+// 数据库存储，token以sha256值保存
+type dbTokenStore struct{}
+
+func (s *dbTokenStore) Save(tokenCache common.TokenCache, accessExpire, refreshExpire time.Duration) error {
+	tx, err := DbW.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	session := entity.Session{
+		Id:                util.SnowflakeString(),
+		UserId:            tokenCache.Id,
+		Name:              tokenCache.Name,
+		AccessToken:       util.EncryptSHA256([]byte(tokenCache.AccessToken)),
+		RefreshToken:      util.EncryptSHA256([]byte(tokenCache.RefreshToken)),
+		AccessExpireTime:  now.Add(accessExpire).UnixMilli(),
+		RefreshExpireTime: now.Add(refreshExpire).UnixMilli(),
+		CreateTime:        now.UnixMilli(),
+	}
+	err = dao.SessionAdd(tx, session)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *dbTokenStore) GetByAccessToken(accessToken string) (common.TokenCache, error) {
+	session, err := dao.SessionGetByAccessToken(Db, util.EncryptSHA256([]byte(accessToken)), time.Now().UnixMilli())
+	if err != nil {
+		return common.TokenCache{}, ErrTokenNotFound
+	}
+	tokenCache := common.TokenCache{Id: session.UserId}
+	tokenCache.Name = session.Name
+	tokenCache.AccessToken = accessToken
+	return tokenCache, nil
+}
+
+func (s *dbTokenStore) GetByRefreshToken(refreshToken string) (common.TokenCache, error) {
+	session, err := dao.SessionGetByRefreshToken(Db, util.EncryptSHA256([]byte(refreshToken)), time.Now().UnixMilli())
+	if err != nil {
+		return common.TokenCache{}, ErrTokenNotFound
+	}
+	tokenCache := common.TokenCache{Id: session.UserId}
+	tokenCache.Name = session.Name
+	tokenCache.RefreshToken = refreshToken
+	return tokenCache, nil
+}
+
+func (s *dbTokenStore) DeleteByRefreshToken(refreshToken string) error {
+	tx, err := DbW.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := dao.SessionDeleteByRefreshToken(tx, util.EncryptSHA256([]byte(refreshToken)))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTokenNotFound
+	}
+	return tx.Commit()
+}
+
+// 定时清理已过期的会话
+func cleanExpiredSession() {
+	ticker := time.NewTicker(time.Hour)
+	for {
+		tx, err := DbW.Beginx()
+		if err == nil {
+			err = dao.SessionDeleteExpired(tx, time.Now().UnixMilli())
+			if err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+		}
+		if err != nil {
+			Log.Error("清理过期会话失败：", err)
+		}
+		<-ticker.C
+	}
+}
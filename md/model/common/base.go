@@ -26,6 +26,7 @@ type TokenResult struct {
 }
 
 type TokenCache struct {
-	Id string `json:"id"`
+	Id     string   `json:"id"`
+	Scopes []string `json:"scopes,omitempty"` // 个人访问令牌的权限，登录获取的令牌为空
 	TokenResult
 }
@@ -1,18 +1,46 @@
 package common
 
 var (
-	Port             string // 端口
-	LogPath          string // 日志目录
-	DataPath         string // 数据目录
-	Register         bool   // 允许注册
-	BasicTokenKey    string // token相关接口认证key前缀
-	ResourceName     string // 静态资源目录名，在数据目录下
-	PictureName      string // 图片目录名，在静态资源目录下
-	ThumbnailName    string // 缩略图目录名，在静态资源目录下
-	PostgresHost     string // postgres主机地址
-	PostgresPort     string // postgres端口
-	PostgresUser     string // postgres用户
-	PostgresPassword string // postgres密码
-	PostgresDB       string // postgres数据库名
-	AIEncryptKey     string // AI API Key加密密钥
+	Port             string   // 端口
+	LogPath          string   // 日志目录
+	DataPath         string   // 数据目录
+	Register         bool     // 允许注册
+	BasicTokenKey    string   // token相关接口认证key前缀
+	ResourceName     string   // 静态资源目录名，在数据目录下
+	PictureName      string   // 图片目录名，在静态资源目录下
+	ThumbnailName    string   // 缩略图目录名，在静态资源目录下
+	AttachmentName   string   // 附件目录名，在静态资源目录下
+	PostgresHost     string   // postgres主机地址
+	PostgresPort     string   // postgres端口
+	PostgresUser     string   // postgres用户
+	PostgresPassword string   // postgres密码
+	PostgresDB       string   // postgres数据库名
+	AIEncryptKey     string   // AI API Key加密密钥
+	AIPrivate        bool     // AI接口地址是否允许使用内网地址
+	SessionStore     string   // 会话存储方式：db/memory
+	DbType           string   // 当前使用的数据库类型：sqlite/postgres
+	PasswordMemory   uint     // 密码哈希（argon2id）内存开销，单位KiB
+	PasswordTime     uint     // 密码哈希（argon2id）迭代次数
+	PasswordThreads  uint     // 密码哈希（argon2id）并行度
+	Admin            string   // 管理员用户名，多个用逗号分隔
+	RevisionKeep     int      // 每个文档保留的历史版本数量，为0时全部保留
+	OpenApiStrict    bool     // 保存OpenAPI文档时是否拒绝未通过校验的内容
+	PictureWebPSize  int      // 上传图片超过此大小（KB）时转为WebP，为0时不转换
+	AttachmentTypes  string   // 允许上传的附件MIME类型，多个用逗号分隔，支持image/*形式
+	AttachmentSize   int      // 附件大小上限，单位MB
+	QuotaSize        int      // 每个用户的图片和附件总大小配额，单位MB，为0时不限制
+	QuotaDocuments   int      // 每个用户的文档数量配额，为0时不限制
+	QuotaTotalSize   int      // 全部图片和附件总大小配额，单位MB，为0时不限制
+	QuotaTotalDocs   int      // 全部文档数量配额，为0时不限制
+	StorageType      string   // 文件存储方式：local/s3
+	S3Endpoint       string   // 对象存储服务地址
+	S3Region         string   // 对象存储区域
+	S3Bucket         string   // 对象存储桶
+	S3AccessKey      string   // 对象存储Access Key
+	S3SecretKey      string   // 对象存储Secret Key
+	S3Prefix         string   // 对象键前缀
+	S3PathStyle      bool     // 是否使用路径形式访问对象存储
+	S3Serve          string   // 对象存储中文件的访问方式：proxy/redirect
+	Command          string   // 命令行子命令，为空时启动服务
+	CommandArgs      []string // 命令行子命令参数
 )
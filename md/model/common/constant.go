@@ -4,4 +4,10 @@ const (
 	AccessTokenCache  = "AccessToken"  // 缓存：AccessToken
 	RefreshTokenCache = "RefreshToken" // 缓存：RefreshToken
 	SignInTimesCache  = "SignInTimes"  // 缓存：登录次数
+	WsTicketCache     = "WsTicket"     // 缓存：WebSocket连接票据
+)
+
+const (
+	DbSqlite   = "sqlite"   // 数据库类型：sqlite
+	DbPostgres = "postgres" // 数据库类型：postgres
 )
@@ -4,6 +4,7 @@ const (
 	HttpSuccess     = 200 // 请求成功
 	HttpAuthFailure = 401 // 认证失败
 	HttpFailure     = 500 // 请求失败
+	HttpConflict    = 409 // 数据冲突
 )
 
 // 通用返回json数据结构体
@@ -15,9 +16,10 @@ type RestResponse struct {
 
 // 主动抛出异常结构体
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Err     error  `json:"-"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Err     error       `json:"-"`
 }
 
 // 填写成功信息
@@ -53,6 +55,15 @@ func NewErrorCode(code int, message string) ErrorResponse {
 	}
 }
 
+// 填写错误编号、错误信息、数据
+func NewErrorData(code int, message string, data interface{}) ErrorResponse {
+	return ErrorResponse{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	}
+}
+
 // 填写错误信息、error对象
 func NewErr(message string, err error) ErrorResponse {
 	return ErrorResponse{
@@ -35,3 +35,31 @@ type AIConfigCondition struct {
 	DocContextEnabled   bool           `json:"docContextEnabled"`
 	SyncEnabled         bool           `json:"syncEnabled"`
 }
+
+// ChatMessage 对话消息
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AIChatCondition AI对话请求条件
+type AIChatCondition struct {
+	Messages   []ChatMessage `json:"messages"`
+	Model      string        `json:"model"` // 为空时使用配置中的模型
+	DocTitle   string        `json:"docTitle"`
+	DocContent string        `json:"docContent"`
+}
+
+// AIModel 模型信息，与上游接口返回的字段一致
+type AIModel struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// AIChatDelta AI流式响应片段
+type AIChatDelta struct {
+	Content   string `json:"content,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
+}
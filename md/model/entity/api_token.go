@@ -0,0 +1,35 @@
+package entity
+
+// ApiToken 个人访问令牌实体，令牌仅保存sha256值
+type ApiToken struct {
+	Id           string `json:"id" db:"id"`
+	Name         string `json:"name" db:"name"`
+	Token        string `json:"-" db:"token"`
+	Prefix       string `json:"prefix" db:"prefix"` // 令牌开头几位，便于识别
+	Scopes       string `json:"scopes" db:"scopes"` // 权限，多个以逗号分隔
+	CreateTime   int64  `json:"createTime" db:"create_time"`
+	LastUsedTime int64  `json:"lastUsedTime" db:"last_used_time"`
+	ExpireTime   int64  `json:"expireTime" db:"expire_time"` // 过期时间，为0时永不过期
+	UserId       string `json:"userId" db:"user_id"`
+}
+
+type ApiTokenAddCondition struct {
+	Name       string          `json:"name"`
+	Scopes     []ApiTokenScope `json:"scopes"`
+	ExpireDays int             `json:"expireDays"` // 有效天数，为0时永不过期
+}
+
+// 添加后返回的令牌，明文仅返回一次
+type ApiTokenAddResult struct {
+	ApiToken
+	Token string `json:"token"`
+}
+
+type ApiTokenScope string
+
+const (
+	ScopeDocRead          ApiTokenScope = "doc:read"          // 权限：查看文集、文档
+	ScopeDocWrite         ApiTokenScope = "doc:write"         // 权限：添加、修改文档
+	ScopePictureUpload    ApiTokenScope = "pic:upload"        // 权限：上传图片
+	ScopeAttachmentUpload ApiTokenScope = "attachment:upload" // 权限：上传附件
+)
@@ -0,0 +1,18 @@
+package entity
+
+type Attachment struct {
+	Id         string `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"`
+	Path       string `json:"path" db:"path"`
+	Hash       string `json:"hash" db:"hash"`
+	Size       int64  `json:"size" db:"size"`
+	Mime       string `json:"mime" db:"mime"`
+	CreateTime int64  `json:"createTime" db:"create_time"`
+	UserId     string `json:"userId" db:"user_id"`
+}
+
+type AttachmentPageResult struct {
+	Attachment
+	AttachmentPrefix string `json:"attachmentPrefix"`
+	Url              string `json:"url"` // 下载地址，包含自己上传时的文件名
+}
@@ -0,0 +1,17 @@
+package entity
+
+// 备份清单
+type BackupManifest struct {
+	Version    int          `json:"version"`    // 备份格式版本
+	CreateTime int64        `json:"createTime"` // 备份时间
+	DbType     string       `json:"dbType"`     // 数据库类型：sqlite/postgres
+	DbVersion  int          `json:"dbVersion"`  // 数据库结构版本
+	Files      []BackupFile `json:"files"`      // 备份文件
+}
+
+// 备份文件
+type BackupFile struct {
+	Path   string `json:"path"`   // 备份包内路径
+	Size   int64  `json:"size"`   // 文件大小
+	Sha256 string `json:"sha256"` // 文件sha256校验码
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"encoding/json"
+	"md/util"
+)
+
+// 协同编辑消息
+type CollabMessage struct {
+	Type      CollabMessageType    `json:"type"`
+	ClientId  string               `json:"clientId,omitempty"`  // 发送操作的客户端
+	Revision  int                  `json:"revision"`            // 操作基于的版本（客户端发送）或操作后的版本（服务端发送）
+	Operation *util.TextOperation  `json:"operation,omitempty"` // 文本操作
+	Selection json.RawMessage      `json:"selection,omitempty"` // 光标、选区，格式由客户端约定
+	Content   string               `json:"content,omitempty"`   // 初始内容
+	Role      ShareRole            `json:"role,omitempty"`      // 当前用户的权限
+	Client    *CollabClient        `json:"client,omitempty"`    // 加入、离开、光标变化的客户端
+	Clients   []CollabClient       `json:"clients,omitempty"`   // 在线的客户端
+	Message   string               `json:"message,omitempty"`   // 错误信息
+	Document  *CollabSavedDocument `json:"document,omitempty"`  // 保存后的文档信息
+}
+
+// 协同编辑在线客户端
+type CollabClient struct {
+	ClientId  string          `json:"clientId"`
+	UserId    string          `json:"userId"`
+	Name      string          `json:"name"`
+	Role      ShareRole       `json:"role"`
+	Selection json.RawMessage `json:"selection,omitempty"`
+}
+
+// 协同编辑保存后的文档信息
+type CollabSavedDocument struct {
+	Revision   int   `json:"revision"`
+	UpdateTime int64 `json:"updateTime"`
+}
+
+type CollabMessageType string
+
+const (
+	CollabInit      CollabMessageType = "init"     // 服务端：加入后的初始状态
+	CollabOperation CollabMessageType = "op"       // 双向：文本操作
+	CollabAck       CollabMessageType = "ack"      // 服务端：操作已应用
+	CollabPresence  CollabMessageType = "presence" // 双向：光标、选区变化
+	CollabJoin      CollabMessageType = "join"     // 服务端：其他客户端加入
+	CollabLeave     CollabMessageType = "leave"    // 服务端：其他客户端离开
+	CollabSaved     CollabMessageType = "saved"    // 服务端：内容已保存
+	CollabError     CollabMessageType = "error"    // 服务端：错误
+)
@@ -10,6 +10,63 @@ type Document struct {
 	UpdateTime int64        `json:"updateTime" db:"update_time"`
 	BookId     string       `json:"bookId" db:"book_id"`
 	UserId     string       `json:"userId" db:"user_id"`
+	ParentId   string       `json:"parentId" db:"parent_id"`
+	Sort       int          `json:"sort" db:"sort"`
+	Revision   int          `json:"revision" db:"-"` // 最新的历史版本号，查询单个文档时返回，保存时可据此校验冲突
+}
+
+// 修改文档内容的条件，UpdateTime、Revision为编辑时基于的更新时间、历史版本号，填写时校验文档未被修改
+type DocumentContentCondition struct {
+	Id         string `json:"id"`
+	Content    string `json:"content"`
+	UpdateTime int64  `json:"updateTime"`
+	Revision   *int   `json:"revision"`
+	UserId     string `json:"-"`
+}
+
+type DocumentValidateCondition struct {
+	Id      string `json:"id"`      // 已保存的文档id，为空时校验content
+	Content string `json:"content"` // 待校验的内容
+	Strict  bool   `json:"strict"`  // 严格模式，存在警告时也视为不通过
+}
+
+// 比较OpenAPI文档的条件，新旧版本可为文档的当前内容或历史版本
+type DocumentOpenApiDiffCondition struct {
+	FromDocumentId string `json:"fromDocumentId"`
+	FromRevisionId string `json:"fromRevisionId"` // 不为空时优先使用历史版本
+	ToDocumentId   string `json:"toDocumentId"`
+	ToRevisionId   string `json:"toRevisionId"` // 新版本均为空时使用旧版本所属文档的当前内容
+}
+
+// 导出文档或文集的条件
+type DocumentExportCondition struct {
+	Id     string `json:"id"`     // 文档或文集id
+	Format string `json:"format"` // 导出格式：pdf、docx、epub
+}
+
+// 导入Markdown的结果
+type MarkdownImportResult struct {
+	Books         []Book   `json:"books"` // 创建的文集
+	FolderCount   int      `json:"folderCount"`
+	DocumentCount int      `json:"documentCount"`
+	PictureCount  int      `json:"pictureCount"`
+	Warnings      []string `json:"warnings"` // 未找到的链接、图片，跳过的文件等
+}
+
+type DocumentImportCondition struct {
+	BookId   string `json:"bookId"`
+	ParentId string `json:"parentId"`
+	Name     string `json:"name"` // 文档名称，为空时使用info.title
+	Main     string `json:"main"` // 上传多个文件时的入口文件路径，为空时自动识别
+	Url      string `json:"url"`  // 从URL导入
+}
+
+// 文档内容冲突时返回的服务器当前内容
+type DocumentConflictResult struct {
+	Id         string `json:"id"`
+	Content    string `json:"content"`
+	UpdateTime int64  `json:"updateTime"`
+	Revision   int    `json:"revision"`
 }
 
 type DocumentPageResult struct {
@@ -35,3 +92,21 @@ const (
 	DocMd      DocumentType = "md"      // 文档类型：markdown
 	DocOpenApi DocumentType = "openApi" // 文档类型：OpenApi
 )
+
+type DocumentSearchCondition struct {
+	Keyword string       `json:"keyword"`
+	BookId  string       `json:"bookId"`
+	Type    DocumentType `json:"type"`
+}
+
+type DocumentSearchResult struct {
+	Id            string       `json:"id" db:"id"`
+	Name          string       `json:"name" db:"name"`
+	Content       string       `json:"-" db:"content"`
+	Type          DocumentType `json:"type" db:"type"`
+	BookId        string       `json:"bookId" db:"book_id"`
+	UpdateTime    int64        `json:"updateTime" db:"update_time"`
+	Score         float64      `json:"score" db:"score"`
+	NameHighlight string       `json:"nameHighlight"`
+	Snippet       string       `json:"snippet"`
+}
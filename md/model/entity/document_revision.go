@@ -0,0 +1,39 @@
+package entity
+
+import "md/util"
+
+// DocumentRevision 文档历史版本实体
+type DocumentRevision struct {
+	Id         string `json:"id" db:"id"`
+	DocumentId string `json:"documentId" db:"document_id"`
+	Revision   int    `json:"revision" db:"revision"`
+	Content    string `json:"content" db:"content"`
+	CreateTime int64  `json:"createTime" db:"create_time"`
+	UserId     string `json:"userId" db:"user_id"`
+}
+
+// DocumentRevisionListItem 历史版本列表项（不含内容）
+type DocumentRevisionListItem struct {
+	Id         string `json:"id" db:"id"`
+	DocumentId string `json:"documentId" db:"document_id"`
+	Revision   int    `json:"revision" db:"revision"`
+	Size       int    `json:"size" db:"size"`
+	CreateTime int64  `json:"createTime" db:"create_time"`
+}
+
+// DocumentRevisionCondition 历史版本请求条件
+type DocumentRevisionCondition struct {
+	Id         string `json:"id"`
+	DocumentId string `json:"documentId"`
+	FromId     string `json:"fromId"`
+	ToId       string `json:"toId"` // 为空时与文档当前内容比较
+}
+
+// DocumentRevisionDiff 两个版本间的行级差异
+type DocumentRevisionDiff struct {
+	FromId      string          `json:"fromId"`
+	ToId        string          `json:"toId"`
+	InsertCount int             `json:"insertCount"`
+	DeleteCount int             `json:"deleteCount"`
+	Lines       []util.DiffLine `json:"lines"`
+}
@@ -0,0 +1,38 @@
+package entity
+
+type Folder struct {
+	Id         string `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"`
+	ParentId   string `json:"parentId" db:"parent_id"`
+	Sort       int    `json:"sort" db:"sort"`
+	CreateTime int64  `json:"createTime" db:"create_time"`
+	BookId     string `json:"bookId" db:"book_id"`
+	UserId     string `json:"userId" db:"user_id"`
+}
+
+type FolderMoveCondition struct {
+	Id       string   `json:"id"`
+	Kind     TreeKind `json:"kind"`     // 移动的节点类型
+	BookId   string   `json:"bookId"`   // 目标文集，仅文档可移动到其他文集，为空时为当前文集
+	ParentId string   `json:"parentId"` // 目标文件夹，为空时移动到文集根目录
+	Index    *int     `json:"index"`    // 在同类节点中的位置，为空时移动到末尾
+}
+
+type TreeKind string
+
+const (
+	TreeFolder   TreeKind = "folder" // 树节点类型：文件夹
+	TreeDocument TreeKind = "doc"    // 树节点类型：文档
+)
+
+type TreeNode struct {
+	Id         string       `json:"id"`
+	Name       string       `json:"name"`
+	Kind       TreeKind     `json:"kind"`
+	Type       DocumentType `json:"type,omitempty"`
+	Published  bool         `json:"published"`
+	Sort       int          `json:"sort"`
+	CreateTime int64        `json:"createTime"`
+	UpdateTime int64        `json:"updateTime,omitempty"`
+	Children   []TreeNode   `json:"children,omitempty"`
+}
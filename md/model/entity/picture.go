@@ -15,3 +15,54 @@ type PicturePageResult struct {
 	PicturePrefix   string `json:"picturePrefix"`
 	ThumbnailPrefix string `json:"thumbnailPrefix"`
 }
+
+type PicturePageCondition struct {
+	Name      string   `json:"name"`      // 原文件名，模糊匹配
+	StartTime int64    `json:"startTime"` // 上传时间起（毫秒时间戳），为0时不限制
+	EndTime   int64    `json:"endTime"`   // 上传时间止（毫秒时间戳），为0时不限制
+	MinSize   int64    `json:"minSize"`   // 最小文件大小（字节），为0时不限制
+	MaxSize   int64    `json:"maxSize"`   // 最大文件大小（字节），为0时不限制
+	Type      string   `json:"type"`      // 图片格式，即保存的文件后缀，如png、jpg，jpg、jpeg、jfif视为同一格式
+	Exts      []string `json:"-"`         // 由Type转换的文件后缀，包括同一格式的其他后缀
+	Sort      string   `json:"sort"`      // 排序字段：createTime、name、size，默认createTime
+	Asc       bool     `json:"asc"`       // 是否升序，默认降序
+}
+
+type PictureDeleteCondition struct {
+	Id    string `json:"id"`
+	Force bool   `json:"force"` // 图片仍被文档引用时是否删除
+}
+
+// 引用图片的文档，其他用户的文档只返回是否为其他用户
+type PictureReference struct {
+	Id         string `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"`
+	BookId     string `json:"bookId" db:"book_id"`
+	BookName   string `json:"bookName" db:"book_name"`
+	UpdateTime int64  `json:"updateTime" db:"update_time"`
+	UserId     string `json:"-" db:"user_id"`
+	Other      bool   `json:"other" db:"-"` // 是否为其他用户的文档
+}
+
+type PictureScanCondition struct {
+	Purge     bool `json:"purge"`     // 是否清理
+	GraceDays int  `json:"graceDays"` // 宽限天数，仅清理早于此天数的文件和记录
+}
+
+type PictureScanFile struct {
+	Dir     string `json:"dir"`     // 所在目录：picture、thumbnail
+	Name    string `json:"name"`    // 文件名
+	Size    int64  `json:"size"`    // 文件大小
+	ModTime int64  `json:"modTime"` // 修改时间
+}
+
+type PictureScanResult struct {
+	OrphanFiles        []PictureScanFile `json:"orphanFiles"`        // 没有图片记录的文件
+	MissingFiles       []Picture         `json:"missingFiles"`       // 图片文件不存在的记录
+	MissingThumbnails  []Picture         `json:"missingThumbnails"`  // 缩略图不存在的记录
+	Unreferenced       []Picture         `json:"unreferenced"`       // 未被任何文档（包括历史版本）引用的记录
+	Purged             bool              `json:"purged"`             // 是否已清理
+	PurgedFiles        int               `json:"purgedFiles"`        // 删除的文件数量
+	PurgedRecords      int               `json:"purgedRecords"`      // 删除的记录数量
+	RepairedThumbnails int               `json:"repairedThumbnails"` // 重新生成的缩略图数量
+}
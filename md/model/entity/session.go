@@ -0,0 +1,13 @@
+package entity
+
+// Session 登录会话实体，token仅保存sha256值
+type Session struct {
+	Id                string `json:"id" db:"id"`
+	UserId            string `json:"userId" db:"user_id"`
+	Name              string `json:"name" db:"name"`
+	AccessToken       string `json:"-" db:"access_token"`
+	RefreshToken      string `json:"-" db:"refresh_token"`
+	AccessExpireTime  int64  `json:"accessExpireTime" db:"access_expire_time"`
+	RefreshExpireTime int64  `json:"refreshExpireTime" db:"refresh_expire_time"`
+	CreateTime        int64  `json:"createTime" db:"create_time"`
+}
@@ -0,0 +1,45 @@
+package entity
+
+type Share struct {
+	Id           string            `json:"id" db:"id"`
+	ResourceType ShareResourceType `json:"resourceType" db:"resource_type"`
+	ResourceId   string            `json:"resourceId" db:"resource_id"`
+	Role         ShareRole         `json:"role" db:"role"`
+	CreateTime   int64             `json:"createTime" db:"create_time"`
+	OwnerId      string            `json:"ownerId" db:"owner_id"`
+	UserId       string            `json:"userId" db:"user_id"`
+}
+
+type ShareAddCondition struct {
+	ResourceType ShareResourceType `json:"resourceType"`
+	ResourceId   string            `json:"resourceId"`
+	UserName     string            `json:"userName"` // 共享给的用户名
+	Role         ShareRole         `json:"role"`
+}
+
+type ShareListResult struct {
+	Share
+	UserName string `json:"userName" db:"user_name"`
+}
+
+type SharedWithMeResult struct {
+	Share
+	Name      string       `json:"name" db:"name"`
+	Type      DocumentType `json:"type" db:"type"`
+	OwnerName string       `json:"ownerName" db:"owner_name"`
+}
+
+type ShareResourceType string
+
+const (
+	ShareBook     ShareResourceType = "book" // 共享类型：文集
+	ShareDocument ShareResourceType = "doc"  // 共享类型：文档
+)
+
+type ShareRole string
+
+const (
+	ShareOwner  ShareRole = "owner"  // 权限：所有者
+	ShareEditor ShareRole = "editor" // 权限：可编辑
+	ShareViewer ShareRole = "viewer" // 权限：仅查看
+)
@@ -0,0 +1,30 @@
+package entity
+
+// 图片或附件的用量，同一用户的相同文件只有一条记录
+type FileUsage struct {
+	Count      int   `json:"count" db:"count"`            // 文件数量
+	Size       int64 `json:"size" db:"size"`              // 文件总大小，计入配额
+	SharedSize int64 `json:"sharedSize" db:"shared_size"` // 其中与其他用户共用（内容相同只保存一份）的文件大小
+	FairSize   int64 `json:"fairSize" db:"fair_size"`     // 共用的文件按用户数分摊后的大小，即实际占用的存储空间
+}
+
+// 用量及配额，配额为0时不限制
+type UsageQuota struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// 全部用户的用量，文件大小按实际保存的文件计算
+type GlobalUsage struct {
+	Size      UsageQuota `json:"size"`      // 图片和附件大小（字节）
+	Documents UsageQuota `json:"documents"` // 文档数量
+}
+
+// 用户的用量及配额
+type UserUsage struct {
+	Pictures    FileUsage    `json:"pictures"`         // 图片
+	Attachments FileUsage    `json:"attachments"`      // 附件
+	Size        UsageQuota   `json:"size"`             // 图片和附件大小（字节）
+	Documents   UsageQuota   `json:"documents"`        // 文档数量
+	Global      *GlobalUsage `json:"global,omitempty"` // 全部用户的用量，仅管理员可见
+}
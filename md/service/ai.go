@@ -60,22 +60,34 @@ func AIConfigSave(userId string, condition entity.AIConfigCondition) {
 			panic(common.NewErr("加密失败", err))
 		}
 		apiKey = encrypted
-	} else if isMaskedKey(apiKey) {
-		// 保持原有的API Key
+	}
+
+	// 校验接口地址
+	baseUrl := strings.TrimRight(strings.TrimSpace(condition.BaseUrl), "/")
+	if isMaskedKey(apiKey) {
+		// 保持原有的API Key，已保存的Key只可用于原接口地址
 		existing, err := dao.AIConfigGetByUserId(tx, userId)
 		if err == nil {
 			apiKey = existing.ApiKey
+			if apiKey != "" && baseUrl != "" && baseUrl != existing.BaseUrl {
+				panic(common.NewError(errAIBaseUrlChanged.Error()))
+			}
 		} else {
 			apiKey = ""
 		}
 	}
+	if baseUrl != "" {
+		if err := aiCheckBaseUrl(baseUrl); err != nil {
+			panic(common.NewErr(err.Error(), err))
+		}
+	}
 
 	// 序列化系统提示词
 	promptsJson, _ := json.Marshal(condition.SystemPrompts)
 
 	config := entity.AIConfig{
 		UserId:              userId,
-		BaseUrl:             strings.TrimSuffix(condition.BaseUrl, "/"),
+		BaseUrl:             baseUrl,
 		ApiKey:              apiKey,
 		Model:               condition.Model,
 		SystemPrompts:       string(promptsJson),
@@ -127,46 +139,6 @@ func AIConfigExists(userId string) bool {
 	return exists
 }
 
-// 获取AI配置（完整版，包含解密后的API Key，仅用于同步）
-func AIConfigGetFull(userId string) entity.AIConfigCondition {
-	config, err := dao.AIConfigGetByUserId(middleware.Db, userId)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return entity.AIConfigCondition{SystemPrompts: []entity.SystemPrompt{}}
-		}
-		panic(common.NewErr("查询失败", err))
-	}
-
-	// 解析系统提示词
-	var prompts []entity.SystemPrompt
-	if config.SystemPrompts != "" && config.SystemPrompts != "[]" {
-		json.Unmarshal([]byte(config.SystemPrompts), &prompts)
-	}
-	if prompts == nil {
-		prompts = []entity.SystemPrompt{}
-	}
-
-	// 解密API Key
-	apiKey := ""
-	if config.ApiKey != "" {
-		decrypted, err := util.DecryptAES(config.ApiKey, common.AIEncryptKey, false)
-		if err == nil {
-			apiKey = decrypted
-		}
-	}
-
-	return entity.AIConfigCondition{
-		BaseUrl:             config.BaseUrl,
-		ApiKey:              apiKey,
-		Model:               config.Model,
-		SystemPrompts:       prompts,
-		CurrentPromptId:     config.CurrentPromptId,
-		SystemPromptEnabled: config.SystemPromptEnabled,
-		DocContextEnabled:   config.DocContextEnabled,
-		SyncEnabled:         config.SyncEnabled,
-	}
-}
-
 // API Key脱敏
 func maskApiKey(key string) string {
 	if key == "" {
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 流式请求不设置整体超时，由请求上下文控制取消；建立连接时校验目标地址，默认禁止访问内网
+var aiHttpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   aiDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	},
+}
+
+// 接口地址为内网地址
+var errAIPrivateAddress = errors.New("AI接口地址不可为内网地址")
+
+// 修改接口地址时未填写API Key，已保存的Key不可发送到其他地址
+var errAIBaseUrlChanged = errors.New("修改 Base URL 后请重新填写 API Key")
+
+// 上游流式响应片段
+type aiStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// AI对话代理：使用服务器保存的配置请求上游接口，流式返回的片段依次交给onDelta处理
+// 开始接收上游数据之前的错误直接抛出，之后的错误通过返回值告知调用方
+func AIChat(ctx context.Context, userId string, condition entity.AIChatCondition, onDelta func(entity.AIChatDelta) error) error {
+	if len(condition.Messages) == 0 {
+		panic(common.NewError("消息不可为空"))
+	}
+	for _, v := range condition.Messages {
+		if v.Role != "user" && v.Role != "assistant" && v.Role != "system" {
+			panic(common.NewError("不支持的消息角色：" + v.Role))
+		}
+	}
+
+	config, apiKey := aiConfigKey(userId)
+	model := condition.Model
+	if model == "" {
+		model = config.Model
+	}
+	if model == "" {
+		panic(common.NewError("请先选择模型"))
+	}
+
+	// 构建请求
+	messages := condition.Messages
+	if systemContent := aiSystemContent(config, condition); systemContent != "" {
+		messages = append([]entity.ChatMessage{{Role: "system", Content: systemContent}}, messages...)
+	}
+	return aiChatStream(ctx, config.BaseUrl, apiKey, model, messages, onDelta)
+}
+
+// 请求上游对话接口并解析流式响应，开始接收上游数据之前的错误直接抛出
+func aiChatStream(ctx context.Context, baseUrl, apiKey, model string, messages []entity.ChatMessage, onDelta func(entity.AIChatDelta) error) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   true,
+	})
+	if err != nil {
+		panic(common.NewErr("AI请求失败", err))
+	}
+	request, err := aiRequest(ctx, http.MethodPost, baseUrl, "/v1/chat/completions", apiKey, bytes.NewReader(body))
+	if err != nil {
+		panic(common.NewErr("AI请求失败："+err.Error(), err))
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "text/event-stream")
+
+	response, err := aiHttpClient.Do(request)
+	if err != nil {
+		if errors.Is(err, errAIPrivateAddress) {
+			panic(common.NewErr(errAIPrivateAddress.Error(), err))
+		}
+		panic(common.NewErr("AI请求失败", err))
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		panic(common.NewError(fmt.Sprintf("AI请求失败 (%d): %s", response.StatusCode, strings.TrimSpace(string(text)))))
+	}
+
+	// 逐行解析上游SSE
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+		chunk := aiStreamChunk{}
+		if json.Unmarshal([]byte(data), &chunk) != nil || len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := entity.AIChatDelta{Content: chunk.Choices[0].Delta.Content, Reasoning: chunk.Choices[0].Delta.ReasoningContent}
+		if delta.Content == "" && delta.Reasoning == "" {
+			continue
+		}
+		if err = onDelta(delta); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.New("AI响应意外中断")
+}
+
+// 构建系统提示词，与前端直连时的规则保持一致
+func aiSystemContent(config entity.AIConfig, condition entity.AIChatCondition) string {
+	var content strings.Builder
+	if config.SystemPromptEnabled {
+		var prompts []entity.SystemPrompt
+		json.Unmarshal([]byte(config.SystemPrompts), &prompts)
+		for _, v := range prompts {
+			if v.Id == config.CurrentPromptId {
+				content.WriteString(v.Content)
+				break
+			}
+		}
+	}
+
+	if config.DocContextEnabled && condition.DocContent != "" {
+		content.WriteString("\n\n---\n## 文档上下文\n\n")
+		content.WriteString("以下是用户当前正在编辑的文档内容，仅供参考。\n")
+		content.WriteString("**重要**：只有当用户明确提及文档、询问文档相关问题、或请求对文档进行操作时，你才应该引用或分析文档内容。")
+		content.WriteString("对于普通对话（如打招呼、闲聊、与文档无关的问题），请正常回复，不要主动提及或评价文档内容。\n\n")
+		if condition.DocTitle != "" {
+			content.WriteString("文档标题：" + condition.DocTitle + "\n")
+		}
+		content.WriteString("文档内容：\n```markdown\n" + condition.DocContent + "\n```")
+	}
+	return content.String()
+}
+
+// 查询模型列表，未填写或为脱敏的API Key时使用已保存的Key，
+// 已保存的Key只发送到已保存的接口地址，使用其他地址时需填写完整的Key
+func AIModels(condition entity.AIConfigCondition, userId string) []entity.AIModel {
+	baseUrl := strings.TrimRight(strings.TrimSpace(condition.BaseUrl), "/")
+	apiKey := condition.ApiKey
+	if isMaskedKey(apiKey) {
+		config, key := aiConfigKey(userId)
+		if baseUrl != "" && baseUrl != config.BaseUrl {
+			panic(common.NewError(errAIBaseUrlChanged.Error()))
+		}
+		apiKey = key
+		baseUrl = config.BaseUrl
+	}
+	if baseUrl == "" {
+		panic(common.NewError("请先填写 Base URL"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	request, err := aiRequest(ctx, http.MethodGet, baseUrl, "/v1/models", apiKey, nil)
+	if err != nil {
+		panic(common.NewErr(err.Error(), err))
+	}
+	response, err := aiHttpClient.Do(request)
+	if err != nil {
+		if errors.Is(err, errAIPrivateAddress) {
+			panic(common.NewErr(errAIPrivateAddress.Error(), err))
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			panic(common.NewErr("请求超时，请检查网络或代理设置", err))
+		}
+		panic(common.NewErr("请求失败，请检查 Base URL", err))
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		panic(common.NewError("API Key 无效或无权限"))
+	case http.StatusNotFound:
+		panic(common.NewError("Base URL 不正确或不兼容"))
+	case http.StatusTooManyRequests:
+		panic(common.NewError("请求频率过高或额度不足"))
+	default:
+		text, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		panic(common.NewError(fmt.Sprintf("上游错误 (%d): %s", response.StatusCode, strings.TrimSpace(string(text)))))
+	}
+
+	result := struct {
+		Data []entity.AIModel `json:"data"`
+	}{}
+	err = json.NewDecoder(io.LimitReader(response.Body, 16*1024*1024)).Decode(&result)
+	if err != nil {
+		panic(common.NewErr("模型列表解析失败", err))
+	}
+	if result.Data == nil {
+		result.Data = []entity.AIModel{}
+	}
+	return result.Data
+}
+
+// 查询配置并解密API Key
+func aiConfigKey(userId string) (entity.AIConfig, string) {
+	config, err := dao.AIConfigGetByUserId(middleware.Db, userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("请先配置AI API"))
+		}
+		panic(common.NewErr("查询失败", err))
+	}
+	if config.BaseUrl == "" || config.ApiKey == "" {
+		panic(common.NewError("请先配置AI API"))
+	}
+	apiKey, err := util.DecryptAES(config.ApiKey, common.AIEncryptKey, false)
+	if err != nil {
+		panic(common.NewErr("API Key解密失败", err))
+	}
+	return config, apiKey
+}
+
+// 创建上游请求，path拼接在去除末尾斜杠的接口地址之后
+func aiRequest(ctx context.Context, method, baseUrl, path, apiKey string, body io.Reader) (*http.Request, error) {
+	if err := aiCheckBaseUrl(baseUrl); err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(baseUrl, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+apiKey)
+	return request, nil
+}
+
+// 校验接口地址：仅支持http、https，未开启ai_private时解析域名并拒绝内网地址
+func aiCheckBaseUrl(baseUrl string) error {
+	u, err := url.Parse(baseUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("Base URL 格式不正确，应以 http:// 或 https:// 开头")
+	}
+	if u.User != nil {
+		return errors.New("Base URL 不可包含用户信息")
+	}
+	if common.AIPrivate {
+		return nil
+	}
+	err = util.CheckPublicHost(u.Hostname(), errAIPrivateAddress)
+	if err != nil && err != errAIPrivateAddress {
+		return errors.New("无法解析 Base URL 的域名")
+	}
+	return err
+}
+
+// 建立连接前校验实际连接的地址，未开启ai_private时拒绝内网地址
+var aiDialControl = util.PublicDialControl(func() bool { return common.AIPrivate }, errAIPrivateAddress)
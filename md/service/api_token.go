@@ -0,0 +1,105 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 每个用户最多可创建的个人访问令牌数量
+const maxApiTokenCount = 50
+
+// 创建个人访问令牌，明文令牌仅在创建时返回
+func ApiTokenAdd(condition entity.ApiTokenAddCondition, userId string) entity.ApiTokenAddResult {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	name := strings.TrimSpace(condition.Name)
+	if name == "" {
+		panic(common.NewError("令牌名称不可为空"))
+	}
+	if util.StringLength(name) > 100 {
+		panic(common.NewError("令牌名称过长，请小于100个字符"))
+	}
+	scopes := []string{}
+	for _, v := range condition.Scopes {
+		if v != entity.ScopeDocRead && v != entity.ScopeDocWrite && v != entity.ScopePictureUpload && v != entity.ScopeAttachmentUpload {
+			panic(common.NewError("不支持的权限：" + string(v)))
+		}
+		if !slices.Contains(scopes, string(v)) {
+			scopes = append(scopes, string(v))
+		}
+	}
+	if len(scopes) == 0 {
+		panic(common.NewError("请选择令牌权限"))
+	}
+	if condition.ExpireDays < 0 || condition.ExpireDays > 3650 {
+		panic(common.NewError("有效天数需在0到3650之间"))
+	}
+
+	count, err := dao.ApiTokenCount(tx, userId)
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+	if count >= maxApiTokenCount {
+		panic(common.NewError("令牌数量已达上限"))
+	}
+
+	random, err := util.RandomSecureHex(32)
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+	token := middleware.ApiTokenPrefix + random
+	apiToken := entity.ApiToken{
+		Id:         util.SnowflakeString(),
+		Name:       name,
+		Token:      util.EncryptSHA256([]byte(token)),
+		Prefix:     token[:len(middleware.ApiTokenPrefix)+8],
+		Scopes:     strings.Join(scopes, ","),
+		CreateTime: time.Now().UnixMilli(),
+		UserId:     userId,
+	}
+	if condition.ExpireDays > 0 {
+		apiToken.ExpireTime = time.Now().AddDate(0, 0, condition.ExpireDays).UnixMilli()
+	}
+	err = dao.ApiTokenAdd(tx, apiToken)
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("创建失败", err))
+	}
+	return entity.ApiTokenAddResult{ApiToken: apiToken, Token: token}
+}
+
+// 撤销个人访问令牌
+func ApiTokenDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.ApiTokenDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("撤销失败", err))
+	}
+}
+
+// 查询个人访问令牌列表
+func ApiTokenList(userId string) []entity.ApiToken {
+	list, err := dao.ApiTokenList(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return list
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 附件文件名中保留的后缀
+var attachmentExtRegex = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// 分页查询附件记录
+func AttachmentPage(pageCondition common.PageCondition[interface{}], userId string) common.PageResult[entity.AttachmentPageResult] {
+	attachments, total, err := dao.AttachmentPage(middleware.Db, pageCondition.Page, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	attachmentPageResults := []entity.AttachmentPageResult{}
+	for _, v := range attachments {
+		attachmentPageResults = append(attachmentPageResults, entity.AttachmentPageResult{Attachment: v, AttachmentPrefix: "/" + common.ResourceName + "/" + common.AttachmentName + "/", Url: attachmentUrl(v.Path, v.Name)})
+	}
+	return common.PageResult[entity.AttachmentPageResult]{Records: attachmentPageResults, Total: total}
+}
+
+// 删除附件
+func AttachmentDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	// 查询附件
+	attachment, err := dao.AttachmentGetById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 删除记录
+	err = dao.AttachmentDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 没有记录再使用该文件时删除文件
+	dedupDelete(func() (common.CountResult, error) { return dao.AttachmentCountByPath(middleware.Db, attachment.Path) },
+		common.AttachmentName+"/"+attachment.Path)
+}
+
+// 上传附件，类型根据文件内容识别，相同大小和校验码的附件只保存一份文件，返回附件地址和提示信息
+func AttachmentUpload(file multipart.File, info *multipart.FileHeader, userId string) (string, string) {
+	// 校验文件大小
+	if info.Size == 0 {
+		panic(common.NewError("附件解析失败"))
+	}
+	if info.Size > int64(common.AttachmentSize)*1000*1000 {
+		panic(common.NewError(fmt.Sprintf("附件大小不可超过%dMB", common.AttachmentSize)))
+	}
+	if util.StringLength(info.Filename) > 1000 {
+		panic(common.NewError("附件文件名称过长"))
+	}
+
+	// 识别类型
+	mimeType := util.DetectMime(file, info.Size, info.Filename)
+	if !util.MimeMatch(mimeType, strings.Split(common.AttachmentTypes, ",")) {
+		panic(common.NewError("不支持的附件类型：" + mimeType))
+	}
+
+	// 生成sha256校验码
+	sha256Str, err := util.EncryptSHA256Reader(io.NewSectionReader(file, 0, info.Size))
+	if err != nil {
+		panic(common.NewErr("附件解析失败", err))
+	}
+
+	// 查询相同大小和校验码的文件
+	attachments, err := dao.AttachmentBySizeHash(middleware.Db, info.Size, sha256Str)
+	if err != nil {
+		panic(common.NewErr("附件上传失败", err))
+	}
+
+	records := []dedupRecord{}
+	for _, v := range attachments {
+		records = append(records, dedupRecord{Path: v.Path, UserId: v.UserId})
+	}
+
+	// 生成文件名，后缀仅保留字母和数字
+	filename := util.SnowflakeString()
+	if ext := util.FileExt(info.Filename); attachmentExtRegex.MatchString(ext) {
+		filename += ext
+	}
+
+	// 无相同文件时保存文件
+	filename, needAddRecord := dedupSave(records, info.Size, filename, userId, func(filename string) {
+		err := middleware.Storage.Put(common.AttachmentName+"/"+filename, io.NewSectionReader(file, 0, info.Size), info.Size)
+		if err != nil {
+			panic(common.NewErr("附件上传失败", err))
+		}
+	})
+	message := "上传成功"
+	if !needAddRecord {
+		message = "附件已存在"
+	}
+
+	// 添加记录
+	if needAddRecord {
+		tx := middleware.DbW.MustBegin()
+		defer tx.Rollback()
+		dedupCheckQuota(tx, records, info.Size, userId, common.AttachmentName+"/"+filename)
+		attachment := entity.Attachment{}
+		attachment.Id = util.SnowflakeString()
+		attachment.CreateTime = time.Now().UnixMilli()
+		attachment.Name = info.Filename
+		attachment.Path = filename
+		attachment.Hash = sha256Str
+		attachment.Size = info.Size
+		attachment.Mime = mimeType
+		attachment.UserId = userId
+		err = dao.AttachmentAdd(tx, attachment)
+		if err != nil {
+			panic(common.NewErr("附件上传失败", err))
+		}
+		err = tx.Commit()
+		if err != nil {
+			panic(common.NewErr("附件上传失败", err))
+		}
+	}
+
+	return attachmentUrl(filename, info.Filename), message
+}
+
+// 附件地址，相同文件被多个用户上传时共用一份文件，下载时的文件名由name参数指定
+func attachmentUrl(path, name string) string {
+	return "/" + common.ResourceName + "/" + common.AttachmentName + "/" + path + "?name=" + url.QueryEscape(name)
+}
+
+// 下载附件时的响应头：识别的类型和文件名，不存在时返回false。
+// 文件名使用地址中的name参数，后缀须与文件一致，否则使用存储的文件名，不使用其他用户上传时的文件名
+func AttachmentServeOptions(path, name string) (middleware.StorageServeOptions, bool) {
+	mime, err := dao.AttachmentMimeByPath(middleware.Db, path)
+	if err != nil {
+		return middleware.StorageServeOptions{}, false
+	}
+	filename := path
+	if name != "" && util.StringLength(name) <= 1000 && util.FileExt(name) == util.FileExt(path) {
+		filename = name
+	}
+	return middleware.StorageServeOptions{
+		ContentType:        mime,
+		ContentDisposition: "attachment; filename*=UTF-8''" + url.PathEscape(filename),
+	}, true
+}
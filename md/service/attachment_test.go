@@ -0,0 +1,78 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 上传文本附件，返回地址
+func attachmentTestUpload(t *testing.T, content, name, userId string) string {
+	t.Helper()
+	path := t.TempDir() + "/upload"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	attachmentUrl, _ := AttachmentUpload(file, &multipart.FileHeader{Filename: name, Size: int64(len(content))}, userId)
+	return attachmentUrl
+}
+
+// 相同文件只保存一份，下载时使用各自上传时的文件名，删除最后一条记录时删除文件
+func TestAttachmentDedup(t *testing.T) {
+	testInitDb(t)
+	types, size := common.AttachmentTypes, common.AttachmentSize
+	common.AttachmentTypes, common.AttachmentSize = "text/plain", 1
+	t.Cleanup(func() { common.AttachmentTypes, common.AttachmentSize = types, size })
+	alice, bob := testAddUser(t, "alice"), testAddUser(t, "bob")
+
+	aliceUrl := attachmentTestUpload(t, "same content", "alice-secret.txt", alice)
+	bobUrl := attachmentTestUpload(t, "same content", "bob.txt", bob)
+	aliceParsed, _ := url.Parse(aliceUrl)
+	bobParsed, _ := url.Parse(bobUrl)
+	if aliceParsed.Path != bobParsed.Path {
+		t.Fatalf("相同文件应共用：%s %s", aliceUrl, bobUrl)
+	}
+	path := strings.TrimPrefix(bobParsed.Path, "/"+common.ResourceName+"/"+common.AttachmentName+"/")
+
+	// 下载时不使用其他用户的文件名
+	options, ok := AttachmentServeOptions(path, bobParsed.Query().Get("name"))
+	if !ok || options.ContentDisposition != "attachment; filename*=UTF-8''bob.txt" || options.ContentType != "text/plain" {
+		t.Errorf("下载响应头错误：%+v", options)
+	}
+	for _, name := range []string{"", "bob.html"} {
+		options, _ = AttachmentServeOptions(path, name)
+		if options.ContentDisposition != "attachment; filename*=UTF-8''"+path {
+			t.Errorf("name为%q时应使用存储的文件名：%+v", name, options)
+		}
+	}
+	if _, ok := AttachmentServeOptions("none.txt", ""); ok {
+		t.Error("不存在的附件应返回false")
+	}
+
+	// 删除一条记录后文件仍在
+	key := common.AttachmentName + "/" + path
+	for i, userId := range []string{alice, bob} {
+		attachments, _, err := dao.AttachmentPage(middleware.Db, common.Page{Current: 1, Size: 10}, userId)
+		if err != nil || len(attachments) != 1 {
+			t.Fatal(attachments, err)
+		}
+		AttachmentDelete(attachments[0].Id, userId)
+		_, err = middleware.StorageReadAll(key)
+		if i == 0 && err != nil {
+			t.Fatal("仍有记录时不应删除文件：", err)
+		}
+		if i == 1 && err == nil {
+			t.Fatal("删除最后一条记录时应删除文件")
+		}
+	}
+}
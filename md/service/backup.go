@@ -0,0 +1,535 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 备份格式版本
+const backupVersion = 1
+
+// 备份包内的文件路径
+const (
+	backupManifestName = "manifest.json"  // 清单
+	backupDatabaseDir  = "database/"      // 数据库目录
+	backupSqliteName   = "database/md.db" // sqlite数据库快照
+)
+
+// 备份中的字段名
+var backupColumnRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// 备份全部数据（数据库及图片）为zip，服务运行时也可执行
+func Backup(w io.Writer) {
+	manifest := entity.BackupManifest{
+		Version:    backupVersion,
+		CreateTime: time.Now().UnixMilli(),
+		DbType:     common.DbType,
+		DbVersion:  middleware.LatestVersion(),
+		Files:      []entity.BackupFile{},
+	}
+	zipWriter := zip.NewWriter(w)
+
+	// 数据库，同时取得与数据库一致的图片、附件列表
+	var keys []string
+	var err error
+	if common.DbType == common.DbPostgres {
+		keys, err = backupPostgres(zipWriter, &manifest)
+	} else {
+		keys, err = backupSqlite(zipWriter, &manifest)
+	}
+	if err != nil {
+		panic(common.NewErr("备份数据库失败", err))
+	}
+
+	// 图片、缩略图及附件，文件不存在时跳过
+	for _, key := range keys {
+		name := common.ResourceName + "/" + key
+		file, err := middleware.Storage.Open(key)
+		if err != nil {
+			middleware.Log.Warn("备份时读取文件失败：", err)
+			continue
+		}
+		err = backupWriteFile(zipWriter, &manifest, name, zip.Store, func(w io.Writer) error {
+			_, err := io.Copy(w, file)
+			return err
+		})
+		file.Close()
+		if err != nil {
+			panic(common.NewErr("备份文件失败", err))
+		}
+	}
+
+	// 清单
+	manifestByte, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		panic(common.NewErr("备份失败", err))
+	}
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: backupManifestName, Method: zip.Deflate, Modified: time.Now()})
+	if err == nil {
+		_, err = writer.Write(manifestByte)
+	}
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if err != nil {
+		panic(common.NewErr("备份失败", err))
+	}
+}
+
+// 备份到数据目录下的临时文件，返回文件路径，使用后需删除
+func BackupTempFile() string {
+	file, err := os.CreateTemp(common.DataPath, "backup-*.zip")
+	if err != nil {
+		panic(common.NewErr("备份失败", err))
+	}
+	defer file.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			file.Close()
+			os.Remove(file.Name())
+			panic(r)
+		}
+	}()
+	Backup(file)
+	err = file.Close()
+	if err != nil {
+		panic(common.NewErr("备份失败", err))
+	}
+	return file.Name()
+}
+
+// sqlite使用VACUUM INTO生成一致的在线快照
+func backupSqlite(zipWriter *zip.Writer, manifest *entity.BackupManifest) ([]string, error) {
+	snapshot := common.DataPath + "backup-" + util.SnowflakeString() + ".db"
+	defer os.Remove(snapshot)
+	_, err := middleware.Db.Exec(`vacuum into $1`, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// 图片、附件列表从快照中查询，保证与数据库一致
+	db, err := sqlx.Connect("sqlite", snapshot)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := backupResourceKeys(db)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	err = backupWriteFile(zipWriter, manifest, backupSqliteName, zip.Deflate, func(w io.Writer) error {
+		_, err := io.Copy(w, file)
+		return err
+	})
+	return keys, err
+}
+
+// postgres在可重复读的只读事务中逐表导出为JSON Lines
+func backupPostgres(zipWriter *zip.Writer, manifest *entity.BackupManifest) ([]string, error) {
+	tx, err := middleware.Db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range middleware.DataTables {
+		err = backupWriteFile(zipWriter, manifest, backupDatabaseDir+table+".jsonl", zip.Deflate, func(w io.Writer) error {
+			rows, err := tx.Queryx("select * from " + table)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			encoder := json.NewEncoder(w)
+			for rows.Next() {
+				row := map[string]interface{}{}
+				err = rows.MapScan(row)
+				if err != nil {
+					return err
+				}
+				for key, value := range row {
+					if v, ok := value.([]byte); ok {
+						row[key] = string(v)
+					}
+				}
+				err = encoder.Encode(row)
+				if err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return backupResourceKeys(tx)
+}
+
+// 数据库中记录的图片、缩略图及附件在文件存储中的路径
+func backupResourceKeys(db interface{}) ([]string, error) {
+	keys := []string{}
+	paths, err := dao.PicturePathList(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if filepath.Base(path) == path {
+			keys = append(keys, common.PictureName+"/"+path, common.ThumbnailName+"/"+path)
+		}
+	}
+	paths, err = dao.AttachmentPathList(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if filepath.Base(path) == path {
+			keys = append(keys, common.AttachmentName+"/"+path)
+		}
+	}
+	return keys, nil
+}
+
+// 写入备份文件，同时计算大小和校验码并记录到清单
+func backupWriteFile(zipWriter *zip.Writer, manifest *entity.BackupManifest, name string, method uint16, write func(w io.Writer) error) error {
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	counter := &backupCounter{}
+	err = write(io.MultiWriter(writer, hash, counter))
+	if err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, entity.BackupFile{Path: name, Size: counter.size, Sha256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+// 统计写入的字节数
+type backupCounter struct {
+	size int64
+}
+
+func (c *backupCounter) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	return len(p), nil
+}
+
+// 从备份恢复全部数据，会覆盖当前数据，需在服务停止时执行
+func Restore(archivePath string) {
+	// 服务运行时拒绝恢复，恢复期间持有运行锁，此时也不能启动服务
+	err := middleware.LockRun(true)
+	if err != nil {
+		panic(common.NewErr("无法恢复", err))
+	}
+	defer middleware.UnlockRun()
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		panic(common.NewErr("打开备份文件失败", err))
+	}
+	defer reader.Close()
+
+	// 校验清单
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	manifest := restoreReadManifest(files)
+	if manifest.DbType != common.DbType {
+		panic(common.NewError(fmt.Sprintf("备份的数据库类型（%s）与当前数据库类型（%s）不一致", manifest.DbType, common.DbType)))
+	}
+	if manifest.DbVersion > middleware.LatestVersion() {
+		panic(common.NewError("备份来自更新版本的程序，请升级后再恢复"))
+	}
+	// sqlite替换文件后执行迁移，postgres逐表导入，需与当前表结构一致
+	if common.DbType == common.DbPostgres && manifest.DbVersion != middleware.LatestVersion() {
+		panic(common.NewError(fmt.Sprintf("备份的数据库结构版本（%d）与当前版本（%d）不一致，请使用相同版本的程序恢复", manifest.DbVersion, middleware.LatestVersion())))
+	}
+	restoreVerify(files, manifest)
+	middleware.Log.Info("备份校验通过，共", len(manifest.Files), "个文件")
+
+	// 图片、附件
+	for _, v := range manifest.Files {
+		if !strings.HasPrefix(v.Path, common.ResourceName+"/") {
+			continue
+		}
+		err = restoreResource(files[v.Path], strings.TrimPrefix(v.Path, common.ResourceName+"/"))
+		if err != nil {
+			panic(common.NewErr("恢复文件失败", err))
+		}
+	}
+
+	// 数据库
+	if common.DbType == common.DbPostgres {
+		err = restorePostgres(files, manifest)
+	} else {
+		err = restoreSqlite(files)
+	}
+	if err != nil {
+		panic(common.NewErr("恢复数据库失败", err))
+	}
+
+	// 检查全文检索索引
+	err = DocumentSearchIndexInit()
+	if err != nil {
+		panic(common.NewErr("重建全文检索索引失败", err))
+	}
+	middleware.Log.Info("恢复完成，备份时间：", time.UnixMilli(manifest.CreateTime).Format("2006-01-02 15:04:05"))
+}
+
+// 读取备份清单
+func restoreReadManifest(files map[string]*zip.File) entity.BackupManifest {
+	manifest := entity.BackupManifest{}
+	file, ok := files[backupManifestName]
+	if !ok {
+		panic(common.NewError("备份文件中缺少清单"))
+	}
+	reader, err := file.Open()
+	if err != nil {
+		panic(common.NewErr("读取备份清单失败", err))
+	}
+	defer reader.Close()
+	err = json.NewDecoder(reader).Decode(&manifest)
+	if err != nil {
+		panic(common.NewErr("读取备份清单失败", err))
+	}
+	if manifest.Version != backupVersion {
+		panic(common.NewError(fmt.Sprintf("不支持的备份格式版本：%d", manifest.Version)))
+	}
+	return manifest
+}
+
+// 校验备份文件与清单一致
+func restoreVerify(files map[string]*zip.File, manifest entity.BackupManifest) {
+	listed := map[string]bool{backupManifestName: true}
+	for _, v := range manifest.Files {
+		if !restoreValidPath(v.Path) {
+			panic(common.NewError("备份清单中的文件路径无效：" + v.Path))
+		}
+		listed[v.Path] = true
+		file, ok := files[v.Path]
+		if !ok {
+			panic(common.NewError("备份文件中缺少：" + v.Path))
+		}
+		reader, err := file.Open()
+		if err != nil {
+			panic(common.NewErr("读取备份文件失败："+v.Path, err))
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, reader)
+		reader.Close()
+		if err != nil {
+			panic(common.NewErr("读取备份文件失败："+v.Path, err))
+		}
+		if size != v.Size || hex.EncodeToString(hash.Sum(nil)) != v.Sha256 {
+			panic(common.NewError("备份文件校验失败：" + v.Path))
+		}
+	}
+	for name := range files {
+		if !listed[name] && !strings.HasSuffix(name, "/") {
+			panic(common.NewError("备份文件中包含清单外的文件：" + name))
+		}
+	}
+}
+
+// 备份包内只允许数据库文件及图片目录下的文件
+func restoreValidPath(path string) bool {
+	if path == backupSqliteName {
+		return true
+	}
+	if dir, name := filepath.Split(path); dir == backupDatabaseDir {
+		return slices.Contains(middleware.DataTables, strings.TrimSuffix(name, ".jsonl")) && strings.HasSuffix(name, ".jsonl")
+	}
+	for _, dir := range []string{common.PictureName, common.ThumbnailName, common.AttachmentName} {
+		prefix := common.ResourceName + "/" + dir + "/"
+		if name, ok := strings.CutPrefix(path, prefix); ok {
+			return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+		}
+	}
+	return false
+}
+
+// 解压图片到文件存储
+func restoreResource(file *zip.File, key string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return middleware.Storage.Put(key, reader, int64(file.UncompressedSize64))
+}
+
+// 解压单个文件，先写入临时文件再替换
+func restoreExtract(file *zip.File, target string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	temp := target + ".restore"
+	writer, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, target)
+}
+
+// 替换sqlite数据库文件，原文件重命名保留
+func restoreSqlite(files map[string]*zip.File) error {
+	file, ok := files[backupSqliteName]
+	if !ok {
+		return fmt.Errorf("备份文件中缺少：%s", backupSqliteName)
+	}
+	dbPath := common.DataPath + "md.db"
+	err := restoreExtract(file, dbPath+".new")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dbPath + ".new")
+
+	// 检查数据库完整性
+	db, err := sqlx.Connect("sqlite", dbPath+".new")
+	if err != nil {
+		return err
+	}
+	var result string
+	err = db.Get(&result, `pragma integrity_check`)
+	db.Close()
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("数据库完整性检查失败：%s", result)
+	}
+
+	// 关闭连接后替换文件，再重新连接并执行迁移
+	middleware.CloseDB()
+	oldPath := dbPath + "." + time.Now().Format("20060102150405") + ".bak"
+	err = os.Rename(dbPath, oldPath)
+	if err != nil {
+		return err
+	}
+	middleware.Log.Info("原数据库文件已保留为：", oldPath)
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+	err = os.Rename(dbPath+".new", dbPath)
+	if err != nil {
+		return err
+	}
+	return middleware.InitDB()
+}
+
+// 清空postgres业务数据表后逐表导入
+func restorePostgres(files map[string]*zip.File, manifest entity.BackupManifest) error {
+	tx, err := middleware.DbW.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 只清空清单中的数据表，缺少数据表时拒绝恢复，避免新旧数据混合
+	tables := map[string]string{}
+	for _, v := range manifest.Files {
+		if strings.HasPrefix(v.Path, backupDatabaseDir) {
+			tables[strings.TrimSuffix(strings.TrimPrefix(v.Path, backupDatabaseDir), ".jsonl")] = v.Path
+		}
+	}
+	for _, table := range middleware.DataTables {
+		if _, ok := tables[table]; !ok {
+			return fmt.Errorf("备份文件中缺少数据表：%s", table)
+		}
+	}
+
+	// 会话和全文检索索引随之清空，启动后重建
+	for _, table := range append([]string{"t_session", "t_document_fts"}, middleware.DataTables...) {
+		_, err = tx.Exec("delete from " + table)
+		if err != nil {
+			return err
+		}
+	}
+	for _, table := range middleware.DataTables {
+		count, err := restoreTable(tx, table, files[tables[table]])
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		middleware.Log.Info("已恢复", table, "：", count, "条")
+	}
+	return tx.Commit()
+}
+
+// 导入单个表
+func restoreTable(tx *sqlx.Tx, table string, file *zip.File) (int, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	decoder.UseNumber()
+	count := 0
+	for {
+		row := map[string]interface{}{}
+		err = decoder.Decode(&row)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			if !backupColumnRegex.MatchString(column) {
+				return count, fmt.Errorf("无效的字段名：%s", column)
+			}
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		placeholders := make([]string, len(columns))
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			values[i] = row[column]
+		}
+		_, err = tx.Exec("insert into "+table+" ("+strings.Join(columns, ",")+") values ("+strings.Join(placeholders, ",")+")", values...)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// 执行恢复，返回主动抛出的异常
+func restoreTestRun(archive string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e := r.(common.ErrorResponse)
+			err = e.Err
+			if err == nil {
+				err = errors.New(e.Message)
+			}
+		}
+	}()
+	Restore(archive)
+	return nil
+}
+
+// 有进程在使用数据库时拒绝恢复，遗留的进程号文件不影响恢复，恢复后删除
+func TestRestoreRunLock(t *testing.T) {
+	testInitDb(t)
+	testAddUser(t, "before")
+	archive := BackupTempFile()
+	t.Cleanup(func() { os.Remove(archive) })
+	testAddUser(t, "after")
+
+	// 模拟服务运行中
+	pidFile := common.DataPath + "md.pid"
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getppid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreTestRun(archive); !errors.Is(err, middleware.ErrDbInUse) {
+		t.Fatal("服务运行时应拒绝恢复：", err)
+	}
+	if _, err := dao.UserGetByName(middleware.Db, "after"); err != nil {
+		t.Fatal("拒绝恢复时不应修改数据：", err)
+	}
+
+	// 进程已退出
+	if err := os.WriteFile(pidFile, []byte("2147483600"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreTestRun(archive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.UserGetByName(middleware.Db, "before"); err != nil {
+		t.Fatal("恢复后缺少备份时的数据：", err)
+	}
+	if _, err := dao.UserGetByName(middleware.Db, "after"); err == nil {
+		t.Fatal("恢复后仍有备份后添加的数据")
+	}
+	if _, err := os.Stat(pidFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("恢复后应删除进程号文件：", err)
+	}
+
+	// 服务持有运行锁时不能再获取
+	if err := middleware.LockRun(false); err != nil {
+		t.Fatal(err)
+	}
+	defer middleware.UnlockRun()
+	if err := restoreTestRun(archive); !errors.Is(err, middleware.ErrDbInUse) {
+		t.Fatal("服务运行时应拒绝恢复：", err)
+	}
+}
@@ -105,6 +105,18 @@ func BookDelete(id, userId string) {
 		panic(common.NewErr("删除失败", err))
 	}
 
+	// 删除文件夹
+	err = dao.FolderDeleteByBookId(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 删除共享
+	err = dao.ShareDeleteByResource(tx, entity.ShareBook, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
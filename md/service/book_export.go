@@ -0,0 +1,432 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// html中引用的站内图片：src="/resource/picture/xxx.png"，编辑器插入的图片地址带有域名：src="https://host/resource/picture/xxx.png"
+var siteResourceRegex = regexp.MustCompile(`(src|href)="(?:https?://[^"/]+)?/resource/(picture|thumbnail)/([^"/?#]+)"`)
+
+// 导出文集为静态网站（zip），可导出共享给当前用户的文集
+func BookExportSite(id, userId string, w io.Writer) string {
+	book := bookAccess(id, userId, entity.ShareViewer)
+	documents, err := dao.DocumentListWithContent(middleware.Db, book.Id, book.UserId)
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	contents := map[string]entity.Document{}
+	for _, v := range documents {
+		contents[v.Id] = v
+	}
+	// 目录与/folder/tree接口一致，文档页面按目录顺序生成
+	tree := FolderTree(book.Id, userId)
+
+	zipWriter := zip.NewWriter(w)
+	resources := map[string]bool{}
+
+	// 文档页面
+	for _, node := range siteTreeDocuments(tree) {
+		document, ok := contents[node.Id]
+		if !ok {
+			continue
+		}
+		var body string
+		if document.Type == entity.DocOpenApi {
+			body = openApiSiteHTML(document)
+		} else {
+			body = `<article class="markdown">` + util.MarkdownToHTML(document.Content) + `</article>`
+		}
+		body = siteRewriteResource(body, resources)
+		page := sitePage(book, tree, document.Id, document.Name, body)
+		err = siteWriteFile(zipWriter, document.Id+".html", []byte(page), document.UpdateTime)
+		if err != nil {
+			panic(common.NewErr("导出失败", err))
+		}
+	}
+
+	// 首页
+	var index strings.Builder
+	index.WriteString(`<article class="markdown"><h1>` + html.EscapeString(book.Name) + `</h1>`)
+	siteTreeHTML(&index, tree, "", true)
+	index.WriteString(`</article>`)
+	err = siteWriteFile(zipWriter, "index.html", []byte(sitePage(book, tree, "", book.Name, index.String())), time.Now().UnixMilli())
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	err = siteWriteFile(zipWriter, "style.css", []byte(siteStyle), time.Now().UnixMilli())
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+
+	// 引用的图片，文件不存在时跳过
+	paths := make([]string, 0, len(resources))
+	for path := range resources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		data, err := middleware.StorageReadAll(path)
+		if err != nil {
+			middleware.Log.Warn("导出文集时读取图片失败：", err)
+			continue
+		}
+		err = siteWriteFile(zipWriter, common.ResourceName+"/"+path, data, time.Now().UnixMilli())
+		if err != nil {
+			panic(common.NewErr("导出失败", err))
+		}
+	}
+
+	err = zipWriter.Close()
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	return book.Name
+}
+
+// 站内图片链接改为相对路径，并记录需要打包的文件
+func siteRewriteResource(body string, resources map[string]bool) string {
+	return siteResourceRegex.ReplaceAllStringFunc(body, func(s string) string {
+		match := siteResourceRegex.FindStringSubmatch(s)
+		path := match[2] + "/" + match[3]
+		resources[path] = true
+		return match[1] + `="` + common.ResourceName + "/" + path + `"`
+	})
+}
+
+// 写入zip文件
+func siteWriteFile(zipWriter *zip.Writer, name string, data []byte, modified int64) error {
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.UnixMilli(modified)})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// 页面框架：左侧文档目录，右侧内容
+func sitePage(book entity.Book, tree []entity.TreeNode, currentId, title, body string) string {
+	var builder strings.Builder
+	builder.WriteString(`<!DOCTYPE html><html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">`)
+	builder.WriteString(`<title>` + html.EscapeString(title) + `</title><link rel="stylesheet" href="style.css"></head><body>`)
+	builder.WriteString(`<nav class="sidebar"><a class="book" href="index.html">` + html.EscapeString(book.Name) + `</a>`)
+	siteTreeHTML(&builder, tree, currentId, false)
+	builder.WriteString(`</nav><main class="content">` + body + `</main></body></html>`)
+	return builder.String()
+}
+
+// 按目录树渲染嵌套列表，文件夹显示为标题，index为true时（首页）显示文档的更新时间
+func siteTreeHTML(builder *strings.Builder, nodes []entity.TreeNode, currentId string, index bool) {
+	if index {
+		builder.WriteString(`<ul class="index">`)
+	} else {
+		builder.WriteString(`<ul>`)
+	}
+	for _, node := range nodes {
+		if node.Kind == entity.TreeFolder {
+			builder.WriteString(`<li class="folder"><span>` + html.EscapeString(node.Name) + `</span>`)
+			if len(node.Children) > 0 {
+				siteTreeHTML(builder, node.Children, currentId, index)
+			}
+			builder.WriteString(`</li>`)
+			continue
+		}
+		class := ""
+		if node.Id == currentId {
+			class = ` class="active"`
+		}
+		builder.WriteString(fmt.Sprintf(`<li%s><a href="%s.html">%s</a>`, class, node.Id, html.EscapeString(node.Name)))
+		if index {
+			builder.WriteString(`<span class="time">` + time.UnixMilli(node.UpdateTime).Format("2006-01-02 15:04") + `</span>`)
+		}
+		builder.WriteString(`</li>`)
+	}
+	builder.WriteString(`</ul>`)
+}
+
+// 按目录顺序列出目录树中的文档
+func siteTreeDocuments(nodes []entity.TreeNode) []entity.TreeNode {
+	result := []entity.TreeNode{}
+	for _, node := range nodes {
+		if node.Kind == entity.TreeFolder {
+			result = append(result, siteTreeDocuments(node.Children)...)
+		} else {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// OpenAPI文档渲染为静态接口说明
+func openApiSiteHTML(document entity.Document) string {
+	spec, err := util.ParseOpenApi(document.Content)
+	if err != nil {
+		return `<article class="markdown"><h1>` + html.EscapeString(document.Name) + `</h1><p class="error">OpenAPI文档解析失败：` + html.EscapeString(err.Error()) + `</p><pre><code>` + html.EscapeString(document.Content) + `</code></pre></article>`
+	}
+
+	var builder strings.Builder
+	builder.WriteString(`<article class="markdown openapi">`)
+
+	// 基本信息
+	info := util.MapValue(spec, "info")
+	title := util.StringValue(info, "title")
+	if title == "" {
+		title = document.Name
+	}
+	builder.WriteString(`<h1>` + html.EscapeString(title) + `</h1>`)
+	specVersion := util.StringValue(spec, "openapi")
+	if specVersion == "" {
+		specVersion = util.StringValue(spec, "swagger")
+	}
+	builder.WriteString(`<p class="meta">`)
+	if version := util.StringValue(info, "version"); version != "" {
+		builder.WriteString(`<span>版本 ` + html.EscapeString(version) + `</span>`)
+	}
+	if specVersion != "" {
+		builder.WriteString(`<span>OpenAPI ` + html.EscapeString(specVersion) + `</span>`)
+	}
+	builder.WriteString(`</p>`)
+	builder.WriteString(util.MarkdownToHTML(util.StringValue(info, "description")))
+
+	// 服务地址
+	var servers []string
+	for _, v := range util.ListValue(spec, "servers") {
+		server, _ := v.(map[string]interface{})
+		if url := util.StringValue(server, "url"); url != "" {
+			servers = append(servers, url)
+		}
+	}
+	if host := util.StringValue(spec, "host"); host != "" {
+		servers = append(servers, host+util.StringValue(spec, "basePath"))
+	}
+	if len(servers) > 0 {
+		builder.WriteString(`<h2>服务地址</h2><ul>`)
+		for _, v := range servers {
+			builder.WriteString(`<li><code>` + html.EscapeString(v) + `</code></li>`)
+		}
+		builder.WriteString(`</ul>`)
+	}
+
+	// 接口
+	paths := util.MapValue(spec, "paths")
+	pathNames := make([]string, 0, len(paths))
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+	if len(pathNames) > 0 {
+		builder.WriteString(`<h2>接口</h2>`)
+	}
+	for _, path := range pathNames {
+		pathItem := util.Deref(spec, util.MapValue(paths, path))
+		for _, method := range util.OpenApiMethods {
+			operation := util.MapValue(pathItem, method)
+			if operation == nil {
+				continue
+			}
+			openApiOperationHTML(&builder, spec, pathItem, operation, method, path)
+		}
+	}
+
+	// 数据模型
+	schemas := util.MapValue(util.MapValue(spec, "components"), "schemas")
+	if schemas == nil {
+		schemas = util.MapValue(spec, "definitions")
+	}
+	schemaNames := make([]string, 0, len(schemas))
+	for name := range schemas {
+		schemaNames = append(schemaNames, name)
+	}
+	sort.Strings(schemaNames)
+	if len(schemaNames) > 0 {
+		builder.WriteString(`<h2>数据模型</h2>`)
+	}
+	for _, name := range schemaNames {
+		schema := util.MapValue(schemas, name)
+		builder.WriteString(`<section class="schema" id="schema-` + html.EscapeString(name) + `"><h3>` + html.EscapeString(name) + ` <small>` + html.EscapeString(util.SchemaTypeName(schema)) + `</small></h3>`)
+		builder.WriteString(util.MarkdownToHTML(util.StringValue(schema, "description")))
+		openApiPropertiesHTML(&builder, schema)
+		builder.WriteString(`</section>`)
+	}
+
+	builder.WriteString(`</article>`)
+	return builder.String()
+}
+
+// 渲染单个接口
+func openApiOperationHTML(builder *strings.Builder, spec, pathItem, operation map[string]interface{}, method, path string) {
+	builder.WriteString(`<section class="operation">`)
+	builder.WriteString(`<h3><span class="method ` + method + `">` + strings.ToUpper(method) + `</span> <code>` + html.EscapeString(path) + `</code>`)
+	if util.BoolValue(operation, "deprecated") {
+		builder.WriteString(` <span class="deprecated">已废弃</span>`)
+	}
+	builder.WriteString(`</h3>`)
+	if summary := util.StringValue(operation, "summary"); summary != "" {
+		builder.WriteString(`<p class="summary">` + html.EscapeString(summary) + `</p>`)
+	}
+	builder.WriteString(util.MarkdownToHTML(util.StringValue(operation, "description")))
+
+	// 参数，接口级参数覆盖路径级同名参数
+	var parameters []map[string]interface{}
+	var body map[string]interface{}
+	index := map[string]int{}
+	for _, list := range [][]interface{}{util.ListValue(pathItem, "parameters"), util.ListValue(operation, "parameters")} {
+		for _, v := range list {
+			parameter, _ := v.(map[string]interface{})
+			parameter = util.Deref(spec, parameter)
+			if parameter == nil {
+				continue
+			}
+			// Swagger 2.0的请求体参数
+			if util.StringValue(parameter, "in") == "body" {
+				body = parameter
+				continue
+			}
+			key := util.StringValue(parameter, "in") + ":" + util.StringValue(parameter, "name")
+			if i, ok := index[key]; ok {
+				parameters[i] = parameter
+			} else {
+				index[key] = len(parameters)
+				parameters = append(parameters, parameter)
+			}
+		}
+	}
+	if len(parameters) > 0 {
+		builder.WriteString(`<h4>参数</h4><table><thead><tr><th>名称</th><th>位置</th><th>类型</th><th>必填</th><th>说明</th></tr></thead><tbody>`)
+		for _, parameter := range parameters {
+			schema := util.MapValue(parameter, "schema")
+			if schema == nil {
+				schema = parameter
+			}
+			builder.WriteString(`<tr><td><code>` + html.EscapeString(util.StringValue(parameter, "name")) + `</code></td>`)
+			builder.WriteString(`<td>` + html.EscapeString(util.StringValue(parameter, "in")) + `</td>`)
+			builder.WriteString(`<td>` + html.EscapeString(util.SchemaTypeName(schema)) + `</td>`)
+			builder.WriteString(`<td>` + siteRequired(util.BoolValue(parameter, "required")) + `</td>`)
+			builder.WriteString(`<td>` + html.EscapeString(util.StringValue(parameter, "description")) + `</td></tr>`)
+		}
+		builder.WriteString(`</tbody></table>`)
+	}
+
+	// 请求体
+	requestBody := util.Deref(spec, util.MapValue(operation, "requestBody"))
+	if requestBody != nil || body != nil {
+		builder.WriteString(`<h4>请求体</h4><table><thead><tr><th>类型</th><th>结构</th><th>说明</th></tr></thead><tbody>`)
+		if body != nil {
+			builder.WriteString(`<tr><td>` + html.EscapeString(strings.Join(siteStrings(util.ListValue(operation, "consumes")), ", ")) + `</td><td>` + html.EscapeString(util.SchemaTypeName(util.MapValue(body, "schema"))) + `</td><td>` + html.EscapeString(util.StringValue(body, "description")) + `</td></tr>`)
+		}
+		content := util.MapValue(requestBody, "content")
+		for _, mediaType := range siteSortedKeys(content) {
+			schema := util.MapValue(util.MapValue(content, mediaType), "schema")
+			builder.WriteString(`<tr><td>` + html.EscapeString(mediaType) + `</td><td>` + html.EscapeString(util.SchemaTypeName(schema)) + `</td><td>` + html.EscapeString(util.StringValue(requestBody, "description")) + `</td></tr>`)
+		}
+		builder.WriteString(`</tbody></table>`)
+	}
+
+	// 响应
+	responses := util.MapValue(operation, "responses")
+	if len(responses) > 0 {
+		builder.WriteString(`<h4>响应</h4><table><thead><tr><th>状态码</th><th>结构</th><th>说明</th></tr></thead><tbody>`)
+		for _, code := range siteSortedKeys(responses) {
+			response := util.Deref(spec, util.MapValue(responses, code))
+			var types []string
+			if schema := util.MapValue(response, "schema"); schema != nil {
+				types = append(types, util.SchemaTypeName(schema))
+			}
+			content := util.MapValue(response, "content")
+			for _, mediaType := range siteSortedKeys(content) {
+				types = append(types, mediaType+": "+util.SchemaTypeName(util.MapValue(util.MapValue(content, mediaType), "schema")))
+			}
+			builder.WriteString(`<tr><td><code>` + html.EscapeString(code) + `</code></td><td>` + html.EscapeString(strings.Join(types, "; ")) + `</td><td>` + html.EscapeString(util.StringValue(response, "description")) + `</td></tr>`)
+		}
+		builder.WriteString(`</tbody></table>`)
+	}
+	builder.WriteString(`</section>`)
+}
+
+// 渲染对象属性表格
+func openApiPropertiesHTML(builder *strings.Builder, schema map[string]interface{}) {
+	properties := util.MapValue(schema, "properties")
+	if len(properties) == 0 {
+		return
+	}
+	required := map[string]bool{}
+	for _, v := range siteStrings(util.ListValue(schema, "required")) {
+		required[v] = true
+	}
+	builder.WriteString(`<table><thead><tr><th>属性</th><th>类型</th><th>必填</th><th>说明</th></tr></thead><tbody>`)
+	for _, name := range siteSortedKeys(properties) {
+		property := util.MapValue(properties, name)
+		builder.WriteString(`<tr><td><code>` + html.EscapeString(name) + `</code></td><td>` + html.EscapeString(util.SchemaTypeName(property)) + `</td><td>` + siteRequired(required[name]) + `</td><td>` + html.EscapeString(util.StringValue(property, "description")) + `</td></tr>`)
+	}
+	builder.WriteString(`</tbody></table>`)
+}
+
+// 必填标识
+func siteRequired(required bool) string {
+	if required {
+		return "是"
+	}
+	return "否"
+}
+
+// 对象的键按字母排序
+func siteSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 数组转字符串数组
+func siteStrings(list []interface{}) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		result = append(result, fmt.Sprint(v))
+	}
+	return result
+}
+
+// 静态网站样式
+const siteStyle = `* { box-sizing: border-box; }
+body { margin: 0; display: flex; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; color: #303133; line-height: 1.7; }
+.sidebar { position: sticky; top: 0; width: 260px; height: 100vh; overflow-y: auto; flex-shrink: 0; padding: 20px 0; background: #f5f7fa; border-right: 1px solid #e4e7ed; }
+.sidebar .book { display: block; padding: 0 20px 12px; font-size: 18px; font-weight: bold; color: #303133; text-decoration: none; }
+.sidebar ul { list-style: none; margin: 0; padding: 0; }
+.sidebar li a { display: block; padding: 6px 20px; color: #606266; text-decoration: none; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.sidebar li a:hover, .sidebar li.active a { color: #409eff; background: #ecf5ff; }
+.sidebar .folder > span { display: block; padding: 6px 20px; color: #303133; font-weight: bold; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.sidebar .folder > ul { padding-left: 16px; }
+.content { flex: 1; min-width: 0; padding: 20px 40px 60px; }
+.markdown { max-width: 960px; margin: 0 auto; }
+.markdown img { max-width: 100%; }
+.markdown pre { padding: 12px 16px; overflow-x: auto; background: #f6f8fa; border-radius: 4px; }
+.markdown code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+.markdown table { width: 100%; margin: 12px 0; border-collapse: collapse; }
+.markdown th, .markdown td { padding: 6px 12px; border: 1px solid #e4e7ed; text-align: left; vertical-align: top; }
+.markdown th { background: #f5f7fa; }
+.markdown blockquote { margin: 0; padding: 0 16px; color: #909399; border-left: 4px solid #dcdfe6; }
+.index li:not(.folder) { display: flex; justify-content: space-between; }
+.index .folder > span { font-weight: bold; }
+.index .time, .meta span { margin-right: 12px; color: #909399; font-size: 14px; }
+.operation { margin: 24px 0; padding-bottom: 12px; border-bottom: 1px solid #ebeef5; }
+.method { display: inline-block; min-width: 64px; padding: 0 8px; border-radius: 4px; color: #fff; font-size: 14px; text-align: center; background: #909399; }
+.method.get { background: #409eff; }
+.method.post { background: #67c23a; }
+.method.put, .method.patch { background: #e6a23c; }
+.method.delete { background: #f56c6c; }
+.deprecated { color: #f56c6c; font-size: 14px; }
+.error { color: #f56c6c; }
+`
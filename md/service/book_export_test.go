@@ -0,0 +1,50 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"md/model/entity"
+	"strings"
+	"testing"
+)
+
+// 静态网站的目录按文件夹层级生成
+func TestBookExportSiteTree(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "export")
+	BookAdd(entity.Book{Name: "book", UserId: userId})
+	book := BookList(userId)[1]
+	folder := FolderAdd(entity.Folder{Name: "guide", BookId: book.Id, UserId: userId})
+	inner := DocumentAdd(entity.Document{Name: "install", Content: "# install", Type: entity.DocMd, BookId: book.Id, ParentId: folder.Id, UserId: userId})
+	root := DocumentAdd(entity.Document{Name: "readme", Content: "# readme", Type: entity.DocMd, BookId: book.Id, UserId: userId})
+
+	var buffer bytes.Buffer
+	BookExportSite(book.Id, userId, &buffer)
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range reader.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		files[file.Name] = string(data)
+	}
+
+	nav := `<ul><li class="folder"><span>guide</span><ul><li class="active"><a href="` + inner.Id + `.html">install</a></li></ul></li><li><a href="` + root.Id + `.html">readme</a></li></ul>`
+	if page := files[inner.Id+".html"]; !strings.Contains(page, nav) {
+		t.Errorf("目录错误：%s", page)
+	}
+	index := files["index.html"]
+	if !strings.Contains(index, `<ul class="index"><li class="folder"><span>guide</span><ul class="index"><li><a href="`+inner.Id+`.html">install</a>`) {
+		t.Errorf("首页目录错误：%s", index)
+	}
+	if _, ok := files[root.Id+".html"]; !ok {
+		t.Error("缺少根目录的文档")
+	}
+}
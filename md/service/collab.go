@@ -0,0 +1,466 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	collabSaveDelay   = 2 * time.Second // 内容变化后延迟保存的时间
+	collabHistorySize = 1000            // 保留的历史操作数量，更早版本的操作需客户端重新加载
+	collabSendBuffer  = 256             // 客户端待发送消息数量，超出时断开连接
+	collabMaxLength   = 10000000        // 文档内容最大长度
+)
+
+// 保存结果
+type collabSaveResult int
+
+const (
+	collabSaveFailed   collabSaveResult = iota // 保存失败，需重试
+	collabSaveOk                               // 已保存
+	collabSaveSkipped                          // 已保存更新的版本，无需保存
+	collabSaveConflict                         // 文档已被其他方式修改，需合并后重新保存
+)
+
+// 保存时基于的文档版本，其他方式修改过文档时保存返回冲突
+type collabBase struct {
+	content    string // 加载或最后保存的内容，冲突时据此合并
+	updateTime int64
+	revision   int // 文档的历史版本号
+}
+
+// 协同编辑房间，每个正在编辑的文档一个
+type collabRoom struct {
+	mutex         sync.Mutex
+	saveMutex     sync.Mutex
+	id            string
+	content       []uint16                  // 当前内容（UTF-16）
+	revision      int                       // 当前版本，每应用一个操作加1
+	history       []*util.TextOperation     // 最近的操作，最后一个为上一版本到当前版本的操作
+	sessions      map[string]*CollabSession // 在线的客户端
+	dirty         bool                      // 是否有未保存的修改
+	owner         string                    // 文档所有者，应用操作时已校验编辑权限，保存时以所有者身份保存，避免编辑者权限变化后丢失已应用的修改
+	timer         *time.Timer               // 延迟保存
+	savedRevision int                       // 已保存的版本
+	base          collabBase                // 保存时基于的文档版本
+	closed        bool
+	done          chan struct{} // 关闭并保存完成后关闭，正在关闭时新加入的客户端等待后重新加载
+}
+
+// 协同编辑会话，对应一个客户端连接
+type CollabSession struct {
+	room   *collabRoom
+	client entity.CollabClient
+	send   chan []byte
+	closed bool
+}
+
+var (
+	collabRooms = map[string]*collabRoom{}
+	collabMutex sync.Mutex
+)
+
+// 加入文档的协同编辑，需有查看权限，连接断开时需调用Leave
+func CollabJoin(documentId, userId string) *CollabSession {
+	document := collabLoad(documentId, userId)
+	role := documentRole(middleware.Db, document, userId)
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询用户失败", err))
+	}
+
+	session := &CollabSession{
+		client: entity.CollabClient{ClientId: util.SnowflakeString(), UserId: userId, Name: user.Name, Role: role},
+		send:   make(chan []byte, collabSendBuffer),
+	}
+	for {
+		collabMutex.Lock()
+		room := collabRooms[documentId]
+		if room == nil {
+			room = &collabRoom{
+				id:       documentId,
+				owner:    document.UserId,
+				content:  utf16.Encode([]rune(document.Content)),
+				sessions: map[string]*CollabSession{},
+				base:     collabBase{content: document.Content, updateTime: document.UpdateTime, revision: document.Revision},
+				done:     make(chan struct{}),
+			}
+			collabRooms[documentId] = room
+		}
+		collabMutex.Unlock()
+
+		room.mutex.Lock()
+		// 房间正在关闭，等待保存完成后重新加载
+		if room.closed {
+			room.mutex.Unlock()
+			<-room.done
+			document = collabLoad(documentId, userId)
+			continue
+		}
+		session.room = room
+		clients := []entity.CollabClient{}
+		for _, v := range room.sessions {
+			clients = append(clients, v.client)
+		}
+		room.sessions[session.client.ClientId] = session
+		session.sendMessage(entity.CollabMessage{
+			Type:     entity.CollabInit,
+			ClientId: session.client.ClientId,
+			Revision: room.revision,
+			Content:  string(utf16.Decode(room.content)),
+			Role:     role,
+			Clients:  clients,
+		})
+		client := session.client
+		room.broadcast(session, entity.CollabMessage{Type: entity.CollabJoin, Client: &client})
+		room.mutex.Unlock()
+		return session
+	}
+}
+
+// 查询文档内容及历史版本号，需有查看权限
+func collabLoad(documentId, userId string) entity.Document {
+	document := documentAccess(middleware.Db, documentId, userId, entity.ShareViewer)
+	revision, err := dao.DocumentRevisionMaxNumber(middleware.Db, documentId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	document.Revision = revision
+	return document
+}
+
+// 待发送给客户端的消息，连接断开或发送过慢时关闭
+func (s *CollabSession) Messages() <-chan []byte {
+	return s.send
+}
+
+// 处理客户端发送的消息
+func (s *CollabSession) Handle(data []byte) {
+	message := entity.CollabMessage{}
+	err := json.Unmarshal(data, &message)
+	if err != nil {
+		s.room.mutex.Lock()
+		s.sendMessage(entity.CollabMessage{Type: entity.CollabError, Revision: s.room.revision, Message: "消息格式错误"})
+		s.room.mutex.Unlock()
+		return
+	}
+
+	// 每次修改时重新查询权限，共享被取消或修改后不可继续编辑
+	var role entity.ShareRole
+	var roleErr error
+	if message.Type == entity.CollabOperation {
+		role, roleErr = collabRole(s.room.id, s.client.UserId)
+	}
+
+	r := s.room
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed || s.closed {
+		return
+	}
+	if roleErr != nil {
+		s.sendMessage(entity.CollabMessage{Type: entity.CollabError, Revision: r.revision, Message: "查询权限失败"})
+		return
+	}
+	if message.Type == entity.CollabOperation && role != s.client.Role {
+		// 权限变化后断开连接，客户端重新加入时按新的权限加载
+		s.sendMessage(entity.CollabMessage{Type: entity.CollabError, Revision: r.revision, Message: "文档权限已变化，请重新加载"})
+		s.close()
+		return
+	}
+	switch message.Type {
+	case entity.CollabOperation:
+		r.applyOperation(s, message)
+	case entity.CollabPresence:
+		s.client.Selection = message.Selection
+		client := s.client
+		r.broadcast(s, entity.CollabMessage{Type: entity.CollabPresence, ClientId: client.ClientId, Selection: message.Selection, Client: &client})
+	default:
+		s.sendMessage(entity.CollabMessage{Type: entity.CollabError, Revision: r.revision, Message: "不支持的消息类型"})
+	}
+}
+
+// 查询用户当前对文档的权限，文档不存在或无权限时返回空字符串
+func collabRole(documentId, userId string) (role entity.ShareRole, err error) {
+	document, err := dao.DocumentGetByIdAnyUser(middleware.Db, documentId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		middleware.Log.Error("协同编辑查询权限失败：", documentId, " ", err)
+		return "", err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			middleware.Log.Error("协同编辑查询权限失败：", documentId, " ", e)
+			role, err = "", errors.New("查询权限失败")
+		}
+	}()
+	return documentRole(middleware.Db, document, userId), nil
+}
+
+// 离开协同编辑，最后一个客户端离开时保存内容并关闭房间
+func (s *CollabSession) Leave() {
+	r := s.room
+	r.mutex.Lock()
+	if _, ok := r.sessions[s.client.ClientId]; !ok {
+		r.mutex.Unlock()
+		return
+	}
+	delete(r.sessions, s.client.ClientId)
+	s.close()
+	client := s.client
+	r.broadcast(s, entity.CollabMessage{Type: entity.CollabLeave, ClientId: client.ClientId, Client: &client})
+
+	closed := len(r.sessions) == 0
+	dirty := false
+	if closed {
+		if r.timer != nil {
+			r.timer.Stop()
+			r.timer = nil
+		}
+		// 标记为关闭后不再接受修改，新加入的客户端等待保存完成后重新加载，避免加载到旧内容
+		r.closed = true
+		dirty, r.dirty = r.dirty, false
+	}
+	r.mutex.Unlock()
+
+	if closed {
+		if dirty {
+			r.close()
+		}
+		collabMutex.Lock()
+		if collabRooms[r.id] == r {
+			delete(collabRooms, r.id)
+		}
+		collabMutex.Unlock()
+		close(r.done)
+	}
+}
+
+// 关闭房间时保存内容，冲突时合并后再保存一次；保存时不持有房间锁
+func (r *collabRoom) close() {
+	for i := 0; i < 2; i++ {
+		r.mutex.Lock()
+		base, content, revision := r.base, string(utf16.Decode(r.content)), r.revision
+		r.mutex.Unlock()
+
+		document, result, conflict := r.save(content, revision, r.owner, base)
+
+		r.mutex.Lock()
+		if result == collabSaveOk {
+			r.saved(base, content, document)
+		}
+		merged := result == collabSaveConflict && r.merge(base, conflict)
+		r.mutex.Unlock()
+		if !merged {
+			return
+		}
+	}
+}
+
+// 应用客户端的操作：转换到当前版本后应用，回复确认并转发给其他客户端
+func (r *collabRoom) applyOperation(s *CollabSession, message entity.CollabMessage) {
+	fail := func(text string) {
+		s.sendMessage(entity.CollabMessage{Type: entity.CollabError, Revision: r.revision, Message: text})
+	}
+	if s.client.Role == entity.ShareViewer {
+		fail("没有编辑此文档的权限")
+		return
+	}
+	if message.Operation == nil {
+		fail("操作不可为空")
+		return
+	}
+	start := r.revision - len(r.history)
+	if message.Revision < start || message.Revision > r.revision {
+		fail("版本过旧，请重新加载")
+		return
+	}
+
+	operation := message.Operation
+	var err error
+	for _, v := range r.history[message.Revision-start:] {
+		operation, _, err = util.TransformOperation(operation, v)
+		if err != nil {
+			fail("操作无效，请重新加载")
+			return
+		}
+	}
+	if operation.TargetLength > collabMaxLength {
+		fail("文档内容过多，请小于1000万个字符")
+		return
+	}
+	content, err := operation.Apply(r.content)
+	if err != nil {
+		fail("操作无效，请重新加载")
+		return
+	}
+
+	r.content = content
+	r.revision++
+	r.history = append(r.history, operation)
+	if len(r.history) > collabHistorySize {
+		r.history = append([]*util.TextOperation{}, r.history[len(r.history)-collabHistorySize:]...)
+	}
+	s.sendMessage(entity.CollabMessage{Type: entity.CollabAck, Revision: r.revision})
+	r.broadcast(s, entity.CollabMessage{Type: entity.CollabOperation, ClientId: s.client.ClientId, Revision: r.revision, Operation: operation})
+
+	if operation.IsNoop() {
+		return
+	}
+	r.dirty = true
+	r.schedule()
+}
+
+// 延迟保存，持续编辑时按固定间隔保存，需持有房间锁
+func (r *collabRoom) schedule() {
+	if r.timer == nil {
+		r.timer = time.AfterFunc(collabSaveDelay, r.flush)
+	}
+}
+
+// 定时保存，保存后通知所有客户端
+func (r *collabRoom) flush() {
+	r.mutex.Lock()
+	r.timer = nil
+	if !r.dirty || r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	content, revision, base := string(utf16.Decode(r.content)), r.revision, r.base
+	r.dirty = false
+	r.mutex.Unlock()
+
+	document, result, conflict := r.save(content, revision, r.owner, base)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	switch result {
+	case collabSaveSkipped:
+		// 更新的版本已由其他保存完成并通知
+		return
+	case collabSaveConflict:
+		// 合并其他方式的修改后重新保存
+		if !r.closed {
+			r.merge(base, conflict)
+			r.dirty = true
+			r.schedule()
+		}
+		return
+	case collabSaveFailed:
+		r.dirty = true
+		r.broadcast(nil, entity.CollabMessage{Type: entity.CollabError, Revision: r.revision, Message: "保存失败"})
+		return
+	}
+	r.saved(base, content, document)
+	r.broadcast(nil, entity.CollabMessage{Type: entity.CollabSaved, Revision: r.revision, Document: &entity.CollabSavedDocument{Revision: revision, UpdateTime: document.UpdateTime}})
+}
+
+// 保存成功后更新基础版本，需持有房间锁
+func (r *collabRoom) saved(base collabBase, content string, document entity.Document) {
+	if r.base.updateTime == base.updateTime {
+		r.base = collabBase{content: content, updateTime: document.UpdateTime, revision: document.Revision}
+	}
+}
+
+// 合并保存冲突时服务器的当前内容：将基础版本到当前内容的修改作为一个操作，与房间内的修改转换后应用并转发给所有客户端，
+// 基础版本已变化（已由其他保存合并）时跳过，返回是否已合并，需持有房间锁
+func (r *collabRoom) merge(base collabBase, conflict entity.DocumentConflictResult) bool {
+	if r.base.updateTime != base.updateTime {
+		return false
+	}
+	baseContent := utf16.Encode([]rune(base.content))
+	external := util.TextOperationDiff(baseContent, utf16.Encode([]rune(conflict.Content)))
+	local := util.TextOperationDiff(baseContent, r.content)
+	_, operation, err := util.TransformOperation(local, external)
+	if err == nil {
+		var content []uint16
+		content, err = operation.Apply(r.content)
+		if err == nil {
+			r.content = content
+		}
+	}
+	if err != nil {
+		middleware.Log.Error("协同编辑合并失败：", r.id, " ", err)
+		return false
+	}
+
+	r.base = collabBase{content: conflict.Content, updateTime: conflict.UpdateTime, revision: conflict.Revision}
+	if operation.IsNoop() {
+		return true
+	}
+	r.revision++
+	r.history = append(r.history, operation)
+	if len(r.history) > collabHistorySize {
+		r.history = append([]*util.TextOperation{}, r.history[len(r.history)-collabHistorySize:]...)
+	}
+	r.broadcast(nil, entity.CollabMessage{Type: entity.CollabOperation, Revision: r.revision, Operation: operation})
+	return true
+}
+
+// 通过文档服务保存内容，按版本顺序保存，已保存更新的版本时跳过；基于base校验冲突，冲突时返回服务器的当前内容
+func (r *collabRoom) save(content string, revision int, userId string, base collabBase) (document entity.Document, result collabSaveResult, conflict entity.DocumentConflictResult) {
+	r.saveMutex.Lock()
+	defer r.saveMutex.Unlock()
+	if revision <= r.savedRevision {
+		return document, collabSaveSkipped, conflict
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			if e, ok := err.(common.ErrorResponse); ok && e.Code == common.HttpConflict {
+				if data, ok := e.Data.(entity.DocumentConflictResult); ok {
+					result, conflict = collabSaveConflict, data
+					return
+				}
+			}
+			middleware.Log.Error("协同编辑保存失败：", r.id, " ", err)
+		}
+	}()
+	document = DocumentUpdateContent(entity.DocumentContentCondition{Id: r.id, Content: content, UpdateTime: base.updateTime, Revision: &base.revision, UserId: userId})
+	r.savedRevision = revision
+	return document, collabSaveOk, conflict
+}
+
+// 发送消息给除exclude外的所有客户端，需持有房间锁
+func (r *collabRoom) broadcast(exclude *CollabSession, message entity.CollabMessage) {
+	for _, v := range r.sessions {
+		if v != exclude {
+			v.sendMessage(message)
+		}
+	}
+}
+
+// 发送消息，队列已满时关闭会话，需持有房间锁
+func (s *CollabSession) sendMessage(message entity.CollabMessage) {
+	if s.closed {
+		return
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		middleware.Log.Error("协同编辑消息序列化失败：", err)
+		return
+	}
+	select {
+	case s.send <- data:
+	default:
+		s.close()
+	}
+}
+
+// 关闭发送队列，需持有房间锁
+func (s *CollabSession) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.send)
+	}
+}
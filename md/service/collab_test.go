@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"md/model/entity"
+	"md/util"
+	"strconv"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// 读取会话的下一条消息
+func collabNext(t *testing.T, s *CollabSession) entity.CollabMessage {
+	t.Helper()
+	select {
+	case data, ok := <-s.Messages():
+		if !ok {
+			t.Fatal("会话已关闭")
+		}
+		message := entity.CollabMessage{}
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("等待消息超时")
+	}
+	return entity.CollabMessage{}
+}
+
+// 读取下一条消息并校验类型
+func collabExpect(t *testing.T, s *CollabSession, messageType entity.CollabMessageType) entity.CollabMessage {
+	t.Helper()
+	message := collabNext(t, s)
+	if message.Type != messageType {
+		t.Fatalf("期望%s消息，实际为%+v", messageType, message)
+	}
+	return message
+}
+
+// 发送文本操作
+func collabSend(t *testing.T, s *CollabSession, revision int, operation string) {
+	t.Helper()
+	s.Handle([]byte(`{"type":"op","revision":` + strconv.Itoa(revision) + `,"operation":` + operation + `}`))
+}
+
+// 应用操作并返回文本
+func collabApply(t *testing.T, text string, operation *util.TextOperation) string {
+	t.Helper()
+	result, err := operation.Apply(utf16.Encode([]rune(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(utf16.Decode(result))
+}
+
+func TestCollabRoom(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "collab")
+	document := DocumentAdd(entity.Document{Name: "doc", Content: "abc", Type: entity.DocMd, UserId: userId})
+
+	s1 := CollabJoin(document.Id, userId)
+	init1 := collabExpect(t, s1, entity.CollabInit)
+	s2 := CollabJoin(document.Id, userId)
+	init2 := collabExpect(t, s2, entity.CollabInit)
+	collabExpect(t, s1, entity.CollabJoin)
+	if init1.Content != "abc" || init2.Content != "abc" || init2.Revision != 0 || len(init2.Clients) != 1 {
+		t.Fatalf("初始状态错误：%+v %+v", init1, init2)
+	}
+
+	// 两个客户端基于同一版本并发修改
+	collabSend(t, s1, 0, `[3,"1"]`)
+	collabSend(t, s2, 0, `["0",3]`)
+
+	// 客户端1：先确认自己的操作，再收到转换后的客户端2的操作
+	text1 := "abc1"
+	if ack := collabExpect(t, s1, entity.CollabAck); ack.Revision != 1 {
+		t.Fatal(ack)
+	}
+	op := collabExpect(t, s1, entity.CollabOperation)
+	if op.Revision != 2 || op.ClientId != s2.client.ClientId {
+		t.Fatal(op)
+	}
+	text1 = collabApply(t, text1, op.Operation)
+
+	// 客户端2：收到客户端1的操作时自己的操作尚未确认，按客户端的方式转换后应用
+	pending := &util.TextOperation{}
+	_ = json.Unmarshal([]byte(`["0",3]`), pending)
+	op = collabExpect(t, s2, entity.CollabOperation)
+	if op.Revision != 1 {
+		t.Fatal(op)
+	}
+	_, remote, err := util.TransformOperation(pending, op.Operation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text2 := collabApply(t, "0abc", remote)
+	if ack := collabExpect(t, s2, entity.CollabAck); ack.Revision != 2 {
+		t.Fatal(ack)
+	}
+
+	room := s1.room
+	if text1 != "0abc1" || text2 != "0abc1" || string(utf16.Decode(room.content)) != "0abc1" {
+		t.Fatalf("内容不一致：%q %q %q", text1, text2, string(utf16.Decode(room.content)))
+	}
+
+	// 过旧的版本需重新加载
+	collabSend(t, s1, -1, `[5]`)
+	if message := collabExpect(t, s1, entity.CollabError); message.Message != "版本过旧，请重新加载" {
+		t.Fatal(message)
+	}
+
+	// 保存并通知所有客户端
+	room.mutex.Lock()
+	room.timer.Stop()
+	room.mutex.Unlock()
+	room.flush()
+	for _, s := range []*CollabSession{s1, s2} {
+		saved := collabExpect(t, s, entity.CollabSaved)
+		if saved.Document == nil || saved.Document.Revision != 2 {
+			t.Fatal(saved)
+		}
+	}
+	if saved := DocumentGet(document.Id, userId); saved.Content != "0abc1" {
+		t.Fatal(saved.Content)
+	}
+
+	// 已保存更新的版本时跳过，不视为保存失败
+	if _, result, _ := room.save("old", 1, userId, room.base); result != collabSaveSkipped {
+		t.Fatal(result)
+	}
+	room.mutex.Lock()
+	room.dirty = true
+	room.mutex.Unlock()
+	room.flush()
+	if room.dirty {
+		t.Error("跳过保存后不应标记为未保存")
+	}
+	select {
+	case data := <-s1.Messages():
+		t.Fatalf("跳过保存时不应通知：%s", data)
+	default:
+	}
+
+	// 通过文档接口修改后，协同编辑保存冲突，合并双方的修改
+	saved := DocumentGet(document.Id, userId)
+	if saved.Revision != room.base.revision || saved.UpdateTime != room.base.updateTime {
+		t.Fatalf("基础版本错误：%+v %+v", saved, room.base)
+	}
+	DocumentUpdateContent(entity.DocumentContentCondition{Id: document.Id, Content: "0abc1\nrest", UpdateTime: saved.UpdateTime, UserId: userId})
+	collabSend(t, s1, 2, `["x",5]`)
+	collabExpect(t, s1, entity.CollabAck)
+	collabExpect(t, s2, entity.CollabOperation)
+	room.mutex.Lock()
+	room.timer.Stop()
+	room.timer = nil
+	room.mutex.Unlock()
+	room.flush()
+	for _, s := range []*CollabSession{s1, s2} {
+		op := collabExpect(t, s, entity.CollabOperation)
+		if op.Revision != 4 || op.ClientId != "" {
+			t.Fatal(op)
+		}
+	}
+	if content := string(utf16.Decode(room.content)); content != "x0abc1\nrest" {
+		t.Fatal(content)
+	}
+	room.mutex.Lock()
+	room.timer.Stop()
+	room.timer = nil
+	room.mutex.Unlock()
+	room.flush()
+	collabExpect(t, s1, entity.CollabSaved)
+	collabExpect(t, s2, entity.CollabSaved)
+	if saved := DocumentGet(document.Id, userId); saved.Content != "x0abc1\nrest" || saved.Revision != 4 {
+		t.Fatal(saved.Content, saved.Revision)
+	}
+
+	// 最后一个客户端离开后关闭房间
+	s1.Leave()
+	collabExpect(t, s2, entity.CollabLeave)
+	s2.Leave()
+	collabMutex.Lock()
+	defer collabMutex.Unlock()
+	if collabRooms[document.Id] != nil {
+		t.Error("房间未关闭")
+	}
+}
+
+// 共享权限修改或取消后不可继续编辑，连接断开
+func TestCollabShareChanged(t *testing.T) {
+	testInitDb(t)
+	owner, bob := testAddUser(t, "owner"), testAddUser(t, "bob")
+	document := DocumentAdd(entity.Document{Name: "doc", Content: "abc", Type: entity.DocMd, UserId: owner})
+	share := func(role entity.ShareRole) {
+		ShareAdd(entity.ShareAddCondition{ResourceType: entity.ShareDocument, ResourceId: document.Id, UserName: "bob", Role: role}, owner)
+	}
+
+	share(entity.ShareEditor)
+	s := CollabJoin(document.Id, bob)
+	collabExpect(t, s, entity.CollabInit)
+	collabSend(t, s, 0, `[3,"1"]`)
+	collabExpect(t, s, entity.CollabAck)
+
+	share(entity.ShareViewer)
+	collabSend(t, s, 1, `[4,"2"]`)
+	if message := collabExpect(t, s, entity.CollabError); message.Message != "文档权限已变化，请重新加载" {
+		t.Fatal(message)
+	}
+	if _, ok := <-s.Messages(); ok {
+		t.Fatal("权限变化后应断开连接")
+	}
+	s.Leave()
+
+	// 重新加入后按新的权限加载
+	s = CollabJoin(document.Id, bob)
+	if init := collabExpect(t, s, entity.CollabInit); init.Role != entity.ShareViewer || init.Content != "abc1" {
+		t.Fatal(init)
+	}
+	s.Leave()
+}
+
+// 最后一个客户端离开时不持有房间锁保存，期间加入的客户端等待保存完成后加载新内容
+func TestCollabLeaveSave(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "collab")
+	document := DocumentAdd(entity.Document{Name: "doc", Content: "abc", Type: entity.DocMd, UserId: userId})
+
+	s := CollabJoin(document.Id, userId)
+	collabExpect(t, s, entity.CollabInit)
+	collabSend(t, s, 0, `[3,"1"]`)
+	collabExpect(t, s, entity.CollabAck)
+
+	room := s.room
+	room.saveMutex.Lock()
+	left := make(chan struct{})
+	go func() {
+		s.Leave()
+		close(left)
+	}()
+	for closed := false; !closed; time.Sleep(time.Millisecond) {
+		if !room.mutex.TryLock() {
+			continue
+		}
+		closed = room.closed
+		room.mutex.Unlock()
+	}
+
+	joined := make(chan *CollabSession)
+	go func() { joined <- CollabJoin(document.Id, userId) }()
+	select {
+	case <-joined:
+		t.Fatal("保存完成前不应加入")
+	case <-time.After(50 * time.Millisecond):
+	}
+	room.saveMutex.Unlock()
+	<-left
+	s = <-joined
+	if init := collabExpect(t, s, entity.CollabInit); init.Content != "abc1" {
+		t.Fatal(init)
+	}
+	s.Leave()
+}
@@ -28,14 +28,35 @@ func DocumentAdd(document entity.Document) entity.Document {
 	if document.Type != entity.DocMd && document.Type != entity.DocOpenApi {
 		panic(common.NewError("不支持的文档类型"))
 	}
+	if document.BookId == "" && document.ParentId != "" {
+		panic(common.NewError("未分类的文档不可放入文件夹"))
+	}
+	folderCheckParent(tx, document.BookId, document.ParentId, "", document.UserId, 0)
+	documentCheckOpenApi(document.Type, document.Content)
+	quotaCheckDocuments(tx, document.UserId, 1)
+
+	// 排在同级文档末尾
+	maxSort, err := dao.DocumentMaxSort(tx, document.BookId, document.ParentId, document.UserId)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+	document.Sort = maxSort + 1
 	document.Id = util.SnowflakeString()
 	document.CreateTime = time.Now().UnixMilli()
 	document.UpdateTime = time.Now().UnixMilli()
-	err := dao.DocumentAdd(tx, document)
+	err = dao.DocumentAdd(tx, document)
 	if err != nil {
 		panic(common.NewErr("添加失败", err))
 	}
 
+	// 记录初始版本
+	if document.Content != "" {
+		documentRevisionAdd(tx, document.Id, document.UserId, document.Content)
+	}
+
+	// 更新全文检索索引
+	documentSearchIndex(tx, document)
+
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("添加失败", err))
@@ -61,25 +82,79 @@ func DocumentUpdate(document entity.Document) {
 		panic(common.NewErr("更新失败", err))
 	}
 
+	// 更新全文检索索引
+	current, err := dao.DocumentGetById(tx, document.Id, document.UserId)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+	current.UserId = document.UserId
+	documentSearchIndex(tx, current)
+
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
 }
 
-// 修改文档内容
-func DocumentUpdateContent(document entity.Document) entity.Document {
+// 修改文档内容，填写了编辑时基于的更新时间或历史版本号时，文档已被修改则返回冲突及当前内容
+func DocumentUpdateContent(condition entity.DocumentContentCondition) entity.Document {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
-	if util.StringLength(document.Content) > 10000000 {
+	if util.StringLength(condition.Content) > 10000000 {
 		panic(common.NewError("文档内容过多，请小于1000万个字符"))
 	}
-	document.UpdateTime = time.Now().UnixMilli()
-	err := dao.DocumentUpdateContent(tx, document)
+
+	// 先锁定文档行，之后查询的内容、更新时间和版本号在提交前不会被并发的保存修改，避免版本号重复
+	err := dao.DocumentLock(tx, condition.Id)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+
+	// 查询当前内容，共享的文档需有编辑权限，按所有者保存
+	current := documentAccess(tx, condition.Id, condition.UserId, entity.ShareEditor)
+	document := entity.Document{Id: current.Id, Content: condition.Content, UserId: current.UserId}
+	documentCheckOpenApi(current.Type, document.Content)
+
+	number, err := dao.DocumentRevisionMaxNumber(tx, document.Id)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+	check := condition.UpdateTime != 0 || condition.Revision != nil
+	if condition.UpdateTime != 0 && condition.UpdateTime != current.UpdateTime ||
+		condition.Revision != nil && *condition.Revision != number {
+		documentConflict(current, number)
+	}
+
+	// 校验时仅在更新时间未变化时保存，避免并发保存覆盖；更新时间保证递增，客户端可据此判断是否已修改
+	document.UpdateTime = max(time.Now().UnixMilli(), current.UpdateTime+1)
+	expect := int64(0)
+	if check {
+		expect = current.UpdateTime
+	}
+	updated, err := dao.DocumentUpdateContent(tx, document, expect)
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
 	}
+	if !updated {
+		if check {
+			_ = tx.Rollback()
+			documentConflictCurrent(document.Id, condition.UserId)
+		}
+		panic(common.NewError("文档不存在"))
+	}
+
+	// 内容有变化时记录历史版本，旧文档首次更新时先补录原内容
+	if current.Content != document.Content {
+		if number == 0 && current.Content != "" {
+			documentRevisionAdd(tx, document.Id, document.UserId, current.Content)
+		}
+		documentRevisionAdd(tx, document.Id, document.UserId, document.Content)
+
+		// 更新全文检索索引
+		current.Content = document.Content
+		documentSearchIndex(tx, current)
+	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -89,6 +164,55 @@ func DocumentUpdateContent(document entity.Document) entity.Document {
 	return DocumentGet(document.Id, document.UserId)
 }
 
+// 校验OpenAPI文档，返回校验结果，传入id时校验已保存的文档
+func DocumentValidate(condition entity.DocumentValidateCondition, userId string) util.OpenApiValidateResult {
+	content := condition.Content
+	if condition.Id != "" {
+		document := documentAccess(middleware.Db, condition.Id, userId, entity.ShareViewer)
+		if document.Type != entity.DocOpenApi {
+			panic(common.NewError("仅支持校验OpenAPI文档"))
+		}
+		content = document.Content
+	}
+	if util.StringLength(content) > 10000000 {
+		panic(common.NewError("文档内容过多，请小于1000万个字符"))
+	}
+	return util.ValidateOpenApi(content, condition.Strict)
+}
+
+// 严格模式下保存OpenAPI文档时校验，存在错误时拒绝保存并返回校验结果，内容为空时不校验
+func documentCheckOpenApi(documentType entity.DocumentType, content string) {
+	if !common.OpenApiStrict || documentType != entity.DocOpenApi || strings.TrimSpace(content) == "" {
+		return
+	}
+	result := util.ValidateOpenApi(content, false)
+	if !result.Valid {
+		panic(common.NewErrorData(common.HttpFailure, "OpenAPI文档校验未通过", result))
+	}
+}
+
+// 保存时被并发修改，重新查询当前内容并返回冲突错误
+func documentConflictCurrent(id, userId string) {
+	tx := middleware.Db.MustBegin()
+	defer tx.Rollback()
+	current := documentAccess(tx, id, userId, entity.ShareEditor)
+	number, err := dao.DocumentRevisionMaxNumber(tx, id)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+	documentConflict(current, number)
+}
+
+// 文档已被修改，返回冲突错误及服务器当前内容
+func documentConflict(current entity.Document, revision int) {
+	panic(common.NewErrorData(common.HttpConflict, "文档已被修改，请合并后重新保存", entity.DocumentConflictResult{
+		Id:         current.Id,
+		Content:    current.Content,
+		UpdateTime: current.UpdateTime,
+		Revision:   revision,
+	}))
+}
+
 // 删除文档
 func DocumentDelete(id, userId string) {
 	tx := middleware.DbW.MustBegin()
@@ -99,14 +223,35 @@ func DocumentDelete(id, userId string) {
 		panic(common.NewErr("删除失败", err))
 	}
 
+	// 删除历史版本
+	err = dao.DocumentRevisionDeleteByDocumentId(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 删除全文检索索引
+	err = dao.DocumentSearchIndexDelete(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 删除共享
+	err = dao.ShareDeleteByResource(tx, entity.ShareDocument, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 }
 
-// 查询文档列表
+// 查询文档列表，可查询共享给当前用户的文集
 func DocumentList(bookId, userId string) []entity.Document {
+	if bookId != "" {
+		userId = bookAccess(bookId, userId, entity.ShareViewer).UserId
+	}
 	documents, err := dao.DocumentList(middleware.Db, bookId, userId)
 	if err != nil {
 		panic(common.NewErr("查询失败", err))
@@ -114,9 +259,14 @@ func DocumentList(bookId, userId string) []entity.Document {
 	return documents
 }
 
-// 查询文档
+// 查询文档，可查询共享给当前用户的文档
 func DocumentGet(id, userId string) entity.Document {
-	document, err := dao.DocumentGetById(middleware.Db, id, userId)
+	owner := documentAccess(middleware.Db, id, userId, entity.ShareViewer)
+	document, err := dao.DocumentGetById(middleware.Db, id, owner.UserId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	document.Revision, err = dao.DocumentRevisionMaxNumber(middleware.Db, id)
 	if err != nil {
 		panic(common.NewErr("查询失败", err))
 	}
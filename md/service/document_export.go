@@ -0,0 +1,354 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 导出时可读取的站内图片路径：/resource/picture/xxx.png，编辑器插入的图片地址包含域名，按地址中的路径匹配
+var exportResourceRegex = regexp.MustCompile(`^/resource/(picture|thumbnail)/([0-9A-Za-z_.\-]+)$`)
+
+// 导出文档为PDF、DOCX或EPUB，可导出共享给当前用户的文档，返回文件名（不含扩展名）
+func DocumentExport(id, userId, format string, w io.Writer) string {
+	exportCheckFormat(format)
+	document := documentAccess(middleware.Db, id, userId, entity.ShareViewer)
+	book := util.ExportBook{
+		Id:       document.Id,
+		Title:    document.Name,
+		Author:   exportAuthor(document.UserId),
+		Modified: time.UnixMilli(document.UpdateTime),
+		Chapters: []util.ExportChapter{{Content: exportContent(document)}},
+		Image:    exportImage,
+	}
+	if err := util.Export(book, format, w); err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	return document.Name
+}
+
+// 导出文集为PDF、DOCX或EPUB，文件夹和文档按目录树顺序作为章节，返回文件名（不含扩展名）
+func BookExport(id, userId, format string, w io.Writer) string {
+	exportCheckFormat(format)
+	book := bookAccess(id, userId, entity.ShareViewer)
+	folders, err := dao.FolderList(middleware.Db, book.Id, book.UserId)
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	documents, err := dao.DocumentListWithContent(middleware.Db, book.Id, book.UserId)
+	if err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+
+	// 按上级分组，上级不存在的节点放在根目录
+	exists := map[string]bool{}
+	for _, v := range folders {
+		exists[v.Id] = true
+	}
+	folderChildren := map[string][]entity.Folder{}
+	for _, v := range folders {
+		parentId := v.ParentId
+		if !exists[parentId] {
+			parentId = ""
+		}
+		folderChildren[parentId] = append(folderChildren[parentId], v)
+	}
+	documentChildren := map[string][]entity.Document{}
+	modified := book.CreateTime
+	for _, v := range documents {
+		parentId := v.ParentId
+		if !exists[parentId] {
+			parentId = ""
+		}
+		documentChildren[parentId] = append(documentChildren[parentId], v)
+		modified = max(modified, v.UpdateTime)
+	}
+
+	// 文件夹在前，文档在后，章节级别为目录树深度
+	var chapters []util.ExportChapter
+	var walk func(parentId string, depth int)
+	walk = func(parentId string, depth int) {
+		if depth <= maxFolderDepth {
+			for _, v := range folderChildren[parentId] {
+				chapters = append(chapters, util.ExportChapter{Title: v.Name, Level: depth})
+				walk(v.Id, depth+1)
+			}
+		}
+		for _, v := range documentChildren[parentId] {
+			chapters = append(chapters, util.ExportChapter{Title: v.Name, Level: depth, Link: documentLinkPrefix + v.Id, Content: exportContent(v)})
+		}
+	}
+	walk("", 1)
+
+	exportBook := util.ExportBook{
+		Id:       book.Id,
+		Title:    book.Name,
+		Author:   exportAuthor(book.UserId),
+		Modified: time.UnixMilli(modified),
+		Chapters: chapters,
+		Image:    exportImage,
+	}
+	if err = util.Export(exportBook, format, w); err != nil {
+		panic(common.NewErr("导出失败", err))
+	}
+	return book.Name
+}
+
+// 校验导出格式
+func exportCheckFormat(format string) {
+	if _, ok := util.ExportContentTypes[format]; !ok {
+		panic(common.NewError("不支持的导出格式，可选：pdf、docx、epub"))
+	}
+}
+
+// 作者为文档所有者的用户名
+func exportAuthor(userId string) string {
+	user, err := dao.UserGetById(middleware.Db, userId)
+	if err != nil {
+		return ""
+	}
+	return user.Name
+}
+
+// 读取站内图片，外部图片不下载
+func exportImage(src string) ([]byte, bool) {
+	address, err := url.Parse(src)
+	if err != nil || address.Scheme != "" && address.Scheme != "http" && address.Scheme != "https" {
+		return nil, false
+	}
+	match := exportResourceRegex.FindStringSubmatch(address.Path)
+	if match == nil || match[2] == "." || match[2] == ".." {
+		return nil, false
+	}
+	data, err := middleware.StorageReadAll(match[1] + "/" + match[2])
+	if err != nil {
+		middleware.Log.Warn("导出时读取图片失败：", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// 导出的Markdown内容，OpenAPI文档转为接口说明
+func exportContent(document entity.Document) string {
+	if document.Type == entity.DocOpenApi {
+		return openApiMarkdown(document)
+	}
+	return document.Content
+}
+
+// OpenAPI文档转为Markdown接口说明，结构与静态网站一致
+func openApiMarkdown(document entity.Document) string {
+	spec, err := util.ParseOpenApi(document.Content)
+	if err != nil {
+		return "OpenAPI文档解析失败：" + exportMarkdownCell(err.Error()) + "\n\n```yaml\n" + document.Content + "\n```\n"
+	}
+
+	var builder strings.Builder
+	info := util.MapValue(spec, "info")
+	var meta []string
+	if version := util.StringValue(info, "version"); version != "" {
+		meta = append(meta, "版本 "+version)
+	}
+	specVersion := util.StringValue(spec, "openapi")
+	if specVersion == "" {
+		specVersion = util.StringValue(spec, "swagger")
+	}
+	if specVersion != "" {
+		meta = append(meta, "OpenAPI "+specVersion)
+	}
+	if len(meta) > 0 {
+		builder.WriteString(exportMarkdownCell(strings.Join(meta, " · ")) + "\n\n")
+	}
+	if description := util.StringValue(info, "description"); description != "" {
+		builder.WriteString(description + "\n\n")
+	}
+
+	// 服务地址
+	var servers []string
+	for _, v := range util.ListValue(spec, "servers") {
+		server, _ := v.(map[string]interface{})
+		if url := util.StringValue(server, "url"); url != "" {
+			servers = append(servers, url)
+		}
+	}
+	if host := util.StringValue(spec, "host"); host != "" {
+		servers = append(servers, host+util.StringValue(spec, "basePath"))
+	}
+	if len(servers) > 0 {
+		builder.WriteString("# 服务地址\n\n")
+		for _, v := range servers {
+			builder.WriteString("- " + exportMarkdownCode(v) + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	// 接口
+	paths := util.MapValue(spec, "paths")
+	pathNames := siteSortedKeys(paths)
+	if len(pathNames) > 0 {
+		builder.WriteString("# 接口\n\n")
+	}
+	for _, path := range pathNames {
+		pathItem := util.Deref(spec, util.MapValue(paths, path))
+		for _, method := range util.OpenApiMethods {
+			operation := util.MapValue(pathItem, method)
+			if operation == nil {
+				continue
+			}
+			openApiOperationMarkdown(&builder, spec, pathItem, operation, method, path)
+		}
+	}
+
+	// 数据模型
+	schemas := util.MapValue(util.MapValue(spec, "components"), "schemas")
+	if schemas == nil {
+		schemas = util.MapValue(spec, "definitions")
+	}
+	schemaNames := siteSortedKeys(schemas)
+	if len(schemaNames) > 0 {
+		builder.WriteString("# 数据模型\n\n")
+	}
+	for _, name := range schemaNames {
+		schema := util.MapValue(schemas, name)
+		builder.WriteString("## " + exportMarkdownCell(name) + "\n\n")
+		builder.WriteString("类型：" + exportMarkdownCode(util.SchemaTypeName(schema)) + "\n\n")
+		if description := util.StringValue(schema, "description"); description != "" {
+			builder.WriteString(description + "\n\n")
+		}
+		openApiPropertiesMarkdown(&builder, schema)
+	}
+	return builder.String()
+}
+
+// 单个接口的Markdown说明
+func openApiOperationMarkdown(builder *strings.Builder, spec, pathItem, operation map[string]interface{}, method, path string) {
+	title := strings.ToUpper(method) + " " + path
+	if util.BoolValue(operation, "deprecated") {
+		title += "（已废弃）"
+	}
+	builder.WriteString("## " + exportMarkdownCell(title) + "\n\n")
+	if summary := util.StringValue(operation, "summary"); summary != "" {
+		builder.WriteString("**" + exportMarkdownCell(summary) + "**\n\n")
+	}
+	if description := util.StringValue(operation, "description"); description != "" {
+		builder.WriteString(description + "\n\n")
+	}
+
+	// 参数，接口级参数覆盖路径级同名参数
+	var parameters []map[string]interface{}
+	var body map[string]interface{}
+	index := map[string]int{}
+	for _, list := range [][]interface{}{util.ListValue(pathItem, "parameters"), util.ListValue(operation, "parameters")} {
+		for _, v := range list {
+			parameter, _ := v.(map[string]interface{})
+			parameter = util.Deref(spec, parameter)
+			if parameter == nil {
+				continue
+			}
+			if util.StringValue(parameter, "in") == "body" {
+				body = parameter
+				continue
+			}
+			key := util.StringValue(parameter, "in") + ":" + util.StringValue(parameter, "name")
+			if i, ok := index[key]; ok {
+				parameters[i] = parameter
+			} else {
+				index[key] = len(parameters)
+				parameters = append(parameters, parameter)
+			}
+		}
+	}
+	if len(parameters) > 0 {
+		builder.WriteString("**参数**\n\n| 名称 | 位置 | 类型 | 必填 | 说明 |\n| --- | --- | --- | --- | --- |\n")
+		for _, parameter := range parameters {
+			schema := util.MapValue(parameter, "schema")
+			if schema == nil {
+				schema = parameter
+			}
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", exportMarkdownCode(util.StringValue(parameter, "name")), exportMarkdownCell(util.StringValue(parameter, "in")),
+				exportMarkdownCell(util.SchemaTypeName(schema)), siteRequired(util.BoolValue(parameter, "required")), exportMarkdownCell(util.StringValue(parameter, "description"))))
+		}
+		builder.WriteString("\n")
+	}
+
+	// 请求体
+	requestBody := util.Deref(spec, util.MapValue(operation, "requestBody"))
+	if requestBody != nil || body != nil {
+		builder.WriteString("**请求体**\n\n| 类型 | 结构 | 说明 |\n| --- | --- | --- |\n")
+		if body != nil {
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s |\n", exportMarkdownCell(strings.Join(siteStrings(util.ListValue(operation, "consumes")), ", ")),
+				exportMarkdownCell(util.SchemaTypeName(util.MapValue(body, "schema"))), exportMarkdownCell(util.StringValue(body, "description"))))
+		}
+		content := util.MapValue(requestBody, "content")
+		for _, mediaType := range siteSortedKeys(content) {
+			schema := util.MapValue(util.MapValue(content, mediaType), "schema")
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s |\n", exportMarkdownCell(mediaType), exportMarkdownCell(util.SchemaTypeName(schema)), exportMarkdownCell(util.StringValue(requestBody, "description"))))
+		}
+		builder.WriteString("\n")
+	}
+
+	// 响应
+	responses := util.MapValue(operation, "responses")
+	if len(responses) > 0 {
+		builder.WriteString("**响应**\n\n| 状态码 | 结构 | 说明 |\n| --- | --- | --- |\n")
+		for _, code := range siteSortedKeys(responses) {
+			response := util.Deref(spec, util.MapValue(responses, code))
+			var types []string
+			if schema := util.MapValue(response, "schema"); schema != nil {
+				types = append(types, util.SchemaTypeName(schema))
+			}
+			content := util.MapValue(response, "content")
+			for _, mediaType := range siteSortedKeys(content) {
+				types = append(types, mediaType+": "+util.SchemaTypeName(util.MapValue(util.MapValue(content, mediaType), "schema")))
+			}
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s |\n", exportMarkdownCode(code), exportMarkdownCell(strings.Join(types, "; ")), exportMarkdownCell(util.StringValue(response, "description"))))
+		}
+		builder.WriteString("\n")
+	}
+}
+
+// 对象属性表格
+func openApiPropertiesMarkdown(builder *strings.Builder, schema map[string]interface{}) {
+	properties := util.MapValue(schema, "properties")
+	if len(properties) == 0 {
+		return
+	}
+	required := map[string]bool{}
+	for _, v := range siteStrings(util.ListValue(schema, "required")) {
+		required[v] = true
+	}
+	names := siteSortedKeys(properties)
+	builder.WriteString("| 属性 | 类型 | 必填 | 说明 |\n| --- | --- | --- | --- |\n")
+	for _, name := range names {
+		property := util.MapValue(properties, name)
+		builder.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", exportMarkdownCode(name), exportMarkdownCell(util.SchemaTypeName(property)), siteRequired(required[name]), exportMarkdownCell(util.StringValue(property, "description"))))
+	}
+	builder.WriteString("\n")
+}
+
+// 表格单元格、标题中的文字，转义Markdown符号并去掉换行
+func exportMarkdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", "&lt;", "#", `\#`).Replace(s)
+}
+
+// 行内代码，内容包含反引号时使用更长的定界符
+func exportMarkdownCode(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + " " + strings.ReplaceAll(s, "|", `\|`) + " " + fence
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	openApiImportMaxSize      = 20 << 20 // 导入OpenAPI文档时上传文件或下载内容的总大小限制
+	openApiImportMaxDownloads = 20       // 从URL导入时最多下载的文件数量（包括引用的其他地址）
+	openApiImportMaxRedirects = 5        // 下载时最多跟随的重定向次数
+)
+
+// 导入地址为内网地址
+var errOpenApiImportPrivate = errors.New("导入地址不可为内网地址")
+
+// 下载OpenAPI文档，与AI接口相同，未开启ai_private时建立连接前拒绝内网地址，不使用代理以保证校验的是实际连接的地址
+var openApiImportClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: util.PublicDialControl(func() bool { return common.AIPrivate }, errOpenApiImportPrivate),
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+	// 每次重定向重新校验地址
+	CheckRedirect: func(request *http.Request, via []*http.Request) error {
+		if len(via) >= openApiImportMaxRedirects {
+			return errors.New("重定向次数过多")
+		}
+		return openApiImportCheckUrl(request.URL)
+	},
+}
+
+// OpenAPI文件后缀
+var openApiImportExts = []string{".yaml", ".yml", ".json"}
+
+// 导入OpenAPI文档：支持上传YAML/JSON文件、包含多个文件的zip或从URL下载，
+// 相对路径的$ref合并为一个文档，Swagger 2.0转换为OpenAPI 3.0，导入为选择的文集中的OpenAPI文档
+func DocumentImportOpenApi(condition entity.DocumentImportCondition, files []*multipart.FileHeader, userId string) entity.Document {
+	if condition.BookId != "" {
+		_, err := dao.BookGetById(middleware.Db, condition.BookId, userId)
+		if err != nil {
+			panic(common.NewError("文集不存在"))
+		}
+	}
+
+	importer := &openApiImporter{files: map[string][]byte{}}
+	entry := strings.TrimSpace(condition.Url)
+	if entry != "" {
+		if !strings.HasPrefix(entry, "http://") && !strings.HasPrefix(entry, "https://") {
+			panic(common.NewError("仅支持http、https地址"))
+		}
+		importer.remote = true
+	} else {
+		for _, file := range files {
+			importer.readUpload(file)
+		}
+		entry = importer.entry(path.Clean(strings.TrimSpace(condition.Main)))
+	}
+
+	data, err := importer.load(entry)
+	if err != nil {
+		panic(common.NewErr("读取文档失败："+err.Error(), err))
+	}
+	root, err := util.BundleOpenApi(entry, importer.load)
+	if err != nil {
+		panic(common.NewErr("合并文档失败："+err.Error(), err))
+	}
+	if util.OpenApiField(root, "swagger") != nil {
+		err = util.ConvertSwagger2(root)
+		if err != nil {
+			panic(common.NewErr("转换Swagger 2.0文档失败", err))
+		}
+	} else if util.OpenApiField(root, "openapi") == nil {
+		panic(common.NewError("不是OpenAPI或Swagger文档"))
+	}
+
+	// 保持原文档的格式
+	content, err := util.OpenApiNodeString(root, strings.HasPrefix(strings.TrimSpace(string(data)), "{"))
+	if err != nil {
+		panic(common.NewErr("生成文档失败", err))
+	}
+
+	name := strings.TrimSpace(condition.Name)
+	if name == "" {
+		if title := util.OpenApiField(util.OpenApiField(root, "info"), "title"); title != nil {
+			name = strings.TrimSpace(title.Value)
+		}
+	}
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(entry), path.Ext(entry))
+	}
+	return DocumentAdd(entity.Document{
+		Name:     name,
+		Content:  content,
+		Type:     entity.DocOpenApi,
+		BookId:   condition.BookId,
+		ParentId: condition.ParentId,
+		UserId:   userId,
+	})
+}
+
+// OpenAPI文档导入时的文件来源
+type openApiImporter struct {
+	files     map[string][]byte // 上传的文件，路径 -> 内容
+	remote    bool              // 是否从URL导入，从URL导入时可下载引用的其他地址
+	size      int64             // 已读取的总大小
+	downloads int               // 已下载的文件数量
+}
+
+// 读取上传的文件，zip文件解压其中的YAML/JSON文件
+func (im *openApiImporter) readUpload(file *multipart.FileHeader) {
+	im.addSize(file.Size)
+	f, err := file.Open()
+	if err != nil {
+		panic(common.NewErr("读取文件失败", err))
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		panic(common.NewErr("读取文件失败", err))
+	}
+
+	if util.FileExt(file.Filename) != ".zip" {
+		if !slices.Contains(openApiImportExts, util.FileExt(file.Filename)) {
+			panic(common.NewError("仅支持YAML、JSON或zip文件：" + file.Filename))
+		}
+		im.files[path.Clean(path.Base(file.Filename))] = data
+		return
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		panic(common.NewErr("zip文件解析失败", err))
+	}
+	for _, item := range reader.File {
+		name := path.Clean(strings.ReplaceAll(item.Name, "\\", "/"))
+		if item.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(name, "../") ||
+			!slices.Contains(openApiImportExts, util.FileExt(name)) {
+			continue
+		}
+		im.addSize(int64(item.UncompressedSize64))
+		r, err := item.Open()
+		if err != nil {
+			panic(common.NewErr("zip文件解析失败", err))
+		}
+		content, err := io.ReadAll(io.LimitReader(r, openApiImportMaxSize+1))
+		r.Close()
+		if err != nil {
+			panic(common.NewErr("zip文件解析失败", err))
+		}
+		im.files[strings.TrimPrefix(name, "/")] = content
+	}
+}
+
+// 确定入口文件：指定的文件，或包含openapi、swagger字段且层级最浅的文件
+func (im *openApiImporter) entry(main string) string {
+	if len(im.files) == 0 {
+		panic(common.NewError("请上传文件或填写地址"))
+	}
+	if main != "." {
+		if _, ok := im.files[main]; !ok {
+			panic(common.NewError("入口文件不存在：" + main))
+		}
+		return main
+	}
+	candidates := []string{}
+	for name, data := range im.files {
+		document := map[string]interface{}{}
+		if yaml.Unmarshal(data, &document) == nil && (document["openapi"] != nil || document["swagger"] != nil) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		panic(common.NewError("未找到包含openapi或swagger字段的文件"))
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		di, dj := strings.Count(candidates[i], "/"), strings.Count(candidates[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates[0]
+}
+
+// 加载文件，上传的文件按路径查找，从URL导入时下载
+func (im *openApiImporter) load(name string) ([]byte, error) {
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		if !im.remote {
+			return nil, errors.New("上传的文件中不可引用网络地址")
+		}
+		if data, ok := im.files[name]; ok {
+			return data, nil
+		}
+		data, err := im.download(name)
+		if err == nil {
+			im.files[name] = data
+		}
+		return data, err
+	}
+	data, ok := im.files[path.Clean(name)]
+	if !ok {
+		return nil, errors.New("文件不存在")
+	}
+	return data, nil
+}
+
+// 下载文档，入口地址、引用的其他地址及重定向后的地址均校验不可为内网地址
+func (im *openApiImporter) download(rawUrl string) ([]byte, error) {
+	if im.downloads >= openApiImportMaxDownloads {
+		return nil, fmt.Errorf("引用的网络地址过多，最多%d个", openApiImportMaxDownloads)
+	}
+	im.downloads++
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, errors.New("地址格式错误")
+	}
+	if err = openApiImportCheckUrl(u); err != nil {
+		return nil, err
+	}
+	response, err := openApiImportClient.Get(u.String())
+	if err != nil {
+		if errors.Is(err, errOpenApiImportPrivate) {
+			return nil, errOpenApiImportPrivate
+		}
+		return nil, errors.New("下载失败")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载失败，状态码：%d", response.StatusCode)
+	}
+	if response.ContentLength > openApiImportMaxSize-im.size {
+		return nil, errors.New("文档总大小不可超过20MB")
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, openApiImportMaxSize-im.size+1))
+	if err != nil {
+		return nil, errors.New("下载失败")
+	}
+	if im.size+int64(len(data)) > openApiImportMaxSize {
+		return nil, errors.New("文档总大小不可超过20MB")
+	}
+	im.size += int64(len(data))
+	return data, nil
+}
+
+// 校验下载地址：仅支持http、https，未开启ai_private时解析域名并拒绝内网地址
+func openApiImportCheckUrl(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("仅支持http、https地址")
+	}
+	if u.User != nil {
+		return errors.New("地址不可包含用户信息")
+	}
+	if common.AIPrivate {
+		return nil
+	}
+	err := util.CheckPublicHost(u.Hostname(), errOpenApiImportPrivate)
+	if err != nil && err != errOpenApiImportPrivate {
+		return errors.New("无法解析域名：" + u.Hostname())
+	}
+	return err
+}
+
+// 累计读取的大小，超出限制时终止
+func (im *openApiImporter) addSize(size int64) {
+	im.size += size
+	if im.size > openApiImportMaxSize {
+		panic(common.NewError("文档总大小不可超过20MB"))
+	}
+}
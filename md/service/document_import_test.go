@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"md/model/entity"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 提供OpenAPI文档的服务：/chain/{n}.yaml 依次引用下一个文件，共depth层，/chain/redirect.yaml 重定向到入口文件
+func importTestServer(t *testing.T, depth int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/chain/redirect.yaml":
+			http.Redirect(w, r, "/chain/0.yaml", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/chain/"):
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/chain/"), ".yaml"))
+			switch {
+			case err != nil:
+				http.NotFound(w, r)
+			case n == 0:
+				fmt.Fprint(w, "openapi: 3.0.0\ninfo: {title: chain, version: '1'}\npaths: {}\ncomponents:\n  schemas:\n    A: {$ref: '1.yaml#/S'}\n")
+			case n < depth:
+				fmt.Fprintf(w, "S: {$ref: '%d.yaml#/S'}\n", n+1)
+			default:
+				fmt.Fprint(w, "S: {type: string}\n")
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// 从URL导入时入口地址、重定向地址和引用的地址不可为内网地址
+func TestDocumentImportOpenApiPrivateAddress(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "import")
+	server := importTestServer(t, 2)
+
+	aiAllowPrivate(t, false)
+	for _, u := range []string{server.URL + "/chain/0.yaml", "http://169.254.169.254/latest/meta-data", "http://localhost:1/a.yaml"} {
+		message := aiRecover(func() { DocumentImportOpenApi(entity.DocumentImportCondition{Url: u}, nil, userId) })
+		if !strings.Contains(message, errOpenApiImportPrivate.Error()) {
+			t.Errorf("%s：%q", u, message)
+		}
+	}
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := openApiImportClient.CheckRedirect(request, nil); err != errOpenApiImportPrivate {
+		t.Errorf("重定向到内网地址：%v", err)
+	}
+
+	aiAllowPrivate(t, true)
+	if message := aiRecover(func() {
+		document := DocumentImportOpenApi(entity.DocumentImportCondition{Url: server.URL + "/chain/redirect.yaml"}, nil, userId)
+		if document.Name != "chain" || !strings.Contains(document.Content, "type: string") {
+			t.Errorf("导入结果错误：%+v", document)
+		}
+	}); message != "" {
+		t.Fatal(message)
+	}
+}
+
+// 限制引用的层级和下载的文件数量
+func TestDocumentImportOpenApiRemoteLimit(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "import")
+	aiAllowPrivate(t, true)
+
+	server := importTestServer(t, 12)
+	message := aiRecover(func() {
+		DocumentImportOpenApi(entity.DocumentImportCondition{Url: server.URL + "/chain/0.yaml"}, nil, userId)
+	})
+	if !strings.Contains(message, "层级过深") {
+		t.Errorf("层级：%q", message)
+	}
+
+	wide := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wide.yaml" {
+			fmt.Fprint(w, "openapi: 3.0.0\ninfo: {title: wide, version: '1'}\npaths: {}\ncomponents:\n  schemas:\n")
+			for i := 0; i < openApiImportMaxDownloads+5; i++ {
+				fmt.Fprintf(w, "    S%d: {$ref: 'f%d.yaml#/S'}\n", i, i)
+			}
+			return
+		}
+		fmt.Fprint(w, "S: {type: string}\n")
+	}))
+	t.Cleanup(wide.Close)
+	message = aiRecover(func() {
+		DocumentImportOpenApi(entity.DocumentImportCondition{Url: wide.URL + "/wide.yaml"}, nil, userId)
+	})
+	if !strings.Contains(message, "网络地址过多") {
+		t.Errorf("数量：%q", message)
+	}
+}
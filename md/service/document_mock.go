@@ -0,0 +1,61 @@
+package service
+
+import (
+	"database/sql"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"sync"
+)
+
+const documentMockCacheSize = 100 // 缓存的模拟服务数量
+
+// 已生成的模拟服务，文档更新后重新生成
+type documentMock struct {
+	updateTime int64
+	mock       *util.OpenApiMock
+}
+
+var (
+	documentMocks     = map[string]documentMock{}
+	documentMockMutex sync.Mutex
+)
+
+// 按公开发布的OpenAPI文档模拟接口
+func DocumentMock(id string, request util.OpenApiMockRequest) util.OpenApiMockResponse {
+	document, err := dao.DocumentGetPublished(middleware.Db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文档不存在或未公开发布"))
+		}
+		panic(common.NewErr("查询失败", err))
+	}
+	if document.Type != entity.DocOpenApi {
+		panic(common.NewError("仅OpenAPI文档可模拟接口"))
+	}
+	return documentMockGet(document).Serve(request)
+}
+
+// 获取文档的模拟服务，未生成或文档已更新时重新生成
+func documentMockGet(document entity.Document) *util.OpenApiMock {
+	documentMockMutex.Lock()
+	cached, ok := documentMocks[document.Id]
+	documentMockMutex.Unlock()
+	if ok && cached.updateTime == document.UpdateTime {
+		return cached.mock
+	}
+
+	mock, err := util.NewOpenApiMock(document.Content)
+	if err != nil {
+		panic(common.NewErr("OpenAPI文档解析失败："+err.Error(), err))
+	}
+	documentMockMutex.Lock()
+	defer documentMockMutex.Unlock()
+	if len(documentMocks) >= documentMockCacheSize {
+		documentMocks = map[string]documentMock{}
+	}
+	documentMocks[document.Id] = documentMock{updateTime: document.UpdateTime, mock: mock}
+	return mock
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"database/sql"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询文档的历史版本列表
+func DocumentRevisionList(documentId, userId string) []entity.DocumentRevisionListItem {
+	document := documentAccess(middleware.Db, documentId, userId, entity.ShareViewer)
+	list, err := dao.DocumentRevisionList(middleware.Db, documentId, document.UserId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return list
+}
+
+// 查询历史版本
+func DocumentRevisionGet(id, userId string) entity.DocumentRevision {
+	revision, err := dao.DocumentRevisionGetById(middleware.Db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewErr("历史版本不存在", err))
+		}
+		panic(common.NewErr("查询失败", err))
+	}
+	document := documentAccess(middleware.Db, revision.DocumentId, userId, entity.ShareViewer)
+	if revision.UserId != document.UserId {
+		panic(common.NewError("历史版本不存在"))
+	}
+	return revision
+}
+
+// 恢复到指定历史版本，恢复操作本身也会生成新的版本
+func DocumentRevisionRestore(id, userId string) entity.Document {
+	revision := DocumentRevisionGet(id, userId)
+	return DocumentUpdateContent(entity.DocumentContentCondition{Id: revision.DocumentId, Content: revision.Content, UserId: userId})
+}
+
+// 比较两个历史版本，toId为空时与文档当前内容比较
+func DocumentRevisionDiff(fromId, toId, userId string) entity.DocumentRevisionDiff {
+	if fromId == "" {
+		panic(common.NewError("比较版本不可为空"))
+	}
+	from := DocumentRevisionGet(fromId, userId)
+
+	var toContent string
+	if toId == "" {
+		toContent = DocumentGet(from.DocumentId, userId).Content
+	} else {
+		to := DocumentRevisionGet(toId, userId)
+		if to.DocumentId != from.DocumentId {
+			panic(common.NewError("仅支持比较同一文档的版本"))
+		}
+		toContent = to.Content
+	}
+
+	result := entity.DocumentRevisionDiff{FromId: fromId, ToId: toId, Lines: util.DiffLines(from.Content, toContent)}
+	for _, v := range result.Lines {
+		switch v.Type {
+		case util.DiffInsert:
+			result.InsertCount++
+		case util.DiffDelete:
+			result.DeleteCount++
+		}
+	}
+	return result
+}
+
+// 比较OpenAPI文档的两个版本（两个文档或两个历史版本），列出不兼容的变更并生成变更日志
+func DocumentOpenApiDiff(condition entity.DocumentOpenApiDiffCondition, userId string) util.OpenApiDiffResult {
+	if condition.FromDocumentId == "" && condition.FromRevisionId == "" {
+		panic(common.NewError("旧版本不可为空"))
+	}
+	fromContent, documentId := documentOpenApiContent(condition.FromDocumentId, condition.FromRevisionId, userId)
+	if condition.ToDocumentId == "" && condition.ToRevisionId == "" {
+		condition.ToDocumentId = documentId
+	}
+	toContent, _ := documentOpenApiContent(condition.ToDocumentId, condition.ToRevisionId, userId)
+
+	result, err := util.DiffOpenApi(fromContent, toContent)
+	if err != nil {
+		panic(common.NewErr(err.Error(), err))
+	}
+	return result
+}
+
+// 查询OpenAPI文档的当前内容或历史版本内容，返回内容和所属文档id
+func documentOpenApiContent(documentId, revisionId, userId string) (string, string) {
+	if revisionId != "" {
+		revision := DocumentRevisionGet(revisionId, userId)
+		documentId = revision.DocumentId
+		document := documentAccess(middleware.Db, documentId, userId, entity.ShareViewer)
+		if document.Type != entity.DocOpenApi {
+			panic(common.NewError("仅支持比较OpenAPI文档"))
+		}
+		return revision.Content, documentId
+	}
+	document := DocumentGet(documentId, userId)
+	if document.Type != entity.DocOpenApi {
+		panic(common.NewError("仅支持比较OpenAPI文档"))
+	}
+	return document.Content, documentId
+}
+
+// 记录文档历史版本，需在文档内容变更的事务中调用，修改已有文档时需先锁定文档行（dao.DocumentLock）
+func documentRevisionAdd(tx *sqlx.Tx, documentId, userId, content string) {
+	number, err := dao.DocumentRevisionMaxNumber(tx, documentId)
+	if err != nil {
+		panic(common.NewErr("历史版本保存失败", err))
+	}
+	revision := entity.DocumentRevision{
+		Id:         util.SnowflakeString(),
+		DocumentId: documentId,
+		Revision:   number + 1,
+		Content:    content,
+		CreateTime: time.Now().UnixMilli(),
+		UserId:     userId,
+	}
+	err = dao.DocumentRevisionAdd(tx, revision)
+	if err != nil {
+		panic(common.NewErr("历史版本保存失败", err))
+	}
+
+	// 删除超出保留数量的最早版本
+	if common.RevisionKeep > 0 && revision.Revision > common.RevisionKeep {
+		err = dao.DocumentRevisionDeleteBefore(tx, documentId, revision.Revision-common.RevisionKeep)
+		if err != nil {
+			panic(common.NewErr("历史版本保存失败", err))
+		}
+	}
+}
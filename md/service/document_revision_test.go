@@ -0,0 +1,63 @@
+package service
+
+import (
+	"md/model/entity"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 并发保存时版本号按顺序分配，基于同一版本的保存只有一个成功，其余返回冲突
+func TestDocumentRevisionConcurrent(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "revision")
+	document := DocumentAdd(entity.Document{Name: "doc", Content: "0", Type: entity.DocMd, UserId: userId})
+
+	const count = 8
+	var wg sync.WaitGroup
+	messages := make([]string, count*2)
+	for i := 0; i < count; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			messages[i] = aiRecover(func() {
+				DocumentUpdateContent(entity.DocumentContentCondition{Id: document.Id, Content: "a" + strconv.Itoa(i), UserId: userId})
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			base := 1
+			messages[count+i] = aiRecover(func() {
+				DocumentUpdateContent(entity.DocumentContentCondition{Id: document.Id, Content: "b" + strconv.Itoa(i), Revision: &base, UserId: userId})
+			})
+		}()
+	}
+	wg.Wait()
+
+	conflicts := 0
+	for i, message := range messages {
+		switch {
+		case message == "":
+		case i >= count && message == "文档已被修改，请合并后重新保存":
+			conflicts++
+		default:
+			t.Errorf("第%d次保存失败：%s", i, message)
+		}
+	}
+	if conflicts < count-1 {
+		t.Errorf("基于同一版本的保存应只有一个成功：%d", conflicts)
+	}
+
+	list := DocumentRevisionList(document.Id, userId)
+	if len(list) != 1+count*2-conflicts {
+		t.Fatalf("版本数量错误：%d", len(list))
+	}
+	for i, v := range list {
+		if v.Revision != len(list)-i {
+			t.Fatalf("版本号不连续：%+v", list)
+		}
+	}
+	if current := DocumentGet(document.Id, userId); current.Revision != len(list) {
+		t.Errorf("当前版本号错误：%d", current.Revision)
+	}
+}
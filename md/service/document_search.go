@@ -0,0 +1,122 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 检索结果片段截取半径（字符数）
+const searchSnippetRadius = 60
+
+// 全文检索文档
+func DocumentSearch(pageCondition common.PageCondition[entity.DocumentSearchCondition], userId string) common.PageResult[entity.DocumentSearchResult] {
+	condition := pageCondition.Condition
+	condition.Keyword = strings.TrimSpace(condition.Keyword)
+	if condition.Keyword == "" {
+		panic(common.NewError("关键词不可为空"))
+	}
+	if util.StringLength(condition.Keyword) > 100 {
+		panic(common.NewError("关键词过长，请小于100个字符"))
+	}
+
+	// 无法分词或包含单个中日韩文字时（索引中按两字切分），使用模糊查询
+	tokens := util.UniqueTokens(util.SearchTokens(condition.Keyword))
+	useLike := len(tokens) == 0
+	for _, v := range tokens {
+		if r, size := utf8.DecodeRuneInString(v); size == len(v) && util.IsCJK(r) {
+			useLike = true
+			break
+		}
+	}
+
+	// 只查询内容中生成片段所需的部分
+	terms := strings.Fields(condition.Keyword)
+	var records []entity.DocumentSearchResult
+	var total int
+	var err error
+	if useLike {
+		records, total, err = dao.DocumentSearchLike(middleware.Db, condition.Keyword, terms, searchSnippetRadius, condition, pageCondition.Page, userId)
+	} else {
+		records, total, err = dao.DocumentSearchFts(middleware.Db, tokens, terms, searchSnippetRadius, condition, pageCondition.Page, userId)
+	}
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	// 生成高亮片段
+	for i := range records {
+		records[i].NameHighlight = util.HighlightSnippet(records[i].Name, terms, 0)
+		records[i].Snippet = util.HighlightSnippet(records[i].Content, terms, searchSnippetRadius)
+	}
+	return common.PageResult[entity.DocumentSearchResult]{Records: records, Total: total}
+}
+
+// 检查全文检索索引，与文档数量不一致时重建
+func DocumentSearchIndexInit() error {
+	documentCount, err := dao.DocumentCount(middleware.Db)
+	if err != nil {
+		middleware.Log.Error("查询文档数量失败：", err)
+		return err
+	}
+	indexCount, err := dao.DocumentSearchIndexCount(middleware.Db)
+	if err != nil {
+		middleware.Log.Error("查询全文检索索引失败：", err)
+		return err
+	}
+	if documentCount.Count == indexCount.Count {
+		return nil
+	}
+
+	middleware.Log.Info("正在重建全文检索索引")
+	ids, err := dao.DocumentIdList(middleware.Db)
+	if err != nil {
+		middleware.Log.Error("重建全文检索索引失败：", err)
+		return err
+	}
+	// 分批提交，避免长时间占用写连接
+	for start := 0; start < len(ids); start += 100 {
+		err = documentSearchIndexRebuild(ids[start:min(start+100, len(ids))])
+		if err != nil {
+			middleware.Log.Error("重建全文检索索引失败：", err)
+			return err
+		}
+	}
+	middleware.Log.Info("全文检索索引重建完成，共", len(ids), "个文档")
+	return nil
+}
+
+// 重建一批文档的索引
+func documentSearchIndexRebuild(ids []string) error {
+	tx, err := middleware.DbW.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		document, err := dao.DocumentGetByIdAnyUser(tx, id)
+		if err != nil {
+			return err
+		}
+		err = dao.DocumentSearchIndexSave(tx, document.Id, document.UserId, util.SearchTokens(document.Name), util.SearchTokens(document.Content))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 更新文档的全文检索索引，需在文档变更的事务中调用
+func documentSearchIndex(tx *sqlx.Tx, document entity.Document) {
+	err := dao.DocumentSearchIndexSave(tx, document.Id, document.UserId, util.SearchTokens(document.Name), util.SearchTokens(document.Content))
+	if err != nil {
+		panic(common.NewErr("全文检索索引更新失败", err))
+	}
+}
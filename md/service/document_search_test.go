@@ -0,0 +1,51 @@
+package service
+
+import (
+	"md/model/common"
+	"md/model/entity"
+	"strings"
+	"testing"
+)
+
+// 检索结果片段
+func documentSearchTest(keyword, userId string) []entity.DocumentSearchResult {
+	return DocumentSearch(common.PageCondition[entity.DocumentSearchCondition]{
+		Page:      common.Page{Current: 1, Size: 10},
+		Condition: entity.DocumentSearchCondition{Keyword: keyword},
+	}, userId).Records
+}
+
+// 只查询关键词附近的内容，片段与查询完整内容时一致
+func TestDocumentSearchSnippet(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "search")
+	content := strings.Repeat("filler ", 100000) + "the Needle is here " + strings.Repeat("tail ", 1000)
+	DocumentAdd(entity.Document{Name: "large", Content: content, Type: entity.DocMd, UserId: userId})
+
+	for _, keyword := range []string{"needle", "here needle"} {
+		records := documentSearchTest(keyword, userId)
+		if len(records) != 1 {
+			t.Fatalf("%s：%+v", keyword, records)
+		}
+		if len(records[0].Content) > searchSnippetRadius*5 {
+			t.Errorf("%s：查询了过多内容：%d", keyword, len(records[0].Content))
+		}
+		if !strings.Contains(records[0].Snippet, "the <mark>Needle</mark> is") || !strings.HasPrefix(records[0].Snippet, "...") {
+			t.Errorf("%s：片段错误：%q", keyword, records[0].Snippet)
+		}
+	}
+}
+
+// 模糊查询时%、_按普通字符匹配
+func TestDocumentSearchLikeEscape(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "search")
+	DocumentAdd(entity.Document{Name: "percent", Content: "完成度 100% 了", Type: entity.DocMd, UserId: userId})
+	DocumentAdd(entity.Document{Name: "other", Content: "完成度 1000 了", Type: entity.DocMd, UserId: userId})
+
+	for keyword, want := range map[string]int{"度 100%": 1, "度 1_0": 0, "% 了": 1, `度 \`: 0} {
+		if records := documentSearchTest(keyword, userId); len(records) != want {
+			t.Errorf("%s：%+v", keyword, records)
+		}
+	}
+}
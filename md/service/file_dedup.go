@@ -0,0 +1,70 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 大小和校验码相同的已有文件记录
+type dedupRecord struct {
+	Path   string
+	UserId string
+}
+
+// 上传时去重，相同大小和校验码的文件只保存一份：检查配额，无相同文件时调用put以filename保存文件，
+// 否则沿用已有记录的文件名。返回文件名，以及是否需要添加记录（自己已有相同文件时不添加）
+func dedupSave(records []dedupRecord, size int64, filename, userId string, put func(filename string)) (string, bool) {
+	// 检查配额，自己已存在相同文件时不占用新的空间
+	// 上传前先检查一次，避免超出配额时仍上传文件，添加记录时在事务中再次检查（见dedupCheckQuota）
+	if !slices.ContainsFunc(records, func(v dedupRecord) bool { return v.UserId == userId }) {
+		quotaCheckSize(middleware.Db, userId, size, len(records) == 0)
+	}
+	if len(records) == 0 {
+		put(filename)
+		return filename, true
+	}
+	for _, v := range records {
+		if v.UserId == userId {
+			return v.Path, false
+		}
+	}
+	return records[0].Path, true
+}
+
+// 在添加记录的写事务中再次检查配额，避免并发上传时超出配额；超出时删除本次新保存的文件（records为空时）
+func dedupCheckQuota(tx *sqlx.Tx, records []dedupRecord, size int64, userId string, keys ...string) {
+	defer func() {
+		if err := recover(); err != nil {
+			if len(records) == 0 {
+				for _, key := range keys {
+					if e := middleware.Storage.Delete(key); e != nil {
+						middleware.Log.Error("删除文件失败：", e)
+					}
+				}
+			}
+			panic(err)
+		}
+	}()
+	quotaCheckSize(tx, userId, size, len(records) == 0)
+}
+
+// 删除记录的事务提交后调用，没有记录再使用该文件时删除文件；
+// 在提交后按文件名重新计数，避免持有写事务时等待存储请求，也避免删除同时上传的相同文件
+func dedupDelete(count func() (common.CountResult, error), keys ...string) {
+	countResult, err := count()
+	if err != nil {
+		middleware.Log.Error("查询文件记录数量失败：", err)
+		return
+	}
+	if countResult.Count > 0 {
+		return
+	}
+	for _, key := range keys {
+		if err := middleware.Storage.Delete(key); err != nil {
+			middleware.Log.Error("删除文件失败：", err)
+		}
+	}
+}
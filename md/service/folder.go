@@ -0,0 +1,326 @@
+package service
+
+import (
+	"database/sql"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 文件夹最大层级
+const maxFolderDepth = 20
+
+// 添加文件夹
+func FolderAdd(folder entity.Folder) entity.Folder {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	folder.Name = strings.TrimSpace(folder.Name)
+	if folder.Name == "" {
+		panic(common.NewError("文件夹名称不可为空"))
+	}
+	if util.StringLength(folder.Name) > 1000 {
+		panic(common.NewError("文件夹名称过长，请小于1000个字符"))
+	}
+	if folder.BookId == "" {
+		panic(common.NewError("请选择文集"))
+	}
+	_, err := dao.BookGetById(middleware.Db, folder.BookId, folder.UserId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文集不存在"))
+		}
+		panic(common.NewErr("添加失败", err))
+	}
+	folderCheckParent(tx, folder.BookId, folder.ParentId, "", folder.UserId, 1)
+
+	// 排在同级文件夹末尾
+	maxSort, err := dao.FolderMaxSort(tx, folder.BookId, folder.ParentId, folder.UserId)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+	folder.Id = util.SnowflakeString()
+	folder.Sort = maxSort + 1
+	folder.CreateTime = time.Now().UnixMilli()
+	err = dao.FolderAdd(tx, folder)
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("添加失败", err))
+	}
+	return folder
+}
+
+// 修改文件夹名称
+func FolderUpdate(folder entity.Folder) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	folder.Name = strings.TrimSpace(folder.Name)
+	if folder.Name == "" {
+		panic(common.NewError("文件夹名称不可为空"))
+	}
+	if util.StringLength(folder.Name) > 1000 {
+		panic(common.NewError("文件夹名称过长，请小于1000个字符"))
+	}
+	_, err := dao.FolderGetById(tx, folder.Id, folder.UserId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文件夹不存在"))
+		}
+		panic(common.NewErr("更新失败", err))
+	}
+	err = dao.FolderUpdate(tx, folder)
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("更新失败", err))
+	}
+}
+
+// 删除文件夹，其中的文件夹和文档移动到上一级
+func FolderDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	folder, err := dao.FolderGetById(tx, id, userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文件夹不存在"))
+		}
+		panic(common.NewErr("删除失败", err))
+	}
+
+	// 移动到上一级同类节点之后
+	maxSort, err := dao.FolderMaxSort(tx, folder.BookId, folder.ParentId, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	err = dao.FolderMoveChildren(tx, folder.Id, folder.ParentId, maxSort+1, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	maxSort, err = dao.DocumentMaxSort(tx, folder.BookId, folder.ParentId, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+	err = dao.DocumentMoveChildren(tx, folder.Id, folder.ParentId, maxSort+1, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = dao.FolderDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("删除失败", err))
+	}
+}
+
+// 移动文件夹或文档，也用于同级排序
+func FolderMove(condition entity.FolderMoveCondition, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	switch condition.Kind {
+	case entity.TreeFolder:
+		folder, err := dao.FolderGetById(tx, condition.Id, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				panic(common.NewError("文件夹不存在"))
+			}
+			panic(common.NewErr("移动失败", err))
+		}
+		folderCheckParent(tx, folder.BookId, condition.ParentId, folder.Id, userId, folderHeight(tx, folder, userId))
+
+		// 重新排列目标位置的同级文件夹
+		siblings, err := dao.FolderListByParent(tx, folder.BookId, condition.ParentId, userId)
+		if err != nil {
+			panic(common.NewErr("移动失败", err))
+		}
+		ids := []string{}
+		for _, v := range siblings {
+			if v.Id != folder.Id {
+				ids = append(ids, v.Id)
+			}
+		}
+		for i, id := range folderInsert(ids, folder.Id, condition.Index) {
+			err = dao.FolderUpdateParent(tx, id, condition.ParentId, i, userId)
+			if err != nil {
+				panic(common.NewErr("移动失败", err))
+			}
+		}
+	case entity.TreeDocument:
+		document, err := dao.DocumentGetById(tx, condition.Id, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				panic(common.NewError("文档不存在"))
+			}
+			panic(common.NewErr("移动失败", err))
+		}
+		if condition.BookId == "" {
+			condition.BookId = document.BookId
+		} else if condition.BookId != document.BookId {
+			_, err = dao.BookGetById(middleware.Db, condition.BookId, userId)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					panic(common.NewError("文集不存在"))
+				}
+				panic(common.NewErr("移动失败", err))
+			}
+		}
+		if condition.BookId == "" && condition.ParentId != "" {
+			panic(common.NewError("未分类的文档不可放入文件夹"))
+		}
+		folderCheckParent(tx, condition.BookId, condition.ParentId, "", userId, 0)
+
+		// 重新排列目标位置的同级文档
+		siblings, err := dao.DocumentListByParent(tx, condition.BookId, condition.ParentId, userId)
+		if err != nil {
+			panic(common.NewErr("移动失败", err))
+		}
+		ids := []string{}
+		for _, v := range siblings {
+			if v.Id != document.Id {
+				ids = append(ids, v.Id)
+			}
+		}
+		for i, id := range folderInsert(ids, document.Id, condition.Index) {
+			err = dao.DocumentUpdateParent(tx, id, condition.BookId, condition.ParentId, i, userId)
+			if err != nil {
+				panic(common.NewErr("移动失败", err))
+			}
+		}
+	default:
+		panic(common.NewError("不支持的节点类型"))
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("移动失败", err))
+	}
+}
+
+// 查询文集的目录树，可查询共享给当前用户的文集，文件夹在前、文档在后，同类按排序值、名称升序
+func FolderTree(bookId, userId string) []entity.TreeNode {
+	folders := []entity.Folder{}
+	var err error
+	if bookId != "" {
+		userId = bookAccess(bookId, userId, entity.ShareViewer).UserId
+		folders, err = dao.FolderList(middleware.Db, bookId, userId)
+		if err != nil {
+			panic(common.NewErr("查询失败", err))
+		}
+	}
+	documents, err := dao.DocumentList(middleware.Db, bookId, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+
+	// 按上级分组，上级不存在的节点放在根目录
+	exists := map[string]bool{}
+	for _, v := range folders {
+		exists[v.Id] = true
+	}
+	children := map[string][]entity.TreeNode{}
+	for _, v := range folders {
+		parentId := v.ParentId
+		if !exists[parentId] {
+			parentId = ""
+		}
+		children[parentId] = append(children[parentId], entity.TreeNode{Id: v.Id, Name: v.Name, Kind: entity.TreeFolder, Sort: v.Sort, CreateTime: v.CreateTime})
+	}
+	documentChildren := map[string][]entity.TreeNode{}
+	for _, v := range documents {
+		parentId := v.ParentId
+		if !exists[parentId] {
+			parentId = ""
+		}
+		documentChildren[parentId] = append(documentChildren[parentId], entity.TreeNode{Id: v.Id, Name: v.Name, Kind: entity.TreeDocument, Type: v.Type, Published: v.Published, Sort: v.Sort, CreateTime: v.CreateTime, UpdateTime: v.UpdateTime})
+	}
+	return folderTreeBuild("", children, documentChildren, 0)
+}
+
+// 递归组装目录树
+func folderTreeBuild(parentId string, children, documentChildren map[string][]entity.TreeNode, depth int) []entity.TreeNode {
+	nodes := []entity.TreeNode{}
+	if depth < maxFolderDepth {
+		for _, v := range children[parentId] {
+			v.Children = folderTreeBuild(v.Id, children, documentChildren, depth+1)
+			nodes = append(nodes, v)
+		}
+	}
+	return append(nodes, documentChildren[parentId]...)
+}
+
+// 校验上级文件夹属于同一文集，移动文件夹时不可移动到自身或其子文件夹中；
+// height为放入的节点占用的层数（文档为0，文件夹为1加上其下子文件夹的层数），放入后不可超过最大层级
+func folderCheckParent(tx *sqlx.Tx, bookId, parentId, folderId, userId string, height int) {
+	if height > maxFolderDepth {
+		panic(common.NewError("文件夹层级过多"))
+	}
+	depth := 1
+	for id := parentId; id != ""; depth++ {
+		if id == folderId {
+			panic(common.NewError("不可移动到自身或其子文件夹中"))
+		}
+		if depth+height > maxFolderDepth {
+			panic(common.NewError("文件夹层级过多"))
+		}
+		parent, err := dao.FolderGetById(tx, id, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				panic(common.NewError("上级文件夹不存在"))
+			}
+			panic(common.NewErr("查询文件夹失败", err))
+		}
+		if parent.BookId != bookId {
+			panic(common.NewError("上级文件夹不在当前文集中"))
+		}
+		id = parent.ParentId
+	}
+}
+
+// 文件夹及其下子文件夹占用的层数
+func folderHeight(tx *sqlx.Tx, folder entity.Folder, userId string) int {
+	height := 0
+	for level := []string{folder.Id}; len(level) > 0 && height <= maxFolderDepth; height++ {
+		next := []string{}
+		for _, id := range level {
+			children, err := dao.FolderListByParent(tx, folder.BookId, id, userId)
+			if err != nil {
+				panic(common.NewErr("查询文件夹失败", err))
+			}
+			for _, v := range children {
+				next = append(next, v.Id)
+			}
+		}
+		level = next
+	}
+	return height
+}
+
+// 将id插入到列表的指定位置，位置为空或超出范围时放在末尾
+func folderInsert(ids []string, id string, index *int) []string {
+	if index == nil || *index < 0 || *index >= len(ids) {
+		return append(ids, id)
+	}
+	result := append([]string{}, ids[:*index]...)
+	result = append(result, id)
+	return append(result, ids[*index:]...)
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"md/model/entity"
+	"testing"
+)
+
+// 修改不存在或其他用户的文件夹时返回不存在
+func TestFolderUpdateNotFound(t *testing.T) {
+	testInitDb(t)
+	alice, bob := testAddUser(t, "alice"), testAddUser(t, "bob")
+	BookAdd(entity.Book{Name: "book", UserId: alice})
+	book := BookList(alice)[1]
+	folder := FolderAdd(entity.Folder{Name: "folder", BookId: book.Id, UserId: alice})
+
+	for _, v := range []entity.Folder{{Id: "none", Name: "new", UserId: alice}, {Id: folder.Id, Name: "new", UserId: bob}} {
+		if message := aiRecover(func() { FolderUpdate(v) }); message != "文件夹不存在" {
+			t.Errorf("%+v：%q", v, message)
+		}
+	}
+	if message := aiRecover(func() { FolderUpdate(entity.Folder{Id: folder.Id, Name: "new", UserId: alice}) }); message != "" {
+		t.Fatal(message)
+	}
+}
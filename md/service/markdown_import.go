@@ -0,0 +1,540 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	markdownImportMaxSize     = 200 << 20 // 导入Markdown时文件的总大小限制
+	markdownImportMaxWarnings = 100       // 最多返回的提示数量
+	documentLinkPrefix        = "/#/doc/" // 文档链接，导入时笔记之间的链接改为此格式，前端打开链接时跳转到文档编辑页
+)
+
+// Markdown文件后缀
+var markdownImportExts = []string{".md", ".markdown"}
+
+// 导入Markdown：第一层文件夹作为文集，其下的文件夹作为文件夹，根目录下的笔记放入以name命名的文集
+type markdownImporter struct {
+	userId   string
+	name     string              // 根目录名称
+	files    map[string][]byte   // 笔记和图片，相对根目录的路径 -> 内容
+	size     int64               // 已读取的总大小
+	notes    map[string]string   // 笔记路径 -> 文档id
+	names    map[string][]string // 小写的笔记名称（不含后缀） -> 笔记路径，用于解析wikilink
+	pictures map[string]string   // 图片路径 -> 图片地址
+	warnings []string
+}
+
+// 从上传的zip文件导入Markdown，name为根目录下的笔记所在文集的名称，为空时使用文件名
+func MarkdownImportUpload(file *multipart.FileHeader, name, userId string) entity.MarkdownImportResult {
+	if util.FileExt(file.Filename) != ".zip" {
+		panic(common.NewError("仅支持zip文件"))
+	}
+	f, err := file.Open()
+	if err != nil {
+		panic(common.NewErr("读取文件失败", err))
+	}
+	defer f.Close()
+	reader, err := zip.NewReader(f, file.Size)
+	if err != nil {
+		panic(common.NewErr("zip文件解析失败", err))
+	}
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(file.Filename), path.Ext(file.Filename))
+	}
+	im := newMarkdownImporter(name, userId)
+	im.readZip(reader)
+	return im.run()
+}
+
+// 从zip文件或目录（如Obsidian仓库）导入Markdown
+func MarkdownImportPath(source, userId string) entity.MarkdownImportResult {
+	info, err := os.Stat(source)
+	if err != nil {
+		panic(common.NewErr("读取文件失败", err))
+	}
+	name := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	if info.IsDir() {
+		name = filepath.Base(source)
+	}
+	im := newMarkdownImporter(name, userId)
+	if info.IsDir() {
+		im.readDir(source)
+		return im.run()
+	}
+	reader, err := zip.OpenReader(source)
+	if err != nil {
+		panic(common.NewErr("zip文件解析失败", err))
+	}
+	defer reader.Close()
+	im.readZip(&reader.Reader)
+	return im.run()
+}
+
+func newMarkdownImporter(name, userId string) *markdownImporter {
+	return &markdownImporter{
+		userId:   userId,
+		name:     strings.TrimSpace(name),
+		files:    map[string][]byte{},
+		notes:    map[string]string{},
+		names:    map[string][]string{},
+		pictures: map[string]string{},
+	}
+}
+
+// 读取zip中的笔记和图片
+func (im *markdownImporter) readZip(reader *zip.Reader) {
+	for _, item := range reader.File {
+		name := path.Clean(strings.ReplaceAll(item.Name, "\\", "/"))
+		if item.FileInfo().IsDir() || strings.HasPrefix(name, "../") || !im.accept(name) {
+			continue
+		}
+		im.addSize(int64(item.UncompressedSize64))
+		r, err := item.Open()
+		if err != nil {
+			panic(common.NewErr("zip文件解析失败", err))
+		}
+		content, err := io.ReadAll(io.LimitReader(r, markdownImportMaxSize+1))
+		r.Close()
+		if err != nil {
+			panic(common.NewErr("zip文件解析失败", err))
+		}
+		im.files[strings.TrimPrefix(name, "/")] = content
+	}
+}
+
+// 读取目录中的笔记和图片
+func (im *markdownImporter) readDir(dir string) {
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if entry.IsDir() {
+			// 跳过隐藏目录，如.obsidian、.trash、.git
+			if name != "." && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !im.accept(name) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		im.addSize(info.Size())
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		im.files[name] = content
+		return nil
+	})
+	if err != nil {
+		panic(common.NewErr("读取目录失败", err))
+	}
+}
+
+// 是否为需要导入的文件：笔记和图片，跳过隐藏文件及目录
+func (im *markdownImporter) accept(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") || segment == "__MACOSX" {
+			return false
+		}
+	}
+	ext := util.FileExt(name)
+	return slices.Contains(markdownImportExts, ext) || slices.Contains(pictureExts, ext)
+}
+
+// 累计文件大小，超出限制时终止导入
+func (im *markdownImporter) addSize(size int64) {
+	im.size += size
+	if im.size > markdownImportMaxSize {
+		panic(common.NewError("文件总大小不可超过200MB"))
+	}
+}
+
+// 记录提示信息
+func (im *markdownImporter) warn(format string, args ...interface{}) {
+	if len(im.warnings) < markdownImportMaxWarnings {
+		im.warnings = append(im.warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// 执行导入：上传引用的图片，创建文集、文件夹、文档，改写笔记之间的链接和图片地址
+func (im *markdownImporter) run() entity.MarkdownImportResult {
+	im.unwrap()
+	var notes []string
+	for name, content := range im.files {
+		if !slices.Contains(markdownImportExts, util.FileExt(name)) {
+			continue
+		}
+		if util.StringLength(string(content)) > 10000000 {
+			im.warn("%s内容过多，已跳过", name)
+			continue
+		}
+		notes = append(notes, name)
+	}
+	if len(notes) == 0 {
+		panic(common.NewError("未找到Markdown文件"))
+	}
+	sort.Strings(notes)
+	for _, note := range notes {
+		im.notes[note] = util.SnowflakeString()
+		key := strings.ToLower(markdownNoteName(note))
+		im.names[key] = append(im.names[key], note)
+	}
+
+	// 检查文档数量配额后再上传图片，图片超出配额时导入失败
+	quotaCheckDocuments(middleware.Db, im.userId, len(notes))
+
+	// 图片在事务外上传，与上传图片使用相同的去重逻辑，上传失败的图片保留原链接
+	im.uploadPictures(notes)
+
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+	// 上传图片期间可能已添加其他文档，在添加文档的事务中再次检查
+	quotaCheckDocuments(tx, im.userId, len(notes))
+	result := entity.MarkdownImportResult{Books: []entity.Book{}, PictureCount: len(im.pictures)}
+	books := map[string]entity.Book{} // 第一层文件夹名称 -> 文集
+	folders := map[string]string{}    // 文件夹路径 -> 文件夹id
+	sorts := map[string]int{}         // 文集id/上级文件夹id -> 已使用的排序号
+	now := time.Now().UnixMilli()
+	for _, note := range notes {
+		// 确定文集和文件夹
+		segments := strings.Split(note, "/")
+		bookName := im.name
+		if len(segments) > 1 {
+			bookName, segments = segments[0], segments[1:]
+		}
+		book, ok := books[bookName]
+		if !ok {
+			book = markdownImportBook(tx, bookName, im.userId, now)
+			books[bookName] = book
+			result.Books = append(result.Books, book)
+		}
+		parentId := ""
+		folderPath := bookName
+		folderSegments := segments[:len(segments)-1]
+		if len(folderSegments) > maxFolderDepth {
+			im.warn("%s的文件夹超过%d层，已放入第%d层文件夹", note, maxFolderDepth, maxFolderDepth)
+			folderSegments = folderSegments[:maxFolderDepth]
+		}
+		for _, segment := range folderSegments {
+			folderPath += "/" + segment
+			id, ok := folders[folderPath]
+			if !ok {
+				id = util.SnowflakeString()
+				sorts[book.Id+"/"+parentId+"/folder"]++
+				err := dao.FolderAdd(tx, entity.Folder{
+					Id:         id,
+					Name:       markdownImportName(segment),
+					ParentId:   parentId,
+					Sort:       sorts[book.Id+"/"+parentId+"/folder"],
+					CreateTime: now,
+					BookId:     book.Id,
+					UserId:     im.userId,
+				})
+				if err != nil {
+					panic(common.NewErr("导入失败", err))
+				}
+				folders[folderPath] = id
+				result.FolderCount++
+			}
+			parentId = id
+		}
+
+		sorts[book.Id+"/"+parentId+"/doc"]++
+		document := entity.Document{
+			Id:         im.notes[note],
+			Name:       markdownImportName(markdownNoteName(note)),
+			Content:    im.rewrite(note, string(im.files[note])),
+			Type:       entity.DocMd,
+			CreateTime: now,
+			UpdateTime: now,
+			BookId:     book.Id,
+			ParentId:   parentId,
+			Sort:       sorts[book.Id+"/"+parentId+"/doc"],
+			UserId:     im.userId,
+		}
+		err := dao.DocumentAdd(tx, document)
+		if err != nil {
+			panic(common.NewErr("导入失败", err))
+		}
+		if document.Content != "" {
+			documentRevisionAdd(tx, document.Id, document.UserId, document.Content)
+		}
+		documentSearchIndex(tx, document)
+		result.DocumentCount++
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("导入失败", err))
+	}
+	result.Warnings = im.warnings
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	return result
+}
+
+// 所有文件都在同一个文件夹中时（如压缩整个仓库），以该文件夹作为根目录
+func (im *markdownImporter) unwrap() {
+	root := ""
+	for name := range im.files {
+		first, _, nested := strings.Cut(name, "/")
+		if !nested || (root != "" && root != first) {
+			return
+		}
+		root = first
+	}
+	if root == "" {
+		return
+	}
+	files := make(map[string][]byte, len(im.files))
+	for name, content := range im.files {
+		files[strings.TrimPrefix(name, root+"/")] = content
+	}
+	im.files = files
+	im.name = root
+}
+
+// 上传笔记中引用的图片
+func (im *markdownImporter) uploadPictures(notes []string) {
+	for _, note := range notes {
+		util.ReplaceMarkdownLinks(string(im.files[note]), func(link *util.MarkdownLink) (string, bool) {
+			if !link.Embed {
+				return "", false
+			}
+			file := im.resolveFile(note, link)
+			if file == "" || !slices.Contains(pictureExts, util.FileExt(file)) {
+				return "", false
+			}
+			if _, ok := im.pictures[file]; ok {
+				return "", false
+			}
+			data := im.files[file]
+			if len(data) > 1000*1000*20 {
+				im.warn("图片%s超过20MB，未导入", file)
+				im.pictures[file] = ""
+				return "", false
+			}
+			im.pictures[file] = im.uploadPicture(file, data)
+			return "", false
+		})
+	}
+	for file, address := range im.pictures {
+		if address == "" {
+			delete(im.pictures, file)
+		}
+	}
+}
+
+// 上传单张图片，返回图片地址。图片无法解析、超出配额、保存失败时不终止导入，记录提示并返回空，笔记中保留原链接
+func (im *markdownImporter) uploadPicture(file string, data []byte) (address string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message := "图片解析失败"
+			if errorResponse, ok := r.(common.ErrorResponse); ok {
+				message = errorResponse.Message
+			} else {
+				middleware.Log.Error("导入图片失败：", file, r)
+			}
+			im.warn("图片%s未导入：%s", file, message)
+			address = ""
+		}
+	}()
+	// 导入时没有缩略图，由服务端生成；第二个返回值为提示信息（上传成功或图片已存在），导入时不需要
+	address, _ = pictureSave(data, nil, path.Base(file), im.userId)
+	return address
+}
+
+// 改写笔记中的链接：笔记之间的链接改为文档链接，图片改为上传后的地址
+func (im *markdownImporter) rewrite(note, content string) string {
+	return util.ReplaceMarkdownLinks(content, func(link *util.MarkdownLink) (string, bool) {
+		file := im.resolveFile(note, link)
+		if file == "" {
+			if link.Wiki || markdownLocalLink(link.Target) {
+				im.warn("%s中的链接%s未找到", note, link.Target)
+			}
+			return "", false
+		}
+		if address, ok := im.pictures[file]; ok {
+			return address, true
+		}
+		if id, ok := im.notes[file]; ok {
+			// 嵌入的笔记改为链接
+			link.Embed = false
+			return documentLinkPrefix + id, true
+		}
+		return "", false
+	})
+}
+
+// 解析链接指向的文件，返回相对根目录的路径，不是导入的文件时返回空
+func (im *markdownImporter) resolveFile(note string, link *util.MarkdownLink) string {
+	target := link.Target
+	if link.Wiki {
+		// [[笔记#标题|别名]]，仅有标题时为笔记内链接
+		target, _, _ = strings.Cut(target, "#")
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return ""
+		}
+		if _, ok := im.files[target]; ok {
+			return target
+		}
+		return im.resolveName(note, target)
+	}
+
+	if !markdownLocalLink(target) {
+		return ""
+	}
+	target, _, _ = strings.Cut(target, "#")
+	target, _, _ = strings.Cut(target, "?")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	if target == "" {
+		return ""
+	}
+	file := path.Join(path.Dir(note), target)
+	if strings.HasPrefix(target, "/") {
+		file = strings.TrimPrefix(path.Clean(target), "/")
+	}
+	if _, ok := im.files[file]; ok {
+		return file
+	}
+	if _, ok := im.files[file+".md"]; ok && util.FileExt(file) == "" {
+		return file + ".md"
+	}
+	// Obsidian中的链接也可只写文件名，按名称查找
+	return im.resolveName(note, target)
+}
+
+// 按名称查找文件（不区分大小写），名称可包含路径，多个同名文件时优先同一目录，其次路径最短的
+func (im *markdownImporter) resolveName(note, name string) string {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	ext := util.FileExt(name)
+	var candidates []string
+	if !slices.Contains(pictureExts, ext) {
+		key := strings.ToLower(name)
+		if slices.Contains(markdownImportExts, ext) {
+			key = strings.TrimSuffix(key, ext)
+		}
+		candidates = im.names[path.Base(key)]
+		if strings.Contains(key, "/") {
+			var matched []string
+			for _, v := range candidates {
+				if strings.HasSuffix(strings.ToLower(strings.TrimSuffix(v, util.FileExt(v))), key) {
+					matched = append(matched, v)
+				}
+			}
+			candidates = matched
+		}
+	}
+	if len(candidates) == 0 {
+		lower := strings.ToLower(name)
+		for file := range im.files {
+			if f := strings.ToLower(file); f == lower || strings.HasSuffix(f, "/"+lower) {
+				candidates = append(candidates, file)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if sameA, sameB := path.Dir(a) == path.Dir(note), path.Dir(b) == path.Dir(note); sameA != sameB {
+			return sameA
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return candidates[0]
+}
+
+// 是否为本地文件链接，不含协议、不是页内锚点
+func markdownLocalLink(target string) bool {
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") {
+		return false
+	}
+	if i := strings.Index(target, ":"); i > 0 && !strings.ContainsAny(target[:i], "/.#?") {
+		return false
+	}
+	return true
+}
+
+// 笔记名称，去掉路径和后缀
+func markdownNoteName(note string) string {
+	name := path.Base(note)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// 文集、文件夹、文档名称不可超过1000个字符
+func markdownImportName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "未命名"
+	}
+	if util.StringLength(name) > 1000 {
+		name = string([]rune(name)[:1000])
+	}
+	return name
+}
+
+// 创建文集，已存在同名文集时在名称后加序号
+func markdownImportBook(tx *sqlx.Tx, name, userId string, now int64) entity.Book {
+	name = markdownImportName(name)
+	for i := 1; ; i++ {
+		bookName := name
+		if i > 1 {
+			bookName = fmt.Sprintf("%s (%d)", name, i)
+		}
+		if bookName == "全部" {
+			continue
+		}
+		books, err := dao.BookListByName(tx, bookName, userId)
+		if err != nil {
+			panic(common.NewErr("导入失败", err))
+		}
+		if len(books) > 0 {
+			continue
+		}
+		book := entity.Book{Id: util.SnowflakeString(), Name: bookName, CreateTime: now, UserId: userId}
+		err = dao.BookAdd(tx, book)
+		if err != nil {
+			panic(common.NewErr("导入失败", err))
+		}
+		return book
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"md/middleware"
+	"md/model/entity"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 无法导入的图片不终止导入，保留原链接并返回提示
+func TestMarkdownImportBadPicture(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "import")
+	dir := t.TempDir() + "/vault"
+	files := map[string][]byte{
+		"note.md":  []byte("![good](good.png)\n![bad](bad.png)\n"),
+		"good.png": pictureTestPng(t, 20, 20, ""),
+		"bad.png":  bytes.Repeat([]byte("not a picture "), 10000),
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(dir+"/"+name, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := MarkdownImportPath(dir, userId)
+	if result.DocumentCount != 1 || result.PictureCount != 1 {
+		t.Fatalf("导入结果错误：%+v", result)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "bad.png") {
+		t.Errorf("应提示未导入的图片：%v", result.Warnings)
+	}
+	documents := []entity.Document{}
+	if err := middleware.Db.Select(&documents, `select * from t_document where user_id=$1`, userId); err != nil || len(documents) != 1 {
+		t.Fatal(documents, err)
+	}
+	content := documents[0].Content
+	if !strings.Contains(content, "![good](/resource/picture/") || !strings.Contains(content, "![bad](bad.png)") {
+		t.Errorf("链接改写错误：%s", content)
+	}
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"md/middleware"
+	"md/model/common"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 迁移数据库时每批复制的行数
+const migrateBatchSize = 500
+
+// 迁移数据库时复制的表及主键，全文检索索引两种数据库结构不同，在目标数据库启动时重建
+func migrateTables() [][2]string {
+	tables := [][2]string{}
+	for _, table := range append(slices.Clone(middleware.DataTables), "t_session") {
+		tables = append(tables, [2]string{table, "id"})
+	}
+	return append(tables, [2]string{"t_db_version", "version"})
+}
+
+// 在sqlite和postgres之间迁移全部数据，target为目标数据库类型，需在服务停止时执行
+func MigrateDB(target string) {
+	if target != common.DbSqlite && target != common.DbPostgres {
+		panic(common.NewError("目标数据库类型只能为sqlite或postgres"))
+	}
+	if !middleware.PostgresConfigured() {
+		panic(common.NewError("请填写postgres相关的5个参数"))
+	}
+
+	// 当前连接为postgres，另行连接数据目录下的sqlite
+	if target == common.DbPostgres {
+		if _, err := os.Stat(common.DataPath + "md.db"); err != nil {
+			panic(common.NewErr("sqlite数据库文件不存在", err))
+		}
+	}
+	sqlite, err := middleware.ConnectSqlite()
+	if err != nil {
+		panic(common.NewErr("开启sqlite数据库文件失败", err))
+	}
+	defer sqlite.Close()
+	sqlite.SetMaxOpenConns(1)
+	err = middleware.InitSchema(sqlite, common.DbSqlite)
+	if err != nil {
+		panic(common.NewErr("初始化sqlite数据库失败", err))
+	}
+
+	source, dest := sqlite, middleware.Db
+	if target == common.DbSqlite {
+		source, dest = middleware.Db, sqlite
+	}
+
+	// 目标数据库需为空
+	for _, table := range migrateTables() {
+		if table[0] == "t_db_version" {
+			continue
+		}
+		count, err := migrateCount(dest, table[0])
+		if err != nil {
+			panic(common.NewErr("查询目标数据库失败", err))
+		}
+		if count > 0 {
+			panic(common.NewError(fmt.Sprintf("目标数据库的表%s不为空（%d条），请使用空数据库", table[0], count)))
+		}
+	}
+
+	// 在一个事务中复制，失败时目标数据库不变
+	tx, err := dest.Beginx()
+	if err != nil {
+		panic(common.NewErr("迁移失败", err))
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`delete from t_db_version`)
+	if err != nil {
+		panic(common.NewErr("迁移失败", err))
+	}
+	for _, table := range migrateTables() {
+		count, err := migrateCopyTable(source, tx, table[0], table[1])
+		if err != nil {
+			panic(common.NewErr("复制表"+table[0]+"失败", err))
+		}
+
+		// 校验行数
+		sourceCount, err := migrateCount(source, table[0])
+		if err != nil {
+			panic(common.NewErr("查询源数据库失败", err))
+		}
+		destCount, err := migrateCount(tx, table[0])
+		if err != nil {
+			panic(common.NewErr("查询目标数据库失败", err))
+		}
+		if sourceCount != count || destCount != count {
+			panic(common.NewError(fmt.Sprintf("表%s行数校验失败：源%d条，已复制%d条，目标%d条", table[0], sourceCount, count, destCount)))
+		}
+		middleware.Log.Info("已复制", table[0], "：", count, "条")
+	}
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("迁移失败", err))
+	}
+	middleware.Log.Info("数据库迁移完成，目标数据库：", target)
+
+	// 目标为当前连接的postgres时直接重建全文检索索引，sqlite在使用其启动时重建
+	if target == common.DbPostgres {
+		err = DocumentSearchIndexInit()
+		if err != nil {
+			panic(common.NewErr("重建全文检索索引失败", err))
+		}
+	}
+}
+
+// 分批复制单个表，按主键顺序读取，返回复制的行数
+func migrateCopyTable(source *sqlx.DB, tx *sqlx.Tx, table, key string) (int, error) {
+	count := 0
+	var last interface{}
+	for {
+		var rows *sqlx.Rows
+		var err error
+		if last == nil {
+			rows, err = source.Queryx(fmt.Sprintf("select * from %s order by %s limit %d", table, key, migrateBatchSize))
+		} else {
+			rows, err = source.Queryx(fmt.Sprintf("select * from %s where %s>$1 order by %s limit %d", table, key, key, migrateBatchSize), last)
+		}
+		if err != nil {
+			return count, err
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return count, err
+		}
+		var values []interface{}
+		n := 0
+		for rows.Next() {
+			row, err := rows.SliceScan()
+			if err != nil {
+				rows.Close()
+				return count, err
+			}
+			for i, v := range row {
+				// 文本统一按字符串写入，避免sqlite中存为blob
+				if b, ok := v.([]byte); ok {
+					row[i] = string(b)
+				}
+				if columns[i] == key {
+					last = row[i]
+				}
+			}
+			values = append(values, row...)
+			n++
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return count, err
+		}
+		if n == 0 {
+			return count, nil
+		}
+
+		// 多行插入
+		placeholders := make([]string, n)
+		for i := 0; i < n; i++ {
+			params := make([]string, len(columns))
+			for j := range columns {
+				params[j] = fmt.Sprintf("$%d", i*len(columns)+j+1)
+			}
+			placeholders[i] = "(" + strings.Join(params, ",") + ")"
+		}
+		_, err = tx.Exec("insert into "+table+" ("+strings.Join(columns, ",")+") values "+strings.Join(placeholders, ","), values...)
+		if err != nil {
+			return count, err
+		}
+		count += n
+		if n < migrateBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// 查询表的行数
+func migrateCount(db sqlx.Queryer, table string) (int, error) {
+	result := common.CountResult{}
+	err := sqlx.Get(db, &result, "select count(*) as count from "+table)
+	return result.Count, err
+}
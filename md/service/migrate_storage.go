@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"md/middleware"
+	"md/model/common"
+)
+
+// 将当前文件存储中的全部文件复制到目标存储（local/s3），目标中已存在且大小相同的文件跳过，源文件保留。
+// 可重复执行，建议在服务停止时执行，或执行后停止服务再执行一次
+func MigrateStorage(target string) {
+	if target == common.StorageType {
+		panic(common.NewError("目标存储方式与当前存储方式相同：" + target))
+	}
+	dest, err := middleware.NewStorage(target)
+	if err != nil {
+		panic(common.NewErr("初始化目标存储失败", err))
+	}
+
+	sourceFiles, err := middleware.Storage.List("")
+	if err != nil {
+		panic(common.NewErr("读取文件列表失败", err))
+	}
+	destSizes, err := migrateStorageSizes(dest)
+	if err != nil {
+		panic(common.NewErr("读取目标存储文件列表失败", err))
+	}
+
+	// 复制文件
+	copied, skipped := 0, 0
+	for i, file := range sourceFiles {
+		if size, ok := destSizes[file.Key]; ok && size == file.Size {
+			skipped++
+			continue
+		}
+		reader, err := middleware.Storage.Open(file.Key)
+		if err != nil {
+			panic(common.NewErr("读取文件失败："+file.Key, err))
+		}
+		err = dest.Put(file.Key, reader, file.Size)
+		reader.Close()
+		if err != nil {
+			panic(common.NewErr("保存文件失败："+file.Key, err))
+		}
+		copied++
+		if (i+1)%100 == 0 {
+			middleware.Log.Infof("已处理%d/%d个文件", i+1, len(sourceFiles))
+		}
+	}
+
+	// 校验目标存储中的文件数量和大小
+	destSizes, err = migrateStorageSizes(dest)
+	if err != nil {
+		panic(common.NewErr("读取目标存储文件列表失败", err))
+	}
+	for _, file := range sourceFiles {
+		if size, ok := destSizes[file.Key]; !ok || size != file.Size {
+			panic(common.NewError(fmt.Sprintf("校验失败，目标存储中的文件缺失或大小不一致：%s", file.Key)))
+		}
+	}
+	middleware.Log.Infof("迁移完成，复制%d个文件，跳过%d个已存在的文件，源文件已保留。请使用 -storage %s 启动服务", copied, skipped, target)
+}
+
+// 目标存储中文件的大小
+func migrateStorageSizes(storage middleware.FileStorage) (map[string]int64, error) {
+	files, err := storage.List("")
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, file := range files {
+		sizes[file.Key] = file.Size
+	}
+	return sizes, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+)
+
+// 校验密码哈希参数
+func CheckPasswordParams() error {
+	if common.PasswordThreads < 1 || common.PasswordThreads > 255 {
+		return errors.New("密码哈希并行度需在1-255之间")
+	}
+	if common.PasswordTime < 1 {
+		return errors.New("密码哈希迭代次数不可小于1")
+	}
+	if common.PasswordMemory < 8*common.PasswordThreads {
+		return errors.New("密码哈希内存开销不可小于8倍并行度（KiB）")
+	}
+	return nil
+}
+
+// 当前的密码哈希参数
+func passwordParams() util.Argon2Params {
+	return util.Argon2Params{
+		Memory:  uint32(common.PasswordMemory),
+		Time:    uint32(common.PasswordTime),
+		Threads: uint8(common.PasswordThreads),
+	}
+}
+
+// 生成密码哈希
+func passwordHash(password string) string {
+	hash, err := util.HashPassword(password, passwordParams())
+	if err != nil {
+		panic(common.NewErr("密码加密失败", err))
+	}
+	return hash
+}
+
+// 校验用户密码，needUpgrade表示密码需要按当前方式重新哈希
+func passwordVerify(user entity.User, password string) (match bool, needUpgrade bool) {
+	// 旧版本：sha256(id + password)
+	if !util.IsPasswordHash(user.Password) {
+		legacy := util.EncryptSHA256([]byte(user.Id + password))
+		match = subtle.ConstantTimeCompare([]byte(legacy), []byte(user.Password)) == 1
+		return match, match
+	}
+	match, needUpgrade, err := util.VerifyPassword(password, user.Password, passwordParams())
+	if err != nil {
+		panic(common.NewErr("密码校验失败", err))
+	}
+	return match, needUpgrade
+}
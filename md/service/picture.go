@@ -1,6 +1,9 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"image"
 	"io"
 	"md/dao"
 	"md/middleware"
@@ -8,14 +11,44 @@ import (
 	"md/model/entity"
 	"md/util"
 	"mime/multipart"
-	"os"
 	"slices"
+	"strings"
 	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 支持的图片格式
+var (
+	pictureExts     = []string{".apng", ".bmp", ".gif", ".ico", ".jfif", ".jpeg", ".jpg", ".png", ".webp"}
+	pictureExtNames = "APNG、BMP、GIF、ICO、JPEG、PNG、WebP"
 )
 
-// 分页查询图片记录
-func PicturePage(pageCondition common.PageCondition[interface{}], userId string) common.PageResult[entity.PicturePageResult] {
-	pictures, total, err := dao.PicturePage(middleware.Db, pageCondition.Page, userId)
+// 分页查询图片记录，可按名称、上传时间、大小、格式筛选
+func PicturePage(pageCondition common.PageCondition[entity.PicturePageCondition], userId string) common.PageResult[entity.PicturePageResult] {
+	condition := &pageCondition.Condition
+	if !slices.Contains([]string{"", "createTime", "name", "size"}, condition.Sort) {
+		panic(common.NewError("不支持的排序字段"))
+	}
+	if condition.StartTime < 0 || condition.EndTime < 0 || condition.MinSize < 0 || condition.MaxSize < 0 {
+		panic(common.NewError("查询条件不可小于0"))
+	}
+	if condition.EndTime > 0 && condition.StartTime > condition.EndTime {
+		panic(common.NewError("开始时间不可晚于结束时间"))
+	}
+	if condition.MaxSize > 0 && condition.MinSize > condition.MaxSize {
+		panic(common.NewError("最小大小不可大于最大大小"))
+	}
+	condition.Name = strings.TrimSpace(condition.Name)
+	if condition.Type != "" {
+		condition.Type = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(condition.Type)), ".")
+		if !slices.Contains(pictureExts, condition.Type) {
+			panic(common.NewError("仅支持以下格式的图片：" + pictureExtNames))
+		}
+		condition.Exts = pictureTypeExts(condition.Type)
+	}
+
+	pictures, total, err := dao.PicturePage(middleware.Db, pageCondition, userId)
 	if err != nil {
 		panic(common.NewErr("查询失败", err))
 	}
@@ -27,69 +60,103 @@ func PicturePage(pageCondition common.PageCondition[interface{}], userId string)
 	return pageResult
 }
 
-// 删除图片
-func PictureDelete(id, userId string) {
+// 查询引用图片的文档
+func PictureReferences(id, userId string) []entity.PictureReference {
+	tx := middleware.Db.MustBegin()
+	defer tx.Rollback()
+	picture, err := dao.PictureGetById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return pictureReferences(tx, picture)
+}
+
+// 同一格式的文件后缀
+func pictureTypeExts(ext string) []string {
+	jpeg := []string{".jfif", ".jpeg", ".jpg"}
+	if slices.Contains(jpeg, ext) {
+		return jpeg
+	}
+	return []string{ext}
+}
+
+// 查询内容中包含图片或缩略图地址的文档。通常只查询当前用户的文档，
+// 图片记录是该文件的最后一条记录（删除后文件也会删除）时同时查询其他用户的文档，其他用户的文档只标记为其他用户，不返回内容
+func pictureReferences(tx *sqlx.Tx, picture entity.Picture) []entity.PictureReference {
+	countResult, err := dao.PictureCountByPath(tx, picture.Path)
+	if err != nil {
+		panic(common.NewErr("查询引用图片的文档失败", err))
+	}
+	userId := picture.UserId
+	if countResult.Count <= 1 {
+		userId = ""
+	}
+	references, err := dao.PictureReferenceList(tx,
+		"/"+common.ResourceName+"/"+common.PictureName+"/"+picture.Path,
+		"/"+common.ResourceName+"/"+common.ThumbnailName+"/"+picture.Path,
+		userId)
+	if err != nil {
+		panic(common.NewErr("查询引用图片的文档失败", err))
+	}
+	for i, v := range references {
+		if v.UserId != picture.UserId {
+			references[i] = entity.PictureReference{Other: true}
+		}
+	}
+	return references
+}
+
+// 删除图片，未指定force时图片仍被文档引用则返回冲突错误及引用的文档
+func PictureDelete(condition entity.PictureDeleteCondition, userId string) {
 	tx := middleware.DbW.MustBegin()
 	defer tx.Rollback()
 
 	// 查询图片
-	picture, err := dao.PictureGetById(tx, id, userId)
+	picture, err := dao.PictureGetById(tx, condition.Id, userId)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 
-	// 查询相同大小、相同hash的图片数量
-	countResult, err := dao.PictureCountBySizeHash(tx, picture.Size, picture.Hash)
-	if err != nil {
-		panic(common.NewErr("删除失败", err))
+	// 检查引用
+	if !condition.Force {
+		references := pictureReferences(tx, picture)
+		if len(references) > 0 {
+			panic(common.NewErrorData(common.HttpConflict, fmt.Sprintf("图片正在被%d个文档使用，确认后再删除", len(references)), references))
+		}
 	}
 
 	// 删除记录
-	err = dao.PictureDeleteById(tx, id, userId)
+	err = dao.PictureDeleteById(tx, condition.Id, userId)
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
 
-	// 如果相同的图片只有一条记录，删除文件
-	if countResult.Count == 1 {
-		os.Remove(common.DataPath + common.ResourceName + "/" + common.PictureName + "/" + picture.Path)
-		os.Remove(common.DataPath + common.ResourceName + "/" + common.ThumbnailName + "/" + picture.Path)
-	}
-
 	err = tx.Commit()
 	if err != nil {
 		panic(common.NewErr("删除失败", err))
 	}
+
+	// 没有记录再使用该文件时删除图片及缩略图
+	dedupDelete(func() (common.CountResult, error) { return dao.PictureCountByPath(middleware.Db, picture.Path) },
+		common.PictureName+"/"+picture.Path, common.ThumbnailName+"/"+picture.Path)
 }
 
-// 图片上传
+// 图片上传，未上传缩略图时由服务端生成
 func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumbnailInfo *multipart.FileHeader, userId string) (string, string) {
 	// 校验文件大小
 	if pictureInfo.Size == 0 {
 		panic(common.NewError("图片解析失败"))
 	}
-	if thumbnailInfo.Size == 0 {
-		panic(common.NewError("缩略图解析失败"))
-	}
 	if pictureInfo.Size > 1000*1000*20 {
 		panic(common.NewError("图片大小不可超过20MB"))
 	}
-	if thumbnailInfo.Size > 1000*100 {
-		panic(common.NewError("缩略图大小不可超过100KB"))
-	}
 
 	// 获取文件后缀
 	pictureExt := util.FileExt(pictureInfo.Filename)
-	thumbnailExt := util.FileExt(thumbnailInfo.Filename)
-	extArr := []string{".apng", ".bmp", ".gif", ".ico", ".jfif", ".jpeg", ".jpg", ".png", ".webp"}
-	extNames := "APNG、BMP、GIF、ICO、JPEG、PNG、WebP"
-	if !slices.Contains(extArr, pictureExt) {
-		panic(common.NewError("仅支持以下格式的图片：" + extNames))
-	}
-	if !slices.Contains(extArr, thumbnailExt) {
-		panic(common.NewError("仅支持以下格式的缩略图：" + extNames))
+	if !slices.Contains(pictureExts, pictureExt) {
+		panic(common.NewError("仅支持以下格式的图片：" + pictureExtNames))
 	}
-	if util.StringLength(pictureInfo.Filename) > 1000 || util.StringLength(thumbnailInfo.Filename) > 1000 {
+	if util.StringLength(pictureInfo.Filename) > 1000 {
 		panic(common.NewError("图片文件名称过长"))
 	}
 
@@ -98,70 +165,89 @@ func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumb
 	if err != nil {
 		panic(common.NewErr("图片解析失败", err))
 	}
-	thumbnailByte, err := io.ReadAll(thumbnailFile)
-	if err != nil {
-		panic(common.NewErr("缩略图解析失败", err))
+
+	// 校验并读取缩略图
+	var thumbnailByte []byte
+	if thumbnailFile != nil {
+		if thumbnailInfo.Size == 0 {
+			panic(common.NewError("缩略图解析失败"))
+		}
+		if thumbnailInfo.Size > 1000*100 {
+			panic(common.NewError("缩略图大小不可超过100KB"))
+		}
+		if !slices.Contains(pictureExts, util.FileExt(thumbnailInfo.Filename)) {
+			panic(common.NewError("仅支持以下格式的缩略图：" + pictureExtNames))
+		}
+		if util.StringLength(thumbnailInfo.Filename) > 1000 {
+			panic(common.NewError("图片文件名称过长"))
+		}
+		thumbnailByte, err = io.ReadAll(thumbnailFile)
+		if err != nil {
+			panic(common.NewErr("缩略图解析失败", err))
+		}
+	}
+
+	return pictureSave(pictureByte, thumbnailByte, pictureInfo.Filename, userId)
+}
+
+// 保存图片及缩略图，相同大小和校验码的图片只保存一份文件，返回图片地址和提示信息；
+// thumbnailByte为nil时由服务端生成缩略图
+func pictureSave(pictureByte, thumbnailByte []byte, name, userId string) (string, string) {
+	// 去除元数据、校正方向，按配置转为WebP，客户端上传的缩略图同样处理
+	pictureByte, ext := pictureProcess(pictureByte, util.FileExt(name))
+	if thumbnailByte != nil {
+		thumbnailByte, _ = pictureProcess(thumbnailByte, "")
 	}
 
 	// 生成sha256校验码
 	sha256Str := util.EncryptSHA256(pictureByte)
+	size := int64(len(pictureByte))
 
 	// 查询相同大小和校验码的文件
-	pictures, err := dao.PictureBySizeHash(middleware.Db, pictureInfo.Size, sha256Str)
+	pictures, err := dao.PictureBySizeHash(middleware.Db, size, sha256Str)
 	if err != nil {
 		panic(common.NewErr("图片上传失败", err))
 	}
 
-	// 生成文件名
-	filename := util.SnowflakeString() + pictureExt
+	records := []dedupRecord{}
+	for _, v := range pictures {
+		records = append(records, dedupRecord{Path: v.Path, UserId: v.UserId})
+	}
 
-	needAddRecord := true
-	message := "上传成功"
-	if len(pictures) == 0 {
-		// 无相同文件，保存文件
-		savePictureFile, err := os.Create(common.DataPath + common.ResourceName + "/" + common.PictureName + "/" + filename)
+	// 无相同文件时保存图片及缩略图
+	filename, needAddRecord := dedupSave(records, size, util.SnowflakeString()+ext, userId, func(filename string) {
+		err := middleware.StoragePutBytes(common.PictureName+"/"+filename, pictureByte)
 		if err != nil {
 			panic(common.NewErr("图片上传失败", err))
 		}
-		defer savePictureFile.Close()
-		_, err = savePictureFile.Write(pictureByte)
-		if err != nil {
-			panic(common.NewErr("图片上传失败", err))
-		}
-		// 保存缩略图
-		saveThumbnailFile, err := os.Create(common.DataPath + common.ResourceName + "/" + common.ThumbnailName + "/" + filename)
-		if err != nil {
-			panic(common.NewErr("图片上传失败", err))
+		if thumbnailByte == nil {
+			thumbnailByte, err = pictureThumbnail(pictureByte)
+			if err != nil {
+				panic(common.NewError("无法生成缩略图，请同时上传缩略图"))
+			}
 		}
-		defer saveThumbnailFile.Close()
-		_, err = saveThumbnailFile.Write(thumbnailByte)
+		err = middleware.StoragePutBytes(common.ThumbnailName+"/"+filename, thumbnailByte)
 		if err != nil {
 			panic(common.NewErr("图片上传失败", err))
 		}
-	} else {
-		// 存在相同文件，文件名使用原记录中的名字
-		for _, v := range pictures {
-			filename = v.Path
-			// 判断是否自己也存在相同文件，如存在则不添加记录
-			if v.UserId == userId {
-				needAddRecord = false
-				message = "图片已存在"
-				break
-			}
-		}
+	})
+	message := "上传成功"
+	if !needAddRecord {
+		message = "图片已存在"
 	}
 
 	// 添加记录
 	if needAddRecord {
 		tx := middleware.DbW.MustBegin()
 		defer tx.Rollback()
+		dedupCheckQuota(tx, records, size, userId, common.PictureName+"/"+filename, common.ThumbnailName+"/"+filename)
 		picture := entity.Picture{}
 		picture.Id = util.SnowflakeString()
 		picture.CreateTime = time.Now().UnixMilli()
-		picture.Name = pictureInfo.Filename
+		picture.Name = name
 		picture.Path = filename
 		picture.Hash = sha256Str
-		picture.Size = pictureInfo.Size
+		picture.Size = size
 		picture.UserId = userId
 		err = dao.PictureAdd(tx, picture)
 		if err != nil {
@@ -175,3 +261,67 @@ func PictureUpload(pictureFile, thumbnailFile multipart.File, pictureInfo, thumb
 
 	return "/" + common.ResourceName + "/" + common.PictureName + "/" + filename, message
 }
+
+// 处理上传的图片：去除EXIF（包括GPS位置）等元数据，按EXIF方向旋转图片，
+// 配置了PictureWebPSize时将超过大小的图片转为WebP，返回处理后的图片及文件后缀
+func pictureProcess(data []byte, ext string) ([]byte, string) {
+	format := util.ImageFormat(data)
+	data, orientation := util.ImageStripMetadata(data)
+	animated := format == "gif" || util.ImageAnimated(data)
+
+	var img image.Image
+	if orientation > 1 && !animated {
+		decoded, err := util.ImageDecode(data)
+		if err == nil {
+			img = util.ImageOrient(decoded, orientation)
+			oriented, err := util.ImageEncode(img, format, 90)
+			if err == nil {
+				data = oriented
+				// BMP编码为PNG
+				if format == "bmp" {
+					format, ext = "png", ".png"
+				}
+			}
+		}
+	}
+
+	// 带透明度的图片和动图不转换
+	maxSize := common.PictureWebPSize * 1000
+	if maxSize <= 0 || len(data) <= maxSize || animated || !slices.Contains([]string{"jpeg", "png", "bmp", "webp"}, format) {
+		return data, ext
+	}
+	if img == nil {
+		decoded, err := util.ImageDecode(data)
+		if err != nil {
+			return data, ext
+		}
+		img = decoded
+	}
+	if !util.ImageOpaque(img) {
+		return data, ext
+	}
+	webp, err := util.ImageToWebP(img, maxSize)
+	if err != nil {
+		middleware.Log.Warn("图片转换为WebP失败：", err)
+		return data, ext
+	}
+	if len(webp) >= len(data) {
+		return data, ext
+	}
+	return webp, ".webp"
+}
+
+// 生成缩略图，最大100×100像素，无法解码时不超过100KB的图片直接作为缩略图
+func pictureThumbnail(data []byte) ([]byte, error) {
+	img, err := util.ImageDecode(data)
+	if err == nil {
+		thumbnail, err := util.ImageEncode(util.ImageResize(img, 100, 100), util.ImageFormat(data), 80)
+		if err == nil {
+			return thumbnail, nil
+		}
+	}
+	if len(data) <= 1000*100 {
+		return data, nil
+	}
+	return nil, errors.New("无法生成缩略图")
+}
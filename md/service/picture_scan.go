@@ -0,0 +1,200 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 默认宽限天数
+const PictureScanGraceDays = 7
+
+// 最短宽限时间，宽限天数为0时也不清理此时间内的文件和记录，避免删除正在上传、尚未保存到文档中的图片
+const pictureScanMinGrace = 10 * time.Minute
+
+// 扫描图片：对照图片记录、图片及缩略图目录中的文件、文档（包括历史版本）中引用的图片地址，
+// 找出没有记录的文件、文件不存在的记录和未被引用的图片。清理时删除早于宽限天数的孤立文件和未被引用的图片，
+// 删除文件不存在的记录，并重新生成缺失的缩略图
+func PictureScan(condition entity.PictureScanCondition) entity.PictureScanResult {
+	if condition.GraceDays < 0 {
+		panic(common.NewError("宽限天数不可小于0"))
+	}
+
+	pictures, err := dao.PictureList(middleware.Db)
+	if err != nil {
+		panic(common.NewErr("查询图片失败", err))
+	}
+
+	// 文档中引用的图片文件名，缩略图地址也视为引用
+	referenceRegex := regexp.MustCompile("/" + regexp.QuoteMeta(common.ResourceName) + "/(?:" + regexp.QuoteMeta(common.PictureName) + "|" + regexp.QuoteMeta(common.ThumbnailName) + ")/([0-9A-Za-z_.\\-]+)")
+	referenced := map[string]bool{}
+	err = dao.DocumentContentEach(middleware.Db, func(content string) {
+		for _, match := range referenceRegex.FindAllStringSubmatch(content, -1) {
+			referenced[match[1]] = true
+		}
+	})
+	if err != nil {
+		panic(common.NewErr("查询文档失败", err))
+	}
+
+	// 目录中的文件
+	pictureFiles := pictureScanDir(common.PictureName)
+	thumbnailFiles := pictureScanDir(common.ThumbnailName)
+
+	result := entity.PictureScanResult{
+		OrphanFiles:       []entity.PictureScanFile{},
+		MissingFiles:      []entity.Picture{},
+		MissingThumbnails: []entity.Picture{},
+		Unreferenced:      []entity.Picture{},
+	}
+	paths := map[string]bool{}
+	for _, picture := range pictures {
+		paths[picture.Path] = true
+		if _, ok := pictureFiles[picture.Path]; !ok {
+			result.MissingFiles = append(result.MissingFiles, picture)
+			continue
+		}
+		if _, ok := thumbnailFiles[picture.Path]; !ok {
+			result.MissingThumbnails = append(result.MissingThumbnails, picture)
+		}
+		if !referenced[picture.Path] {
+			result.Unreferenced = append(result.Unreferenced, picture)
+		}
+	}
+	for _, files := range []map[string]entity.PictureScanFile{pictureFiles, thumbnailFiles} {
+		for name, file := range files {
+			if !paths[name] {
+				result.OrphanFiles = append(result.OrphanFiles, file)
+			}
+		}
+	}
+	sort.Slice(result.OrphanFiles, func(i, j int) bool {
+		if result.OrphanFiles[i].Dir != result.OrphanFiles[j].Dir {
+			return result.OrphanFiles[i].Dir < result.OrphanFiles[j].Dir
+		}
+		return result.OrphanFiles[i].Name < result.OrphanFiles[j].Name
+	})
+
+	if condition.Purge {
+		now := time.Now()
+		recent := now.Add(-pictureScanMinGrace)
+		cutoff := min(now.AddDate(0, 0, -condition.GraceDays).UnixMilli(), recent.UnixMilli())
+		pictureScanPurge(&result, pictures, map[string]map[string]entity.PictureScanFile{common.PictureName: pictureFiles, common.ThumbnailName: thumbnailFiles}, cutoff, recent.UnixMilli())
+	}
+	return result
+}
+
+// 列出图片或缩略图目录中的文件
+func pictureScanDir(dir string) map[string]entity.PictureScanFile {
+	list, err := middleware.Storage.List(dir + "/")
+	if err != nil {
+		panic(common.NewErr("读取图片目录失败", err))
+	}
+	files := map[string]entity.PictureScanFile{}
+	for _, v := range list {
+		name := strings.TrimPrefix(v.Key, dir+"/")
+		if strings.Contains(name, "/") {
+			continue
+		}
+		files[name] = entity.PictureScanFile{Dir: dir, Name: name, Size: v.Size, ModTime: v.ModTime.UnixMilli()}
+	}
+	return files
+}
+
+// 清理扫描结果，仅删除早于cutoff（毫秒时间戳）的孤立文件和未被引用的图片，files为按目录区分的现有文件
+func pictureScanPurge(result *entity.PictureScanResult, pictures []entity.Picture, files map[string]map[string]entity.PictureScanFile, cutoff, recent int64) {
+	// 孤立文件
+	for _, file := range result.OrphanFiles {
+		if file.ModTime >= cutoff {
+			continue
+		}
+		err := middleware.Storage.Delete(file.Dir + "/" + file.Name)
+		if err == nil {
+			result.PurgedFiles++
+		}
+	}
+
+	// 删除记录：文件不存在的记录，以及早于宽限时间且未被引用的记录
+	deleted := map[string]bool{}
+	for _, picture := range result.MissingFiles {
+		if picture.CreateTime < recent {
+			deleted[picture.Id] = true
+		}
+	}
+	for _, picture := range result.Unreferenced {
+		if picture.CreateTime < cutoff {
+			deleted[picture.Id] = true
+		}
+	}
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+	for id := range deleted {
+		err := dao.PictureDeleteByIdAnyUser(tx, id)
+		if err != nil {
+			panic(common.NewErr("清理图片失败", err))
+		}
+	}
+	err := tx.Commit()
+	if err != nil {
+		panic(common.NewErr("清理图片失败", err))
+	}
+	result.PurgedRecords = len(deleted)
+
+	// 相同文件的记录全部删除后，删除文件（图片文件不存在时删除剩余的缩略图）
+	remaining := map[string]bool{}
+	for _, picture := range pictures {
+		if !deleted[picture.Id] {
+			remaining[picture.Path] = true
+		}
+	}
+	removed := map[string]bool{}
+	for _, picture := range slices.Concat(result.MissingFiles, result.Unreferenced) {
+		if !deleted[picture.Id] || remaining[picture.Path] || removed[picture.Path] {
+			continue
+		}
+		removed[picture.Path] = true
+		// 扫描期间上传的相同图片会复用文件，删除前确认已没有记录
+		countResult, err := dao.PictureCountByPath(middleware.Db, picture.Path)
+		if err != nil || countResult.Count > 0 {
+			continue
+		}
+		for _, dir := range []string{common.PictureName, common.ThumbnailName} {
+			if _, ok := files[dir][picture.Path]; !ok {
+				continue
+			}
+			err := middleware.Storage.Delete(dir + "/" + picture.Path)
+			if err == nil {
+				result.PurgedFiles++
+			}
+		}
+	}
+
+	// 重新生成缺失的缩略图
+	repaired := map[string]bool{}
+	for _, picture := range result.MissingThumbnails {
+		if !remaining[picture.Path] || repaired[picture.Path] {
+			continue
+		}
+		repaired[picture.Path] = true
+		data, err := middleware.StorageReadAll(common.PictureName + "/" + picture.Path)
+		if err != nil {
+			continue
+		}
+		thumbnail, err := pictureThumbnail(data)
+		if err != nil {
+			middleware.Log.Warn("生成缩略图失败：", picture.Path)
+			continue
+		}
+		err = middleware.StoragePutBytes(common.ThumbnailName+"/"+picture.Path, thumbnail)
+		if err == nil {
+			result.RepairedThumbnails++
+		}
+	}
+	result.Purged = true
+}
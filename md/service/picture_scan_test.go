@@ -0,0 +1,46 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 宽限天数为0时不清理刚上传的图片和刚写入的文件
+func TestPictureScanMinGrace(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "scan")
+	url, _ := pictureSave(pictureTestPng(t, 20, 20, ""), nil, "a.png", userId)
+	filename := url[strings.LastIndex(url, "/")+1:]
+	orphan := common.PictureName + "/orphan.png"
+	if err := middleware.StoragePutBytes(orphan, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := PictureScan(entity.PictureScanCondition{Purge: true, GraceDays: 0})
+	if len(result.Unreferenced) != 1 || len(result.OrphanFiles) != 1 || result.PurgedFiles != 0 || result.PurgedRecords != 0 {
+		t.Fatalf("不应清理刚上传的图片：%+v", result)
+	}
+
+	// 超过最短宽限时间后清理
+	old := time.Now().Add(-2 * pictureScanMinGrace)
+	if _, err := middleware.DbW.Exec(`update t_picture set create_time=$1`, old.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(common.DataPath+common.ResourceName+"/"+orphan, old, old); err != nil {
+		t.Fatal(err)
+	}
+	result = PictureScan(entity.PictureScanCondition{Purge: true, GraceDays: 0})
+	if result.PurgedRecords != 1 || result.PurgedFiles != 3 {
+		t.Fatalf("清理数量错误：%+v", result)
+	}
+	for _, key := range []string{orphan, common.PictureName + "/" + filename, common.ThumbnailName + "/" + filename} {
+		if _, err := middleware.StorageReadAll(key); err == nil {
+			t.Errorf("%s 未删除", key)
+		}
+	}
+}
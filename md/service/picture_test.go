@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"strings"
+	"testing"
+)
+
+// 包含文本注释的PNG
+func pictureTestPng(t *testing.T, width, height int, comment string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	if comment == "" {
+		return data
+	}
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(comment)))
+	chunk = append(append(chunk, "tEXt"...), comment...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	ihdrEnd := 8 + 12 + 13
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+// 客户端上传的缩略图同样去除元数据
+func TestPictureSaveThumbnailMetadata(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "picture")
+
+	url, _ := pictureSave(pictureTestPng(t, 300, 200, "Comment\x00picture"), pictureTestPng(t, 30, 20, "Comment\x00thumbnail"), "a.png", userId)
+	filename := url[strings.LastIndex(url, "/")+1:]
+	for _, key := range []string{common.PictureName + "/" + filename, common.ThumbnailName + "/" + filename} {
+		data, err := middleware.StorageReadAll(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("tEXt")) {
+			t.Errorf("%s 未去除元数据", key)
+		}
+	}
+}
+
+// 按格式筛选时jpg、jpeg、jfif视为同一格式
+func TestPicturePageTypeAlias(t *testing.T) {
+	testInitDb(t)
+	userId := testAddUser(t, "type")
+	tx := middleware.DbW.MustBegin()
+	for i, path := range []string{"1.jpg", "2.jpeg", "3.jfif", "4.png", "5.apng"} {
+		tx.MustExec(`insert into t_picture (id,name,path,hash,size,create_time,user_id) values ($1,$2,$3,'',1,$4,$5)`, path, path, path, i, userId)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		Type  string
+		Total int
+	}{{"jpeg", 3}, {".JPG", 3}, {"jfif", 3}, {"png", 1}, {"apng", 1}, {"", 5}} {
+		result := PicturePage(common.PageCondition[entity.PicturePageCondition]{Page: common.Page{Current: 1, Size: 10}, Condition: entity.PicturePageCondition{Type: v.Type}}, userId)
+		if result.Total != v.Total || len(result.Records) != v.Total {
+			t.Errorf("%s：应为%d张，实际%d张", v.Type, v.Total, result.Total)
+		}
+	}
+}
+
+// 删除后文件也会删除时，检查引用包括其他用户的文档，且不返回其他用户文档的内容
+func TestPictureReferencesOtherUsers(t *testing.T) {
+	testInitDb(t)
+	alice, bob := testAddUser(t, "alice"), testAddUser(t, "bob")
+	aliceUrl, _ := pictureSave(pictureTestPng(t, 20, 20, ""), nil, "a.png", alice)
+	bobUrl, _ := pictureSave(pictureTestPng(t, 20, 20, ""), nil, "b.png", bob)
+	if aliceUrl != bobUrl {
+		t.Fatalf("相同图片应共用文件：%s %s", aliceUrl, bobUrl)
+	}
+	DocumentAdd(entity.Document{Name: "alice doc", Content: "![](" + aliceUrl + ")", Type: entity.DocMd, UserId: alice})
+	DocumentAdd(entity.Document{Name: "bob secret", Content: "![](" + bobUrl + ")", Type: entity.DocMd, UserId: bob})
+	pictureId := func(userId string) string {
+		pictures, _, err := dao.PicturePage(middleware.Db, common.PageCondition[entity.PicturePageCondition]{Page: common.Page{Current: 1, Size: 10}}, userId)
+		if err != nil || len(pictures) != 1 {
+			t.Fatal(pictures, err)
+		}
+		return pictures[0].Id
+	}
+
+	// 其他用户仍有记录，删除不影响其他用户的文档
+	references := PictureReferences(pictureId(alice), alice)
+	if len(references) != 1 || references[0].Name != "alice doc" || references[0].Other {
+		t.Fatalf("引用错误：%+v", references)
+	}
+	PictureDelete(entity.PictureDeleteCondition{Id: pictureId(alice), Force: true}, alice)
+
+	// 最后一条记录
+	references = PictureReferences(pictureId(bob), bob)
+	if len(references) != 2 {
+		t.Fatalf("应包括其他用户的文档：%+v", references)
+	}
+	for _, v := range references {
+		if v.Other != (v.Name == "") || v.Other && v.Id != "" {
+			t.Errorf("其他用户的文档不应返回内容：%+v", v)
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("仍被引用时应返回冲突")
+			}
+		}()
+		PictureDelete(entity.PictureDeleteCondition{Id: pictureId(bob)}, bob)
+	}()
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 查询用户的用量及配额，管理员同时返回全部用户的用量
+func UserUsage(userId string) entity.UserUsage {
+	pictures, err := dao.PictureUsage(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	attachments, err := dao.AttachmentUsage(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	documents, err := dao.DocumentCountByUser(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	usage := entity.UserUsage{
+		Pictures:    pictures,
+		Attachments: attachments,
+		Size:        entity.UsageQuota{Used: pictures.Size + attachments.Size, Limit: int64(common.QuotaSize) * 1000 * 1000},
+		Documents:   entity.UsageQuota{Used: int64(documents.Count), Limit: int64(common.QuotaDocuments)},
+	}
+
+	if middleware.IsAdmin(userId) {
+		size, err := quotaTotalSize(middleware.Db)
+		if err != nil {
+			panic(common.NewErr("查询失败", err))
+		}
+		documents, err := dao.DocumentCount(middleware.Db)
+		if err != nil {
+			panic(common.NewErr("查询失败", err))
+		}
+		usage.Global = &entity.GlobalUsage{
+			Size:      entity.UsageQuota{Used: size, Limit: int64(common.QuotaTotalSize) * 1000 * 1000},
+			Documents: entity.UsageQuota{Used: int64(documents.Count), Limit: int64(common.QuotaTotalDocs)},
+		}
+	}
+	return usage
+}
+
+// 检查文件大小配额，size为新增的文件大小，newFile为是否需要保存新文件（与已有文件不重复）。
+// 用户按上传的文件大小计算（与其他用户共用的文件也全额计算），全部用户按实际保存的文件计算。
+// db为添加记录的写事务时先锁定用户，避免同一用户并发添加时超出配额
+func quotaCheckSize(db interface{}, userId string, size int64, newFile bool) {
+	quotaLock(db, userId)
+	if common.QuotaSize > 0 {
+		pictures, err := dao.PictureUsage(db, userId)
+		if err != nil {
+			panic(common.NewErr("查询存储空间失败", err))
+		}
+		attachments, err := dao.AttachmentUsage(db, userId)
+		if err != nil {
+			panic(common.NewErr("查询存储空间失败", err))
+		}
+		if pictures.Size+attachments.Size+size > int64(common.QuotaSize)*1000*1000 {
+			panic(common.NewError(fmt.Sprintf("存储空间不足，每个用户可使用%dMB，已使用%.1fMB", common.QuotaSize, float64(pictures.Size+attachments.Size)/1000/1000)))
+		}
+	}
+	if newFile && common.QuotaTotalSize > 0 {
+		total, err := quotaTotalSize(db)
+		if err != nil {
+			panic(common.NewErr("查询存储空间失败", err))
+		}
+		if total+size > int64(common.QuotaTotalSize)*1000*1000 {
+			panic(common.NewError("服务器存储空间已满，请联系管理员"))
+		}
+	}
+}
+
+// 检查文档数量配额，count为将要添加的文档数量，db为添加文档的写事务时先锁定用户
+func quotaCheckDocuments(db interface{}, userId string, count int) {
+	quotaLock(db, userId)
+	if common.QuotaDocuments > 0 {
+		documents, err := dao.DocumentCountByUser(db, userId)
+		if err != nil {
+			panic(common.NewErr("查询文档数量失败", err))
+		}
+		if documents.Count+count > common.QuotaDocuments {
+			panic(common.NewError(fmt.Sprintf("文档数量超出配额，每个用户最多%d个文档，已有%d个", common.QuotaDocuments, documents.Count)))
+		}
+	}
+	if common.QuotaTotalDocs > 0 {
+		documents, err := dao.DocumentCount(db)
+		if err != nil {
+			panic(common.NewErr("查询文档数量失败", err))
+		}
+		if documents.Count+count > common.QuotaTotalDocs {
+			panic(common.NewError("服务器文档数量已达上限，请联系管理员"))
+		}
+	}
+}
+
+// 全部图片和附件文件的大小
+func quotaTotalSize(db interface{}) (int64, error) {
+	pictures, err := dao.PictureSizeTotal(db)
+	if err != nil {
+		return 0, err
+	}
+	attachments, err := dao.AttachmentSizeTotal(db)
+	return pictures + attachments, err
+}
+
+// 在写事务中检查配额时锁定用户，未配置配额时不锁定
+func quotaLock(db interface{}, userId string) {
+	tx, ok := db.(*sqlx.Tx)
+	if !ok || common.QuotaSize <= 0 && common.QuotaTotalSize <= 0 && common.QuotaDocuments <= 0 && common.QuotaTotalDocs <= 0 {
+		return
+	}
+	if err := dao.UserLock(tx, userId); err != nil {
+		panic(common.NewErr("查询配额失败", err))
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 设置配额，测试结束后恢复
+func quotaTestSet(t *testing.T, size, documents int) {
+	t.Helper()
+	oldSize, oldDocuments := common.QuotaSize, common.QuotaDocuments
+	common.QuotaSize, common.QuotaDocuments = size, documents
+	t.Cleanup(func() { common.QuotaSize, common.QuotaDocuments = oldSize, oldDocuments })
+}
+
+// 并发添加文档时不超出配额
+func TestQuotaDocumentsConcurrent(t *testing.T) {
+	testInitDb(t)
+	quotaTestSet(t, 0, 3)
+	userId := testAddUser(t, "quota")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			aiRecover(func() {
+				DocumentAdd(entity.Document{Name: "doc" + strconv.Itoa(i), Type: entity.DocMd, UserId: userId})
+			})
+		}()
+	}
+	wg.Wait()
+	if documents, err := dao.DocumentCountByUser(middleware.Db, userId); err != nil || documents.Count != 3 {
+		t.Errorf("文档数量超出配额：%+v %v", documents, err)
+	}
+}
+
+// 添加记录时再次检查配额，超出时删除新保存的文件
+func TestQuotaCheckInTransaction(t *testing.T) {
+	testInitDb(t)
+	quotaTestSet(t, 1, 0)
+	userId := testAddUser(t, "quota")
+
+	// 上传前检查通过后，其他请求已用满配额
+	key := common.AttachmentName + "/new.txt"
+	if err := middleware.StoragePutBytes(key, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	tx := middleware.DbW.MustBegin()
+	err := dao.AttachmentAdd(tx, entity.Attachment{Id: "1", Name: "a.txt", Path: "a.txt", Hash: "a", Size: 1000 * 1000, Mime: "text/plain", CreateTime: time.Now().UnixMilli(), UserId: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := aiRecover(func() { dedupCheckQuota(tx, nil, 3, userId, key) })
+	tx.Rollback()
+	if message == "" {
+		t.Fatal("超出配额时应失败")
+	}
+	if _, err := middleware.StorageReadAll(key); err == nil {
+		t.Error("超出配额时应删除新保存的文件")
+	}
+}
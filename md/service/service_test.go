@@ -0,0 +1,54 @@
+package service
+
+import (
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"testing"
+	"time"
+
+	"github.com/kataras/golog"
+)
+
+// 使用临时目录的sqlite数据库和本地文件存储，测试结束后关闭并恢复
+func testInitDb(t *testing.T) {
+	t.Helper()
+	if middleware.Log == nil {
+		middleware.Log = golog.New()
+		middleware.Log.SetLevel("error")
+	}
+	if err := util.InitSnowflake(0); err != nil {
+		t.Fatal(err)
+	}
+	common.ResourceName, common.PictureName, common.ThumbnailName, common.AttachmentName = "resource", "picture", "thumbnail", "attachment"
+	dataPath, db, dbW, storage := common.DataPath, middleware.Db, middleware.DbW, middleware.Storage
+	common.DataPath = t.TempDir() + "/"
+	if err := middleware.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if middleware.Storage, err = middleware.NewStorage(middleware.StorageLocal); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		middleware.CloseDB()
+		common.DataPath, middleware.Db, middleware.DbW, middleware.Storage = dataPath, db, dbW, storage
+	})
+}
+
+// 添加测试用户，返回用户id
+func testAddUser(t *testing.T, name string) string {
+	t.Helper()
+	user := entity.User{Id: util.SnowflakeString(), Name: name, Password: "-", CreateTime: time.Now().UnixMilli()}
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+	if err := dao.UserAdd(tx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return user.Id
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"database/sql"
+	"md/dao"
+	"md/middleware"
+	"md/model/common"
+	"md/model/entity"
+	"md/util"
+	"strings"
+	"time"
+)
+
+// 共享文集或文档给其他用户，已共享时修改权限
+func ShareAdd(condition entity.ShareAddCondition, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	if condition.Role != entity.ShareViewer && condition.Role != entity.ShareEditor {
+		panic(common.NewError("不支持的权限类型"))
+	}
+
+	// 仅所有者可共享
+	switch condition.ResourceType {
+	case entity.ShareBook:
+		_, err := dao.BookGetById(middleware.Db, condition.ResourceId, userId)
+		if err != nil {
+			panic(common.NewError("文集不存在"))
+		}
+	case entity.ShareDocument:
+		_, err := dao.DocumentGetById(tx, condition.ResourceId, userId)
+		if err != nil {
+			panic(common.NewError("文档不存在"))
+		}
+	default:
+		panic(common.NewError("不支持的共享类型"))
+	}
+
+	user, err := dao.UserGetByName(middleware.Db, strings.TrimSpace(condition.UserName))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("用户不存在"))
+		}
+		panic(common.NewErr("共享失败", err))
+	}
+	if user.Id == userId {
+		panic(common.NewError("不可共享给自己"))
+	}
+
+	shares, err := dao.ShareGetByResourceUser(tx, condition.ResourceType, condition.ResourceId, user.Id)
+	if err != nil {
+		panic(common.NewErr("共享失败", err))
+	}
+	if len(shares) > 0 {
+		err = dao.ShareUpdateRole(tx, shares[0].Id, condition.Role)
+	} else {
+		err = dao.ShareAdd(tx, entity.Share{
+			Id:           util.SnowflakeString(),
+			ResourceType: condition.ResourceType,
+			ResourceId:   condition.ResourceId,
+			Role:         condition.Role,
+			CreateTime:   time.Now().UnixMilli(),
+			OwnerId:      userId,
+			UserId:       user.Id,
+		})
+	}
+	if err != nil {
+		panic(common.NewErr("共享失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("共享失败", err))
+	}
+}
+
+// 取消共享，所有者可取消共享，被共享的用户可退出共享
+func ShareDelete(id, userId string) {
+	tx := middleware.DbW.MustBegin()
+	defer tx.Rollback()
+
+	err := dao.ShareDeleteById(tx, id, userId)
+	if err != nil {
+		panic(common.NewErr("取消共享失败", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		panic(common.NewErr("取消共享失败", err))
+	}
+}
+
+// 查询文集或文档的共享列表
+func ShareList(resourceType entity.ShareResourceType, resourceId, userId string) []entity.ShareListResult {
+	list, err := dao.ShareList(middleware.Db, resourceType, resourceId, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return list
+}
+
+// 查询共享给我的文集和文档
+func ShareListWithMe(userId string) []entity.SharedWithMeResult {
+	list, err := dao.ShareListByUser(middleware.Db, userId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	return list
+}
+
+// 校验当前用户对文档的权限，返回文档（UserId为所有者），无权限时视为文档不存在
+func documentAccess(db interface{}, id, userId string, role entity.ShareRole) entity.Document {
+	document, err := dao.DocumentGetByIdAnyUser(db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文档不存在"))
+		}
+		panic(common.NewErr("查询失败", err))
+	}
+	current := documentRole(db, document, userId)
+	if !shareRoleAllowed([]entity.ShareRole{current}, role) {
+		if current != "" {
+			panic(common.NewError("没有编辑此文档的权限"))
+		}
+		panic(common.NewError("文档不存在"))
+	}
+	return document
+}
+
+// 当前用户对文档的权限，无权限时返回空字符串
+func documentRole(db interface{}, document entity.Document, userId string) entity.ShareRole {
+	if document.UserId == userId {
+		return entity.ShareOwner
+	}
+	roles, err := dao.ShareRoleList(db, userId, document.Id, document.BookId)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	var result entity.ShareRole
+	for _, v := range roles {
+		if v == entity.ShareEditor || result == "" {
+			result = v
+		}
+	}
+	return result
+}
+
+// 校验当前用户对文集的权限，返回文集（UserId为所有者）
+func bookAccess(id, userId string, role entity.ShareRole) entity.Book {
+	book, err := dao.BookGetByIdAnyUser(middleware.Db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			panic(common.NewError("文集不存在"))
+		}
+		panic(common.NewErr("查询失败", err))
+	}
+	if book.UserId == userId {
+		return book
+	}
+	roles, err := dao.ShareRoleList(middleware.Db, userId, "", book.Id)
+	if err != nil {
+		panic(common.NewErr("查询失败", err))
+	}
+	if !shareRoleAllowed(roles, role) {
+		panic(common.NewError("文集不存在"))
+	}
+	return book
+}
+
+// 共享权限是否满足要求，所有者拥有全部权限，可编辑包含仅查看
+func shareRoleAllowed(roles []entity.ShareRole, role entity.ShareRole) bool {
+	for _, v := range roles {
+		if v == role || v == entity.ShareOwner || v == entity.ShareEditor && role == entity.ShareViewer {
+			return true
+		}
+	}
+	return false
+}
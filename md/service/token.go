@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"md/dao"
 	"md/middleware"
@@ -53,7 +54,7 @@ func SignUp(user entity.User) {
 
 	// 保存用户信息
 	user.Id = util.SnowflakeString()
-	user.Password = util.EncryptSHA256([]byte(user.Id + user.Password))
+	user.Password = passwordHash(user.Password)
 	user.CreateTime = time.Now().UnixMilli()
 	dao.UserAdd(tx, user)
 
@@ -80,11 +81,17 @@ func SignIn(user entity.User) common.TokenResult {
 		panic(common.NewErr("用户名或密码错误", err))
 	}
 
-	// 匹配密码：sha256(id + password)
-	if util.EncryptSHA256([]byte(userResult.Id+user.Password)) != userResult.Password {
+	// 匹配密码
+	match, needUpgrade := passwordVerify(userResult, user.Password)
+	if !match {
 		panic(common.NewError("用户名或密码错误"))
 	}
 
+	// 旧格式或参数已变更的密码，登录成功后重新哈希
+	if needUpgrade {
+		upgradePassword(userResult.Id, user.Password)
+	}
+
 	// 生成token
 	tokenResult := common.TokenResult{}
 	tokenResult.Name = userResult.Name
@@ -95,9 +102,11 @@ func SignIn(user entity.User) common.TokenResult {
 	tokenCache.Id = userResult.Id
 	tokenCache.TokenResult = tokenResult
 
-	// 缓存token
-	cache2go.Cache(common.AccessTokenCache).Add(tokenResult.AccessToken, AccessTokenExpire, &tokenCache)
-	cache2go.Cache(common.RefreshTokenCache).Add(tokenResult.RefreshToken, RefreshTokenExpire, &tokenCache)
+	// 保存token
+	err = middleware.Tokens.Save(tokenCache, AccessTokenExpire, RefreshTokenExpire)
+	if err != nil {
+		panic(common.NewErr("登录失败", err))
+	}
 	cache2go.Cache(common.SignInTimesCache).Delete(user.Name)
 
 	return tokenResult
@@ -105,34 +114,33 @@ func SignIn(user entity.User) common.TokenResult {
 
 // 退出登录
 func SignOut(tokenResult common.TokenResult) {
-	res, err := cache2go.Cache(common.RefreshTokenCache).Value(tokenResult.RefreshToken)
-	if err == nil {
-		tokenCache := res.Data().(*common.TokenCache)
-		if tokenCache.RefreshToken != "" {
-			cache2go.Cache(common.RefreshTokenCache).Delete(tokenCache.RefreshToken)
-		}
-		if tokenCache.AccessToken != "" {
-			cache2go.Cache(common.AccessTokenCache).Delete(tokenCache.AccessToken)
-		}
+	if tokenResult.RefreshToken == "" {
+		return
+	}
+	err := middleware.Tokens.DeleteByRefreshToken(tokenResult.RefreshToken)
+	if err != nil && !errors.Is(err, middleware.ErrTokenNotFound) {
+		panic(common.NewErr("退出登录失败", err))
 	}
 }
 
 // 刷新token
 func TokenRefresh(refreshToken string) common.TokenResult {
-	res, err := cache2go.Cache(common.RefreshTokenCache).Value(refreshToken)
+	tokenCache, err := middleware.Tokens.GetByRefreshToken(refreshToken)
 	if err != nil {
 		panic(common.NewError("认证信息已过期，请重新登录"))
 	}
-	tokenCache := res.Data().(*common.TokenCache)
 	if tokenCache.RefreshToken == "" {
 		panic(common.NewError("认证信息已过期，请重新登录"))
 	}
 
-	// Delete old tokens before generating new ones
-	if tokenCache.AccessToken != "" {
-		cache2go.Cache(common.AccessTokenCache).Delete(tokenCache.AccessToken)
+	// 删除旧token，并发刷新时已被其他请求删除则失败，同一refresh token只能使用一次
+	err = middleware.Tokens.DeleteByRefreshToken(refreshToken)
+	if errors.Is(err, middleware.ErrTokenNotFound) {
+		panic(common.NewError("认证信息已过期，请重新登录"))
+	}
+	if err != nil {
+		panic(common.NewErr("token刷新失败", err))
 	}
-	cache2go.Cache(common.RefreshTokenCache).Delete(refreshToken)
 
 	// 重新生成token
 	tokenResult := common.TokenResult{}
@@ -144,13 +152,33 @@ func TokenRefresh(refreshToken string) common.TokenResult {
 	newTokenCache.Id = tokenCache.Id
 	newTokenCache.TokenResult = tokenResult
 
-	// 缓存token
-	cache2go.Cache(common.AccessTokenCache).Add(newTokenCache.AccessToken, AccessTokenExpire, &newTokenCache)
-	cache2go.Cache(common.RefreshTokenCache).Add(newTokenCache.RefreshToken, RefreshTokenExpire, &newTokenCache)
+	// 保存token
+	err = middleware.Tokens.Save(newTokenCache, AccessTokenExpire, RefreshTokenExpire)
+	if err != nil {
+		panic(common.NewErr("token刷新失败", err))
+	}
 
 	return tokenResult
 }
 
+// 重新哈希用户密码，失败时仅记录日志，不影响登录
+func upgradePassword(id, password string) {
+	tx, err := middleware.DbW.Beginx()
+	if err != nil {
+		middleware.Log.Error("密码哈希升级失败：", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = dao.UserResetPassword(tx, entity.User{Id: id, Password: passwordHash(password)})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		middleware.Log.Error("密码哈希升级失败：", err)
+	}
+}
+
 // 校验登录次数，如已超出则抛出异常
 func checkSignInTimes(name string) {
 	cache := cache2go.Cache(common.SignInTimesCache)
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"md/middleware"
+	"md/model/common"
+	"sync"
+	"testing"
+)
+
+// 同一refresh token并发刷新时只有一个请求成功，旧token失效
+func TestTokenRefreshOnce(t *testing.T) {
+	for _, storeType := range []string{middleware.TokenStoreDb, middleware.TokenStoreMemory} {
+		t.Run(storeType, func(t *testing.T) {
+			testInitDb(t)
+			tokens := middleware.Tokens
+			t.Cleanup(func() { middleware.Tokens = tokens })
+			if err := middleware.InitTokenStore(storeType); err != nil {
+				t.Fatal(err)
+			}
+			userId := testAddUser(t, "token")
+			tokenCache := common.TokenCache{Id: userId}
+			tokenCache.Name, tokenCache.AccessToken, tokenCache.RefreshToken = "token", "access-"+storeType, "refresh-"+storeType
+			if err := middleware.Tokens.Save(tokenCache, AccessTokenExpire, RefreshTokenExpire); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			results := make(chan common.TokenResult, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { recover() }()
+					results <- TokenRefresh(tokenCache.RefreshToken)
+				}()
+			}
+			wg.Wait()
+			close(results)
+			if len(results) != 1 {
+				t.Fatalf("应只有一个请求刷新成功，实际%d个", len(results))
+			}
+			if _, err := middleware.Tokens.GetByAccessToken(tokenCache.AccessToken); err == nil {
+				t.Error("旧access token仍有效")
+			}
+			result := <-results
+			if _, err := middleware.Tokens.GetByRefreshToken(result.RefreshToken); err != nil {
+				t.Error("新refresh token无效：", err)
+			}
+
+			// 已删除的token再次删除返回ErrTokenNotFound，退出登录不报错
+			if err := middleware.Tokens.DeleteByRefreshToken(tokenCache.RefreshToken); err != middleware.ErrTokenNotFound {
+				t.Errorf("重复删除：%v", err)
+			}
+			SignOut(tokenCache.TokenResult)
+
+			// 查询后、删除前已被其他请求刷新
+			middleware.Tokens = tokenTestRaceStore{middleware.Tokens}
+			message := aiRecover(func() { TokenRefresh(result.RefreshToken) })
+			if message != "认证信息已过期，请重新登录" {
+				t.Errorf("已被其他请求刷新时应失败：%q", message)
+			}
+		})
+	}
+}
+
+// 查询refresh token后立即删除，模拟另一个刷新请求先完成
+type tokenTestRaceStore struct {
+	middleware.TokenStore
+}
+
+func (s tokenTestRaceStore) GetByRefreshToken(refreshToken string) (common.TokenCache, error) {
+	tokenCache, err := s.TokenStore.GetByRefreshToken(refreshToken)
+	if err == nil {
+		s.TokenStore.DeleteByRefreshToken(refreshToken)
+	}
+	return tokenCache, err
+}
@@ -5,7 +5,6 @@ import (
 	"md/middleware"
 	"md/model/common"
 	"md/model/entity"
-	"md/util"
 )
 
 // 更新用户密码
@@ -20,12 +19,15 @@ func UserUpdatePassword(userCondition entity.UserCondition) {
 	}
 
 	// 判断原密码相同
-	if util.EncryptSHA256([]byte(user.Id+userCondition.Password)) != user.Password {
+	if match, _ := passwordVerify(user, userCondition.Password); !match {
 		panic(common.NewError("原密码不正确"))
 	}
+	if userCondition.NewPassword == "" {
+		panic(common.NewError("新密码不可为空"))
+	}
 
 	// 更新用户
-	user.Password = util.EncryptSHA256([]byte(user.Id + userCondition.NewPassword))
+	user.Password = passwordHash(userCondition.NewPassword)
 	err = dao.UserResetPassword(tx, user)
 	if err != nil {
 		panic(common.NewErr("更新失败", err))
@@ -0,0 +1,163 @@
+// 文本差异比较工具类
+package util
+
+import (
+	"strings"
+)
+
+type DiffType string
+
+const (
+	DiffEqual  DiffType = "equal"  // 相同行
+	DiffInsert DiffType = "insert" // 新增行
+	DiffDelete DiffType = "delete" // 删除行
+)
+
+// 行级差异
+type DiffLine struct {
+	Type    DiffType `json:"type"`
+	Content string   `json:"content"`
+	OldLine int      `json:"oldLine"` // 旧文本中的行号，从1开始，新增行为0
+	NewLine int      `json:"newLine"` // 新文本中的行号，从1开始，删除行为0
+}
+
+// 差异比较的上限，超出时不再计算最短编辑脚本，整段替换
+const (
+	diffMaxEdits = 1000     // 编辑次数上限，回溯记录占用的内存与其平方成正比
+	diffMaxCost  = 20000000 // 比较行的次数上限
+)
+
+// 按行比较两段文本（Myers差异算法），差异过大时整段显示为删除和新增
+func DiffLines(oldText, newText string) []DiffLine {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// 去除相同的首尾行，缩小比较范围
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]DiffLine, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		result = append(result, DiffLine{Type: DiffEqual, Content: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+	for _, v := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if v.OldLine > 0 {
+			v.OldLine += prefix
+		}
+		if v.NewLine > 0 {
+			v.NewLine += prefix
+		}
+		result = append(result, v)
+	}
+	for i := suffix; i > 0; i-- {
+		result = append(result, DiffLine{Type: DiffEqual, Content: a[len(a)-i], OldLine: len(a) - i + 1, NewLine: len(b) - i + 1})
+	}
+	return result
+}
+
+// 拆分文本行，统一换行符
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// Myers算法，返回最短编辑脚本，超出上限时返回整段替换
+func myers(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	maxD := min(n+m, diffMaxEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// 每一轮开始前k∈[-d,d]范围内v的快照，用于回溯
+	var trace [][]int
+
+	found := false
+	cost := 0
+	for d := 0; d <= maxD && !found; d++ {
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+				cost++
+			}
+			cost++
+			if cost > diffMaxCost {
+				return diffReplace(a, b)
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return diffReplace(a, b)
+	}
+
+	// 从终点回溯
+	var reversed []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		snapshot := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && snapshot[k-1+d] < snapshot[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := snapshot[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Type: DiffEqual, Content: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{Type: DiffInsert, Content: b[y-1], NewLine: y})
+		} else {
+			reversed = append(reversed, DiffLine{Type: DiffDelete, Content: a[x-1], OldLine: x})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{Type: DiffEqual, Content: a[x-1], OldLine: x, NewLine: y})
+		x--
+		y--
+	}
+
+	result := make([]DiffLine, len(reversed))
+	for i, v := range reversed {
+		result[len(reversed)-1-i] = v
+	}
+	return result
+}
+
+// 整段替换：删除全部旧行，再新增全部新行
+func diffReplace(a, b []string) []DiffLine {
+	result := make([]DiffLine, 0, len(a)+len(b))
+	for i, v := range a {
+		result = append(result, DiffLine{Type: DiffDelete, Content: v, OldLine: i + 1})
+	}
+	for i, v := range b {
+		result = append(result, DiffLine{Type: DiffInsert, Content: v, NewLine: i + 1})
+	}
+	return result
+}
@@ -16,6 +16,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"io"
 )
 
 type SignType string
@@ -378,3 +379,13 @@ func DecryptAES(message, key string, isHex bool) (res string, err error) {
 
 	return "", errors.New("decryption failed")
 }
+
+// SHA256加密，读取全部内容计算
+func EncryptSHA256Reader(reader io.Reader) (string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,611 @@
+// 文档导出工具类，Markdown导出为PDF、DOCX、EPUB
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	"image/png"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// 导出格式
+const (
+	ExportPDF  = "pdf"
+	ExportDOCX = "docx"
+	ExportEPUB = "epub"
+)
+
+// 导出格式对应的Content-Type
+var ExportContentTypes = map[string]string{
+	ExportPDF:  "application/pdf",
+	ExportDOCX: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	ExportEPUB: "application/epub+zip",
+}
+
+// 目录收录的正文标题级别，章节标题均收录
+const exportTocDepth = 3
+
+// HTML片段中的图片
+var exportImgRegex = regexp.MustCompile(`(?i)<img\s[^>]*?src=["']([^"']+)["'][^>]*>`)
+
+// HTML标签
+var exportTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// 导出的书籍，单个文档导出时只有一章
+type ExportBook struct {
+	Id       string // 唯一标识
+	Title    string
+	Author   string
+	Modified time.Time
+	Chapters []ExportChapter
+	Image    func(src string) ([]byte, bool) // 读取图片地址对应的文件，外部或不存在的图片返回false
+}
+
+// 导出的章节，文集导出时每个文件夹、文档为一章
+type ExportChapter struct {
+	Title   string // 章节标题，为空时不输出
+	Level   int    // 章节标题级别，正文标题级别在此基础上增加
+	Link    string // 其他章节中指向本章的链接地址
+	Content string // Markdown内容
+}
+
+// 导出为指定格式
+func Export(book ExportBook, format string, w io.Writer) error {
+	c := newExportContext(book)
+	switch format {
+	case ExportPDF:
+		return c.writePDF(w)
+	case ExportDOCX:
+		return c.writeDOCX(w)
+	case ExportEPUB:
+		return c.writeEPUB(w)
+	}
+	return errors.New("不支持的导出格式：" + format)
+}
+
+// 导出过程中的上下文
+type exportContext struct {
+	book     ExportBook
+	chapters []*exportChapter
+	toc      []*exportHeading         // 目录
+	links    map[string]string        // 链接地址 -> 章节锚点
+	images   map[string]*exportImage  // 图片地址 -> 图片，不可用时为nil
+	imageSeq []*exportImage           // 按首次引用排序的图片
+	footnote map[*ast.ListItem]string // 脚注 -> 锚点
+	anchors  map[string]int           // 锚点 -> 所在章节序号
+}
+
+// 解析后的章节
+type exportChapter struct {
+	ExportChapter
+	index    int
+	heading  *exportHeading // 章节标题，未输出标题时为nil
+	root     ast.Node
+	headings map[*ast.Heading]*exportHeading
+}
+
+// 标题
+type exportHeading struct {
+	level  int
+	text   string
+	anchor string
+	toc    bool // 是否收录在目录中
+}
+
+// 图片，webp、bmp转为png
+type exportImage struct {
+	name   string // 文件名
+	mime   string
+	data   []byte
+	format string // jpeg、png、gif
+	width  int
+	height int
+}
+
+type exportBlockKind int
+
+const (
+	exportParagraph exportBlockKind = iota
+	exportHeadingBlock
+	exportCode
+	exportList
+	exportQuote
+	exportTable
+	exportImageBlock
+	exportRule
+)
+
+// 块级元素
+type exportBlock struct {
+	kind      exportBlockKind
+	heading   *exportHeading
+	pageBreak bool // 标题前分页
+	runs      []exportRun
+	lang      string
+	code      string
+	ordered   bool
+	start     int
+	items     [][]exportBlock
+	anchors   []string // 脚注列表各项的锚点
+	children  []exportBlock
+	rows      [][][]exportRun
+	header    int // 表头行数
+	image     *exportImage
+	alt       string
+}
+
+// 行内文字
+type exportRun struct {
+	text    string
+	bold    bool
+	italic  bool
+	strike  bool
+	code    bool
+	sup     bool
+	link    string // 外部链接
+	anchor  string // 内部链接的锚点
+	newline bool   // 换行
+}
+
+// 解析全部章节，生成目录
+func newExportContext(book ExportBook) *exportContext {
+	c := &exportContext{
+		book:     book,
+		links:    map[string]string{},
+		images:   map[string]*exportImage{},
+		footnote: map[*ast.ListItem]string{},
+		anchors:  map[string]int{},
+	}
+	if c.book.Modified.IsZero() {
+		c.book.Modified = time.Now()
+	}
+	seq := 0
+	newAnchor := func(chapter int) string {
+		seq++
+		anchor := fmt.Sprintf("toc%d", seq)
+		c.anchors[anchor] = chapter
+		return anchor
+	}
+	for i, v := range book.Chapters {
+		chapter := &exportChapter{ExportChapter: v, index: i, headings: map[*ast.Heading]*exportHeading{}}
+		if chapter.Title != "" {
+			chapter.heading = &exportHeading{level: exportLevel(chapter.Level), text: chapter.Title, anchor: newAnchor(i), toc: true}
+			c.toc = append(c.toc, chapter.heading)
+			if chapter.Link != "" {
+				c.links[chapter.Link] = chapter.heading.anchor
+			}
+		}
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes)
+		chapter.root = markdown.Parse(markdown.NormalizeNewlines([]byte(chapter.Content)), p)
+		for _, node := range chapter.root.GetChildren() {
+			switch node := node.(type) {
+			case *ast.Heading:
+				level := exportLevel(chapter.Level + node.Level)
+				heading := &exportHeading{level: level, text: exportRunsText(c.inline(node, exportRun{}, nil)), anchor: newAnchor(i)}
+				heading.toc = heading.text != "" && level-chapter.Level <= exportTocDepth
+				if heading.toc {
+					c.toc = append(c.toc, heading)
+				}
+				chapter.headings[node] = heading
+			case *ast.List:
+				// 脚注的锚点
+				if node.IsFootnotesList {
+					for _, item := range node.GetChildren() {
+						if item, ok := item.(*ast.ListItem); ok {
+							c.footnote[item] = newAnchor(i)
+						}
+					}
+				}
+			}
+		}
+		c.chapters = append(c.chapters, chapter)
+	}
+	return c
+}
+
+// 标题级别限制在1-6
+func exportLevel(level int) int {
+	return max(1, min(6, level))
+}
+
+// 链接地址：站内文档链接返回章节锚点，外部链接返回原地址，其他链接均返回空
+func (c *exportContext) link(target string) (string, string) {
+	if anchor, ok := c.links[target]; ok {
+		return anchor, ""
+	}
+	lower := strings.ToLower(target)
+	for _, scheme := range []string{"http://", "https://", "mailto:", "ftp://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return "", target
+		}
+	}
+	return "", ""
+}
+
+// 读取并识别图片，同一地址只读取一次
+func (c *exportContext) image(src string) *exportImage {
+	if v, ok := c.images[src]; ok {
+		return v
+	}
+	c.images[src] = nil
+	if c.book.Image == nil {
+		return nil
+	}
+	data, ok := c.book.Image(src)
+	if !ok {
+		return nil
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil
+	}
+	result := &exportImage{data: data, format: format, width: config.Width, height: config.Height}
+	if format != "jpeg" && format != "png" && format != "gif" {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		var buffer bytes.Buffer
+		if err = png.Encode(&buffer, img); err != nil {
+			return nil
+		}
+		result.data, result.format = buffer.Bytes(), "png"
+	}
+	result.mime = "image/" + result.format
+	c.imageSeq = append(c.imageSeq, result)
+	result.name = fmt.Sprintf("image%d.%s", len(c.imageSeq), strings.Replace(result.format, "jpeg", "jpg", 1))
+	c.images[src] = result
+	return result
+}
+
+// 目录树节点
+type exportTocNode struct {
+	heading  *exportHeading
+	children []*exportTocNode
+}
+
+// 按标题级别把目录组装为树
+func (c *exportContext) tocTree() []*exportTocNode {
+	var roots []*exportTocNode
+	var stack []*exportTocNode
+	for _, heading := range c.toc {
+		node := &exportTocNode{heading: heading}
+		for len(stack) > 0 && stack[len(stack)-1].heading.level >= heading.level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		}
+		stack = append(stack, node)
+	}
+	return roots
+}
+
+// 章节转为块级元素，章节标题在前
+func (c *exportContext) blocks(chapter *exportChapter) []exportBlock {
+	var blocks []exportBlock
+	if chapter.heading != nil {
+		blocks = append(blocks, exportBlock{kind: exportHeadingBlock, heading: chapter.heading, pageBreak: chapter.heading.level == 1, runs: []exportRun{{text: chapter.Title}}})
+	}
+	return append(blocks, c.convertBlocks(chapter, chapter.root.GetChildren())...)
+}
+
+// 转换块级节点，连续的行内节点合并为段落
+func (c *exportContext) convertBlocks(chapter *exportChapter, nodes []ast.Node) []exportBlock {
+	var blocks []exportBlock
+	var inlines []ast.Node
+	flush := func() {
+		if len(inlines) > 0 {
+			container := &ast.Paragraph{}
+			container.Children = inlines
+			blocks = append(blocks, c.paragraph(container)...)
+			inlines = nil
+		}
+	}
+	for _, node := range nodes {
+		if exportIsInline(node) {
+			inlines = append(inlines, node)
+			continue
+		}
+		flush()
+		switch node := node.(type) {
+		case *ast.Heading:
+			heading := chapter.headings[node]
+			if heading == nil {
+				// 嵌套在列表、引用中的标题按加粗段落处理
+				blocks = append(blocks, exportBlock{kind: exportParagraph, runs: c.inline(node, exportRun{bold: true}, nil)})
+				continue
+			}
+			blocks = append(blocks, exportBlock{kind: exportHeadingBlock, heading: heading, runs: c.inline(node, exportRun{}, nil)})
+		case *ast.Paragraph:
+			blocks = append(blocks, c.paragraph(node)...)
+		case *ast.CodeBlock:
+			lang, _, _ := strings.Cut(strings.TrimSpace(string(node.Info)), " ")
+			blocks = append(blocks, exportBlock{kind: exportCode, lang: lang, code: exportCodeText(string(node.Literal))})
+		case *ast.MathBlock:
+			blocks = append(blocks, exportBlock{kind: exportCode, code: exportCodeText(string(node.Literal))})
+		case *ast.List:
+			list := exportBlock{kind: exportList, ordered: node.ListFlags&ast.ListTypeOrdered != 0, start: max(node.Start, 1)}
+			for _, item := range node.GetChildren() {
+				list.items = append(list.items, c.convertBlocks(chapter, item.GetChildren()))
+				if item, ok := item.(*ast.ListItem); ok && node.IsFootnotesList {
+					list.anchors = append(list.anchors, c.footnote[item])
+				}
+			}
+			blocks = append(blocks, list)
+		case *ast.BlockQuote, *ast.Aside:
+			blocks = append(blocks, exportBlock{kind: exportQuote, children: c.convertBlocks(chapter, node.GetChildren())})
+		case *ast.HorizontalRule, *ast.Footnotes:
+			blocks = append(blocks, exportBlock{kind: exportRule})
+		case *ast.Table:
+			blocks = append(blocks, c.table(node))
+		case *ast.HTMLBlock:
+			blocks = append(blocks, c.htmlBlock(string(node.Literal))...)
+		default:
+			if node.AsContainer() != nil {
+				blocks = append(blocks, c.convertBlocks(chapter, node.GetChildren())...)
+			}
+		}
+	}
+	flush()
+	return blocks
+}
+
+// 是否为行内节点
+func exportIsInline(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Text, *ast.Emph, *ast.Strong, *ast.Del, *ast.Link, *ast.Image, *ast.Code, *ast.HTMLSpan,
+		*ast.Hardbreak, *ast.Softbreak, *ast.NonBlockingSpace, *ast.Math, *ast.Subscript, *ast.Superscript:
+		return true
+	}
+	return false
+}
+
+// 代码块去掉末尾换行，制表符替换为空格
+func exportCodeText(code string) string {
+	return strings.ReplaceAll(strings.TrimRight(code, "\n"), "\t", "    ")
+}
+
+// 段落中的图片拆分为单独的块
+func (c *exportContext) paragraph(node ast.Node) []exportBlock {
+	var blocks []exportBlock
+	var runs []exportRun
+	flush := func() {
+		// 去掉首尾的换行
+		for len(runs) > 0 && runs[0].newline {
+			runs = runs[1:]
+		}
+		for len(runs) > 0 && runs[len(runs)-1].newline {
+			runs = runs[:len(runs)-1]
+		}
+		if strings.TrimSpace(exportRunsText(runs)) != "" {
+			blocks = append(blocks, exportBlock{kind: exportParagraph, runs: runs})
+		}
+		runs = nil
+	}
+	c.inline(node, exportRun{}, func(run exportRun, img *exportBlock) {
+		if img == nil {
+			runs = append(runs, run)
+			return
+		}
+		flush()
+		blocks = append(blocks, *img)
+	})
+	flush()
+	return blocks
+}
+
+// 转换行内节点，emit为nil时忽略图片并返回文字，否则依次输出文字和图片
+func (c *exportContext) inline(node ast.Node, style exportRun, emit func(run exportRun, img *exportBlock)) []exportRun {
+	var runs []exportRun
+	out := func(run exportRun) {
+		if emit != nil {
+			emit(run, nil)
+		} else {
+			runs = append(runs, run)
+		}
+	}
+	var walk func(node ast.Node, style exportRun)
+	walk = func(node ast.Node, style exportRun) {
+		run := style
+		switch node := node.(type) {
+		case *ast.Text:
+			run.text = exportSoftBreak(string(node.Literal))
+			if run.text != "" {
+				out(run)
+			}
+		case *ast.Softbreak, *ast.NonBlockingSpace:
+			run.text = " "
+			out(run)
+		case *ast.Hardbreak:
+			run.newline = true
+			out(run)
+		case *ast.Code, *ast.Math:
+			run.code = true
+			run.text = string(node.AsLeaf().Literal)
+			out(run)
+		case *ast.HTMLSpan:
+			literal := string(node.Literal)
+			if strings.HasPrefix(strings.ToLower(literal), "<br") {
+				run.newline = true
+				out(run)
+			} else if match := exportImgRegex.FindStringSubmatch(literal); match != nil && emit != nil {
+				emit(exportRun{}, c.imageBlock(match[1], ""))
+			}
+		case *ast.Image:
+			alt := exportRunsText(c.inline(node, exportRun{}, nil))
+			if emit != nil {
+				emit(exportRun{}, c.imageBlock(string(node.Destination), alt))
+			} else if alt != "" {
+				run.text = alt
+				out(run)
+			}
+		case *ast.Link:
+			if node.NoteID > 0 {
+				// 脚注引用
+				run.sup = true
+				if item, ok := node.Footnote.(*ast.ListItem); ok {
+					run.anchor = c.footnote[item]
+				}
+				run.text = fmt.Sprintf("[%d]", node.NoteID)
+				out(run)
+				return
+			}
+			run.anchor, run.link = c.link(string(node.Destination))
+			for _, child := range node.GetChildren() {
+				walk(child, run)
+			}
+		default:
+			switch node.(type) {
+			case *ast.Strong:
+				run.bold = true
+			case *ast.Emph:
+				run.italic = true
+			case *ast.Del:
+				run.strike = true
+			case *ast.Superscript:
+				run.sup = true
+			}
+			for _, child := range node.GetChildren() {
+				walk(child, run)
+			}
+		}
+	}
+	for _, child := range node.GetChildren() {
+		walk(child, style)
+	}
+	return runs
+}
+
+// 段落内的换行：中文之间直接相连，其他替换为空格
+func exportSoftBreak(s string) string {
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	var builder strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := strings.TrimRight(lines[i-1], " ")
+			line = strings.TrimLeft(line, " ")
+			last, _ := utf8.DecodeLastRuneInString(prev)
+			first, _ := utf8.DecodeRuneInString(line)
+			if !exportIsWide(last) || !exportIsWide(first) {
+				builder.WriteString(" ")
+			}
+		}
+		builder.WriteString(strings.TrimRight(line, " "))
+	}
+	return builder.String()
+}
+
+// 是否为中日韩等宽字符
+func exportIsWide(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) || r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef
+}
+
+// 图片块，图片不可用时显示替代文字
+func (c *exportContext) imageBlock(src, alt string) *exportBlock {
+	return &exportBlock{kind: exportImageBlock, image: c.image(src), alt: alt}
+}
+
+// 表格
+func (c *exportContext) table(node *ast.Table) exportBlock {
+	table := exportBlock{kind: exportTable}
+	for _, part := range node.GetChildren() {
+		for _, row := range part.GetChildren() {
+			var cells [][]exportRun
+			for _, cell := range row.GetChildren() {
+				style := exportRun{}
+				if cell, ok := cell.(*ast.TableCell); ok && cell.IsHeader {
+					style.bold = true
+				}
+				cells = append(cells, c.inline(cell, style, nil))
+			}
+			if len(cells) == 0 {
+				continue
+			}
+			table.rows = append(table.rows, cells)
+			if _, ok := part.(*ast.TableHeader); ok {
+				table.header++
+			}
+		}
+	}
+	// 补齐列数
+	columns := 0
+	for _, row := range table.rows {
+		columns = max(columns, len(row))
+	}
+	for i := range table.rows {
+		for len(table.rows[i]) < columns {
+			table.rows[i] = append(table.rows[i], nil)
+		}
+	}
+	return table
+}
+
+// HTML块保留文字和图片
+func (c *exportContext) htmlBlock(literal string) []exportBlock {
+	var blocks []exportBlock
+	for _, match := range exportImgRegex.FindAllStringSubmatch(literal, -1) {
+		blocks = append(blocks, *c.imageBlock(match[1], ""))
+	}
+	literal = exportImgRegex.ReplaceAllString(literal, "")
+	if strings.HasPrefix(strings.TrimSpace(literal), "<!--") {
+		return blocks
+	}
+	text := strings.Join(strings.Fields(exportHTMLUnescape(exportTagRegex.ReplaceAllString(literal, " "))), " ")
+	if text != "" {
+		blocks = append([]exportBlock{{kind: exportParagraph, runs: []exportRun{{text: text}}}}, blocks...)
+	}
+	return blocks
+}
+
+// 常用HTML实体
+func exportHTMLUnescape(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'", "&nbsp;", " ", "&amp;", "&").Replace(s)
+}
+
+// 文字内容
+func exportRunsText(runs []exportRun) string {
+	var builder strings.Builder
+	for _, run := range runs {
+		if run.newline {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(run.text)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// XML文本转义
+func exportEscape(s string) string {
+	return html.EscapeString(exportClean(s))
+}
+
+// 去掉XML中不允许的控制字符
+func exportClean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s)
+}
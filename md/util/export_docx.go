@@ -0,0 +1,468 @@
+package util
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 页面内容的最大宽高（EMU），A4纸张边距2.54厘米
+const (
+	docxContentWidth  = 5731510
+	docxContentHeight = 8229600
+	docxEmuPerPixel   = 9525
+	docxIndent        = 420 // 每级缩进（缇）
+)
+
+const docxNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+	`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+	`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+	`xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"`
+
+// DOCX文档的生成状态
+type docxWriter struct {
+	c          *exportContext
+	body       strings.Builder
+	rels       strings.Builder         // 图片、外部链接的关系
+	relSeq     int                     // 关系序号，1-9为固定的部件
+	links      map[string]string       // 外部链接 -> 关系id
+	images     []*exportImage          // 引用的图片
+	imageRels  map[*exportImage]string // 图片 -> 关系id
+	numbering  strings.Builder         // 有序列表的编号实例
+	numSeq     int
+	drawingSeq int
+	fresh      bool // 当前位于新页面开头，分页的标题不再分页
+}
+
+// 列表、引用中的段落格式
+type docxScope struct {
+	level    int  // 列表层级，0为不在列表中
+	numId    int  // 列表项的编号，第一个段落使用后置为0
+	quote    bool // 是否在引用中
+	listItem bool // 列表项中的后续段落保持缩进
+}
+
+// 导出为Word文档，目录为带链接的静态目录，可在Word中更新域获取页码
+func (c *exportContext) writeDOCX(w io.Writer) error {
+	d := &docxWriter{c: c, relSeq: 9, numSeq: 1, links: map[string]string{}, imageRels: map[*exportImage]string{}}
+
+	// 标题、目录
+	d.body.WriteString(`<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr>` + docxRun(c.book.Title, "") + `</w:p>`)
+	if c.book.Author != "" {
+		d.body.WriteString(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr>` + docxRun(c.book.Author, `<w:color w:val="606266"/>`) + `</w:p>`)
+	}
+	if len(c.toc) > 0 {
+		maxLevel := 1
+		for _, heading := range c.toc {
+			maxLevel = max(maxLevel, heading.level)
+		}
+		d.body.WriteString(`<w:p><w:pPr><w:pStyle w:val="TOCHeading"/></w:pPr>` + docxRun("目录", "") + `</w:p>`)
+		for i, heading := range c.toc {
+			d.body.WriteString(fmt.Sprintf(`<w:p><w:pPr><w:pStyle w:val="TOC%d"/></w:pPr>`, heading.level))
+			if i == 0 {
+				d.body.WriteString(`<w:r><w:fldChar w:fldCharType="begin"/></w:r>`)
+				d.body.WriteString(fmt.Sprintf(`<w:r><w:instrText xml:space="preserve"> TOC \o "1-%d" \h \z \u </w:instrText></w:r>`, maxLevel))
+				d.body.WriteString(`<w:r><w:fldChar w:fldCharType="separate"/></w:r>`)
+			}
+			d.body.WriteString(`<w:hyperlink w:anchor="` + heading.anchor + `" w:history="1">` + docxRun(heading.text, "") + `</w:hyperlink>`)
+			if i == len(c.toc)-1 {
+				d.body.WriteString(`<w:r><w:fldChar w:fldCharType="end"/></w:r>`)
+			}
+			d.body.WriteString(`</w:p>`)
+		}
+		d.body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+		d.fresh = true
+	}
+
+	// 正文
+	for _, chapter := range c.chapters {
+		d.blocks(c.blocks(chapter), docxScope{})
+	}
+
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + `<w:document ` + docxNamespaces + `><w:body>` + d.body.String() +
+		`<w:sectPr><w:footerReference w:type="default" r:id="rId4"/><w:pgSz w:w="11906" w:h="16838"/>` +
+		`<w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="851" w:footer="992" w:gutter="0"/></w:sectPr></w:body></w:document>`
+	files := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"docProps/core.xml", d.core()},
+		{"word/document.xml", document},
+		{"word/_rels/document.xml.rels", docxDocumentRels + d.rels.String() + `</Relationships>`},
+		{"word/styles.xml", docxStyles()},
+		{"word/numbering.xml", docxNumbering + d.numbering.String() + `</w:numbering>`},
+		{"word/settings.xml", docxSettings},
+		{"word/footer1.xml", docxFooter},
+	}
+	zipWriter := zip.NewWriter(w)
+	for _, file := range files {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: c.book.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(writer, file.data); err != nil {
+			return err
+		}
+	}
+	for _, img := range d.images {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "word/media/" + img.name, Method: zip.Store, Modified: c.book.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(img.data); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// 文档属性
+func (d *docxWriter) core() string {
+	modified := d.c.book.Modified.UTC().Format("2006-01-02T15:04:05Z")
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" ` +
+		`xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + exportEscape(d.c.book.Title) + `</dc:title><dc:creator>` + exportEscape(d.c.book.Author) + `</dc:creator>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + modified + `</dcterms:created>` +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + modified + `</dcterms:modified></cp:coreProperties>`
+}
+
+// 新的关系id
+func (d *docxWriter) rel(kind, target string, external bool) string {
+	d.relSeq++
+	id := fmt.Sprintf("rId%d", d.relSeq)
+	mode := ""
+	if external {
+		mode = ` TargetMode="External"`
+	}
+	d.rels.WriteString(`<Relationship Id="` + id + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/` + kind + `" Target="` + exportEscape(target) + `"` + mode + `/>`)
+	return id
+}
+
+// 输出块级元素
+func (d *docxWriter) blocks(blocks []exportBlock, scope docxScope) {
+	for _, block := range blocks {
+		switch block.kind {
+		case exportHeadingBlock:
+			props := fmt.Sprintf(`<w:pStyle w:val="Heading%d"/>`, block.heading.level)
+			if block.pageBreak && !d.fresh {
+				props += `<w:pageBreakBefore/>`
+			}
+			id := strings.TrimPrefix(block.heading.anchor, "toc")
+			d.body.WriteString(`<w:p><w:pPr>` + props + `</w:pPr><w:bookmarkStart w:id="` + id + `" w:name="` + block.heading.anchor + `"/>`)
+			d.runs(block.runs, "")
+			d.body.WriteString(`<w:bookmarkEnd w:id="` + id + `"/></w:p>`)
+			d.fresh = false
+		case exportParagraph:
+			d.paragraphStart(&scope, "", "")
+			d.runs(block.runs, "")
+			d.paragraphEnd()
+		case exportCode:
+			d.paragraphStart(&scope, "Code", "")
+			for _, token := range HighlightCode(block.code, block.lang) {
+				props := ""
+				if token.Kind != HighlightText {
+					props = `<w:color w:val="` + HighlightColors[token.Kind] + `"/>`
+				}
+				if token.Kind == HighlightComment {
+					props += `<w:i/>`
+				}
+				for i, line := range strings.Split(token.Text, "\n") {
+					if i > 0 {
+						d.body.WriteString(`<w:r><w:br/></w:r>`)
+					}
+					if line != "" {
+						d.body.WriteString(docxRun(line, props))
+					}
+				}
+			}
+			d.paragraphEnd()
+		case exportList:
+			numId := 1
+			if block.ordered {
+				// 每个有序列表单独编号
+				d.numSeq++
+				numId = d.numSeq
+				d.numbering.WriteString(fmt.Sprintf(`<w:num w:numId="%d"><w:abstractNumId w:val="2"/>`, numId))
+				d.numbering.WriteString(fmt.Sprintf(`<w:lvlOverride w:ilvl="%d"><w:startOverride w:val="%d"/></w:lvlOverride></w:num>`, min(scope.level, 8), block.start))
+			}
+			for i, item := range block.items {
+				if i < len(block.anchors) {
+					id := strings.TrimPrefix(block.anchors[i], "toc")
+					d.body.WriteString(`<w:bookmarkStart w:id="` + id + `" w:name="` + block.anchors[i] + `"/><w:bookmarkEnd w:id="` + id + `"/>`)
+				}
+				child := docxScope{level: scope.level + 1, numId: numId, quote: scope.quote, listItem: true}
+				if len(item) == 0 {
+					item = []exportBlock{{kind: exportParagraph}}
+				}
+				d.blocks(item, child)
+			}
+		case exportQuote:
+			child := scope
+			child.quote = true
+			d.blocks(block.children, child)
+			scope.numId = child.numId
+		case exportTable:
+			d.table(block)
+		case exportImageBlock:
+			d.paragraphStart(&scope, "", `<w:jc w:val="center"/>`)
+			d.image(block)
+			d.paragraphEnd()
+		case exportRule:
+			d.paragraphStart(&scope, "", `<w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="DCDFE6"/></w:pBdr>`)
+			d.paragraphEnd()
+		}
+	}
+}
+
+// 段落开始，列表项的第一个段落添加编号
+func (d *docxWriter) paragraphStart(scope *docxScope, style, props string) {
+	if style == "" && scope.quote {
+		style = "Quote"
+	}
+	d.body.WriteString(`<w:p><w:pPr>`)
+	if style != "" {
+		d.body.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+	}
+	if scope.numId != 0 {
+		d.body.WriteString(fmt.Sprintf(`<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="%d"/></w:numPr>`, min(scope.level-1, 8), scope.numId))
+		scope.numId = 0
+	} else if scope.listItem {
+		d.body.WriteString(fmt.Sprintf(`<w:ind w:left="%d"/>`, docxIndent*scope.level))
+	}
+	d.body.WriteString(props + `</w:pPr>`)
+	d.fresh = false
+}
+
+func (d *docxWriter) paragraphEnd() {
+	d.body.WriteString(`</w:p>`)
+}
+
+// 行内文字，相邻的同一链接合并
+func (d *docxWriter) runs(runs []exportRun, extra string) {
+	open := ""
+	for _, run := range runs {
+		link := ""
+		if run.anchor != "" {
+			link = `<w:hyperlink w:anchor="` + run.anchor + `" w:history="1">`
+		} else if run.link != "" {
+			id, ok := d.links[run.link]
+			if !ok {
+				id = d.rel("hyperlink", run.link, true)
+				d.links[run.link] = id
+			}
+			link = `<w:hyperlink r:id="` + id + `" w:history="1">`
+		}
+		if link != open {
+			if open != "" {
+				d.body.WriteString(`</w:hyperlink>`)
+			}
+			d.body.WriteString(link)
+			open = link
+		}
+		if run.newline {
+			d.body.WriteString(`<w:r><w:br/></w:r>`)
+			continue
+		}
+		props := extra
+		if run.code {
+			props = `<w:rStyle w:val="CodeChar"/>` + props
+		} else if link != "" {
+			props = `<w:rStyle w:val="Hyperlink"/>` + props
+		}
+		if run.bold {
+			props += `<w:b/>`
+		}
+		if run.italic {
+			props += `<w:i/>`
+		}
+		if run.strike {
+			props += `<w:strike/>`
+		}
+		if run.sup {
+			props += `<w:vertAlign w:val="superscript"/>`
+		}
+		d.body.WriteString(docxRun(run.text, props))
+	}
+	if open != "" {
+		d.body.WriteString(`</w:hyperlink>`)
+	}
+}
+
+// 文字片段
+func docxRun(text, props string) string {
+	if props != "" {
+		props = `<w:rPr>` + props + `</w:rPr>`
+	}
+	return `<w:r>` + props + `<w:t xml:space="preserve">` + exportEscape(text) + `</w:t></w:r>`
+}
+
+// 表格，列宽平均分配
+func (d *docxWriter) table(block exportBlock) {
+	if len(block.rows) == 0 {
+		return
+	}
+	columns := len(block.rows[0])
+	d.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < columns; i++ {
+		d.body.WriteString(fmt.Sprintf(`<w:gridCol w:w="%d"/>`, 9026/columns))
+	}
+	d.body.WriteString(`</w:tblGrid>`)
+	for i, row := range block.rows {
+		d.body.WriteString(`<w:tr>`)
+		header := i < block.header
+		if header {
+			d.body.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		for _, cell := range row {
+			d.body.WriteString(fmt.Sprintf(`<w:tc><w:tcPr><w:tcW w:w="%d" w:type="pct"/>`, 5000/columns))
+			if header {
+				d.body.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="F5F7FA"/>`)
+			}
+			d.body.WriteString(`</w:tcPr><w:p><w:pPr><w:spacing w:before="0" w:after="0"/></w:pPr>`)
+			d.runs(cell, "")
+			d.body.WriteString(`</w:p></w:tc>`)
+		}
+		d.body.WriteString(`</w:tr>`)
+	}
+	d.body.WriteString(`</w:tbl>`)
+	d.fresh = false
+}
+
+// 图片，缩小到页面宽高以内，不可用时显示替代文字
+func (d *docxWriter) image(block exportBlock) {
+	img := block.image
+	if img == nil {
+		if block.alt != "" {
+			d.body.WriteString(docxRun("["+block.alt+"]", `<w:i/><w:color w:val="909399"/>`))
+		}
+		return
+	}
+	id, ok := d.imageRels[img]
+	if !ok {
+		id = d.rel("image", "media/"+img.name, false)
+		d.imageRels[img] = id
+		d.images = append(d.images, img)
+	}
+	width, height := int64(img.width)*docxEmuPerPixel, int64(img.height)*docxEmuPerPixel
+	if width > docxContentWidth {
+		height = height * docxContentWidth / width
+		width = docxContentWidth
+	}
+	if height > docxContentHeight {
+		width = width * docxContentHeight / height
+		height = docxContentHeight
+	}
+	d.drawingSeq++
+	size := fmt.Sprintf(`cx="%d" cy="%d"`, width, height)
+	d.body.WriteString(fmt.Sprintf(`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0"><wp:extent %s/><wp:docPr id="%d" name="%s" descr="%s"/>`, size, d.drawingSeq, img.name, exportEscape(block.alt)))
+	d.body.WriteString(`<wp:cNvGraphicFramePr><a:graphicFrameLocks noChangeAspect="1"/></wp:cNvGraphicFramePr>`)
+	d.body.WriteString(`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic>`)
+	d.body.WriteString(fmt.Sprintf(`<pic:nvPicPr><pic:cNvPr id="%d" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`, d.drawingSeq, img.name))
+	d.body.WriteString(`<pic:blipFill><a:blip r:embed="` + id + `"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`)
+	d.body.WriteString(`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext ` + size + `/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`)
+	d.body.WriteString(`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`)
+}
+
+// 样式：正文、标题、目录、代码、引用、链接、表格
+func docxStyles() string {
+	var builder strings.Builder
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + `<w:styles ` + docxNamespaces + `>`)
+	builder.WriteString(`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="宋体" w:cs="Calibri"/>`)
+	builder.WriteString(`<w:sz w:val="21"/><w:szCs w:val="21"/><w:lang w:val="en-US" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault>`)
+	builder.WriteString(`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="312" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+		`<w:pPr><w:spacing w:before="2400" w:after="480"/><w:jc w:val="center"/></w:pPr><w:rPr><w:b/><w:sz w:val="44"/><w:szCs w:val="44"/></w:rPr></w:style>`)
+	sizes := []int{32, 28, 26, 24, 22, 21}
+	for i, size := range sizes {
+		builder.WriteString(fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Heading%d"><w:name w:val="heading %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`, i+1, i+1))
+		builder.WriteString(fmt.Sprintf(`<w:pPr><w:keepNext/><w:keepLines/><w:spacing w:before="%d" w:after="120"/><w:outlineLvl w:val="%d"/></w:pPr>`, 360-i*40, i))
+		builder.WriteString(fmt.Sprintf(`<w:rPr><w:b/><w:sz w:val="%d"/><w:szCs w:val="%d"/></w:rPr></w:style>`, size, size))
+	}
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="TOCHeading"><w:name w:val="TOC Heading"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>` +
+		`<w:pPr><w:spacing w:before="240" w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="28"/><w:szCs w:val="28"/></w:rPr></w:style>`)
+	for i := 1; i <= 6; i++ {
+		builder.WriteString(fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="TOC%d"><w:name w:val="toc %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>`, i, i))
+		builder.WriteString(fmt.Sprintf(`<w:pPr><w:spacing w:after="60"/><w:ind w:left="%d"/></w:pPr></w:style>`, docxIndent*(i-1)))
+	}
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F6F8FA"/><w:spacing w:after="120" w:line="240" w:lineRule="auto"/></w:pPr>` +
+		`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:color w:val="` + HighlightColors[HighlightText] + `"/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:pBdr><w:left w:val="single" w:sz="24" w:space="8" w:color="DCDFE6"/></w:pBdr><w:ind w:left="360"/></w:pPr><w:rPr><w:color w:val="606266"/></w:rPr></w:style>`)
+	builder.WriteString(`<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0366D6"/><w:u w:val="single"/></w:rPr></w:style>`)
+	builder.WriteString(`<w:style w:type="character" w:styleId="CodeChar"><w:name w:val="Code Char"/>` +
+		`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:color w:val="C7254E"/><w:sz w:val="19"/><w:shd w:val="clear" w:color="auto" w:fill="F3F4F4"/></w:rPr></w:style>`)
+	builder.WriteString(`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders>`)
+	for _, side := range []string{"top", "left", "bottom", "right", "insideH", "insideV"} {
+		builder.WriteString(`<w:` + side + ` w:val="single" w:sz="4" w:space="0" w:color="DCDFE6"/>`)
+	}
+	builder.WriteString(`</w:tblBorders><w:tblCellMar><w:left w:w="108" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>`)
+	builder.WriteString(`</w:styles>`)
+	return builder.String()
+}
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Default Extension="png" ContentType="image/png"/>` +
+	`<Default Extension="jpg" ContentType="image/jpeg"/>` +
+	`<Default Extension="gif" ContentType="image/gif"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>` +
+	`<Override PartName="/word/footer1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const docxPackageRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+// 文档的固定关系，后面追加图片和链接
+const docxDocumentRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>` +
+	`<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>`
+
+// 列表编号：1为无序列表，2为有序列表，后面追加有序列表的编号实例
+var docxNumbering = func() string {
+	var builder strings.Builder
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + `<w:numbering ` + docxNamespaces + `>`)
+	bullets := []string{"•", "◦", "▪"}
+	for id := 1; id <= 2; id++ {
+		builder.WriteString(fmt.Sprintf(`<w:abstractNum w:abstractNumId="%d"><w:multiLevelType w:val="hybridMultilevel"/>`, id))
+		for level := 0; level < 9; level++ {
+			format, text := "bullet", bullets[level%len(bullets)]
+			if id == 2 {
+				format, text = "decimal", fmt.Sprintf("%%%d.", level+1)
+			}
+			builder.WriteString(fmt.Sprintf(`<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="%s"/><w:lvlText w:val="%s"/><w:lvlJc w:val="left"/>`, level, format, text))
+			builder.WriteString(fmt.Sprintf(`<w:pPr><w:ind w:left="%d" w:hanging="%d"/></w:pPr></w:lvl>`, docxIndent*(level+1), docxIndent))
+		}
+		builder.WriteString(`</w:abstractNum>`)
+	}
+	builder.WriteString(`<w:num w:numId="1"><w:abstractNumId w:val="1"/></w:num>`)
+	return builder.String()
+}()
+
+const docxSettings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:defaultTabStop w:val="420"/>` +
+	`<w:compat><w:compatSetting w:name="compatibilityMode" w:uri="http://schemas.microsoft.com/office/word" w:val="15"/></w:compat></w:settings>`
+
+// 页脚居中显示页码
+const docxFooter = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:pPr><w:jc w:val="center"/></w:pPr>` +
+	`<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> PAGE </w:instrText></w:r>` +
+	`<w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>1</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r></w:p></w:ftr>`
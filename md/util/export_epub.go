@@ -0,0 +1,350 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// 高亮片段的样式名
+var exportHighlightClasses = map[HighlightKind]string{
+	HighlightKeyword: "hl-keyword",
+	HighlightString:  "hl-string",
+	HighlightComment: "hl-comment",
+	HighlightNumber:  "hl-number",
+	HighlightLiteral: "hl-literal",
+}
+
+// 导出为EPUB 3电子书，同时生成toc.ncx兼容EPUB 2阅读器
+func (c *exportContext) writeEPUB(w io.Writer) error {
+	zipWriter := zip.NewWriter(w)
+	write := func(name string, data []byte) error {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: c.book.Modified})
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	}
+	if err := epubWriteMimetype(zipWriter, c.book.Modified); err != nil {
+		return err
+	}
+	if err := write("META-INF/container.xml", []byte(epubContainer)); err != nil {
+		return err
+	}
+
+	// 封面页和各章节
+	title := exportEscape(c.book.Title)
+	cover := `<h1 class="title">` + title + `</h1>`
+	if c.book.Author != "" {
+		cover += `<p class="author">` + exportEscape(c.book.Author) + `</p>`
+	}
+	if err := write("OEBPS/title.xhtml", epubPage(title, cover)); err != nil {
+		return err
+	}
+	for _, chapter := range c.chapters {
+		name := chapter.Title
+		if name == "" {
+			name = c.book.Title
+		}
+		if err := write("OEBPS/"+epubChapterFile(chapter.index), epubPage(exportEscape(name), c.epubChapter(chapter))); err != nil {
+			return err
+		}
+	}
+	for _, img := range c.imageSeq {
+		if err := write("OEBPS/images/"+img.name, img.data); err != nil {
+			return err
+		}
+	}
+
+	// 目录，没有标题时指向第一章
+	tree := c.tocTree()
+	if len(tree) == 0 && len(c.chapters) > 0 {
+		tree = []*exportTocNode{{heading: &exportHeading{level: 1, text: c.book.Title, anchor: ""}}}
+	}
+	var nav strings.Builder
+	nav.WriteString(`<nav epub:type="toc" id="toc"><h1>目录</h1>`)
+	c.epubNav(&nav, tree)
+	nav.WriteString(`</nav>`)
+	if err := write("OEBPS/nav.xhtml", epubPage("目录", nav.String())); err != nil {
+		return err
+	}
+	var ncx strings.Builder
+	ncx.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	ncx.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><head>`)
+	ncx.WriteString(`<meta name="dtb:uid" content="` + c.epubIdentifier() + `"/><meta name="dtb:depth" content="` + fmt.Sprint(epubDepth(tree)) + `"/>`)
+	ncx.WriteString(`<meta name="dtb:totalPageCount" content="0"/><meta name="dtb:maxPageNumber" content="0"/></head>`)
+	ncx.WriteString(`<docTitle><text>` + title + `</text></docTitle><navMap>`)
+	order := 0
+	c.epubNavPoints(&ncx, tree, &order)
+	ncx.WriteString(`</navMap></ncx>`)
+	if err := write("OEBPS/toc.ncx", []byte(ncx.String())); err != nil {
+		return err
+	}
+	if err := write("OEBPS/style.css", []byte(epubStyle())); err != nil {
+		return err
+	}
+
+	// 包描述文件
+	var opf strings.Builder
+	opf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	opf.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="zh-CN">`)
+	opf.WriteString(`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+	opf.WriteString(`<dc:identifier id="bookid">` + c.epubIdentifier() + `</dc:identifier>`)
+	opf.WriteString(`<dc:title>` + title + `</dc:title><dc:language>zh-CN</dc:language>`)
+	if c.book.Author != "" {
+		opf.WriteString(`<dc:creator>` + exportEscape(c.book.Author) + `</dc:creator>`)
+	}
+	opf.WriteString(`<meta property="dcterms:modified">` + c.book.Modified.UTC().Format("2006-01-02T15:04:05Z") + `</meta></metadata><manifest>`)
+	opf.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`)
+	opf.WriteString(`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>`)
+	opf.WriteString(`<item id="style" href="style.css" media-type="text/css"/>`)
+	opf.WriteString(`<item id="title" href="title.xhtml" media-type="application/xhtml+xml"/>`)
+	for _, chapter := range c.chapters {
+		opf.WriteString(fmt.Sprintf(`<item id="chapter%d" href="%s" media-type="application/xhtml+xml"/>`, chapter.index+1, epubChapterFile(chapter.index)))
+	}
+	for i, img := range c.imageSeq {
+		opf.WriteString(fmt.Sprintf(`<item id="image%d" href="images/%s" media-type="%s"/>`, i+1, img.name, img.mime))
+	}
+	opf.WriteString(`</manifest><spine toc="ncx"><itemref idref="title"/><itemref idref="nav"/>`)
+	for _, chapter := range c.chapters {
+		opf.WriteString(fmt.Sprintf(`<itemref idref="chapter%d"/>`, chapter.index+1))
+	}
+	opf.WriteString(`</spine></package>`)
+	if err := write("OEBPS/content.opf", []byte(opf.String())); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// mimetype必须为第一个文件且不压缩，阅读器按固定偏移读取，因此不可有扩展字段（Modified会添加扩展时间戳）和数据描述符
+func epubWriteMimetype(zipWriter *zip.Writer, modified time.Time) error {
+	data := []byte("application/epub+zip")
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	}
+	// MS-DOS格式的修改时间
+	if modified.Year() >= 1980 {
+		header.ModifiedDate = uint16((modified.Year()-1980)<<9 | int(modified.Month())<<5 | modified.Day())
+		header.ModifiedTime = uint16(modified.Hour()<<11 | modified.Minute()<<5 | modified.Second()/2)
+	}
+	writer, err := zipWriter.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// 书籍唯一标识
+func (c *exportContext) epubIdentifier() string {
+	return "urn:md:" + exportEscape(c.book.Id)
+}
+
+// 章节文件名
+func epubChapterFile(index int) string {
+	return fmt.Sprintf("chapter%d.xhtml", index+1)
+}
+
+// 锚点所在的页面地址
+func (c *exportContext) epubHref(anchor string) string {
+	if anchor == "" {
+		return epubChapterFile(0)
+	}
+	return epubChapterFile(c.anchors[anchor]) + "#" + anchor
+}
+
+// 目录导航
+func (c *exportContext) epubNav(builder *strings.Builder, nodes []*exportTocNode) {
+	builder.WriteString(`<ol>`)
+	for _, node := range nodes {
+		builder.WriteString(`<li><a href="` + c.epubHref(node.heading.anchor) + `">` + exportEscape(node.heading.text) + `</a>`)
+		if len(node.children) > 0 {
+			c.epubNav(builder, node.children)
+		}
+		builder.WriteString(`</li>`)
+	}
+	builder.WriteString(`</ol>`)
+}
+
+// EPUB 2的目录
+func (c *exportContext) epubNavPoints(builder *strings.Builder, nodes []*exportTocNode, order *int) {
+	for _, node := range nodes {
+		*order++
+		builder.WriteString(fmt.Sprintf(`<navPoint id="navPoint%d" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/>`, *order, *order, exportEscape(node.heading.text), c.epubHref(node.heading.anchor)))
+		c.epubNavPoints(builder, node.children, order)
+		builder.WriteString(`</navPoint>`)
+	}
+}
+
+// 目录层数
+func epubDepth(nodes []*exportTocNode) int {
+	depth := 0
+	for _, node := range nodes {
+		depth = max(depth, epubDepth(node.children))
+	}
+	if len(nodes) > 0 {
+		depth++
+	}
+	return depth
+}
+
+// XHTML页面
+func epubPage(title, body string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<!DOCTYPE html>` + "\n" +
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="zh-CN" lang="zh-CN">` +
+		`<head><meta charset="utf-8"/><title>` + title + `</title><link rel="stylesheet" type="text/css" href="style.css"/></head>` +
+		"<body>\n" + body + "\n</body></html>")
+}
+
+// 章节内容渲染为XHTML，标题、链接、图片、代码块等自行处理
+func (c *exportContext) epubChapter(chapter *exportChapter) string {
+	var buffer bytes.Buffer
+	if chapter.heading != nil {
+		fmt.Fprintf(&buffer, `<h%d id="%s">%s</h%d>`+"\n", chapter.heading.level, chapter.heading.anchor, exportEscape(chapter.Title), chapter.heading.level)
+	}
+	hook := func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		switch node := node.(type) {
+		case *ast.Heading:
+			heading := chapter.headings[node]
+			if heading == nil {
+				return ast.GoToNext, false
+			}
+			if entering {
+				fmt.Fprintf(w, `<h%d id="%s">`, heading.level, heading.anchor)
+			} else {
+				fmt.Fprintf(w, "</h%d>\n", heading.level)
+			}
+		case *ast.Link:
+			if node.NoteID > 0 {
+				if entering {
+					anchor := ""
+					if item, ok := node.Footnote.(*ast.ListItem); ok {
+						anchor = c.footnote[item]
+					}
+					fmt.Fprintf(w, `<sup><a epub:type="noteref" href="#%s">[%d]</a></sup>`, anchor, node.NoteID)
+				}
+				return ast.SkipChildren, true
+			}
+			anchor, external := c.link(string(node.Destination))
+			switch {
+			case anchor != "" && entering:
+				io.WriteString(w, `<a href="`+c.epubHref(anchor)+`">`)
+			case external != "" && entering:
+				io.WriteString(w, `<a href="`+exportEscape(external)+`">`)
+			case anchor != "" || external != "":
+				io.WriteString(w, `</a>`)
+			}
+		case *ast.ListItem:
+			if node.RefLink == nil {
+				return ast.GoToNext, false
+			}
+			if entering {
+				io.WriteString(w, `<li id="`+c.footnote[node]+`">`)
+			} else {
+				io.WriteString(w, "</li>\n")
+			}
+		case *ast.Image:
+			if entering {
+				c.epubImage(w, string(node.Destination), exportRunsText(c.inline(node, exportRun{}, nil)))
+			}
+			return ast.SkipChildren, true
+		case *ast.HTMLSpan:
+			literal := string(node.Literal)
+			if strings.HasPrefix(strings.ToLower(literal), "<br") {
+				io.WriteString(w, "<br />")
+			} else if match := exportImgRegex.FindStringSubmatch(literal); match != nil {
+				c.epubImage(w, match[1], "")
+			}
+		case *ast.HTMLBlock:
+			for _, block := range c.htmlBlock(string(node.Literal)) {
+				if block.kind == exportImageBlock {
+					io.WriteString(w, `<p>`)
+					c.epubImageBlock(w, block)
+					io.WriteString(w, "</p>\n")
+				} else {
+					io.WriteString(w, `<p>`+exportEscape(exportRunsText(block.runs))+"</p>\n")
+				}
+			}
+		case *ast.CodeBlock:
+			lang, _, _ := strings.Cut(strings.TrimSpace(string(node.Info)), " ")
+			io.WriteString(w, `<pre><code`)
+			if lang != "" {
+				io.WriteString(w, ` class="language-`+exportEscape(lang)+`"`)
+			}
+			io.WriteString(w, `>`)
+			for _, token := range HighlightCode(exportCodeText(string(node.Literal)), lang) {
+				if class := exportHighlightClasses[token.Kind]; class != "" {
+					io.WriteString(w, `<span class="`+class+`">`+exportEscape(token.Text)+`</span>`)
+				} else {
+					io.WriteString(w, exportEscape(token.Text))
+				}
+			}
+			io.WriteString(w, "</code></pre>\n")
+		default:
+			return ast.GoToNext, false
+		}
+		return ast.GoToNext, true
+	}
+	renderer := html.NewRenderer(html.RendererOptions{Flags: html.UseXHTML, RenderNodeHook: hook})
+	buffer.Write(markdown.Render(chapter.root, renderer))
+	// XHTML只支持XML的预定义实体
+	return strings.ReplaceAll(exportClean(buffer.String()), "&nbsp;", "&#160;")
+}
+
+// 图片，不可用时显示替代文字
+func (c *exportContext) epubImage(w io.Writer, src, alt string) {
+	c.epubImageBlock(w, *c.imageBlock(src, alt))
+}
+
+func (c *exportContext) epubImageBlock(w io.Writer, block exportBlock) {
+	if block.image == nil {
+		if block.alt != "" {
+			io.WriteString(w, `<span class="missing">[`+exportEscape(block.alt)+`]</span>`)
+		}
+		return
+	}
+	io.WriteString(w, `<img src="images/`+block.image.name+`" alt="`+exportEscape(block.alt)+`"/>`)
+}
+
+// 样式，包含代码高亮的颜色
+func epubStyle() string {
+	var builder strings.Builder
+	builder.WriteString(epubBaseStyle)
+	for _, kind := range []HighlightKind{HighlightKeyword, HighlightString, HighlightComment, HighlightNumber, HighlightLiteral} {
+		builder.WriteString(fmt.Sprintf(".%s { color: #%s; }\n", exportHighlightClasses[kind], HighlightColors[kind]))
+	}
+	return builder.String()
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+const epubBaseStyle = `body { line-height: 1.7; }
+h1, h2, h3, h4, h5, h6 { line-height: 1.4; }
+h1.title { margin-top: 30%; text-align: center; }
+p.author { text-align: center; color: #606266; }
+img { max-width: 100%; }
+pre { padding: 0.6em 0.8em; white-space: pre-wrap; word-wrap: break-word; background: #f6f8fa; border-radius: 4px; }
+code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 0.3em 0.6em; border: 1px solid #dcdfe6; text-align: left; vertical-align: top; }
+th { background: #f5f7fa; }
+blockquote { margin: 0; padding: 0 1em; color: #606266; border-left: 4px solid #dcdfe6; }
+nav ol { list-style: none; }
+.missing { color: #909399; }
+`
@@ -0,0 +1,1028 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A4页面尺寸及版式（磅）
+const (
+	pdfPageWidth    = 595.28
+	pdfPageHeight   = 841.89
+	pdfMarginX      = 56.0
+	pdfMarginTop    = 64.0
+	pdfMarginBottom = 64.0
+	pdfContentWidth = pdfPageWidth - 2*pdfMarginX
+	pdfTop          = pdfPageHeight - pdfMarginTop
+	pdfFontSize     = 10.5
+	pdfLineHeight   = 1.65
+	pdfCodeSize     = 8.5
+	pdfTableSize    = 9.5
+	pdfIndent       = 18.0
+	pdfTocLine      = 20.0
+)
+
+// 各级标题的字号
+var pdfHeadingSizes = []float64{0, 20, 16, 14, 12.5, 11.5, 10.5}
+
+// 不能出现在行首的标点，不能出现在行尾的标点，西文标点随单词一起断行
+const (
+	pdfNoLineStart = "，。、；：？！）》」』】〕〉”’…—～·"
+	pdfNoLineEnd   = "（《「『【〔〈“‘"
+)
+
+var (
+	pdfTextColor  = [3]float64{0.19, 0.19, 0.2}
+	pdfGrayColor  = [3]float64{0.38, 0.38, 0.4}
+	pdfLightColor = [3]float64{0.56, 0.58, 0.6}
+	pdfLinkColor  = [3]float64{0.01, 0.4, 0.84}
+	pdfCodeColor  = [3]float64{0.78, 0.15, 0.31}
+	pdfBorder     = [3]float64{0.86, 0.87, 0.9}
+	pdfCodeFill   = [3]float64{0.965, 0.973, 0.98}
+	pdfHeaderFill = [3]float64{0.96, 0.97, 0.98}
+)
+
+// 页面
+type pdfPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+// 链接区域
+type pdfLink struct {
+	rect   [4]float64
+	uri    string
+	anchor string
+}
+
+// 锚点位置
+type pdfDest struct {
+	page int
+	y    float64
+}
+
+// 行内排版的片段，不可再拆分
+type pdfFragment struct {
+	text    string
+	run     exportRun
+	font    pdfFont
+	size    float64
+	width   float64
+	space   bool
+	newline bool
+}
+
+// 列表项标记，在列表项的第一行绘制
+type pdfMarker struct {
+	text string
+	x    float64
+}
+
+// 排版区域
+type pdfBox struct {
+	x     float64
+	width float64
+	quote bool
+}
+
+// PDF文档的排版状态
+type pdfWriter struct {
+	c      *exportContext
+	pages  []*pdfPage
+	page   *pdfPage
+	y      float64
+	empty  bool // 当前页面还没有内容
+	dests  map[string]pdfDest
+	marker *pdfMarker
+	images []*exportImage
+}
+
+// 导出为PDF，目录含页码，同时生成书签
+func (c *exportContext) writePDF(w io.Writer) error {
+	p := &pdfWriter{c: c, dests: map[string]pdfDest{}}
+
+	// 预留封面和目录的页数
+	tocPages := 0
+	if len(c.toc) > 0 {
+		first := int((pdfTop-p.coverHeight()-pdfMarginBottom)/pdfTocLine) - 1
+		rest := int(math.Floor((pdfTop - pdfMarginBottom) / pdfTocLine))
+		tocPages = 1
+		if len(c.toc) > first {
+			tocPages += (len(c.toc) - first + rest - 1) / rest
+		}
+		for i := 0; i < tocPages; i++ {
+			p.pages = append(p.pages, &pdfPage{})
+		}
+	}
+
+	// 正文，没有目录时标题在正文开头
+	p.newPage()
+	if tocPages == 0 {
+		p.cover()
+	}
+	for _, chapter := range c.chapters {
+		p.blocks(c.blocks(chapter), pdfBox{x: pdfMarginX, width: pdfContentWidth})
+	}
+
+	if tocPages > 0 {
+		p.toc()
+	}
+	for i, page := range p.pages {
+		text := fmt.Sprintf("%d / %d", i+1, len(p.pages))
+		page.content.WriteString(pdfTextOp(pdfPageWidth/2-pdfTextWidth(text, pdfRegular, 9)/2, pdfMarginBottom/2, text, pdfRegular, 9, pdfLightColor, false))
+	}
+	return p.output(w)
+}
+
+// 新页面
+func (p *pdfWriter) newPage() {
+	p.page = &pdfPage{}
+	p.pages = append(p.pages, p.page)
+	p.y = pdfTop
+	p.empty = true
+}
+
+// 剩余空间不足时换页
+func (p *pdfWriter) ensure(height float64) {
+	if p.y-height < pdfMarginBottom && !p.empty {
+		p.newPage()
+	}
+}
+
+// 封面标题的高度
+func (p *pdfWriter) coverHeight() float64 {
+	lines := pdfBreakLines(p.fragments([]exportRun{{text: p.c.book.Title, bold: true}}, 24), pdfContentWidth)
+	height := 80 + float64(len(lines))*24*1.4 + 50
+	if p.c.book.Author != "" {
+		height += 24
+	}
+	return height
+}
+
+// 封面标题，居中显示
+func (p *pdfWriter) cover() {
+	p.y -= 80
+	for _, line := range pdfBreakLines(p.fragments([]exportRun{{text: p.c.book.Title, bold: true}}, 24), pdfContentWidth) {
+		p.y -= 24 * 1.4
+		p.drawLine(line, pdfMarginX+(pdfContentWidth-pdfLineWidth(line))/2, p.y+24*0.3, pdfTextColor)
+	}
+	if p.c.book.Author != "" {
+		p.y -= 24
+		width := pdfTextWidth(p.c.book.Author, pdfRegular, 12)
+		p.page.content.WriteString(pdfTextOp(pdfMarginX+(pdfContentWidth-width)/2, p.y+4, p.c.book.Author, pdfRegular, 12, pdfGrayColor, false))
+	}
+	p.y -= 50
+	p.empty = false
+}
+
+// 目录，标题过长时截断，页码右对齐
+func (p *pdfWriter) toc() {
+	pageIndex := 0
+	p.page, p.y = p.pages[0], pdfTop
+	p.cover()
+	p.y -= pdfTocLine
+	p.page.content.WriteString(pdfTextOp(pdfMarginX, p.y+6, "目录", pdfBold, 16, pdfTextColor, true))
+	minLevel := 6
+	for _, heading := range p.c.toc {
+		minLevel = min(minLevel, heading.level)
+	}
+	for _, heading := range p.c.toc {
+		if p.y-pdfTocLine < pdfMarginBottom {
+			pageIndex++
+			p.page, p.y = p.pages[pageIndex], pdfTop
+		}
+		p.y -= pdfTocLine
+		dest, ok := p.dests[heading.anchor]
+		number := ""
+		if ok {
+			number = strconv.Itoa(dest.page + 1)
+		}
+		font, size := pdfRegular, pdfFontSize
+		if heading.level == minLevel {
+			font = pdfBold
+		}
+		x := pdfMarginX + float64(heading.level-minLevel)*pdfIndent
+		numberWidth := pdfTextWidth(number, pdfRegular, size)
+		text := pdfTruncate(heading.text, font, size, pdfContentWidth-(x-pdfMarginX)-numberWidth-24)
+		textWidth := pdfTextWidth(text, font, size)
+		baseline := p.y + 6
+		p.page.content.WriteString(pdfTextOp(x, baseline, text, font, size, pdfTextColor, false))
+		// 点状引导线
+		dotStart, dotEnd := x+textWidth+6, pdfMarginX+pdfContentWidth-numberWidth-6
+		if dotEnd > dotStart {
+			dots := strings.Repeat(".", int((dotEnd-dotStart)/pdfTextWidth(".", pdfRegular, size)))
+			p.page.content.WriteString(pdfTextOp(dotEnd-pdfTextWidth(dots, pdfRegular, size), baseline, dots, pdfRegular, size, pdfLightColor, false))
+		}
+		p.page.content.WriteString(pdfTextOp(pdfMarginX+pdfContentWidth-numberWidth, baseline, number, pdfRegular, size, pdfTextColor, false))
+		p.page.links = append(p.page.links, pdfLink{rect: [4]float64{x, p.y, pdfMarginX + pdfContentWidth, p.y + pdfTocLine}, anchor: heading.anchor})
+	}
+}
+
+// 截断文字，超出宽度时以省略号结尾
+func pdfTruncate(text string, font pdfFont, size, width float64) string {
+	if pdfTextWidth(text, font, size) <= width {
+		return text
+	}
+	ellipsis := pdfTextWidth("…", font, size)
+	total := 0.0
+	for i, r := range text {
+		_, w := pdfRuneWidth(r, font)
+		total += float64(w) * size / 1000
+		if total+ellipsis > width {
+			return text[:i] + "…"
+		}
+	}
+	return text
+}
+
+// 排版块级元素
+func (p *pdfWriter) blocks(blocks []exportBlock, box pdfBox) {
+	for _, block := range blocks {
+		switch block.kind {
+		case exportHeadingBlock:
+			p.heading(block, box)
+		case exportParagraph:
+			p.paragraph(block.runs, box, pdfFontSize)
+			p.y -= 6
+		case exportCode:
+			p.code(block, box)
+		case exportList:
+			for i, item := range block.items {
+				marker := "•"
+				if block.ordered {
+					marker = fmt.Sprintf("%d.", block.start+i)
+				} else if box.x > pdfMarginX+pdfIndent*1.5 {
+					marker = "–"
+				}
+				if i < len(block.anchors) {
+					p.ensure(pdfFontSize * pdfLineHeight)
+					p.dests[block.anchors[i]] = pdfDest{page: len(p.pages) - 1, y: p.y}
+				}
+				p.marker = &pdfMarker{text: marker, x: box.x + 4}
+				if len(item) == 0 {
+					item = []exportBlock{{kind: exportParagraph, runs: []exportRun{{text: " "}}}}
+				}
+				child := box
+				child.x += pdfIndent
+				child.width -= pdfIndent
+				p.blocks(item, child)
+				p.marker = nil
+			}
+		case exportQuote:
+			startPage, startY := len(p.pages)-1, p.y
+			child := box
+			child.x += 12
+			child.width -= 12
+			child.quote = true
+			p.blocks(block.children, child)
+			// 左侧竖线，跨页时每页分别绘制
+			for i := startPage; i < len(p.pages); i++ {
+				top, bottom := pdfTop, pdfMarginBottom
+				if i == startPage {
+					top = startY
+				}
+				if i == len(p.pages)-1 {
+					bottom = p.y + 6
+				}
+				if top > bottom {
+					p.pages[i].content.WriteString(pdfRectOp(box.x, bottom, 3, top-bottom, pdfBorder))
+				}
+			}
+		case exportTable:
+			p.table(block, box)
+		case exportImageBlock:
+			p.image(block, box)
+		case exportRule:
+			p.ensure(14)
+			p.y -= 7
+			p.page.content.WriteString(pdfRectOp(box.x, p.y, box.width, 0.8, pdfBorder))
+			p.y -= 7
+			p.empty = false
+		}
+	}
+}
+
+// 标题，记录锚点位置，一二级标题下方加分隔线
+func (p *pdfWriter) heading(block exportBlock, box pdfBox) {
+	size := pdfHeadingSizes[block.heading.level]
+	lineHeight := size * 1.4
+	if block.pageBreak && !p.empty {
+		p.newPage()
+	}
+	if !p.empty {
+		p.y -= size * 0.6
+	}
+	// 标题与下文至少两行在同一页
+	p.ensure(lineHeight + pdfFontSize*pdfLineHeight*2)
+	p.dests[block.heading.anchor] = pdfDest{page: len(p.pages) - 1, y: p.y + 4}
+	runs := make([]exportRun, len(block.runs))
+	for i, run := range block.runs {
+		run.bold = true
+		runs[i] = run
+	}
+	for _, line := range pdfBreakLines(p.fragments(runs, size), box.width) {
+		p.y -= lineHeight
+		p.drawLine(line, box.x, p.y+size*0.3, pdfTextColor)
+	}
+	if block.heading.level <= 2 {
+		p.y -= 4
+		p.page.content.WriteString(pdfRectOp(box.x, p.y, box.width, 0.6, pdfBorder))
+	}
+	p.y -= size * 0.4
+	p.empty = false
+}
+
+// 段落
+func (p *pdfWriter) paragraph(runs []exportRun, box pdfBox, size float64) {
+	color := pdfTextColor
+	if box.quote {
+		color = pdfGrayColor
+	}
+	lineHeight := size * pdfLineHeight
+	for _, line := range pdfBreakLines(p.fragments(runs, size), box.width) {
+		p.ensure(lineHeight)
+		p.y -= lineHeight
+		p.drawLine(line, box.x, p.y+size*0.45, color)
+		p.empty = false
+	}
+}
+
+// 代码块，等宽字体，按关键字着色，超长的行自动换行
+func (p *pdfWriter) code(block exportBlock, box pdfBox) {
+	const padding = 6.0
+	lineHeight := pdfCodeSize * 1.5
+	width := box.width - 2*padding
+
+	// 按宽度拆分为显示行
+	var lines [][]HighlightToken
+	var line []HighlightToken
+	lineWidth := 0.0
+	for _, token := range HighlightCode(block.code, block.lang) {
+		for i, part := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, line)
+				line, lineWidth = nil, 0
+			}
+			start := 0
+			for j, r := range part {
+				_, w := pdfRuneWidth(r, pdfMono)
+				charWidth := float64(w) * pdfCodeSize / 1000
+				if lineWidth+charWidth > width && lineWidth > 0 {
+					line = append(line, HighlightToken{Kind: token.Kind, Text: part[start:j]})
+					lines = append(lines, line)
+					line, lineWidth, start = nil, 0, j
+				}
+				lineWidth += charWidth
+			}
+			if start < len(part) {
+				line = append(line, HighlightToken{Kind: token.Kind, Text: part[start:]})
+			}
+		}
+	}
+	lines = append(lines, line)
+
+	for i, tokens := range lines {
+		height := lineHeight
+		if i == 0 {
+			height += padding
+		}
+		if i == len(lines)-1 {
+			height += padding
+		}
+		p.ensure(height)
+		p.page.content.WriteString(pdfRectOp(box.x, p.y-height, box.width, height, pdfCodeFill))
+		if i == 0 {
+			p.y -= padding
+		}
+		p.y -= lineHeight
+		baseline := p.y + pdfCodeSize*0.4
+		p.drawMarker(baseline)
+		x := box.x + padding
+		for _, token := range tokens {
+			color := pdfHexColor(HighlightColors[token.Kind])
+			p.page.content.WriteString(pdfTextOp(x, baseline, token.Text, pdfMono, pdfCodeSize, color, false))
+			x += pdfTextWidth(token.Text, pdfMono, pdfCodeSize)
+		}
+		if i == len(lines)-1 {
+			p.y -= padding
+		}
+		p.empty = false
+	}
+	p.y -= 8
+}
+
+// 表格，列宽按内容比例分配，跨页时重复表头
+func (p *pdfWriter) table(block exportBlock, box pdfBox) {
+	if len(block.rows) == 0 {
+		return
+	}
+	const padding = 4.0
+	columns := len(block.rows[0])
+	natural := make([]float64, columns)
+	total := 0.0
+	for _, row := range block.rows {
+		for i, cell := range row {
+			width := 0.0
+			for _, fragment := range p.fragments(cell, pdfTableSize) {
+				width += fragment.width
+			}
+			natural[i] = max(natural[i], min(width+2*padding, box.width))
+		}
+	}
+	for i := range natural {
+		natural[i] = max(natural[i], 30)
+		total += natural[i]
+	}
+	widths := make([]float64, columns)
+	for i := range natural {
+		if total > box.width {
+			widths[i] = natural[i] * box.width / total
+		} else {
+			widths[i] = natural[i] + (box.width-total)/float64(columns)
+		}
+	}
+
+	lineHeight := pdfTableSize * 1.5
+	drawRow := func(row [][]exportRun, header bool) {
+		cells := make([][][]pdfFragment, columns)
+		height := 0.0
+		for i, cell := range row {
+			cells[i] = pdfBreakLines(p.fragments(cell, pdfTableSize), widths[i]-2*padding)
+			height = max(height, float64(len(cells[i]))*lineHeight)
+		}
+		height += 2 * padding
+		p.ensure(height)
+		x := box.x
+		for i := range cells {
+			if header {
+				p.page.content.WriteString(pdfRectOp(x, p.y-height, widths[i], height, pdfHeaderFill))
+			}
+			p.page.content.WriteString(pdfStrokeOp(x, p.y-height, widths[i], height, pdfBorder))
+			y := p.y - padding
+			for _, line := range cells[i] {
+				y -= lineHeight
+				p.drawLine(line, x+padding, y+pdfTableSize*0.4, pdfTextColor)
+			}
+			x += widths[i]
+		}
+		p.y -= height
+		p.empty = false
+	}
+
+	p.ensure(lineHeight*2 + 4*padding)
+	p.drawMarker(p.y - lineHeight)
+	for i, row := range block.rows {
+		pageCount := len(p.pages)
+		if i >= block.header {
+			// 换页后重复表头
+			height := 0.0
+			for j, cell := range row {
+				height = max(height, float64(len(pdfBreakLines(p.fragments(cell, pdfTableSize), widths[j]-2*padding)))*lineHeight)
+			}
+			p.ensure(height + 2*padding)
+			if len(p.pages) != pageCount {
+				for _, header := range block.rows[:block.header] {
+					drawRow(header, true)
+				}
+			}
+		}
+		drawRow(row, i < block.header)
+	}
+	p.y -= 8
+}
+
+// 图片，缩小到区域宽度和页面高度以内，像素按0.75磅换算
+func (p *pdfWriter) image(block exportBlock, box pdfBox) {
+	img := block.image
+	if img == nil {
+		if block.alt != "" {
+			p.paragraph([]exportRun{{text: "[" + block.alt + "]", italic: true}}, box, pdfFontSize)
+			p.y -= 6
+		}
+		return
+	}
+	width, height := float64(img.width)*0.75, float64(img.height)*0.75
+	if width > box.width {
+		height = height * box.width / width
+		width = box.width
+	}
+	if maxHeight := pdfTop - pdfMarginBottom; height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	p.ensure(height)
+	p.drawMarker(p.y - pdfFontSize)
+	index := -1
+	for i, v := range p.images {
+		if v == img {
+			index = i
+		}
+	}
+	if index < 0 {
+		p.images = append(p.images, img)
+		index = len(p.images) - 1
+	}
+	p.y -= height
+	p.page.content.WriteString(fmt.Sprintf("q %s 0 0 %s %s %s cm /Im%d Do Q\n", pdfNum(width), pdfNum(height), pdfNum(box.x), pdfNum(p.y), index+1))
+	p.y -= 8
+	p.empty = false
+}
+
+// 行内文字拆分为片段：西文单词、单个中文字符、空格，避头尾的标点与相邻字符合并
+func (p *pdfWriter) fragments(runs []exportRun, size float64) []pdfFragment {
+	var fragments []pdfFragment
+	for _, run := range runs {
+		if run.newline {
+			fragments = append(fragments, pdfFragment{newline: true})
+			continue
+		}
+		font := pdfRegular
+		fontSize := size
+		switch {
+		case run.code:
+			font = pdfMono
+			fontSize = size * 0.9
+		case run.bold && run.italic:
+			font = pdfBoldItalic
+		case run.bold:
+			font = pdfBold
+		case run.italic:
+			font = pdfItalic
+		}
+		if run.sup {
+			fontSize = size * 0.7
+		}
+		add := func(text string, space bool) {
+			fragments = append(fragments, pdfFragment{text: text, run: run, font: font, size: fontSize, width: pdfTextWidth(text, font, fontSize), space: space})
+		}
+		// 追加到上一个片段
+		appendLast := func(text string) bool {
+			n := len(fragments)
+			if n == 0 || fragments[n-1].space || fragments[n-1].newline || fragments[n-1].run != run {
+				return false
+			}
+			fragments[n-1].text += text
+			fragments[n-1].width += pdfTextWidth(text, font, fontSize)
+			return true
+		}
+		word := ""
+		glue := false // 上一个字符不能出现在行尾
+		flush := func() {
+			if word != "" {
+				if !glue || !appendLast(word) {
+					add(word, false)
+				}
+				word, glue = "", false
+			}
+		}
+		for _, r := range strings.Map(pdfCleanRune, run.text) {
+			switch {
+			case unicode.IsSpace(r) && !run.code:
+				flush()
+				glue = false
+				add(" ", true)
+			case exportIsWide(r) || strings.ContainsRune(pdfNoLineStart, r) || strings.ContainsRune(pdfNoLineEnd, r):
+				flush()
+				text := string(r)
+				if (glue || strings.ContainsRune(pdfNoLineStart, r)) && appendLast(text) {
+					// 已合并到上一个片段
+				} else {
+					add(text, false)
+				}
+				glue = strings.ContainsRune(pdfNoLineEnd, r)
+			default:
+				word += string(r)
+			}
+		}
+		flush()
+	}
+	return fragments
+}
+
+// 去掉控制字符，制表符替换为空格，不支持的字符替换为问号
+func pdfCleanRune(r rune) rune {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return ' '
+	case r < 0x20 || r == 0x7f:
+		return -1
+	case r > 0xffff:
+		return '?'
+	}
+	return r
+}
+
+// 按宽度断行，超长的单词按字符拆分
+func pdfBreakLines(fragments []pdfFragment, width float64) [][]pdfFragment {
+	var lines [][]pdfFragment
+	var line []pdfFragment
+	lineWidth := 0.0
+	flush := func() {
+		for len(line) > 0 && line[len(line)-1].space {
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, line)
+		line, lineWidth = nil, 0
+	}
+	var add func(fragment pdfFragment)
+	add = func(fragment pdfFragment) {
+		if fragment.space && len(line) == 0 {
+			return
+		}
+		if !fragment.space && lineWidth+fragment.width > width && len(line) > 0 {
+			flush()
+		}
+		if !fragment.space && fragment.width > width && utf8.RuneCountInString(fragment.text) > 1 {
+			for _, r := range fragment.text {
+				part := fragment
+				part.text = string(r)
+				part.width = pdfTextWidth(part.text, part.font, part.size)
+				add(part)
+			}
+			return
+		}
+		line = append(line, fragment)
+		lineWidth += fragment.width
+	}
+	for _, fragment := range fragments {
+		if fragment.newline {
+			flush()
+			continue
+		}
+		add(fragment)
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines
+}
+
+// 行宽
+func pdfLineWidth(line []pdfFragment) float64 {
+	width := 0.0
+	for _, fragment := range line {
+		width += fragment.width
+	}
+	return width
+}
+
+// 绘制列表项标记
+func (p *pdfWriter) drawMarker(baseline float64) {
+	if p.marker == nil {
+		return
+	}
+	p.page.content.WriteString(pdfTextOp(p.marker.x, baseline, p.marker.text, pdfRegular, pdfFontSize, pdfTextColor, false))
+	p.marker = nil
+}
+
+// 绘制一行文字，记录链接区域
+func (p *pdfWriter) drawLine(line []pdfFragment, x, baseline float64, color [3]float64) {
+	p.drawMarker(baseline)
+	for _, fragment := range line {
+		run := fragment.run
+		y := baseline
+		if run.sup {
+			y += fragment.size * 0.5
+		}
+		textColor := color
+		switch {
+		case run.link != "" || run.anchor != "":
+			textColor = pdfLinkColor
+			p.page.links = append(p.page.links, pdfLink{
+				rect:   [4]float64{x, baseline - fragment.size*0.3, x + fragment.width, baseline + fragment.size},
+				uri:    run.link,
+				anchor: run.anchor,
+			})
+		case run.code:
+			textColor = pdfCodeColor
+		}
+		if run.code && !fragment.space {
+			p.page.content.WriteString(pdfRectOp(x, baseline-fragment.size*0.3, fragment.width, fragment.size*1.3, pdfCodeFill))
+		}
+		p.page.content.WriteString(pdfTextOp(x, y, fragment.text, fragment.font, fragment.size, textColor, run.bold))
+		if run.strike {
+			p.page.content.WriteString(pdfRectOp(x, baseline+fragment.size*0.3, fragment.width, 0.6, textColor))
+		}
+		if run.link != "" || run.anchor != "" {
+			p.page.content.WriteString(pdfRectOp(x, baseline-1.5, fragment.width, 0.5, textColor))
+		}
+		x += fragment.width
+	}
+}
+
+// 文字的绘制指令，按字符切换西文和中文字体，中文加粗使用描边模拟
+func pdfTextOp(x, y float64, text string, font pdfFont, size float64, color [3]float64, bold bool) string {
+	var builder strings.Builder
+	builder.WriteString("BT " + pdfColor(color) + " rg " + pdfColor(color) + " RG " + pdfNum(x) + " " + pdfNum(y) + " Td ")
+	var segment []rune
+	current := pdfFont(-1)
+	flush := func() {
+		if len(segment) == 0 {
+			return
+		}
+		builder.WriteString(fmt.Sprintf("/F%d %s Tf ", int(current)+1, pdfNum(size)))
+		if current == pdfCJK {
+			if bold {
+				builder.WriteString(pdfNum(size/30) + " w 2 Tr ")
+			}
+			builder.WriteString("<")
+			for _, r := range segment {
+				builder.WriteString(fmt.Sprintf("%04X", r))
+			}
+			builder.WriteString("> Tj ")
+			if bold {
+				builder.WriteString("0 Tr ")
+			}
+		} else {
+			builder.WriteString("(")
+			for _, r := range segment {
+				code, _ := pdfWinAnsi(r)
+				switch code {
+				case '\\', '(', ')':
+					builder.WriteByte('\\')
+					builder.WriteByte(code)
+				default:
+					if code < 0x80 {
+						builder.WriteByte(code)
+					} else {
+						builder.WriteString(fmt.Sprintf("\\%03o", code))
+					}
+				}
+			}
+			builder.WriteString(") Tj ")
+		}
+		segment = segment[:0]
+	}
+	for _, r := range text {
+		actual, _ := pdfRuneWidth(r, font)
+		if actual != current {
+			flush()
+			current = actual
+		}
+		segment = append(segment, r)
+	}
+	flush()
+	builder.WriteString("ET\n")
+	return builder.String()
+}
+
+// 填充矩形
+func pdfRectOp(x, y, width, height float64, color [3]float64) string {
+	return pdfColor(color) + " rg " + pdfNum(x) + " " + pdfNum(y) + " " + pdfNum(width) + " " + pdfNum(height) + " re f\n"
+}
+
+// 矩形边框
+func pdfStrokeOp(x, y, width, height float64, color [3]float64) string {
+	return "0.6 w " + pdfColor(color) + " RG " + pdfNum(x) + " " + pdfNum(y) + " " + pdfNum(width) + " " + pdfNum(height) + " re S\n"
+}
+
+func pdfColor(color [3]float64) string {
+	return pdfNum(color[0]) + " " + pdfNum(color[1]) + " " + pdfNum(color[2])
+}
+
+// 十六进制颜色
+func pdfHexColor(s string) [3]float64 {
+	var color [3]float64
+	if data, err := hex.DecodeString(s); err == nil && len(data) == 3 {
+		for i, v := range data {
+			color[i] = float64(v) / 255
+		}
+	}
+	return color
+}
+
+// 数字保留两位小数
+func pdfNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
+
+// 文本字符串，使用带BOM的UTF-16BE
+func pdfString(s string) string {
+	var builder strings.Builder
+	builder.WriteString("<FEFF")
+	for _, v := range utf16.Encode([]rune(s)) {
+		builder.WriteString(fmt.Sprintf("%04X", v))
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
+
+// 链接地址使用字面字符串
+func pdfLiteral(s string) string {
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", "", "\n", "").Replace(s) + ")"
+}
+
+// 输出PDF文件：目录、字体、页面、图片、书签
+func (p *pdfWriter) output(w io.Writer) error {
+	var objects []string
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+	reserve := func() int {
+		return add("")
+	}
+
+	catalog := reserve()
+	pagesId := reserve()
+	pageIds := make([]int, len(p.pages))
+	for i := range p.pages {
+		pageIds[i] = reserve()
+	}
+	dest := func(anchor string) (string, bool) {
+		d, ok := p.dests[anchor]
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("[%d 0 R /XYZ 0 %s null]", pageIds[d.page], pdfNum(d.y)), true
+	}
+
+	// 字体
+	var fonts strings.Builder
+	for i, name := range pdfFontNames {
+		id := add("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+		fonts.WriteString(fmt.Sprintf("/F%d %d 0 R ", i+1, id))
+	}
+	descriptor := add("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cidFont := add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor %d 0 R /DW 1000 >>", descriptor))
+	cjkFont := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UCS2-H /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFont))
+	fonts.WriteString(fmt.Sprintf("/F%d %d 0 R", int(pdfCJK)+1, cjkFont))
+
+	// 图片
+	var xObjects strings.Builder
+	for i, img := range p.images {
+		id, err := pdfImageObject(img, add)
+		if err != nil {
+			return err
+		}
+		xObjects.WriteString(fmt.Sprintf("/Im%d %d 0 R ", i+1, id))
+	}
+	resources := "<< /Font << " + fonts.String() + " >> /XObject << " + xObjects.String() + ">> >>"
+
+	// 页面
+	kids := make([]string, len(p.pages))
+	for i, page := range p.pages {
+		content, err := pdfDeflate(page.content.Bytes())
+		if err != nil {
+			return err
+		}
+		contentId := add(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content))
+		var annots strings.Builder
+		for _, link := range page.links {
+			action := ""
+			if link.anchor != "" {
+				d, ok := dest(link.anchor)
+				if !ok {
+					continue
+				}
+				action = "/Dest " + d
+			} else if link.uri != "" {
+				action = "/A << /S /URI /URI " + pdfLiteral(link.uri) + " >>"
+			} else {
+				continue
+			}
+			annots.WriteString(fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%s %s %s %s] /Border [0 0 0] %s >> ",
+				pdfNum(link.rect[0]), pdfNum(link.rect[1]), pdfNum(link.rect[2]), pdfNum(link.rect[3]), action))
+		}
+		page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R", pagesId, pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), resources, contentId)
+		if annots.Len() > 0 {
+			page += " /Annots [" + annots.String() + "]"
+		}
+		objects[pageIds[i]-1] = page + " >>"
+		kids[i] = fmt.Sprintf("%d 0 R", pageIds[i])
+	}
+	objects[pagesId-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))
+
+	// 书签
+	outlines := ""
+	if tree := p.c.tocTree(); len(tree) > 0 {
+		root := reserve()
+		first, last, count := p.outlines(tree, root, &objects, reserve, dest)
+		objects[root-1] = fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, last, count)
+		outlines = fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", root)
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R%s >>", pagesId, outlines)
+	info := add(fmt.Sprintf("<< /Title %s /Author %s /Producer (md) /CreationDate (D:%s) >>", pdfString(p.c.book.Title), pdfString(p.c.book.Author), p.c.book.Modified.Format("20060102150405")))
+
+	// 交叉引用表
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buffer.Len()
+		buffer.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, object))
+	}
+	xref := buffer.Len()
+	buffer.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		buffer.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buffer.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, info, xref))
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// 书签树，返回第一个、最后一个节点及可见节点数
+func (p *pdfWriter) outlines(nodes []*exportTocNode, parent int, objects *[]string, reserve func() int, dest func(string) (string, bool)) (int, int, int) {
+	ids := make([]int, len(nodes))
+	for i := range nodes {
+		ids[i] = reserve()
+	}
+	count := len(nodes)
+	for i, node := range nodes {
+		object := fmt.Sprintf("<< /Title %s /Parent %d 0 R", pdfString(node.heading.text), parent)
+		if d, ok := dest(node.heading.anchor); ok {
+			object += " /Dest " + d
+		}
+		if i > 0 {
+			object += fmt.Sprintf(" /Prev %d 0 R", ids[i-1])
+		}
+		if i < len(nodes)-1 {
+			object += fmt.Sprintf(" /Next %d 0 R", ids[i+1])
+		}
+		if len(node.children) > 0 {
+			first, last, childCount := p.outlines(node.children, ids[i], objects, reserve, dest)
+			object += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", first, last, childCount)
+			count += childCount
+		}
+		(*objects)[ids[i]-1] = object + " >>"
+	}
+	return ids[0], ids[len(ids)-1], count
+}
+
+// 图片对象，JPEG直接嵌入，其他格式解码后压缩，透明通道作为软遮罩
+func pdfImageObject(img *exportImage, add func(string) int) (int, error) {
+	size := fmt.Sprintf("/Width %d /Height %d /BitsPerComponent 8", img.width, img.height)
+	if img.format == "jpeg" {
+		config, _, err := image.DecodeConfig(bytes.NewReader(img.data))
+		if err == nil && (config.ColorModel == color.YCbCrModel || config.ColorModel == color.GrayModel) {
+			colorSpace := "/DeviceRGB"
+			if config.ColorModel == color.GrayModel {
+				colorSpace = "/DeviceGray"
+			}
+			return add(fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /ColorSpace %s /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream", size, colorSpace, len(img.data), img.data)), nil
+		}
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img.data))
+	if err != nil {
+		return 0, err
+	}
+	bounds := decoded.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 255 {
+				opaque = false
+			}
+		}
+	}
+	size = fmt.Sprintf("/Width %d /Height %d /BitsPerComponent 8", bounds.Dx(), bounds.Dy())
+	mask := ""
+	if !opaque {
+		data, err := pdfDeflate(alpha)
+		if err != nil {
+			return 0, err
+		}
+		id := add(fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /ColorSpace /DeviceGray /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", size, len(data), data))
+		mask = fmt.Sprintf(" /SMask %d 0 R", id)
+	}
+	data, err := pdfDeflate(rgb)
+	if err != nil {
+		return 0, err
+	}
+	return add(fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /ColorSpace /DeviceRGB%s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", size, mask, len(data), data)), nil
+}
+
+// zlib压缩
+func pdfDeflate(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,109 @@
+package util
+
+// PDF内置字体：西文使用Helvetica、Courier（WinAnsi编码），
+// 中文等其他字符使用Adobe-GB1字符集的STSong-Light（UCS-2编码），由阅读器提供字形，不嵌入字体文件
+type pdfFont int
+
+const (
+	pdfRegular pdfFont = iota
+	pdfBold
+	pdfItalic
+	pdfBoldItalic
+	pdfMono
+	pdfCJK
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique", "Courier"}
+
+// Helvetica字符宽度（千分之一字号），从空格(32)到~(126)
+var pdfHelveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// Helvetica-Bold字符宽度，从空格(32)到~(126)
+var pdfHelveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Latin-1补充符号(160-191)的宽度
+var pdfLatin1Widths = [32]int{
+	278, 333, 556, 556, 556, 556, 260, 556, 333, 737, 370, 556, 584, 333, 737, 333,
+	400, 584, 333, 333, 333, 556, 537, 278, 333, 333, 365, 556, 834, 834, 834, 611,
+}
+
+// Latin-1字母(192-255)按对应的基本字母计算宽度
+const pdfLatin1Letters = "AAAAAA" + "\x00" + "CEEEEIIIIDNOOOOO" + "\x01" + "OUUUUY" + "\x02\x03" + "aaaaaa" + "\x04" + "ceeeeiiiidnooooo" + "\x01" + "ouuuuy" + "\x05" + "y"
+
+// 无法对应基本字母的Latin-1字符宽度
+var pdfLatin1Special = map[byte]int{0: 1000, 1: 584, 2: 667, 3: 611, 4: 889, 5: 556}
+
+// WinAnsi编码128-159中的字符及宽度
+var pdfWinAnsiExtra = map[rune]struct {
+	code  byte
+	width int
+}{
+	'€': {0x80, 556}, '‚': {0x82, 222}, 'ƒ': {0x83, 556}, '„': {0x84, 333}, '…': {0x85, 1000}, '†': {0x86, 556}, '‡': {0x87, 556},
+	'ˆ': {0x88, 333}, '‰': {0x89, 1000}, 'Š': {0x8a, 667}, '‹': {0x8b, 333}, 'Œ': {0x8c, 1000}, 'Ž': {0x8e, 611},
+	'‘': {0x91, 222}, '’': {0x92, 222}, '“': {0x93, 333}, '”': {0x94, 333}, '•': {0x95, 350}, '–': {0x96, 556}, '—': {0x97, 1000},
+	'˜': {0x98, 333}, '™': {0x99, 1000}, 'š': {0x9a, 500}, '›': {0x9b, 333}, 'œ': {0x9c, 944}, 'ž': {0x9e, 500}, 'Ÿ': {0x9f, 667},
+}
+
+// 字符的WinAnsi编码，不支持时返回false
+func pdfWinAnsi(r rune) (byte, bool) {
+	if r >= 32 && r <= 126 || r >= 160 && r <= 255 {
+		return byte(r), true
+	}
+	if v, ok := pdfWinAnsiExtra[r]; ok {
+		return v.code, true
+	}
+	return 0, false
+}
+
+// 字符实际使用的字体和宽度（千分之一字号），WinAnsi不支持的字符使用中文字体
+func pdfRuneWidth(r rune, font pdfFont) (pdfFont, int) {
+	code, ok := pdfWinAnsi(r)
+	if !ok {
+		return pdfCJK, 1000
+	}
+	if font == pdfMono {
+		return font, 600
+	}
+	bold := font == pdfBold || font == pdfBoldItalic
+	switch {
+	case code >= 32 && code <= 126:
+		if bold {
+			return font, pdfHelveticaBoldWidths[code-32]
+		}
+		return font, pdfHelveticaWidths[code-32]
+	case code >= 160 && code <= 191:
+		return font, pdfLatin1Widths[code-160]
+	case code >= 192:
+		letter := pdfLatin1Letters[code-192]
+		if width, ok := pdfLatin1Special[letter]; ok {
+			return font, width
+		}
+		_, width := pdfRuneWidth(rune(letter), font)
+		return font, width
+	}
+	return font, pdfWinAnsiExtra[r].width
+}
+
+// 文字宽度（磅）
+func pdfTextWidth(text string, font pdfFont, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		_, width := pdfRuneWidth(r, font)
+		total += width
+	}
+	return float64(total) * size / 1000
+}
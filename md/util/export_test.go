@@ -0,0 +1,288 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 包含标题、列表、表格、代码、链接、PNG和JPEG图片的测试书籍
+func exportTestBook(t *testing.T) ExportBook {
+	t.Helper()
+	var pngData, jpegData bytes.Buffer
+	if err := png.Encode(&pngData, imageTestGradient(40, 30, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, imageTestGradient(50, 20, false), nil); err != nil {
+		t.Fatal(err)
+	}
+	images := map[string][]byte{"/resource/picture/a.png": pngData.Bytes(), "https://example.com/resource/picture/b.jpg": jpegData.Bytes()}
+	return ExportBook{
+		Id:       "1",
+		Title:    "测试文集",
+		Author:   "md",
+		Modified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Chapters: []ExportChapter{
+			{Title: "第一章", Level: 1, Link: "/doc/1", Content: "# 标题 <&>\n\n正文 **加粗** [第二章](/doc/2) [外链](https://example.com)\n\n- 一\n- 二\n\n![透明](/resource/picture/a.png)\n\n| 列1 | 列2 |\n| --- | --- |\n| a | b |\n"},
+			{Title: "第二章", Level: 1, Link: "/doc/2", Content: "## 代码\n\n```go\nfunc main() {}\n```\n\n![照片](https://example.com/resource/picture/b.jpg)\n\n![缺失](/resource/picture/none.png)\n"},
+		},
+		Image: func(src string) ([]byte, bool) {
+			data, ok := images[src]
+			return data, ok
+		},
+	}
+}
+
+// 导出为指定格式
+func exportTestOutput(t *testing.T, format string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := Export(exportTestBook(t), format, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// 读取zip中的全部文件
+func exportTestZip(t *testing.T, data []byte) (*zip.Reader, map[string][]byte) {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, file := range reader.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(file.Name, err)
+		}
+		files[file.Name] = content
+	}
+	return reader, files
+}
+
+// 校验XML格式正确
+func exportTestXML(t *testing.T, name string, data []byte) {
+	t.Helper()
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+	decoder.Entity = xml.HTMLEntity
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s格式错误：%v", name, err)
+		}
+	}
+}
+
+// 交叉引用表中的偏移指向对应的对象，流的长度与内容一致，页数和图片数量正确
+func TestExportPDF(t *testing.T) {
+	data := exportTestOutput(t, ExportPDF)
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("文件头或文件尾错误")
+	}
+
+	// 交叉引用表
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if match == nil {
+		t.Fatal("缺少startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if xref >= len(data) || !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref偏移错误：%d", xref)
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	size, _ := strconv.Atoi(strings.TrimPrefix(lines[1], "0 "))
+	if size < 2 || lines[2] != "0000000000 65535 f " {
+		t.Fatalf("交叉引用表错误：%q", lines[1:3])
+	}
+	objects := map[int]string{}
+	for i := 1; i < size; i++ {
+		entry := lines[2+i]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("第%d项格式错误：%q", i, entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		header := strconv.Itoa(i) + " 0 obj\n"
+		if offset >= xref || !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Fatalf("对象%d的偏移错误：%d", i, offset)
+		}
+		end := bytes.Index(data[offset:], []byte("\nendobj\n"))
+		if end < 0 {
+			t.Fatalf("对象%d未结束", i)
+		}
+		objects[i] = string(data[offset+len(header) : offset+end])
+	}
+	trailer := regexp.MustCompile(`trailer\n<< /Size (\d+) /Root (\d+) 0 R /Info (\d+) 0 R >>`).FindStringSubmatch(string(data[xref:]))
+	if trailer == nil || trailer[1] != strconv.Itoa(size) {
+		t.Fatalf("trailer错误：%v", trailer)
+	}
+	root, _ := strconv.Atoi(trailer[2])
+	if !strings.HasPrefix(objects[root], "<< /Type /Catalog /Pages ") || !strings.Contains(objects[root], "/Outlines") {
+		t.Errorf("Root错误：%s", objects[root])
+	}
+
+	// 流的长度
+	streamRegex := regexp.MustCompile(`(?s)^<<.*?/Length (\d+).*?>>\nstream\n(.*)\nendstream$`)
+	pages, images, count := 0, 0, ""
+	for i, object := range objects {
+		if strings.Contains(object, "stream\n") {
+			m := streamRegex.FindStringSubmatch(object)
+			if m == nil || m[1] != strconv.Itoa(len(m[2])) {
+				t.Errorf("对象%d的流长度错误", i)
+			}
+		}
+		switch {
+		case strings.HasPrefix(object, "<< /Type /Page /"):
+			pages++
+		case strings.HasPrefix(object, "<< /Type /Pages "):
+			count = regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(object)[1]
+		case strings.HasPrefix(object, "<< /Type /XObject /Subtype /Image ") && !strings.Contains(object, "/ColorSpace /DeviceGray /Filter /FlateDecode"):
+			images++
+		}
+	}
+	if pages == 0 || count != strconv.Itoa(pages) {
+		t.Errorf("页数错误：%d %s", pages, count)
+	}
+	if images != 2 {
+		t.Errorf("图片数量错误：%d", images)
+	}
+}
+
+// 包含必需的部件，XML格式正确，关系指向的部件和图片存在
+func TestExportDOCX(t *testing.T) {
+	_, files := exportTestZip(t, exportTestOutput(t, ExportDOCX))
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml", "word/_rels/document.xml.rels", "word/styles.xml", "docProps/core.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("缺少%s", name)
+		}
+	}
+	for name, data := range files {
+		if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels") {
+			exportTestXML(t, name, data)
+		}
+	}
+
+	// 关系
+	type relationships struct {
+		Items []struct {
+			Id         string `xml:"Id,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	rels := relationships{}
+	if err := xml.Unmarshal(files["word/_rels/document.xml.rels"], &rels); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	media := 0
+	for _, v := range rels.Items {
+		ids[v.Id] = true
+		if v.TargetMode == "External" {
+			continue
+		}
+		if _, ok := files["word/"+v.Target]; !ok {
+			t.Errorf("关系%s指向的部件不存在：%s", v.Id, v.Target)
+		}
+		if strings.HasPrefix(v.Target, "media/") {
+			media++
+		}
+	}
+	if media != 2 {
+		t.Errorf("图片数量错误：%d", media)
+	}
+	for _, m := range regexp.MustCompile(`r:(?:embed|id)="([^"]+)"`).FindAllStringSubmatch(string(files["word/document.xml"]), -1) {
+		if !ids[m[1]] {
+			t.Errorf("引用的关系不存在：%s", m[1])
+		}
+	}
+	for name := range files {
+		ext := strings.TrimPrefix(path.Ext(name), ".")
+		if strings.HasPrefix(name, "word/media/") && !strings.Contains(string(files["[Content_Types].xml"]), `Extension="`+ext+`"`) {
+			t.Errorf("缺少%s的类型", name)
+		}
+	}
+}
+
+// mimetype为第一个文件且不压缩，清单中的文件均存在，XHTML格式正确
+func TestExportEPUB(t *testing.T) {
+	data := exportTestOutput(t, ExportEPUB)
+	reader, files := exportTestZip(t, data)
+	first := reader.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store || string(files["mimetype"]) != "application/epub+zip" || len(first.Extra) != 0 {
+		t.Fatalf("mimetype错误：%+v", first.FileHeader)
+	}
+	// 阅读器按固定偏移识别：文件头30字节 + 文件名8字节后为mimetype内容
+	if string(data[38:58]) != "application/epub+zip" {
+		t.Errorf("mimetype偏移错误：%q", data[30:58])
+	}
+
+	container := struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}{}
+	if err := xml.Unmarshal(files["META-INF/container.xml"], &container); err != nil || len(container.Rootfiles) != 1 {
+		t.Fatal(container, err)
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	opf := struct {
+		Items []struct {
+			Id        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IdRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}{}
+	if err := xml.Unmarshal(files[opfPath], &opf); err != nil {
+		t.Fatal(err)
+	}
+	items := map[string]bool{}
+	images := 0
+	for _, item := range opf.Items {
+		items[item.Id] = true
+		name := path.Join(path.Dir(opfPath), item.Href)
+		content, ok := files[name]
+		if !ok {
+			t.Errorf("清单中的文件不存在：%s", name)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(item.MediaType, "image/"):
+			images++
+		case item.MediaType == "application/xhtml+xml" || strings.HasSuffix(item.MediaType, "+xml"):
+			exportTestXML(t, name, content)
+		}
+	}
+	if images != 2 {
+		t.Errorf("图片数量错误：%d", images)
+	}
+	if len(opf.Spine) != 4 {
+		t.Errorf("阅读顺序错误：%+v", opf.Spine)
+	}
+	for _, v := range opf.Spine {
+		if !items[v.IdRef] {
+			t.Errorf("阅读顺序引用的文件不在清单中：%s", v.IdRef)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// 代码高亮工具类，按语言识别关键字、字符串、注释、数字
+package util
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type HighlightKind int
+
+const (
+	HighlightText HighlightKind = iota
+	HighlightKeyword
+	HighlightString
+	HighlightComment
+	HighlightNumber
+	HighlightLiteral // true、false、null等
+)
+
+// 高亮片段
+type HighlightToken struct {
+	Kind HighlightKind
+	Text string
+}
+
+// 各类片段的颜色（RGB十六进制）
+var HighlightColors = map[HighlightKind]string{
+	HighlightText:    "24292E",
+	HighlightKeyword: "D73A49",
+	HighlightString:  "032F62",
+	HighlightComment: "6A737D",
+	HighlightNumber:  "005CC5",
+	HighlightLiteral: "005CC5",
+}
+
+// 语言的词法规则
+type highlightLanguage struct {
+	keywords      map[string]bool
+	literals      map[string]bool
+	lineComments  []string
+	blockComments [][2]string
+	quotes        string // 字符串的引号
+	ignoreCase    bool   // 关键字不区分大小写
+}
+
+var highlightLiterals = highlightWords("true false null nil None True False undefined NULL")
+
+var highlightLanguages = map[string]*highlightLanguage{
+	"go": {
+		keywords:      highlightWords("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+	},
+	"java": {
+		keywords:      highlightWords("abstract assert boolean break byte case catch char class const continue default do double else enum extends final finally float for goto if implements import instanceof int interface long native new package private protected public return short static strictfp super switch synchronized this throw throws transient try var void volatile while record"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+	"kotlin": {
+		keywords:      highlightWords("as break class continue do else for fun if in interface is object package return super this throw try typealias val var when while import private public protected internal override open data sealed companion"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+	"c": {
+		keywords:      highlightWords("auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while bool class namespace template typename public private protected virtual new delete this using try catch throw operator nullptr include define ifdef ifndef endif"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+	"csharp": {
+		keywords:      highlightWords("abstract as base bool break byte case catch char class const continue decimal default delegate do double else enum event explicit extern finally fixed float for foreach goto if implicit in int interface internal is lock long namespace new object operator out override params private protected public readonly ref return sealed short sizeof static string struct switch this throw try typeof uint ulong unsafe ushort using var virtual void volatile while async await"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+	"javascript": {
+		keywords:      highlightWords("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while yield interface type enum implements private public protected readonly declare namespace"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+	},
+	"rust": {
+		keywords:      highlightWords("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while"),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"",
+	},
+	"php": {
+		keywords:      highlightWords("abstract and array as break case catch class clone const continue declare default do echo else elseif empty endif endforeach endwhile extends final finally fn for foreach function global if implements include instanceof interface isset list namespace new or print private protected public require return static switch throw trait try unset use var while yield"),
+		lineComments:  []string{"//", "#"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+	"python": {
+		keywords:     highlightWords("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"ruby": {
+		keywords:     highlightWords("alias and begin break case class def defined do else elsif end ensure for if in module next not or redo rescue retry return self super then undef unless until when while yield require"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"shell": {
+		keywords:     highlightWords("if then else elif fi case esac for while until do done in function return local export echo exit set unset source"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"sql": {
+		keywords:      highlightWords("select from where and or not insert into values update set delete create table alter drop index primary key foreign references join left right inner outer on group by order having limit offset as distinct union all case when then else end is in like between exists default unique constraint view begin commit rollback"),
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "'\"",
+		ignoreCase:    true,
+	},
+	"json": {
+		quotes: "\"",
+	},
+	"yaml": {
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"xml": {
+		blockComments: [][2]string{{"<!--", "-->"}},
+		quotes:        "\"'",
+	},
+	"css": {
+		keywords:      highlightWords("important media import font-face keyframes"),
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'",
+	},
+}
+
+// 语言别名
+var highlightAliases = map[string]string{
+	"golang": "go", "js": "javascript", "jsx": "javascript", "ts": "javascript", "tsx": "javascript", "typescript": "javascript",
+	"vue": "javascript", "kt": "kotlin", "cpp": "c", "c++": "c", "h": "c", "hpp": "c", "objc": "c", "cs": "csharp", "c#": "csharp",
+	"rs": "rust", "py": "python", "python3": "python", "rb": "ruby", "sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell",
+	"mysql": "sql", "postgresql": "sql", "pgsql": "sql", "sqlite": "sql", "yml": "yaml", "html": "xml", "xhtml": "xml", "svg": "xml",
+	"scss": "css", "less": "css", "swift": "kotlin", "scala": "java", "groovy": "java", "dart": "java",
+}
+
+// 空格分隔的单词集合
+func highlightWords(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, v := range strings.Fields(s) {
+		words[v] = true
+	}
+	return words
+}
+
+// 代码高亮，未知语言整体作为普通文本
+func HighlightCode(code, lang string) []HighlightToken {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if alias, ok := highlightAliases[lang]; ok {
+		lang = alias
+	}
+	language := highlightLanguages[lang]
+	if language == nil {
+		return []HighlightToken{{Kind: HighlightText, Text: code}}
+	}
+
+	var tokens []HighlightToken
+	add := func(kind HighlightKind, text string) {
+		if text == "" {
+			return
+		}
+		// 合并相邻的同类片段
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, HighlightToken{Kind: kind, Text: text})
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		// 注释
+		if end := highlightComment(language, rest); end > 0 {
+			add(HighlightComment, rest[:end])
+			i += end
+			continue
+		}
+
+		c := code[i]
+		// 字符串，支持反斜杠转义，反引号字符串可跨行
+		if strings.IndexByte(language.quotes, c) >= 0 {
+			end := 1
+			for end < len(rest) {
+				if rest[end] == '\\' && c != '`' {
+					end += 2
+					continue
+				}
+				if rest[end] == c {
+					end++
+					break
+				}
+				if rest[end] == '\n' && c != '`' {
+					break
+				}
+				end++
+			}
+			if end > len(rest) {
+				end = len(rest)
+			}
+			add(HighlightString, rest[:end])
+			i += end
+			continue
+		}
+
+		// 数字
+		if c >= '0' && c <= '9' && (i == 0 || !highlightWordByte(code[i-1])) {
+			end := 1
+			for end < len(rest) && (highlightWordByte(rest[end]) || rest[end] == '.' && end+1 < len(rest) && rest[end+1] >= '0' && rest[end+1] <= '9') {
+				end++
+			}
+			add(HighlightNumber, rest[:end])
+			i += end
+			continue
+		}
+
+		// 单词
+		if highlightWordByte(c) {
+			end := 1
+			for end < len(rest) && highlightWordByte(rest[end]) {
+				end++
+			}
+			word := rest[:end]
+			key := word
+			if language.ignoreCase {
+				key = strings.ToLower(word)
+			}
+			switch {
+			case language.keywords[key]:
+				add(HighlightKeyword, word)
+			case highlightLiterals[word] || language.literals[word]:
+				add(HighlightLiteral, word)
+			default:
+				add(HighlightText, word)
+			}
+			i += end
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		add(HighlightText, rest[:size])
+		i += size
+	}
+	return tokens
+}
+
+// 注释的长度，不是注释时返回0
+func highlightComment(language *highlightLanguage, s string) int {
+	for _, prefix := range language.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	for _, pair := range language.blockComments {
+		if strings.HasPrefix(s, pair[0]) {
+			if end := strings.Index(s[len(pair[0]):], pair[1]); end >= 0 {
+				return len(pair[0]) + end + len(pair[1])
+			}
+			return len(s)
+		}
+	}
+	return 0
+}
+
+// 可组成标识符的字符，非ASCII字符按字母处理
+func highlightWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
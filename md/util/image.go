@@ -0,0 +1,354 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	// 注册BMP、WebP解码器，ImageDecode及导出时使用
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 处理图片时允许解码的最大像素数，避免超大图片占用过多内存
+const ImageMaxPixels = 50_000_000
+
+// ImageFormat 根据文件内容判断图片格式：jpeg、png、gif、webp、bmp、ico，无法识别时返回空字符串
+func ImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "bmp"
+	case bytes.HasPrefix(data, []byte{0, 0, 1, 0}):
+		return "ico"
+	}
+	return ""
+}
+
+// ImageAnimated 是否为APNG或WebP动图，GIF不做判断
+func ImageAnimated(data []byte) bool {
+	switch ImageFormat(data) {
+	case "png":
+		animated := false
+		pngChunks(data, func(name string, _ []byte) bool {
+			animated = animated || name == "acTL"
+			return name != "IDAT"
+		})
+		return animated
+	case "webp":
+		return len(data) >= 21 && string(data[12:16]) == "VP8X" && data[20]&0x02 != 0
+	}
+	return false
+}
+
+// ImageStripMetadata 去除JPEG、PNG、WebP中的EXIF、XMP、文本注释等元数据（包括GPS位置），
+// 返回处理后的数据及EXIF中的方向（1-8，无方向信息时为1），其他格式原样返回
+func ImageStripMetadata(data []byte) ([]byte, int) {
+	switch ImageFormat(data) {
+	case "jpeg":
+		return jpegStripMetadata(data)
+	case "png":
+		return pngStripMetadata(data)
+	case "webp":
+		return webpStripMetadata(data)
+	}
+	return data, 1
+}
+
+// JPEG：去除APP1（EXIF、XMP）、APP13（IPTC）和注释，保留色彩配置等其他段
+func jpegStripMetadata(data []byte) ([]byte, int) {
+	orientation := 1
+	out := make([]byte, 2, len(data))
+	copy(out, data[:2])
+	stripped := false
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xff {
+		marker := data[i+1]
+		if marker == 0xff {
+			i++
+			continue
+		}
+		// 扫描数据开始后不再有元数据
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+		if marker == 0x01 || marker >= 0xd0 && marker <= 0xd7 {
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			break
+		}
+		segment := data[i:end]
+		if marker == 0xe1 || marker == 0xed || marker == 0xfe {
+			if marker == 0xe1 && bytes.HasPrefix(segment[4:], []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(segment[10:])
+			}
+			stripped = true
+		} else {
+			out = append(out, segment...)
+		}
+		i = end
+	}
+	if !stripped {
+		return data, orientation
+	}
+	return append(out, data[i:]...), orientation
+}
+
+// PNG：去除eXIf、文本和时间块
+func pngStripMetadata(data []byte) ([]byte, int) {
+	orientation := 1
+	out := make([]byte, 8, len(data))
+	copy(out, data[:8])
+	stripped := false
+	end := pngChunks(data, func(name string, chunk []byte) bool {
+		switch name {
+		case "eXIf":
+			orientation = exifOrientation(chunk[8 : len(chunk)-4])
+			stripped = true
+		case "tEXt", "zTXt", "iTXt", "tIME":
+			stripped = true
+		default:
+			out = append(out, chunk...)
+		}
+		return true
+	})
+	if !stripped {
+		return data, orientation
+	}
+	return append(out, data[end:]...), orientation
+}
+
+// 遍历PNG的块（包括长度、类型和CRC），fn返回false时停止，返回最后处理的位置
+func pngChunks(data []byte, fn func(name string, chunk []byte) bool) int {
+	i := 8
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			break
+		}
+		name := string(data[i+4 : i+8])
+		if !fn(name, data[i:end]) {
+			return end
+		}
+		i = end
+		if name == "IEND" {
+			break
+		}
+	}
+	return i
+}
+
+// WebP：去除EXIF、XMP块并更新VP8X中的标记
+func webpStripMetadata(data []byte) ([]byte, int) {
+	orientation := 1
+	// VP8X块至少10字节，否则不是有效的扩展格式，不处理
+	if len(data) < 30 || string(data[12:16]) != "VP8X" || binary.LittleEndian.Uint32(data[16:]) < 10 {
+		return data, orientation
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	stripped := false
+	i := 12
+	for i+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || i+8+size > len(data) {
+			break
+		}
+		end = min(end, len(data))
+		switch string(data[i : i+4]) {
+		case "EXIF":
+			exif := data[i+8 : i+8+size]
+			orientation = exifOrientation(bytes.TrimPrefix(exif, []byte("Exif\x00\x00")))
+			stripped = true
+		case "XMP ":
+			stripped = true
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	if !stripped {
+		return data, orientation
+	}
+	out[20] &^= 0x08 | 0x04
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, orientation
+}
+
+// 从EXIF（TIFF格式）的第一个IFD中读取方向
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// ImageDecode 解码图片，动图只解码第一帧，像素数超过ImageMaxPixels时返回错误
+func ImageDecode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > ImageMaxPixels {
+		return nil, errors.New("图片尺寸过大")
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// ImageOrient 按EXIF方向（1-8）旋转、翻转图片，使其以正常方向显示
+func ImageOrient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Rect, img, bounds.Min, draw.Src)
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = width-1-x, y
+			case 3:
+				sx, sy = width-1-x, height-1-y
+			case 4:
+				sx, sy = x, height-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, height-1-x
+			case 7:
+				sx, sy = width-1-y, height-1-x
+			case 8:
+				sx, sy = width-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// ImageResize 等比缩小到不超过指定宽高，图片较小时原样返回
+func ImageResize(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	dst := image.NewNRGBA(image.Rect(0, 0, max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))))
+	xdraw.CatmullRom.Scale(dst, dst.Rect, img, bounds, draw.Src, nil)
+	return dst
+}
+
+// ImageOpaque 图片是否不含透明像素
+func ImageOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// ImageEncode 按格式编码图片，quality用于JPEG和WebP，不支持编码的格式（BMP、ICO）使用PNG
+func ImageEncode(img image.Image, format string, quality int) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: quality})
+	case "webp":
+		err = EncodeWebP(&buffer, img, quality)
+	case "gif":
+		bounds := img.Bounds()
+		paletted := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+		err = gif.Encode(&buffer, paletted, nil)
+	default:
+		err = png.Encode(&buffer, img)
+	}
+	return buffer.Bytes(), err
+}
+
+// ImageToWebP 将图片编码为不超过maxSize字节的WebP，依次降低质量和尺寸，
+// 无法压缩到该大小时返回尝试过的最小结果
+func ImageToWebP(img image.Image, maxSize int) ([]byte, error) {
+	var smallest []byte
+	for {
+		for _, quality := range []int{80, 65, 50} {
+			data, err := ImageEncode(img, "webp", quality)
+			if err != nil {
+				return nil, err
+			}
+			if smallest == nil || len(data) < len(smallest) {
+				smallest = data
+			}
+			if len(data) <= maxSize {
+				return data, nil
+			}
+		}
+		// 按超出的比例缩小尺寸后重试，最长边不小于320像素
+		bounds := img.Bounds()
+		longest := max(bounds.Dx(), bounds.Dy())
+		if longest <= 320 {
+			return smallest, nil
+		}
+		scale := max(0.5, min(0.9, math.Sqrt(float64(maxSize)/float64(len(smallest)))))
+		target := max(320, int(float64(longest)*scale))
+		img = ImageResize(img, target, target)
+	}
+}
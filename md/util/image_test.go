@@ -0,0 +1,221 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// 渐变测试图片
+func imageTestGradient(width, height int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			a := uint8(255)
+			if alpha && x < width/2 {
+				a = 0
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: a})
+		}
+	}
+	return img
+}
+
+// 包含方向的EXIF（TIFF格式，小端）
+func imageTestExif(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	return append(tiff, 0, 0, 0, 0, 0, 0)
+}
+
+// 两张图片的平均像素误差
+func imageTestDiff(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	total := 0.0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				total += float64(max(d, -d))
+			}
+		}
+	}
+	return total / float64(bounds.Dx()*bounds.Dy()*3)
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	for _, size := range [][2]int{{64, 48}, {37, 21}, {1, 1}} {
+		for _, alpha := range []bool{false, true} {
+			src := imageTestGradient(size[0], size[1], alpha)
+			var buffer bytes.Buffer
+			if err := EncodeWebP(&buffer, src, 90); err != nil {
+				t.Fatal(err)
+			}
+			if ImageFormat(buffer.Bytes()) != "webp" {
+				t.Fatalf("%v: 格式错误", size)
+			}
+			img, err := webp.Decode(bytes.NewReader(buffer.Bytes()))
+			if err != nil {
+				t.Fatalf("%v %v: %v", size, alpha, err)
+			}
+			if img.Bounds().Dx() != size[0] || img.Bounds().Dy() != size[1] {
+				t.Fatalf("%v: 尺寸错误 %v", size, img.Bounds())
+			}
+			if alpha {
+				if _, _, _, a := img.At(0, 0).RGBA(); a != 0 && size[0] > 1 {
+					t.Errorf("%v: 透明度未保存", size)
+				}
+			} else if diff := imageTestDiff(src, img); diff > 12 {
+				t.Errorf("%v: 平均误差过大 %.2f", size, diff)
+			}
+		}
+	}
+}
+
+// 超出大小时降低质量、缩小尺寸
+func TestImageToWebPMaxSize(t *testing.T) {
+	img := imageTestGradient(1600, 1200, false)
+	for i := range img.Pix {
+		if i%4 != 3 && i%13 == 0 {
+			img.Pix[i] ^= 0x3f
+		}
+	}
+	low, err := ImageEncode(img, "webp", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxSize := len(low) / 3
+	data, err := ImageToWebP(img, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxSize {
+		t.Fatalf("超出大小：%d > %d", len(data), maxSize)
+	}
+	decoded, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() >= 1600 || max(bounds.Dx()*3-bounds.Dy()*4, bounds.Dy()*4-bounds.Dx()*3) > 4 {
+		t.Fatalf("尺寸错误：%v", bounds)
+	}
+}
+
+func TestImageStripMetadataJpeg(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, imageTestGradient(16, 8, false), nil); err != nil {
+		t.Fatal(err)
+	}
+	exif := append([]byte("Exif\x00\x00"), imageTestExif(6)...)
+	segment := append([]byte{0xff, 0xe1, 0, 0}, exif...)
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+	comment := []byte{0xff, 0xfe, 0, 6, 'g', 'p', 's', '!'}
+	data := append(append(append([]byte{0xff, 0xd8}, segment...), comment...), buffer.Bytes()[2:]...)
+
+	stripped, orientation := ImageStripMetadata(data)
+	if orientation != 6 {
+		t.Fatalf("方向错误：%d", orientation)
+	}
+	if bytes.Contains(stripped, []byte("Exif")) || bytes.Contains(stripped, []byte("gps!")) {
+		t.Fatal("元数据未去除")
+	}
+	img, err := ImageDecode(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oriented := ImageOrient(img, orientation); oriented.Bounds().Dx() != 8 || oriented.Bounds().Dy() != 16 {
+		t.Fatalf("旋转后尺寸错误：%v", oriented.Bounds())
+	}
+}
+
+// PNG块，包括长度、类型和CRC
+func imageTestPngChunk(name string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, name...), data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestImageStripMetadataPng(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, imageTestGradient(8, 8, false)); err != nil {
+		t.Fatal(err)
+	}
+	src := buffer.Bytes()
+	ihdrEnd := 8 + 12 + 13
+	data := append([]byte{}, src[:ihdrEnd]...)
+	data = append(data, imageTestPngChunk("eXIf", imageTestExif(3))...)
+	data = append(data, imageTestPngChunk("tEXt", []byte("Comment\x00gps!"))...)
+	data = append(data, src[ihdrEnd:]...)
+
+	stripped, orientation := ImageStripMetadata(data)
+	if orientation != 3 {
+		t.Fatalf("方向错误：%d", orientation)
+	}
+	if !bytes.Equal(stripped, src) {
+		t.Fatal("元数据未去除")
+	}
+	if stripped, _ = ImageStripMetadata(src); !bytes.Equal(stripped, src) {
+		t.Fatal("无元数据时内容不应改变")
+	}
+}
+
+// RIFF块，奇数长度补齐
+func imageTestRiffChunk(name string, data []byte) []byte {
+	chunk := append([]byte(name), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestImageStripMetadataWebP(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := EncodeWebP(&buffer, imageTestGradient(16, 8, false), 80); err != nil {
+		t.Fatal(err)
+	}
+	simple := buffer.Bytes()
+	if string(simple[12:16]) != "VP8 " {
+		t.Fatalf("期望简单格式：%q", simple[12:16])
+	}
+
+	// 扩展格式：VP8X（带EXIF、XMP标记）+ VP8 + EXIF + XMP
+	vp8x := []byte{0x08 | 0x04, 0, 0, 0, 15, 0, 0, 7, 0, 0}
+	body := append([]byte("WEBP"), imageTestRiffChunk("VP8X", vp8x)...)
+	body = append(body, simple[12:]...)
+	body = append(body, imageTestRiffChunk("EXIF", append([]byte("Exif\x00\x00"), imageTestExif(8)...))...)
+	body = append(body, imageTestRiffChunk("XMP ", []byte("<x:xmpmeta gps/>"))...)
+	data := append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+	if _, err := webp.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	stripped, orientation := ImageStripMetadata(data)
+	if orientation != 8 {
+		t.Fatalf("方向错误：%d", orientation)
+	}
+	if bytes.Contains(stripped, []byte("EXIF")) || bytes.Contains(stripped, []byte("xmpmeta")) {
+		t.Fatal("元数据未去除")
+	}
+	if stripped[20]&(0x08|0x04) != 0 || int(binary.LittleEndian.Uint32(stripped[4:])) != len(stripped)-8 {
+		t.Fatal("VP8X标记或RIFF长度未更新")
+	}
+	img, err := webp.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Fatal(img.Bounds())
+	}
+}
@@ -0,0 +1,138 @@
+// Markdown渲染工具类
+package util
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Markdown中的链接：[[wikilink]]、[文字](地址)、<img src="地址">，前加!时为图片或嵌入
+var markdownLinkRegex = regexp.MustCompile(`(!?)\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]` +
+	`|(!?)\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\((<[^<>\n]*>|[^()\s]*(?:\([^()\s]*\)[^()\s]*)*)(\s+(?:"[^"\n]*"|'[^'\n]*'))?\)` +
+	`|(<img\s[^>]*?src=["'])([^"']+)(["'])`)
+
+// Markdown中的链接
+type MarkdownLink struct {
+	Embed  bool   // 是否为图片或嵌入
+	Wiki   bool   // 是否为[[wikilink]]
+	Text   string // 链接文字，wikilink为别名
+	Target string // 链接地址，wikilink为笔记名称（可包含#标题）
+}
+
+// Markdown转html片段，标题自动生成id
+func MarkdownToHTML(content string) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.Footnotes)
+	renderer := html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})
+	return string(markdown.ToHTML([]byte(content), p, renderer))
+}
+
+// 替换Markdown中的链接地址，代码块和行内代码中的内容不处理；
+// replace返回新的地址，返回false时保留原文，wikilink替换后改为普通链接（可修改Embed改为非嵌入的链接）
+func ReplaceMarkdownLinks(content string, replace func(link *MarkdownLink) (string, bool)) string {
+	lines := strings.SplitAfter(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		lines[i] = replaceLineLinks(line, replace)
+	}
+	return strings.Join(lines, "")
+}
+
+// 替换一行中行内代码以外的链接
+func replaceLineLinks(line string, replace func(link *MarkdownLink) (string, bool)) string {
+	var builder strings.Builder
+	for line != "" {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			builder.WriteString(replaceTextLinks(line, replace))
+			break
+		}
+		// 行内代码以相同数量的反引号结束，没有结束时视为普通文本
+		n := start
+		for n < len(line) && line[n] == '`' {
+			n++
+		}
+		ticks := line[start:n]
+		end := -1
+		for offset := n; offset < len(line); {
+			i := strings.Index(line[offset:], ticks)
+			if i < 0 {
+				break
+			}
+			i += offset
+			j := i + len(ticks)
+			if j == len(line) || line[j] != '`' {
+				end = j
+				break
+			}
+			for j < len(line) && line[j] == '`' {
+				j++
+			}
+			offset = j
+		}
+		if end < 0 {
+			builder.WriteString(replaceTextLinks(line, replace))
+			break
+		}
+		builder.WriteString(replaceTextLinks(line[:start], replace))
+		builder.WriteString(line[start:end])
+		line = line[end:]
+	}
+	return builder.String()
+}
+
+// 替换文本中的链接
+func replaceTextLinks(text string, replace func(link *MarkdownLink) (string, bool)) string {
+	return markdownLinkRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := markdownLinkRegex.FindStringSubmatch(s)
+		switch {
+		case m[2] != "":
+			link := MarkdownLink{Embed: m[1] != "", Wiki: true, Text: strings.TrimSpace(m[3]), Target: strings.TrimSpace(m[2])}
+			target, ok := replace(&link)
+			if !ok {
+				return s
+			}
+			prefix := ""
+			text := link.Text
+			if link.Embed {
+				prefix = "!"
+				// 图片的别名为显示尺寸，使用文件名作为说明文字
+				text = strings.TrimSuffix(path.Base(link.Target), path.Ext(link.Target))
+			} else if text == "" {
+				text = link.Target
+			}
+			return prefix + "[" + text + "](" + target + ")"
+		case m[8] != "":
+			target, ok := replace(&MarkdownLink{Embed: true, Target: m[9]})
+			if !ok {
+				return s
+			}
+			return m[8] + target + m[10]
+		default:
+			target := strings.TrimSuffix(strings.TrimPrefix(m[6], "<"), ">")
+			if target == "" {
+				return s
+			}
+			newTarget, ok := replace(&MarkdownLink{Embed: m[4] != "", Text: m[5], Target: target})
+			if !ok {
+				return s
+			}
+			return m[4] + "[" + m[5] + "](" + newTarget + m[7] + ")"
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DetectMime 根据文件内容识别MIME类型，在http.DetectContentType的基础上识别Office、OpenDocument、EPUB、7z、RAR、tar，
+// 内容为纯文本或OLE复合文档等无法区分的格式时参考文件名后缀
+func DetectMime(reader io.ReaderAt, size int64, name string) string {
+	head := make([]byte, 512)
+	n, _ := reader.ReadAt(head, 0)
+	head = head[:n]
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	ext := FileExt(name)
+
+	switch {
+	case bytes.HasPrefix(head, []byte("7z\xbc\xaf\x27\x1c")):
+		return "application/x-7z-compressed"
+	case bytes.HasPrefix(head, []byte("Rar!\x1a\x07")):
+		return "application/vnd.rar"
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "application/x-tar"
+	case bytes.HasPrefix(head, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")):
+		// OLE复合文档（旧版Office）
+		switch ext {
+		case ".doc", ".dot":
+			return "application/msword"
+		case ".xls", ".xlt":
+			return "application/vnd.ms-excel"
+		case ".ppt", ".pot", ".pps":
+			return "application/vnd.ms-powerpoint"
+		}
+		return "application/x-ole-storage"
+	case detected == "application/zip":
+		return zipMime(reader, size)
+	case detected == "text/plain":
+		switch ext {
+		case ".csv":
+			return "text/csv"
+		case ".md", ".markdown":
+			return "text/markdown"
+		case ".json":
+			return "application/json"
+		}
+	}
+	return detected
+}
+
+// zip格式的文档：EPUB、OpenDocument的mimetype文件，Office Open XML的目录结构
+func zipMime(reader io.ReaderAt, size int64) string {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil || len(zipReader.File) == 0 {
+		return "application/zip"
+	}
+	if first := zipReader.File[0]; first.Name == "mimetype" && first.UncompressedSize64 < 100 {
+		file, err := first.Open()
+		if err == nil {
+			content, err := io.ReadAll(file)
+			file.Close()
+			if value := strings.TrimSpace(string(content)); err == nil && strings.HasPrefix(value, "application/") && !strings.ContainsAny(value, " ;\r\n") {
+				return value
+			}
+		}
+	}
+	for _, file := range zipReader.File {
+		switch file.Name {
+		case "word/document.xml":
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		case "xl/workbook.xml":
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		case "ppt/presentation.xml":
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		}
+	}
+	return "application/zip"
+}
+
+// MimeMatch MIME类型是否在列表中，列表中可使用image/*形式匹配同一大类
+func MimeMatch(mimeType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mimeType || strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// 判断是否为本机、内网、链路本地等非公网地址
+func PrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		// 0.0.0.0/8、运营商级NAT 100.64.0.0/10
+		return ip4[0] == 0 || ip4[0] == 100 && ip4[1]&0xc0 == 64
+	}
+	return false
+}
+
+// 解析域名，任一地址为内网地址时返回privateErr，用于请求前给出明确的提示
+func CheckPublicHost(host string, privateErr error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, v := range addresses {
+		if PrivateIP(v.IP) {
+			return privateErr
+		}
+	}
+	return nil
+}
+
+// 生成net.Dialer的Control：建立连接前校验实际连接的地址，避免域名解析结果变化或重定向绕过校验，
+// allowPrivate返回true时不校验，连接内网地址时返回privateErr
+func PublicDialControl(allowPrivate func() bool, privateErr error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if allowPrivate() {
+			return nil
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || PrivateIP(ip) {
+			return privateErr
+		}
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+// OpenAPI文档解析工具类
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI中的请求方法，按常用顺序排列
+var OpenApiMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// 解析OpenAPI文档（JSON或YAML），返回统一为map[string]interface{}的结构
+func ParseOpenApi(content string) (map[string]interface{}, error) {
+	var raw interface{}
+	err := yaml.Unmarshal([]byte(content), &raw)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := normalizeYaml(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("文档根节点不是对象")
+	}
+	return spec, nil
+}
+
+// 解析OpenAPI文档，Swagger 2.0文档先转换为OpenAPI 3
+func parseOpenApi3(content string) (map[string]interface{}, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal([]byte(content), &document)
+	if err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, errors.New("文档内容为空")
+	}
+	root := resolveAlias(document.Content[0])
+	if nodeField(root, "swagger") != nil {
+		err = ConvertSwagger2(root)
+		if err != nil {
+			return nil, err
+		}
+	} else if nodeField(root, "openapi") == nil {
+		return nil, errors.New("缺少openapi或swagger字段")
+	}
+	var raw interface{}
+	err = root.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := normalizeYaml(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("文档根节点不是对象")
+	}
+	return spec, nil
+}
+
+// 将yaml解析出的map[interface{}]interface{}统一转为map[string]interface{}
+func normalizeYaml(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYaml(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprint(key)] = normalizeYaml(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYaml(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// 获取对象类型的字段，不存在或类型不符时返回nil
+func MapValue(m map[string]interface{}, key string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+// 获取数组类型的字段
+func ListValue(m map[string]interface{}, key string) []interface{} {
+	if m == nil {
+		return nil
+	}
+	v, _ := m[key].([]interface{})
+	return v
+}
+
+// 获取字符串类型的字段，数字等标量会转为字符串
+func StringValue(m map[string]interface{}, key string) string {
+	if m == nil || m[key] == nil {
+		return ""
+	}
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// 获取布尔类型的字段
+func BoolValue(m map[string]interface{}, key string) bool {
+	if m == nil {
+		return false
+	}
+	v, _ := m[key].(bool)
+	return v
+}
+
+// 解析文档内引用（#/components/schemas/Name），非文档内引用或无法解析时返回nil
+func ResolveRef(spec map[string]interface{}, ref string) map[string]interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var current interface{} = spec
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil
+		}
+	}
+	result, _ := current.(map[string]interface{})
+	return result
+}
+
+// 如果对象是引用则解析引用，否则原样返回
+func Deref(spec, m map[string]interface{}) map[string]interface{} {
+	// 限制层数，防止循环引用
+	for i := 0; i < 10 && m != nil; i++ {
+		ref := StringValue(m, "$ref")
+		if ref == "" {
+			return m
+		}
+		m = ResolveRef(spec, ref)
+	}
+	return m
+}
+
+// 引用名称，取引用路径的最后一段
+func RefName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// schema类型的简要描述，如 string(date-time)、Pet[]、object
+func SchemaTypeName(schema map[string]interface{}) string {
+	if schema == nil {
+		return ""
+	}
+	if ref := StringValue(schema, "$ref"); ref != "" {
+		return RefName(ref)
+	}
+	typeName := StringValue(schema, "type")
+	if types := ListValue(schema, "type"); len(types) > 0 {
+		names := make([]string, 0, len(types))
+		for _, v := range types {
+			names = append(names, fmt.Sprint(v))
+		}
+		typeName = strings.Join(names, " | ")
+	}
+	if typeName == "array" {
+		return SchemaTypeName(MapValue(schema, "items")) + "[]"
+	}
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		if list := ListValue(schema, key); len(list) > 0 && typeName == "" {
+			names := make([]string, 0, len(list))
+			for _, v := range list {
+				item, _ := v.(map[string]interface{})
+				names = append(names, SchemaTypeName(item))
+			}
+			separator := " | "
+			if key == "allOf" {
+				separator = " & "
+			}
+			return strings.Join(names, separator)
+		}
+	}
+	if format := StringValue(schema, "format"); format != "" {
+		typeName += "(" + format + ")"
+	}
+	if typeName == "" && MapValue(schema, "properties") != nil {
+		typeName = "object"
+	}
+	return typeName
+}
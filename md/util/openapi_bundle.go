@@ -0,0 +1,471 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	openApiMaxFiles = 200 // 合并时最多加载的文件数量
+	openApiMaxDepth = 10  // 文件引用的最大层级，入口文件为0
+)
+
+// 加载OpenAPI文件，路径为入口路径或按引用解析后的路径（相对路径或URL）
+type OpenApiLoader func(path string) ([]byte, error)
+
+var (
+	openApiComponentRefRegex = regexp.MustCompile(`^/components/([a-zA-Z]+)/([^/]+)$`)
+	openApiSwaggerRefRegex   = regexp.MustCompile(`^/(definitions|parameters|responses)/([^/]+)$`)
+	openApiNameRegex         = regexp.MustCompile(`[^a-zA-Z0-9.\-_]+`)
+)
+
+// 合并多文件的OpenAPI文档：外部引用的内容放入components（Swagger 2.0为definitions、parameters、responses）并改为内部引用，
+// 无法作为组件的内容（如路径）直接内联
+func BundleOpenApi(entry string, load OpenApiLoader) (*yaml.Node, error) {
+	b := &openApiBundler{
+		load:     load,
+		entry:    entry,
+		files:    map[string]*yaml.Node{},
+		refs:     map[string]string{},
+		names:    map[string]string{},
+		inlining: map[string]bool{},
+		depth:    map[string]int{},
+	}
+	root, err := b.loadFile(entry)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("文档根节点应为对象")
+	}
+	b.root = root
+	b.swagger = nodeField(root, "swagger") != nil
+
+	// 入口文件中已有的组件名称
+	for _, section := range []string{"definitions", "parameters", "responses"} {
+		if b.swagger {
+			nodeEach(nodeField(root, section), func(key, _ *yaml.Node) {
+				b.names[section+"/"+key.Value] = entry + "#/" + section + "/" + pointerEscape(key.Value)
+			})
+		}
+	}
+	if !b.swagger {
+		nodeEach(nodeField(root, "components"), func(section, value *yaml.Node) {
+			nodeEach(value, func(key, _ *yaml.Node) {
+				b.names[section.Value+"/"+key.Value] = entry + "#/components/" + section.Value + "/" + pointerEscape(key.Value)
+			})
+		})
+	}
+
+	err = b.process(root, entry, nil)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// OpenAPI文档合并器
+type openApiBundler struct {
+	load     OpenApiLoader
+	entry    string
+	root     *yaml.Node
+	swagger  bool
+	files    map[string]*yaml.Node // 已加载的文件
+	refs     map[string]string     // 外部引用 -> 合并后的内部引用
+	names    map[string]string     // 已使用的组件名称（类型/名称） -> 外部引用
+	inlining map[string]bool       // 正在内联的引用，用于检测循环引用
+	depth    map[string]int        // 文件的引用层级
+}
+
+// 加载并解析文件
+func (b *openApiBundler) loadFile(file string) (*yaml.Node, error) {
+	if node, ok := b.files[file]; ok {
+		return node, nil
+	}
+	if len(b.files) >= openApiMaxFiles {
+		return nil, fmt.Errorf("引用的文件过多，最多%d个", openApiMaxFiles)
+	}
+	data, err := b.load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s：%w", file, err)
+	}
+	document := yaml.Node{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("%s解析失败：%w", file, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, fmt.Errorf("%s内容为空", file)
+	}
+	node := resolveAlias(document.Content[0])
+	b.files[file] = node
+	return node, nil
+}
+
+// 递归处理节点中的引用，keys为节点所在位置的字段路径
+func (b *openApiBundler) process(node *yaml.Node, file string, keys []string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if ref := nodeField(node, "$ref"); ref != nil && ref.Kind == yaml.ScalarNode {
+			return b.processRef(node, ref, file, keys)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			err := b.process(node.Content[i+1], file, append(keys[:len(keys):len(keys)], node.Content[i].Value))
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			err := b.process(item, file, append(keys[:len(keys):len(keys)], strconv.Itoa(i)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 处理单个引用：指向入口文件的改为内部引用，其他文件的放入组件或内联
+func (b *openApiBundler) processRef(node, ref *yaml.Node, file string, keys []string) error {
+	filePart, pointer, _ := strings.Cut(ref.Value, "#")
+	target := file
+	if filePart != "" {
+		target = openApiResolvePath(file, filePart)
+	}
+	if target == b.entry {
+		ref.Value = "#" + pointer
+		return nil
+	}
+	pointer, err := url.PathUnescape(pointer)
+	if err != nil {
+		return fmt.Errorf("无效的引用：%s", ref.Value)
+	}
+
+	// 首次引用的文件记录层级，超过限制时不再加载
+	if _, ok := b.files[target]; !ok {
+		if b.depth[file]+1 > openApiMaxDepth {
+			return fmt.Errorf("%s中的引用层级过深，最多%d层", file, openApiMaxDepth)
+		}
+		if _, ok := b.depth[target]; !ok {
+			b.depth[target] = b.depth[file] + 1
+		}
+	}
+
+	key := target + "#" + pointer
+	if local, ok := b.refs[key]; ok {
+		ref.Value = local
+		return nil
+	}
+	targetNode, err := b.resolve(target, pointer)
+	if err != nil {
+		return fmt.Errorf("%s中的引用%s无效：%w", file, ref.Value, err)
+	}
+
+	section, name := b.section(target, pointer, keys)
+	if section == "" {
+		// 内联，替换为引用的内容
+		if b.inlining[key] {
+			return fmt.Errorf("循环引用无法合并：%s", key)
+		}
+		b.inlining[key] = true
+		copied := openApiCopyNode(targetNode)
+		err = b.process(copied, target, keys)
+		delete(b.inlining, key)
+		if err != nil {
+			return err
+		}
+		*node = *copied
+		return nil
+	}
+
+	// 放入组件，先记录引用再处理内容，支持循环引用
+	name = b.uniqueName(section, name, key)
+	local := "#/components/" + section + "/" + pointerEscape(name)
+	componentKeys := []string{"components", section, name}
+	if b.swagger {
+		local = "#/" + section + "/" + pointerEscape(name)
+		componentKeys = []string{section, name}
+	}
+	b.refs[key] = local
+	ref.Value = local
+	copied := openApiCopyNode(targetNode)
+	err = b.process(copied, target, componentKeys)
+	if err != nil {
+		return err
+	}
+	b.addComponent(section, name, copied)
+	return nil
+}
+
+// 解析文件中的JSON Pointer
+func (b *openApiBundler) resolve(file, pointer string) (*yaml.Node, error) {
+	node, err := b.loadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if pointer == "" {
+		return node, nil
+	}
+	v := &openApiValidator{root: node}
+	result := v.resolveRef("#" + pointer)
+	if result == nil {
+		return nil, errors.New("位置不存在")
+	}
+	return result, nil
+}
+
+// 根据引用的位置判断组件类型和名称，返回空类型时内联
+func (b *openApiBundler) section(file, pointer string, keys []string) (string, string) {
+	unescape := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	if match := openApiComponentRefRegex.FindStringSubmatch(pointer); match != nil {
+		section := match[1]
+		if b.swagger {
+			section = map[string]string{"schemas": "definitions", "parameters": "parameters", "responses": "responses"}[section]
+		}
+		if section != "" {
+			return section, unescape(match[2])
+		}
+	}
+	if match := openApiSwaggerRefRegex.FindStringSubmatch(pointer); match != nil {
+		section := match[1]
+		if !b.swagger && section == "definitions" {
+			section = "schemas"
+		}
+		return section, unescape(match[2])
+	}
+
+	// 名称取引用位置的最后一段或文件名
+	name := ""
+	if index := strings.LastIndex(pointer, "/"); index >= 0 && index < len(pointer)-1 {
+		name = unescape(pointer[index+1:])
+	} else {
+		name = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+
+	// 按引用所在位置判断类型
+	n := len(keys)
+	parent := ""
+	if n >= 2 {
+		parent = keys[n-2]
+	}
+	switch {
+	case n == 2 && keys[0] == "paths", n == 0:
+		return "", name
+	case parent == "parameters" && n >= 2 && !keysEndWith(keys[:n-2], "properties"):
+		return "parameters", name
+	case parent == "responses" && !keysEndWith(keys[:n-2], "properties"):
+		return "responses", name
+	case b.swagger:
+		return "definitions", name
+	case n >= 1 && keys[n-1] == "requestBody":
+		return "requestBodies", name
+	case parent == "headers", parent == "examples", parent == "links", parent == "callbacks", parent == "securitySchemes":
+		if keysEndWith(keys[:n-2], "properties") {
+			return "schemas", name
+		}
+		return parent, name
+	}
+	return "schemas", name
+}
+
+// 生成不重复的组件名称
+func (b *openApiBundler) uniqueName(section, name, key string) string {
+	name = strings.Trim(openApiNameRegex.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "Component"
+	}
+	result := name
+	for i := 2; ; i++ {
+		existing, ok := b.names[section+"/"+result]
+		if !ok || existing == key {
+			break
+		}
+		result = name + strconv.Itoa(i)
+	}
+	b.names[section+"/"+result] = key
+	return result
+}
+
+// 添加组件到入口文件
+func (b *openApiBundler) addComponent(section, name string, node *yaml.Node) {
+	parent := b.root
+	if !b.swagger {
+		parent = openApiEnsureMap(b.root, "components")
+	}
+	openApiSetField(openApiEnsureMap(parent, section), name, node)
+}
+
+// 字段路径是否以指定字段结尾
+func keysEndWith(keys []string, key string) bool {
+	return len(keys) > 0 && keys[len(keys)-1] == key
+}
+
+// 解析引用的文件路径，相对路径基于引用所在的文件
+func openApiResolvePath(base, ref string) string {
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	if u, err := url.Parse(base); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		r, err := url.Parse(ref)
+		if err == nil {
+			return u.ResolveReference(r).String()
+		}
+	}
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	return path.Join(path.Dir(base), ref)
+}
+
+// 深拷贝节点，别名展开为内容
+func openApiCopyNode(node *yaml.Node) *yaml.Node {
+	node = resolveAlias(node)
+	copied := *node
+	copied.Anchor = ""
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, item := range node.Content {
+		copied.Content[i] = openApiCopyNode(item)
+	}
+	return &copied
+}
+
+// 获取对象字段，不存在时在末尾添加空对象
+func openApiEnsureMap(node *yaml.Node, key string) *yaml.Node {
+	if value := nodeField(node, key); value != nil && value.Kind == yaml.MappingNode {
+		return value
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	openApiSetField(node, key, value)
+	return value
+}
+
+// 设置对象字段，已存在时替换
+func openApiSetField(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, openApiString(key), value)
+}
+
+// 删除对象字段，返回被删除的值
+func openApiDeleteField(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return resolveAlias(value)
+		}
+	}
+	return nil
+}
+
+// 获取对象节点的字段，不存在时返回nil
+func OpenApiField(node *yaml.Node, key string) *yaml.Node {
+	return nodeField(node, key)
+}
+
+// 字符串节点
+func openApiString(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// 将节点输出为文本，asJson为true时输出缩进的JSON，否则输出YAML
+func OpenApiNodeString(node *yaml.Node, asJson bool) (string, error) {
+	if asJson {
+		buffer := bytes.Buffer{}
+		err := openApiWriteJson(&buffer, node, "")
+		return buffer.String(), err
+	}
+	openApiResetStyle(node)
+	buffer := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(node)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Close()
+	return buffer.String(), err
+}
+
+// 清除JSON等来源的流式、引号样式，多行文本使用块样式
+func openApiResetStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && strings.Contains(strings.TrimRight(node.Value, "\n"), "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+	for _, item := range node.Content {
+		openApiResetStyle(item)
+	}
+}
+
+// 按原顺序输出JSON
+func openApiWriteJson(buffer *bytes.Buffer, node *yaml.Node, indent string) error {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buffer.WriteString("null")
+			return nil
+		}
+		return openApiWriteJson(buffer, node.Content[0], indent)
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, end, step := "{", "}", 2
+		if node.Kind == yaml.SequenceNode {
+			open, end, step = "[", "]", 1
+		}
+		if len(node.Content) == 0 {
+			buffer.WriteString(open + end)
+			return nil
+		}
+		buffer.WriteString(open + "\n")
+		for i := 0; i < len(node.Content); i += step {
+			buffer.WriteString(indent + "  ")
+			if step == 2 {
+				key, _ := json.Marshal(node.Content[i].Value)
+				buffer.Write(key)
+				buffer.WriteString(": ")
+			}
+			err := openApiWriteJson(buffer, node.Content[i+step-1], indent+"  ")
+			if err != nil {
+				return err
+			}
+			if i+step < len(node.Content) {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(indent + end)
+	case yaml.ScalarNode:
+		var value interface{}
+		if node.Tag == "!!str" || node.Tag == "!!binary" || node.Tag == "!!timestamp" {
+			value = node.Value
+		} else if err := node.Decode(&value); err != nil {
+			value = node.Value
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			data, _ = json.Marshal(node.Value)
+		}
+		buffer.Write(data)
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+package util
+
+import (
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Swagger 2.0中移入OpenAPI 3参数schema的字段
+var swaggerSchemaFields = []string{"type", "format", "items", "default", "maximum", "exclusiveMaximum", "minimum", "exclusiveMinimum", "maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "enum", "multipleOf"}
+
+// 将Swagger 2.0文档转换为OpenAPI 3.0，直接修改传入的节点
+func ConvertSwagger2(root *yaml.Node) error {
+	c := &swaggerConverter{root: root, bodyParameters: map[string]bool{}}
+	return c.convert()
+}
+
+// Swagger 2.0转换器
+type swaggerConverter struct {
+	root           *yaml.Node
+	consumes       []string        // 全局请求类型
+	produces       []string        // 全局响应类型
+	bodyParameters map[string]bool // 全局参数中的body、formData参数，转换为requestBodies
+}
+
+func (c *swaggerConverter) convert() error {
+	root := c.root
+	c.consumes = nodeStrings(openApiDeleteField(root, "consumes"))
+	c.produces = nodeStrings(openApiDeleteField(root, "produces"))
+	result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	openApiSetField(result, "openapi", openApiString("3.0.3"))
+	openApiDeleteField(root, "swagger")
+
+	// host、basePath、schemes转换为servers
+	host := openApiDeleteField(root, "host")
+	basePath := openApiDeleteField(root, "basePath")
+	schemes := nodeStrings(openApiDeleteField(root, "schemes"))
+	if host != nil || basePath != nil {
+		base := ""
+		if basePath != nil {
+			base = strings.TrimSuffix(basePath.Value, "/")
+		}
+		servers := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if host == nil {
+			servers.Content = append(servers.Content, openApiObject("url", openApiString(base+"/")))
+		} else {
+			if len(schemes) == 0 {
+				schemes = []string{"https"}
+			}
+			for _, scheme := range schemes {
+				servers.Content = append(servers.Content, openApiObject("url", openApiString(scheme+"://"+host.Value+base)))
+			}
+		}
+		c.moveField(root, "info", result)
+		openApiSetField(result, "servers", servers)
+	}
+
+	// 组件
+	components := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if definitions := openApiDeleteField(root, "definitions"); definitions != nil {
+		nodeEach(definitions, func(_, schema *yaml.Node) {
+			c.convertSchema(schema)
+		})
+		openApiSetField(components, "schemas", definitions)
+	}
+	if responses := openApiDeleteField(root, "responses"); responses != nil {
+		nodeEach(responses, func(_, response *yaml.Node) {
+			c.convertResponse(response, c.produces)
+		})
+		openApiSetField(components, "responses", responses)
+	}
+	if parameters := openApiDeleteField(root, "parameters"); parameters != nil {
+		converted := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		requestBodies := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		nodeEach(parameters, func(key, parameter *yaml.Node) {
+			in := nodeField(parameter, "in")
+			if in != nil && (in.Value == "body" || in.Value == "formData") {
+				c.bodyParameters[key.Value] = true
+				openApiSetField(requestBodies, key.Value, c.requestBody([]*yaml.Node{parameter}, c.consumes))
+				return
+			}
+			c.convertParameter(parameter)
+			openApiSetField(converted, key.Value, parameter)
+		})
+		if len(converted.Content) > 0 {
+			openApiSetField(components, "parameters", converted)
+		}
+		if len(requestBodies.Content) > 0 {
+			openApiSetField(components, "requestBodies", requestBodies)
+		}
+	}
+	if definitions := openApiDeleteField(root, "securityDefinitions"); definitions != nil {
+		nodeEach(definitions, func(_, scheme *yaml.Node) {
+			c.convertSecurityScheme(scheme)
+		})
+		openApiSetField(components, "securitySchemes", definitions)
+	}
+
+	// 路径
+	if paths := nodeField(root, "paths"); paths != nil {
+		nodeEach(paths, func(_, item *yaml.Node) {
+			c.convertPathItem(item)
+		})
+	}
+
+	// 按OpenAPI 3的常用顺序排列，其余字段保持原顺序
+	for _, key := range []string{"info", "tags", "paths"} {
+		c.moveField(root, key, result)
+	}
+	if len(components.Content) > 0 {
+		openApiSetField(result, "components", components)
+	}
+	result.Content = append(result.Content, root.Content...)
+	*root = *result
+
+	c.rewriteRefs(root)
+	return nil
+}
+
+// 转换路径下的参数和操作
+func (c *swaggerConverter) convertPathItem(item *yaml.Node) {
+	if item.Kind != yaml.MappingNode {
+		return
+	}
+	// 路径中的body、formData参数移入每个操作
+	var pathBodies []*yaml.Node
+	if parameters := nodeField(item, "parameters"); parameters != nil {
+		pathBodies = c.convertParameters(parameters)
+		if len(parameters.Content) == 0 {
+			openApiDeleteField(item, "parameters")
+		}
+	}
+	for _, method := range OpenApiMethods {
+		operation := nodeField(item, method)
+		if operation == nil || operation.Kind != yaml.MappingNode {
+			continue
+		}
+		consumes, produces := c.consumes, c.produces
+		if value := openApiDeleteField(operation, "consumes"); value != nil {
+			consumes = nodeStrings(value)
+		}
+		if value := openApiDeleteField(operation, "produces"); value != nil {
+			produces = nodeStrings(value)
+		}
+		openApiDeleteField(operation, "schemes")
+
+		bodies := pathBodies
+		if parameters := nodeField(operation, "parameters"); parameters != nil {
+			bodies = append(slices.Clone(bodies), c.convertParameters(parameters)...)
+			if len(parameters.Content) == 0 {
+				openApiDeleteField(operation, "parameters")
+			}
+		}
+		if len(bodies) > 0 {
+			body := c.requestBody(bodies, consumes)
+			// 放在responses之前
+			responses := openApiDeleteField(operation, "responses")
+			openApiSetField(operation, "requestBody", body)
+			if responses != nil {
+				openApiSetField(operation, "responses", responses)
+			}
+		}
+		nodeEach(nodeField(operation, "responses"), func(_, response *yaml.Node) {
+			c.convertResponse(response, produces)
+		})
+	}
+}
+
+// 转换参数列表，移除并返回body、formData参数
+func (c *swaggerConverter) convertParameters(parameters *yaml.Node) []*yaml.Node {
+	if parameters.Kind != yaml.SequenceNode {
+		return nil
+	}
+	bodies := []*yaml.Node{}
+	content := []*yaml.Node{}
+	for _, parameter := range parameters.Content {
+		parameter = resolveAlias(parameter)
+		if ref := nodeField(parameter, "$ref"); ref != nil {
+			name := strings.TrimPrefix(ref.Value, "#/parameters/")
+			if name != ref.Value && c.bodyParameters[name] {
+				bodies = append(bodies, parameter)
+				continue
+			}
+			content = append(content, parameter)
+			continue
+		}
+		in := nodeField(parameter, "in")
+		if in != nil && (in.Value == "body" || in.Value == "formData") {
+			bodies = append(bodies, parameter)
+			continue
+		}
+		c.convertParameter(parameter)
+		content = append(content, parameter)
+	}
+	parameters.Content = content
+	return bodies
+}
+
+// 转换普通参数：类型等字段移入schema，collectionFormat转换为style、explode
+func (c *swaggerConverter) convertParameter(parameter *yaml.Node) {
+	if parameter.Kind != yaml.MappingNode || nodeField(parameter, "$ref") != nil {
+		return
+	}
+	format := openApiDeleteField(parameter, "collectionFormat")
+	schema := c.extractSchema(parameter)
+	if format != nil {
+		in := nodeField(parameter, "in")
+		switch format.Value {
+		case "csv":
+			if in != nil && (in.Value == "query" || in.Value == "cookie") {
+				openApiSetField(parameter, "style", openApiString("form"))
+				openApiSetField(parameter, "explode", openApiBool(false))
+			} else {
+				openApiSetField(parameter, "style", openApiString("simple"))
+			}
+		case "ssv":
+			openApiSetField(parameter, "style", openApiString("spaceDelimited"))
+		case "pipes":
+			openApiSetField(parameter, "style", openApiString("pipeDelimited"))
+		case "multi":
+			openApiSetField(parameter, "style", openApiString("form"))
+			openApiSetField(parameter, "explode", openApiBool(true))
+		}
+	}
+	openApiSetField(parameter, "schema", schema)
+}
+
+// 将参数或响应头中的类型字段移入新的schema
+func (c *swaggerConverter) extractSchema(node *yaml.Node) *yaml.Node {
+	schema := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, key := range swaggerSchemaFields {
+		if value := openApiDeleteField(node, key); value != nil {
+			openApiSetField(schema, key, value)
+		}
+	}
+	openApiDeleteField(node, "collectionFormat")
+	openApiDeleteField(node, "allowEmptyValue")
+	c.convertSchema(schema)
+	return schema
+}
+
+// 将body、formData参数转换为requestBody
+func (c *swaggerConverter) requestBody(parameters []*yaml.Node, consumes []string) *yaml.Node {
+	result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	form := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	formRequired := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	file := false
+	for _, parameter := range parameters {
+		if ref := nodeField(parameter, "$ref"); ref != nil {
+			return openApiObject("$ref", openApiString("#/components/requestBodies/"+strings.TrimPrefix(ref.Value, "#/parameters/")))
+		}
+		if in := nodeField(parameter, "in"); in != nil && in.Value == "body" {
+			if description := nodeField(parameter, "description"); description != nil {
+				openApiSetField(result, "description", description)
+			}
+			schema := nodeField(parameter, "schema")
+			if schema == nil {
+				schema = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			c.convertSchema(schema)
+			types := consumes
+			if len(types) == 0 {
+				types = []string{"application/json"}
+			}
+			content := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for _, t := range types {
+				openApiSetField(content, t, openApiObject("schema", openApiCopyNode(schema)))
+			}
+			openApiSetField(result, "content", content)
+			if required := nodeField(parameter, "required"); required != nil {
+				openApiSetField(result, "required", required)
+			}
+			return result
+		}
+
+		// formData参数合并为一个对象
+		name := nodeField(parameter, "name")
+		if name == nil {
+			continue
+		}
+		if t := nodeField(parameter, "type"); t != nil && t.Value == "file" {
+			file = true
+		}
+		property := c.extractSchema(parameter)
+		if description := nodeField(parameter, "description"); description != nil {
+			openApiSetField(property, "description", description)
+		}
+		openApiSetField(form, name.Value, property)
+		if required := nodeField(parameter, "required"); required != nil && required.Value == "true" {
+			formRequired.Content = append(formRequired.Content, openApiString(name.Value))
+		}
+	}
+
+	schema := openApiObject("type", openApiString("object"))
+	openApiSetField(schema, "properties", form)
+	if len(formRequired.Content) > 0 {
+		openApiSetField(schema, "required", formRequired)
+	}
+	mediaType := "application/x-www-form-urlencoded"
+	if file || slices.Contains(consumes, "multipart/form-data") {
+		mediaType = "multipart/form-data"
+	}
+	openApiSetField(result, "content", openApiObject(mediaType, openApiObject("schema", schema)))
+	return result
+}
+
+// 转换响应：schema、examples移入content，响应头的类型移入schema
+func (c *swaggerConverter) convertResponse(response *yaml.Node, produces []string) {
+	if response.Kind != yaml.MappingNode || nodeField(response, "$ref") != nil {
+		return
+	}
+	if nodeField(response, "description") == nil {
+		openApiSetField(response, "description", openApiString(""))
+	}
+	schema := openApiDeleteField(response, "schema")
+	examples := openApiDeleteField(response, "examples")
+	if schema != nil {
+		c.convertSchema(schema)
+		types := produces
+		if len(types) == 0 {
+			types = []string{"application/json"}
+		}
+		content := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, t := range types {
+			mediaType := openApiObject("schema", openApiCopyNode(schema))
+			if example := nodeField(examples, t); example != nil {
+				openApiSetField(mediaType, "example", example)
+			}
+			openApiSetField(content, t, mediaType)
+		}
+		openApiSetField(response, "content", content)
+	}
+	nodeEach(nodeField(response, "headers"), func(_, header *yaml.Node) {
+		if header.Kind == yaml.MappingNode && nodeField(header, "$ref") == nil {
+			openApiSetField(header, "schema", c.extractSchema(header))
+		}
+	})
+}
+
+// 转换安全方案
+func (c *swaggerConverter) convertSecurityScheme(scheme *yaml.Node) {
+	t := nodeField(scheme, "type")
+	if t == nil {
+		return
+	}
+	switch t.Value {
+	case "basic":
+		t.Value = "http"
+		openApiSetField(scheme, "scheme", openApiString("basic"))
+	case "oauth2":
+		flow := openApiDeleteField(scheme, "flow")
+		authorizationUrl := openApiDeleteField(scheme, "authorizationUrl")
+		tokenUrl := openApiDeleteField(scheme, "tokenUrl")
+		scopes := openApiDeleteField(scheme, "scopes")
+		if flow == nil {
+			return
+		}
+		name := map[string]string{"implicit": "implicit", "password": "password", "application": "clientCredentials", "accessCode": "authorizationCode"}[flow.Value]
+		if name == "" {
+			return
+		}
+		value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if authorizationUrl != nil {
+			openApiSetField(value, "authorizationUrl", authorizationUrl)
+		}
+		if tokenUrl != nil {
+			openApiSetField(value, "tokenUrl", tokenUrl)
+		}
+		if scopes == nil {
+			scopes = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		openApiSetField(value, "scopes", scopes)
+		openApiSetField(scheme, "flows", openApiObject(name, value))
+	}
+}
+
+// 递归转换schema：file类型、x-nullable、字符串形式的discriminator
+func (c *swaggerConverter) convertSchema(schema *yaml.Node) {
+	schema = resolveAlias(schema)
+	switch schema.Kind {
+	case yaml.MappingNode:
+		if t := nodeField(schema, "type"); t != nil && t.Value == "file" {
+			t.Value = "string"
+			openApiSetField(schema, "format", openApiString("binary"))
+		}
+		if nullable := openApiDeleteField(schema, "x-nullable"); nullable != nil {
+			openApiSetField(schema, "nullable", nullable)
+		}
+		if discriminator := nodeField(schema, "discriminator"); discriminator != nil && discriminator.Kind == yaml.ScalarNode {
+			openApiSetField(schema, "discriminator", openApiObject("propertyName", openApiString(discriminator.Value)))
+		}
+		for i := 0; i+1 < len(schema.Content); i += 2 {
+			// 示例、默认值等不是schema
+			switch schema.Content[i].Value {
+			case "example", "default", "enum", "x-example":
+				continue
+			}
+			c.convertSchema(schema.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for _, item := range schema.Content {
+			c.convertSchema(item)
+		}
+	}
+}
+
+// 引用改为OpenAPI 3的组件路径
+func (c *swaggerConverter) rewriteRefs(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if node.Content[i].Value == "$ref" && value.Kind == yaml.ScalarNode {
+				for _, prefix := range [][2]string{{"#/definitions/", "#/components/schemas/"}, {"#/parameters/", "#/components/parameters/"}, {"#/responses/", "#/components/responses/"}} {
+					if strings.HasPrefix(value.Value, prefix[0]) {
+						value.Value = prefix[1] + strings.TrimPrefix(value.Value, prefix[0])
+					}
+				}
+				continue
+			}
+			c.rewriteRefs(value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			c.rewriteRefs(item)
+		}
+	}
+}
+
+// 将字段移动到新对象末尾
+func (c *swaggerConverter) moveField(from *yaml.Node, key string, to *yaml.Node) {
+	if value := openApiDeleteField(from, key); value != nil {
+		openApiSetField(to, key, value)
+	}
+}
+
+// 字符串数组节点的值
+func nodeStrings(node *yaml.Node) []string {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	result := []string{}
+	for _, item := range node.Content {
+		result = append(result, item.Value)
+	}
+	return result
+}
+
+// 只有一个字段的对象节点
+func openApiObject(key string, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{openApiString(key), value}}
+}
+
+// 布尔值节点
+func openApiBool(value bool) *yaml.Node {
+	if value {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+}
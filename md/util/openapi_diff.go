@@ -0,0 +1,800 @@
+// OpenAPI文档版本比较：按接口比较参数、请求体、响应，区分不兼容的变更
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 变更级别
+const (
+	OpenApiBreaking   = "breaking"   // 不兼容，已有的调用方可能出错
+	OpenApiCompatible = "compatible" // 兼容的变更
+	OpenApiInfo       = "info"       // 不影响调用的变更，如标记为废弃
+)
+
+var openApiPathParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// 比较结果
+type OpenApiDiffResult struct {
+	Breaking        bool            `json:"breaking"` // 是否存在不兼容的变更
+	BreakingCount   int             `json:"breakingCount"`
+	CompatibleCount int             `json:"compatibleCount"`
+	InfoCount       int             `json:"infoCount"`
+	Changes         []OpenApiChange `json:"changes"`
+	Markdown        string          `json:"markdown"` // Markdown格式的变更日志
+}
+
+// 一处变更
+type OpenApiChange struct {
+	Level    string `json:"level"`
+	Type     string `json:"type"`               // 变更类型，如 endpoint-removed、request-parameter-required-added
+	Method   string `json:"method,omitempty"`   // 所在接口的请求方法
+	Path     string `json:"path,omitempty"`     // 所在接口的路径（新版本中的路径）
+	Location string `json:"location,omitempty"` // 接口中的位置，如 query.limit、request.body.name、response.200.body.items[].id
+	Message  string `json:"message"`
+}
+
+// 比较两个版本的OpenAPI文档（JSON或YAML），Swagger 2.0文档转换为OpenAPI 3后比较
+func DiffOpenApi(oldContent, newContent string) (OpenApiDiffResult, error) {
+	oldSpec, err := parseOpenApi3(oldContent)
+	if err != nil {
+		return OpenApiDiffResult{}, fmt.Errorf("旧版本解析失败：%w", err)
+	}
+	newSpec, err := parseOpenApi3(newContent)
+	if err != nil {
+		return OpenApiDiffResult{}, fmt.Errorf("新版本解析失败：%w", err)
+	}
+
+	d := &openApiDiffer{oldSpec: oldSpec, newSpec: newSpec}
+	d.compare()
+
+	result := OpenApiDiffResult{Changes: d.changes}
+	if result.Changes == nil {
+		result.Changes = []OpenApiChange{}
+	}
+	for _, v := range result.Changes {
+		switch v.Level {
+		case OpenApiBreaking:
+			result.BreakingCount++
+		case OpenApiCompatible:
+			result.CompatibleCount++
+		default:
+			result.InfoCount++
+		}
+	}
+	result.Breaking = result.BreakingCount > 0
+	result.Markdown = openApiChangelog(oldSpec, newSpec, result)
+	return result, nil
+}
+
+// 文档比较器
+type openApiDiffer struct {
+	oldSpec, newSpec map[string]interface{}
+	changes          []OpenApiChange
+	method, path     string          // 正在比较的接口
+	visited          map[string]bool // 已比较的引用对，防止循环引用
+}
+
+// 接口
+type openApiEndpoint struct {
+	path       string
+	method     string
+	item       map[string]interface{}
+	operation  map[string]interface{}
+	pathParams []string // 路径参数名，按出现顺序
+}
+
+// 记录变更
+func (d *openApiDiffer) add(level, changeType, location, format string, args ...interface{}) {
+	d.changes = append(d.changes, OpenApiChange{
+		Level:    level,
+		Type:     changeType,
+		Method:   strings.ToUpper(d.method),
+		Path:     d.path,
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// 记录变更，breaking为true时不兼容，否则兼容
+func (d *openApiDiffer) addChange(breaking bool, changeType, location, format string, args ...interface{}) {
+	level := OpenApiCompatible
+	if breaking {
+		level = OpenApiBreaking
+	}
+	d.add(level, changeType, location, format, args...)
+}
+
+// 文档中的接口，key为请求方法和去掉参数名的路径，路径参数改名不视为删除接口
+func openApiEndpoints(spec map[string]interface{}) map[string]openApiEndpoint {
+	result := map[string]openApiEndpoint{}
+	paths := MapValue(spec, "paths")
+	for path := range paths {
+		item := Deref(spec, MapValue(paths, path))
+		for _, method := range OpenApiMethods {
+			operation := MapValue(item, method)
+			if operation == nil {
+				continue
+			}
+			var params []string
+			for _, v := range openApiPathParam.FindAllString(path, -1) {
+				params = append(params, strings.Trim(v, "{}"))
+			}
+			key := method + " " + openApiPathParam.ReplaceAllString(path, "{}")
+			result[key] = openApiEndpoint{path: path, method: method, item: item, operation: operation, pathParams: params}
+		}
+	}
+	return result
+}
+
+// 按路径、请求方法排序的接口key
+func openApiEndpointKeys(endpoints ...map[string]openApiEndpoint) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range endpoints {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	methodIndex := func(method string) int {
+		for i, v := range OpenApiMethods {
+			if v == method {
+				return i
+			}
+		}
+		return len(OpenApiMethods)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		mi, pi, _ := strings.Cut(keys[i], " ")
+		mj, pj, _ := strings.Cut(keys[j], " ")
+		if pi != pj {
+			return pi < pj
+		}
+		return methodIndex(mi) < methodIndex(mj)
+	})
+	return keys
+}
+
+func (d *openApiDiffer) compare() {
+	oldVersion := StringValue(MapValue(d.oldSpec, "info"), "version")
+	newVersion := StringValue(MapValue(d.newSpec, "info"), "version")
+	if oldVersion != newVersion {
+		d.add(OpenApiInfo, "version-changed", "", "文档版本由%s变为%s", openApiQuote(oldVersion), openApiQuote(newVersion))
+	}
+
+	oldEndpoints := openApiEndpoints(d.oldSpec)
+	newEndpoints := openApiEndpoints(d.newSpec)
+	for _, key := range openApiEndpointKeys(oldEndpoints, newEndpoints) {
+		oldEndpoint, hasOld := oldEndpoints[key]
+		newEndpoint, hasNew := newEndpoints[key]
+		d.visited = map[string]bool{}
+		switch {
+		case !hasNew:
+			d.method, d.path = oldEndpoint.method, oldEndpoint.path
+			d.add(OpenApiBreaking, "endpoint-removed", "", "删除了接口")
+		case !hasOld:
+			d.method, d.path = newEndpoint.method, newEndpoint.path
+			d.add(OpenApiCompatible, "endpoint-added", "", "新增了接口")
+		default:
+			d.method, d.path = newEndpoint.method, newEndpoint.path
+			d.endpoint(oldEndpoint, newEndpoint)
+		}
+	}
+}
+
+// 比较同一接口的两个版本
+func (d *openApiDiffer) endpoint(oldEndpoint, newEndpoint openApiEndpoint) {
+	oldOperation, newOperation := oldEndpoint.operation, newEndpoint.operation
+	if oldEndpoint.path != newEndpoint.path {
+		d.add(OpenApiInfo, "path-parameter-renamed", "", "路径由%s变为%s", oldEndpoint.path, newEndpoint.path)
+	}
+	if !BoolValue(oldOperation, "deprecated") && BoolValue(newOperation, "deprecated") {
+		d.add(OpenApiInfo, "endpoint-deprecated", "", "接口标记为废弃")
+	}
+	if oldId, newId := StringValue(oldOperation, "operationId"), StringValue(newOperation, "operationId"); oldId != "" && oldId != newId {
+		d.add(OpenApiInfo, "operation-id-changed", "", "operationId由%s变为%s", openApiQuote(oldId), openApiQuote(newId))
+	}
+
+	d.parameters(oldEndpoint, newEndpoint)
+	d.requestBody(Deref(d.oldSpec, MapValue(oldOperation, "requestBody")), Deref(d.newSpec, MapValue(newOperation, "requestBody")))
+	d.responses(MapValue(oldOperation, "responses"), MapValue(newOperation, "responses"))
+	d.security(oldOperation, newOperation)
+}
+
+// 接口的参数（路径上的参数和接口参数），key为位置和名称，路径参数按新版本中同一位置的名称
+func (d *openApiDiffer) endpointParameters(spec map[string]interface{}, endpoint openApiEndpoint, rename map[string]string) (map[string]map[string]interface{}, []string) {
+	result := map[string]map[string]interface{}{}
+	var keys []string
+	for _, list := range [][]interface{}{ListValue(endpoint.item, "parameters"), ListValue(endpoint.operation, "parameters")} {
+		for _, v := range list {
+			parameter, _ := v.(map[string]interface{})
+			parameter = Deref(spec, parameter)
+			if parameter == nil {
+				continue
+			}
+			name, in := StringValue(parameter, "name"), StringValue(parameter, "in")
+			if in == "path" && rename[name] != "" {
+				name = rename[name]
+			}
+			if in == "header" {
+				name = strings.ToLower(name)
+			}
+			key := in + "." + name
+			if _, ok := result[key]; !ok {
+				keys = append(keys, key)
+			}
+			result[key] = parameter
+		}
+	}
+	return result, keys
+}
+
+func (d *openApiDiffer) parameters(oldEndpoint, newEndpoint openApiEndpoint) {
+	rename := map[string]string{}
+	for i, name := range oldEndpoint.pathParams {
+		if i < len(newEndpoint.pathParams) {
+			rename[name] = newEndpoint.pathParams[i]
+		}
+	}
+	oldParams, oldKeys := d.endpointParameters(d.oldSpec, oldEndpoint, rename)
+	newParams, newKeys := d.endpointParameters(d.newSpec, newEndpoint, nil)
+
+	for _, key := range oldKeys {
+		if _, ok := newParams[key]; !ok {
+			d.add(OpenApiCompatible, "request-parameter-removed", key, "删除了参数%s", key)
+		}
+	}
+	for _, key := range newKeys {
+		newParam := newParams[key]
+		required := BoolValue(newParam, "required") || StringValue(newParam, "in") == "path"
+		oldParam, ok := oldParams[key]
+		if !ok {
+			if required {
+				d.add(OpenApiBreaking, "request-parameter-required-added", key, "新增了必填参数%s", key)
+			} else {
+				d.add(OpenApiCompatible, "request-parameter-added", key, "新增了可选参数%s", key)
+			}
+			continue
+		}
+		oldRequired := BoolValue(oldParam, "required") || StringValue(oldParam, "in") == "path"
+		if !oldRequired && required {
+			d.add(OpenApiBreaking, "request-parameter-became-required", key, "参数%s改为必填", key)
+		} else if oldRequired && !required {
+			d.add(OpenApiCompatible, "request-parameter-became-optional", key, "参数%s改为可选", key)
+		}
+		d.schema(MapValue(oldParam, "schema"), MapValue(newParam, "schema"), key, false, 0)
+	}
+}
+
+func (d *openApiDiffer) requestBody(oldBody, newBody map[string]interface{}) {
+	const location = "request.body"
+	switch {
+	case oldBody == nil && newBody == nil:
+		return
+	case oldBody == nil:
+		if BoolValue(newBody, "required") {
+			d.add(OpenApiBreaking, "request-body-required-added", location, "新增了必填的请求体")
+		} else {
+			d.add(OpenApiCompatible, "request-body-added", location, "新增了可选的请求体")
+		}
+		return
+	case newBody == nil:
+		d.add(OpenApiCompatible, "request-body-removed", location, "删除了请求体")
+		return
+	}
+	if !BoolValue(oldBody, "required") && BoolValue(newBody, "required") {
+		d.add(OpenApiBreaking, "request-body-became-required", location, "请求体改为必填")
+	}
+	d.content(MapValue(oldBody, "content"), MapValue(newBody, "content"), location, false)
+}
+
+// 比较请求体或响应的内容，按媒体类型比较schema
+func (d *openApiDiffer) content(oldContent, newContent map[string]interface{}, location string, response bool) {
+	for _, mediaType := range openApiMockSortedKeys(oldContent) {
+		if _, ok := newContent[mediaType]; !ok {
+			if response {
+				d.add(OpenApiBreaking, "response-media-type-removed", location, "响应不再支持%s类型", mediaType)
+			} else {
+				d.add(OpenApiBreaking, "request-media-type-removed", location, "请求不再支持%s类型", mediaType)
+			}
+		}
+	}
+	for _, mediaType := range openApiMockSortedKeys(newContent) {
+		if _, ok := oldContent[mediaType]; !ok {
+			if response {
+				d.add(OpenApiCompatible, "response-media-type-added", location, "响应新增了%s类型", mediaType)
+			} else {
+				d.add(OpenApiCompatible, "request-media-type-added", location, "请求新增支持%s类型", mediaType)
+			}
+			continue
+		}
+		// 只有一种类型时省略类型名称
+		schemaLocation := location
+		if len(oldContent) > 1 || len(newContent) > 1 {
+			schemaLocation += "(" + mediaType + ")"
+		}
+		d.schema(MapValue(MapValue(oldContent, mediaType), "schema"), MapValue(MapValue(newContent, mediaType), "schema"), schemaLocation, response, 0)
+	}
+}
+
+func (d *openApiDiffer) responses(oldResponses, newResponses map[string]interface{}) {
+	for _, status := range openApiMockSortedKeys(oldResponses) {
+		if _, ok := newResponses[status]; !ok {
+			// 删除成功响应时调用方无法按原方式处理结果
+			success := strings.HasPrefix(status, "2") || status == "default"
+			d.addChange(success, "response-status-removed", "response."+status, "删除了状态码%s的响应", status)
+		}
+	}
+	for _, status := range openApiMockSortedKeys(newResponses) {
+		location := "response." + status
+		oldResponse := Deref(d.oldSpec, MapValue(oldResponses, status))
+		newResponse := Deref(d.newSpec, MapValue(newResponses, status))
+		if _, ok := oldResponses[status]; !ok {
+			d.add(OpenApiCompatible, "response-status-added", location, "新增了状态码%s的响应", status)
+			continue
+		}
+
+		oldHeaders, newHeaders := MapValue(oldResponse, "headers"), MapValue(newResponse, "headers")
+		for _, name := range openApiMockSortedKeys(oldHeaders) {
+			if _, ok := newHeaders[name]; !ok {
+				d.add(OpenApiBreaking, "response-header-removed", location+".header."+name, "删除了响应头%s", name)
+			}
+		}
+		for _, name := range openApiMockSortedKeys(newHeaders) {
+			if _, ok := oldHeaders[name]; !ok {
+				d.add(OpenApiCompatible, "response-header-added", location+".header."+name, "新增了响应头%s", name)
+			}
+		}
+
+		oldContent, newContent := MapValue(oldResponse, "content"), MapValue(newResponse, "content")
+		if len(oldContent) > 0 && len(newContent) == 0 {
+			d.add(OpenApiBreaking, "response-body-removed", location+".body", "删除了响应内容")
+			continue
+		}
+		d.content(oldContent, newContent, location+".body", true)
+	}
+}
+
+// 比较安全要求，接口未设置时使用全局的安全要求
+func (d *openApiDiffer) security(oldOperation, newOperation map[string]interface{}) {
+	effective := func(spec, operation map[string]interface{}) []interface{} {
+		if list, ok := operation["security"].([]interface{}); ok {
+			return list
+		}
+		return ListValue(spec, "security")
+	}
+	// 空数组或包含空对象时可匿名访问
+	anonymous := func(list []interface{}) bool {
+		for _, v := range list {
+			if requirement, _ := v.(map[string]interface{}); len(requirement) == 0 {
+				return true
+			}
+		}
+		return len(list) == 0
+	}
+	oldSecurity, newSecurity := effective(d.oldSpec, oldOperation), effective(d.newSpec, newOperation)
+	if openApiMockEqual(oldSecurity, newSecurity) {
+		return
+	}
+	switch {
+	case anonymous(oldSecurity) && !anonymous(newSecurity):
+		d.add(OpenApiBreaking, "security-added", "security", "接口改为需要认证")
+	case !anonymous(oldSecurity) && anonymous(newSecurity):
+		d.add(OpenApiCompatible, "security-removed", "security", "接口改为无需认证")
+	default:
+		d.add(OpenApiBreaking, "security-changed", "security", "认证方式由%s变为%s", openApiMockJoin(oldSecurity), openApiMockJoin(newSecurity))
+	}
+}
+
+// 解析引用并合并allOf中的字段
+func (d *openApiDiffer) flatten(spec, schema map[string]interface{}, depth int) map[string]interface{} {
+	schema = Deref(spec, schema)
+	list := ListValue(schema, "allOf")
+	if len(list) == 0 || depth > openApiMockMaxDepth {
+		return schema
+	}
+	result := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	var required []interface{}
+	merge := func(item map[string]interface{}) {
+		for key, value := range item {
+			switch key {
+			case "properties":
+				for name, property := range MapValue(item, "properties") {
+					properties[name] = property
+				}
+			case "required":
+				required = append(required, ListValue(item, "required")...)
+			case "allOf":
+			default:
+				result[key] = value
+			}
+		}
+	}
+	for _, v := range list {
+		item, _ := v.(map[string]interface{})
+		merge(d.flatten(spec, item, depth+1))
+	}
+	merge(schema)
+	if len(properties) > 0 {
+		result["properties"] = properties
+		if result["type"] == nil {
+			result["type"] = "object"
+		}
+	}
+	if len(required) > 0 {
+		result["required"] = required
+	}
+	return result
+}
+
+// schema的类型集合，nullable视为包含null
+func openApiSchemaTypes(schema map[string]interface{}) []string {
+	var types []string
+	if t := openApiMockType(schema); t != "" {
+		types = append(types, t)
+	}
+	for _, v := range ListValue(schema, "type") {
+		if t := fmt.Sprint(v); t != "null" && !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// 是否可为null
+func openApiNullable(schema map[string]interface{}) bool {
+	if BoolValue(schema, "nullable") {
+		return true
+	}
+	for _, v := range ListValue(schema, "type") {
+		if fmt.Sprint(v) == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// 比较schema，response为true时比较响应（调用方接收的数据，放宽是不兼容的），否则比较请求（调用方发送的数据，收紧是不兼容的）
+func (d *openApiDiffer) schema(oldSchema, newSchema map[string]interface{}, location string, response bool, depth int) {
+	if oldSchema == nil || newSchema == nil || depth > openApiMockMaxDepth {
+		return
+	}
+	oldRef, newRef := StringValue(oldSchema, "$ref"), StringValue(newSchema, "$ref")
+	if oldRef != "" || newRef != "" {
+		key := fmt.Sprintf("%s|%s|%v", oldRef, newRef, response)
+		if d.visited[key] {
+			return
+		}
+		d.visited[key] = true
+	}
+	oldSchema, newSchema = d.flatten(d.oldSpec, oldSchema, 0), d.flatten(d.newSpec, newSchema, 0)
+	if oldSchema == nil || newSchema == nil {
+		return
+	}
+	prefix, kind := openApiDirection(response)
+
+	// 类型：请求中integer改为number、响应中number改为integer兼容
+	oldTypes, newTypes := openApiSchemaTypes(oldSchema), openApiSchemaTypes(newSchema)
+	if len(oldTypes) > 0 && len(newTypes) > 0 && strings.Join(oldTypes, ",") != strings.Join(newTypes, ",") {
+		widened := true
+		for _, t := range oldTypes {
+			if !slices.Contains(newTypes, t) && !(t == "integer" && slices.Contains(newTypes, "number")) {
+				widened = false
+			}
+		}
+		narrowed := true
+		for _, t := range newTypes {
+			if !slices.Contains(oldTypes, t) && !(t == "integer" && slices.Contains(oldTypes, "number")) {
+				narrowed = false
+			}
+		}
+		breaking := (response && !narrowed) || (!response && !widened)
+		d.addChange(breaking, kind+"-type-changed", location, "%s字段%s的类型由%s变为%s", prefix, location, strings.Join(oldTypes, "|"), strings.Join(newTypes, "|"))
+		return
+	}
+
+	if oldNullable, newNullable := openApiNullable(oldSchema), openApiNullable(newSchema); oldNullable != newNullable {
+		if newNullable {
+			d.addChange(response, kind+"-became-nullable", location, "%s字段%s改为可为null", prefix, location)
+		} else {
+			d.addChange(!response, kind+"-became-not-nullable", location, "%s字段%s改为不可为null", prefix, location)
+		}
+	}
+
+	if oldFormat, newFormat := StringValue(oldSchema, "format"), StringValue(newSchema, "format"); oldFormat != newFormat {
+		breaking := (oldFormat != "" && newFormat != "") || (!response && newFormat != "") || (response && oldFormat != "")
+		d.addChange(breaking, kind+"-format-changed", location, "%s字段%s的格式由%s变为%s", prefix, location, openApiQuote(oldFormat), openApiQuote(newFormat))
+	}
+
+	d.enum(oldSchema, newSchema, location, response)
+	d.constraints(oldSchema, newSchema, location, response)
+
+	if oldPattern, newPattern := StringValue(oldSchema, "pattern"), StringValue(newSchema, "pattern"); oldPattern != newPattern {
+		breaking := (!response && newPattern != "") || (response && oldPattern != "")
+		d.addChange(breaking, kind+"-pattern-changed", location, "%s字段%s的正则表达式由%s变为%s", prefix, location, openApiQuote(oldPattern), openApiQuote(newPattern))
+	}
+
+	d.alternatives(oldSchema, newSchema, location, response)
+	d.properties(oldSchema, newSchema, location, response, depth)
+	if oldItems, newItems := MapValue(oldSchema, "items"), MapValue(newSchema, "items"); oldItems != nil && newItems != nil {
+		d.schema(oldItems, newItems, location+"[]", response, depth+1)
+	}
+}
+
+// 比较枚举值：请求中删除枚举值、响应中新增枚举值不兼容
+func (d *openApiDiffer) enum(oldSchema, newSchema map[string]interface{}, location string, response bool) {
+	oldEnum, newEnum := ListValue(oldSchema, "enum"), ListValue(newSchema, "enum")
+	prefix, kind := openApiDirection(response)
+	switch {
+	case len(oldEnum) == 0 && len(newEnum) == 0:
+		return
+	case len(oldEnum) == 0:
+		d.addChange(!response, kind+"-enum-added", location, "%s字段%s限制为以下值：%s", prefix, location, openApiMockJoin(newEnum))
+		return
+	case len(newEnum) == 0:
+		d.addChange(response, kind+"-enum-removed", location, "%s字段%s不再限制取值", prefix, location)
+		return
+	}
+	var removed, added []interface{}
+	for _, v := range oldEnum {
+		if !openApiContains(newEnum, v) {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range newEnum {
+		if !openApiContains(oldEnum, v) {
+			added = append(added, v)
+		}
+	}
+	if len(removed) > 0 {
+		d.addChange(!response, kind+"-enum-value-removed", location, "%s字段%s删除了取值：%s", prefix, location, openApiMockJoin(removed))
+	}
+	if len(added) > 0 {
+		d.addChange(response, kind+"-enum-value-added", location, "%s字段%s新增了取值：%s", prefix, location, openApiMockJoin(added))
+	}
+}
+
+// 比较长度、范围、数量等限制：请求中收紧、响应中放宽不兼容
+func (d *openApiDiffer) constraints(oldSchema, newSchema map[string]interface{}, location string, response bool) {
+	limits := []struct {
+		key   string
+		upper bool // 是否为上限
+		label string
+	}{
+		{"minLength", false, "最小长度"}, {"maxLength", true, "最大长度"},
+		{"minimum", false, "最小值"}, {"maximum", true, "最大值"},
+		{"exclusiveMinimum", false, "最小值（不含）"}, {"exclusiveMaximum", true, "最大值（不含）"},
+		{"minItems", false, "最少元素数量"}, {"maxItems", true, "最多元素数量"},
+		{"minProperties", false, "最少字段数量"}, {"maxProperties", true, "最多字段数量"},
+	}
+	prefix, kind := openApiDirection(response)
+	for _, limit := range limits {
+		oldValue, hasOld := openApiMockNumber(oldSchema, limit.key)
+		newValue, hasNew := openApiMockNumber(newSchema, limit.key)
+		if hasOld == hasNew && oldValue == newValue {
+			continue
+		}
+		var narrowed bool
+		switch {
+		case !hasOld:
+			narrowed = true
+		case !hasNew:
+			narrowed = false
+		case limit.upper:
+			narrowed = newValue < oldValue
+		default:
+			narrowed = newValue > oldValue
+		}
+		breaking := narrowed != response
+		d.addChange(breaking, kind+"-"+openApiKebab(limit.key)+"-changed", location, "%s字段%s的%s由%s变为%s", prefix, location, limit.label, openApiLimitText(oldValue, hasOld), openApiLimitText(newValue, hasNew))
+	}
+}
+
+// 比较oneOf、anyOf中的可选schema：请求中删除、响应中新增不兼容
+func (d *openApiDiffer) alternatives(oldSchema, newSchema map[string]interface{}, location string, response bool) {
+	prefix, kind := openApiDirection(response)
+	for _, key := range []string{"oneOf", "anyOf"} {
+		oldNames, newNames := openApiSchemaNames(ListValue(oldSchema, key)), openApiSchemaNames(ListValue(newSchema, key))
+		if len(oldNames) == 0 && len(newNames) == 0 {
+			continue
+		}
+		var removed, added []string
+		for _, v := range oldNames {
+			if !slices.Contains(newNames, v) {
+				removed = append(removed, v)
+			}
+		}
+		for _, v := range newNames {
+			if !slices.Contains(oldNames, v) {
+				added = append(added, v)
+			}
+		}
+		if len(removed) > 0 {
+			d.addChange(!response, kind+"-"+strings.ToLower(key)+"-removed", location, "%s字段%s的%s删除了%s", prefix, location, key, strings.Join(removed, "、"))
+		}
+		if len(added) > 0 {
+			d.addChange(response, kind+"-"+strings.ToLower(key)+"-added", location, "%s字段%s的%s新增了%s", prefix, location, key, strings.Join(added, "、"))
+		}
+	}
+}
+
+// 比较对象字段：请求中新增必填字段、响应中删除字段或字段改为可选不兼容，请求不比较只读字段，响应不比较只写字段
+func (d *openApiDiffer) properties(oldSchema, newSchema map[string]interface{}, location string, response bool, depth int) {
+	oldProperties, newProperties := MapValue(oldSchema, "properties"), MapValue(newSchema, "properties")
+	if oldProperties == nil && newProperties == nil {
+		return
+	}
+	prefix, kind := openApiDirection(response)
+	required := func(schema map[string]interface{}, name string) bool {
+		for _, v := range ListValue(schema, "required") {
+			if fmt.Sprint(v) == name {
+				return true
+			}
+		}
+		return false
+	}
+	ignored := func(spec, property map[string]interface{}) bool {
+		property = Deref(spec, property)
+		return (!response && BoolValue(property, "readOnly")) || (response && BoolValue(property, "writeOnly"))
+	}
+	join := func(name string) string {
+		if location == "" {
+			return name
+		}
+		return location + "." + name
+	}
+
+	for _, name := range openApiMockSortedKeys(oldProperties) {
+		if _, ok := newProperties[name]; ok || ignored(d.oldSpec, MapValue(oldProperties, name)) {
+			continue
+		}
+		if response {
+			d.add(OpenApiBreaking, "response-property-removed", join(name), "响应删除了字段%s", join(name))
+		} else {
+			// 不允许其他字段时，调用方继续发送此字段会被拒绝
+			closed := newSchema["additionalProperties"] == false
+			d.addChange(closed, "request-property-removed", join(name), "请求删除了字段%s", join(name))
+		}
+	}
+	for _, name := range openApiMockSortedKeys(newProperties) {
+		newProperty := MapValue(newProperties, name)
+		if ignored(d.newSpec, newProperty) {
+			continue
+		}
+		oldProperty, ok := oldProperties[name]
+		if !ok {
+			if !response && required(newSchema, name) {
+				d.add(OpenApiBreaking, "request-property-required-added", join(name), "请求新增了必填字段%s", join(name))
+			} else {
+				d.add(OpenApiCompatible, kind+"-property-added", join(name), "%s新增了字段%s", prefix, join(name))
+			}
+			continue
+		}
+		oldRequired, newRequired := required(oldSchema, name), required(newSchema, name)
+		if !oldRequired && newRequired {
+			d.addChange(!response, kind+"-property-became-required", join(name), "%s字段%s改为必填", prefix, join(name))
+		} else if oldRequired && !newRequired {
+			d.addChange(response, kind+"-property-became-optional", join(name), "%s字段%s改为可选", prefix, join(name))
+		}
+		oldMap, _ := oldProperty.(map[string]interface{})
+		d.schema(oldMap, newProperty, join(name), response, depth+1)
+	}
+}
+
+// 请求或响应的描述文字和变更类型前缀
+func openApiDirection(response bool) (string, string) {
+	if response {
+		return "响应", "response"
+	}
+	return "请求", "request"
+}
+
+// 可选schema的名称，引用取引用名称，否则取类型描述
+func openApiSchemaNames(list []interface{}) []string {
+	var names []string
+	for _, v := range list {
+		item, _ := v.(map[string]interface{})
+		if name := SchemaTypeName(item); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func openApiContains(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if openApiMockEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// 限制值的文本，未设置时为“无”
+func openApiLimitText(value float64, ok bool) string {
+	if !ok {
+		return "无"
+	}
+	return fmt.Sprint(value)
+}
+
+// 字段名转为短横线格式，如 maxLength 转为 max-length
+func openApiKebab(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			builder.WriteByte('-')
+			r += 'a' - 'A'
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// 加引号的文本，为空时为“无”
+func openApiQuote(s string) string {
+	if s == "" {
+		return "无"
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// 生成Markdown格式的变更日志，按级别分组
+func openApiChangelog(oldSpec, newSpec map[string]interface{}, result OpenApiDiffResult) string {
+	var builder strings.Builder
+	title := StringValue(MapValue(newSpec, "info"), "title")
+	if title == "" {
+		title = "API"
+	}
+	builder.WriteString("# " + title + " 变更日志\n\n")
+	oldVersion := StringValue(MapValue(oldSpec, "info"), "version")
+	newVersion := StringValue(MapValue(newSpec, "info"), "version")
+	if oldVersion != "" || newVersion != "" {
+		builder.WriteString(fmt.Sprintf("版本：%s → %s\n\n", openApiVersionText(oldVersion), openApiVersionText(newVersion)))
+	}
+	if len(result.Changes) == 0 {
+		builder.WriteString("没有接口变更\n")
+		return builder.String()
+	}
+	builder.WriteString(fmt.Sprintf("共 %d 处变更：不兼容 %d 处，兼容 %d 处，其他 %d 处\n", len(result.Changes), result.BreakingCount, result.CompatibleCount, result.InfoCount))
+
+	for _, group := range []struct {
+		level string
+		title string
+	}{{OpenApiBreaking, "不兼容的变更"}, {OpenApiCompatible, "兼容的变更"}, {OpenApiInfo, "其他变更"}} {
+		written := false
+		for _, v := range result.Changes {
+			if v.Level != group.level {
+				continue
+			}
+			if !written {
+				builder.WriteString("\n## " + group.title + "\n\n")
+				written = true
+			}
+			builder.WriteString("- ")
+			if v.Path != "" {
+				builder.WriteString("`" + v.Method + " " + v.Path + "`：")
+			}
+			builder.WriteString(v.Message + "\n")
+		}
+	}
+	return builder.String()
+}
+
+func openApiVersionText(version string) string {
+	if version == "" {
+		return "未知"
+	}
+	return version
+}
@@ -0,0 +1,1178 @@
+// OpenAPI模拟服务：按文档匹配请求路径和方法，校验请求参数和请求体，返回示例或按schema生成的响应
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	openApiMockMaxDepth = 32      // 生成、校验schema的最大嵌套层数，防止循环引用
+	openApiMockMaxItems = 10      // 生成数组的最大元素数量
+	openApiMockMaxNodes = 10000   // 生成一个响应的最大节点数，引用多次展开时防止耗时过长
+	openApiMockMaxBytes = 1 << 20 // 生成一个响应的字符串最大字节数
+)
+
+var openApiMockUuid = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// 响应头名称允许的字符
+var openApiMockHeaderName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// 不返回文档中定义的这些响应头：模拟接口与本站同域，不可设置Cookie、跳转、修改安全策略或传输方式
+var openApiMockBlockedHeaders = []string{
+	"content-type", "content-length", "content-encoding", "transfer-encoding", "connection",
+	"set-cookie", "location", "refresh", "clear-site-data", "service-worker-allowed",
+	"content-security-policy", "content-security-policy-report-only", "x-content-type-options",
+	"x-frame-options", "strict-transport-security", "access-control-allow-credentials",
+}
+
+// 模拟请求
+type OpenApiMockRequest struct {
+	Method string
+	Path   string // 去掉模拟接口前缀后的路径
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// 模拟响应
+type OpenApiMockResponse struct {
+	Status int
+	Header map[string]string
+	Body   []byte
+}
+
+// 请求校验问题
+type OpenApiMockIssue struct {
+	Location string `json:"location"` // 问题所在位置：path、query、header、cookie、body
+	Path     string `json:"path"`     // 字段位置，如 /items/0/name
+	Message  string `json:"message"`
+}
+
+// 模拟服务，由OpenAPI文档生成，可重复使用
+type OpenApiMock struct {
+	spec   map[string]interface{}
+	bases  []string // servers中的路径前缀，较长的在前
+	routes []openApiMockRoute
+}
+
+// 文档中的接口路径
+type openApiMockRoute struct {
+	path     string
+	pattern  *regexp.Regexp
+	names    []string // 路径参数名
+	literals int      // 不含参数的路径段数量，匹配多个路径时优先
+	item     map[string]interface{}
+}
+
+// 由OpenAPI文档（JSON或YAML）生成模拟服务，Swagger 2.0文档先转换为OpenAPI 3
+func NewOpenApiMock(content string) (*OpenApiMock, error) {
+	spec, err := parseOpenApi3(content)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &OpenApiMock{spec: spec}
+	for _, v := range ListValue(spec, "servers") {
+		server, _ := v.(map[string]interface{})
+		if base := openApiServerPath(server); base != "" && !slices.Contains(m.bases, base) {
+			m.bases = append(m.bases, base)
+		}
+	}
+	sort.Slice(m.bases, func(i, j int) bool { return len(m.bases[i]) > len(m.bases[j]) })
+
+	paths := MapValue(spec, "paths")
+	keys := make([]string, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		item := Deref(spec, MapValue(paths, key))
+		if item == nil || !strings.HasPrefix(key, "/") {
+			continue
+		}
+		m.routes = append(m.routes, openApiMockCompile(key, item))
+	}
+	return m, nil
+}
+
+// 服务地址中的路径部分，替换其中的变量为默认值
+func openApiServerPath(server map[string]interface{}) string {
+	address := StringValue(server, "url")
+	variables := MapValue(server, "variables")
+	for name := range variables {
+		address = strings.ReplaceAll(address, "{"+name+"}", StringValue(MapValue(variables, name), "default"))
+	}
+	if i := strings.Index(address, "://"); i >= 0 {
+		address = address[i+3:]
+		if j := strings.Index(address, "/"); j >= 0 {
+			address = address[j:]
+		} else {
+			address = ""
+		}
+	}
+	return strings.TrimRight(address, "/")
+}
+
+// 将路径模板（如 /pets/{id}）编译为正则表达式
+func openApiMockCompile(path string, item map[string]interface{}) openApiMockRoute {
+	route := openApiMockRoute{path: path, item: item}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		pattern.WriteString("/")
+		if !strings.Contains(segment, "{") {
+			route.literals++
+		}
+		for segment != "" {
+			start := strings.Index(segment, "{")
+			end := strings.Index(segment, "}")
+			if start < 0 || end < start {
+				pattern.WriteString(regexp.QuoteMeta(segment))
+				break
+			}
+			pattern.WriteString(regexp.QuoteMeta(segment[:start]))
+			pattern.WriteString("([^/]+)")
+			route.names = append(route.names, segment[start+1:end])
+			segment = segment[end+1:]
+		}
+	}
+	pattern.WriteString("/?$")
+	route.pattern = regexp.MustCompile(pattern.String())
+	return route
+}
+
+// 匹配请求路径，返回接口路径和路径参数，请求路径可包含servers中的路径前缀
+func (m *OpenApiMock) match(path string) (*openApiMockRoute, map[string]string) {
+	candidates := []string{path}
+	for _, base := range m.bases {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			candidates = append(candidates, path[len(base):])
+		}
+	}
+	var matched *openApiMockRoute
+	var params map[string]string
+	for _, candidate := range candidates {
+		if candidate == "" {
+			candidate = "/"
+		}
+		for i := range m.routes {
+			route := &m.routes[i]
+			values := route.pattern.FindStringSubmatch(candidate)
+			if values == nil || (matched != nil && route.literals <= matched.literals) {
+				continue
+			}
+			matched = route
+			params = map[string]string{}
+			for j, name := range route.names {
+				value, err := url.PathUnescape(values[j+1])
+				if err != nil {
+					value = values[j+1]
+				}
+				params[name] = value
+			}
+		}
+	}
+	return matched, params
+}
+
+// 处理模拟请求
+func (m *OpenApiMock) Serve(request OpenApiMockRequest) OpenApiMockResponse {
+	method := strings.ToLower(request.Method)
+	route, params := m.match(request.Path)
+	if route == nil {
+		return openApiMockError(http.StatusNotFound, fmt.Sprintf("文档中没有匹配的接口：%s %s", request.Method, request.Path), nil)
+	}
+	operation := MapValue(route.item, method)
+	if operation == nil && method == "head" {
+		operation = MapValue(route.item, "get")
+	}
+	if operation == nil {
+		var allowed []string
+		for _, v := range OpenApiMethods {
+			if MapValue(route.item, v) != nil {
+				allowed = append(allowed, strings.ToUpper(v))
+			}
+		}
+		response := openApiMockError(http.StatusMethodNotAllowed, fmt.Sprintf("接口%s不支持%s请求", route.path, request.Method), nil)
+		response.Header["Allow"] = strings.Join(allowed, ", ")
+		return response
+	}
+
+	// 校验请求
+	v := &openApiMockValidator{spec: m.spec, request: true}
+	m.checkParameters(v, route.item, operation, request, params)
+	if response, ok := m.checkBody(v, operation, request); !ok {
+		return response
+	}
+	if len(v.issues) > 0 {
+		return openApiMockError(http.StatusBadRequest, "请求校验失败", v.issues)
+	}
+
+	response := m.respond(operation, request)
+	if method == "head" {
+		response.Body = nil
+	}
+	return response
+}
+
+// 校验路径、查询、请求头、Cookie参数
+func (m *OpenApiMock) checkParameters(v *openApiMockValidator, item, operation map[string]interface{}, request OpenApiMockRequest, params map[string]string) {
+	// 接口参数覆盖路径上的同名参数
+	parameters := map[string]map[string]interface{}{}
+	var keys []string
+	for _, list := range [][]interface{}{ListValue(item, "parameters"), ListValue(operation, "parameters")} {
+		for _, p := range list {
+			parameter, _ := p.(map[string]interface{})
+			parameter = Deref(m.spec, parameter)
+			if parameter == nil {
+				continue
+			}
+			key := StringValue(parameter, "in") + ":" + StringValue(parameter, "name")
+			if _, ok := parameters[key]; !ok {
+				keys = append(keys, key)
+			}
+			parameters[key] = parameter
+		}
+	}
+
+	cookies := (&http.Request{Header: request.Header}).Cookies()
+	for _, key := range keys {
+		parameter := parameters[key]
+		name, in := StringValue(parameter, "name"), StringValue(parameter, "in")
+		schema := MapValue(parameter, "schema")
+		var values []string
+		switch in {
+		case "path":
+			if value, ok := params[name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = request.Query[name]
+		case "header":
+			// 这些请求头由Content-Type、Accept、认证方式描述，不作为参数校验
+			switch strings.ToLower(name) {
+			case "accept", "content-type", "authorization":
+				continue
+			}
+			values = request.Header.Values(name)
+		case "cookie":
+			for _, cookie := range cookies {
+				if cookie.Name == name {
+					values = append(values, cookie.Value)
+				}
+			}
+		default:
+			continue
+		}
+		v.location = in
+		if len(values) == 0 {
+			if in == "path" || BoolValue(parameter, "required") {
+				v.add("/"+name, "缺少必填参数%s", name)
+			}
+			continue
+		}
+		if schema == nil {
+			continue
+		}
+		// 数组类型参数可重复传递或以逗号分隔
+		var value interface{} = values[0]
+		if openApiMockType(v.deref(schema)) == "array" {
+			var list []interface{}
+			for _, item := range values {
+				if len(values) == 1 && StringValue(parameter, "style") != "deepObject" {
+					for _, s := range strings.Split(item, ",") {
+						list = append(list, s)
+					}
+					continue
+				}
+				list = append(list, item)
+			}
+			value = list
+		}
+		v.coerce = true
+		v.check(value, schema, "/"+name, 0)
+		v.coerce = false
+	}
+}
+
+// 校验请求体，请求类型不支持时直接返回错误响应
+func (m *OpenApiMock) checkBody(v *openApiMockValidator, operation map[string]interface{}, request OpenApiMockRequest) (OpenApiMockResponse, bool) {
+	requestBody := Deref(m.spec, MapValue(operation, "requestBody"))
+	if requestBody == nil {
+		return OpenApiMockResponse{}, true
+	}
+	v.location = "body"
+	if len(request.Body) == 0 {
+		if BoolValue(requestBody, "required") {
+			v.add("", "缺少请求体")
+		}
+		return OpenApiMockResponse{}, true
+	}
+
+	content := MapValue(requestBody, "content")
+	if len(content) == 0 {
+		return OpenApiMockResponse{}, true
+	}
+	contentType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+	mediaType := ""
+	for _, key := range openApiMockSortedKeys(content) {
+		if openApiMediaMatch(key, contentType) && (mediaType == "" || !strings.Contains(key, "*")) {
+			mediaType = key
+		}
+	}
+	if mediaType == "" {
+		return openApiMockError(http.StatusUnsupportedMediaType, fmt.Sprintf("不支持的请求类型：%s，应为%s", contentType, strings.Join(openApiMockSortedKeys(content), "、")), nil), false
+	}
+	schema := MapValue(MapValue(content, mediaType), "schema")
+	if schema == nil {
+		return OpenApiMockResponse{}, true
+	}
+
+	switch {
+	case openApiMediaJson(contentType):
+		var value interface{}
+		if json.Unmarshal(request.Body, &value) != nil {
+			v.add("", "请求体不是有效的JSON")
+			return OpenApiMockResponse{}, true
+		}
+		v.check(value, schema, "", 0)
+	case contentType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(request.Body))
+		if err != nil {
+			v.add("", "请求体不是有效的表单")
+			return OpenApiMockResponse{}, true
+		}
+		value := map[string]interface{}{}
+		properties := MapValue(v.deref(schema), "properties")
+		for key, values := range form {
+			if len(values) == 1 && openApiMockType(v.deref(MapValue(properties, key))) != "array" {
+				value[key] = values[0]
+				continue
+			}
+			list := make([]interface{}, 0, len(values))
+			for _, item := range values {
+				list = append(list, item)
+			}
+			value[key] = list
+		}
+		v.coerce = true
+		v.check(value, schema, "", 0)
+		v.coerce = false
+	}
+	return OpenApiMockResponse{}, true
+}
+
+// 生成响应：通过Prefer请求头可指定状态码（code=404）、示例名称（example=name）或按schema生成（dynamic=true）
+func (m *OpenApiMock) respond(operation map[string]interface{}, request OpenApiMockRequest) OpenApiMockResponse {
+	prefer := map[string]string{}
+	for _, header := range request.Header.Values("Prefer") {
+		for _, item := range strings.Split(header, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+			prefer[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	responses := MapValue(operation, "responses")
+	key, status := openApiMockStatus(responses, prefer["code"])
+	if key == "" {
+		if prefer["code"] != "" {
+			return openApiMockError(http.StatusBadRequest, fmt.Sprintf("文档中没有状态码%s的响应", prefer["code"]), nil)
+		}
+		return OpenApiMockResponse{Status: http.StatusOK, Header: map[string]string{}}
+	}
+
+	response := Deref(m.spec, MapValue(responses, key))
+	result := OpenApiMockResponse{Status: status, Header: map[string]string{}}
+	headers := MapValue(response, "headers")
+	for _, name := range openApiMockSortedKeys(headers) {
+		if !openApiMockHeaderName.MatchString(name) || slices.Contains(openApiMockBlockedHeaders, strings.ToLower(name)) {
+			continue
+		}
+		header := Deref(m.spec, MapValue(headers, name))
+		value, ok := header["example"]
+		if !ok {
+			if value, ok = m.generate(MapValue(header, "schema")); !ok {
+				return openApiMockTooLarge()
+			}
+		}
+		if value != nil {
+			result.Header[name] = fmt.Sprint(value)
+		}
+	}
+
+	content := MapValue(response, "content")
+	if len(content) == 0 || status == http.StatusNoContent {
+		return result
+	}
+	mediaType := openApiMockMediaType(content, request.Header.Get("Accept"))
+	media := MapValue(content, mediaType)
+
+	// 依次使用指定的示例、示例、按schema生成
+	var value interface{}
+	found := false
+	examples := MapValue(media, "examples")
+	if name := prefer["example"]; name != "" {
+		example := Deref(m.spec, MapValue(examples, name))
+		if example == nil {
+			return openApiMockError(http.StatusBadRequest, fmt.Sprintf("文档中没有名为%s的示例", name), nil)
+		}
+		value, found = example["value"]
+	}
+	if !found && prefer["dynamic"] != "true" {
+		if value, found = media["example"]; !found {
+			for _, name := range openApiMockSortedKeys(examples) {
+				if value, found = Deref(m.spec, MapValue(examples, name))["value"]; found {
+					break
+				}
+			}
+		}
+	}
+	if !found {
+		if value, found = m.generate(MapValue(media, "schema")); !found {
+			return openApiMockTooLarge()
+		}
+	}
+
+	contentType := mediaType
+	if strings.Contains(contentType, "*") {
+		contentType = "application/json"
+	}
+	if s, ok := value.(string); ok && !openApiMediaJson(contentType) {
+		result.Body = []byte(s)
+	} else {
+		result.Body, _ = json.Marshal(value)
+		if !openApiMediaJson(contentType) && !strings.HasPrefix(contentType, "text/") {
+			contentType = "application/json"
+		}
+	}
+	result.Header["Content-Type"] = openApiMockSafeType(contentType)
+	return result
+}
+
+// 可被浏览器作为页面或脚本执行的类型（HTML、XML、SVG、JavaScript）改为纯文本返回
+func openApiMockSafeType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "text/plain; charset=utf-8"
+	}
+	if mediaType == "text/html" || strings.Contains(mediaType, "xml") || strings.Contains(mediaType, "javascript") || strings.Contains(mediaType, "ecmascript") {
+		return "text/plain; charset=utf-8"
+	}
+	return contentType
+}
+
+// 选择响应的状态码：指定的状态码，或文档中最小的2xx状态码，返回响应在responses中的key
+func openApiMockStatus(responses map[string]interface{}, prefer string) (string, int) {
+	if prefer != "" {
+		code, err := strconv.Atoi(prefer)
+		if err != nil || code < 100 || code > 599 {
+			return "", 0
+		}
+		for _, key := range []string{prefer, prefer[:1] + "XX", prefer[:1] + "xx", "default"} {
+			if _, ok := responses[key]; ok {
+				return key, code
+			}
+		}
+		return "", 0
+	}
+
+	var codes []int
+	for key := range responses {
+		if code, err := strconv.Atoi(key); err == nil {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		if code >= 200 && code < 300 {
+			return strconv.Itoa(code), code
+		}
+	}
+	for _, key := range []string{"2XX", "2xx", "default"} {
+		if _, ok := responses[key]; ok {
+			return key, http.StatusOK
+		}
+	}
+	if len(codes) > 0 {
+		return strconv.Itoa(codes[0]), codes[0]
+	}
+	return "", 0
+}
+
+// 按Accept请求头选择响应类型，没有匹配时优先使用JSON
+func openApiMockMediaType(content map[string]interface{}, accept string) string {
+	keys := openApiMockSortedKeys(content)
+	type acceptRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []acceptRange
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		if quality > 0 {
+			ranges = append(ranges, acceptRange{mediaType, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+	for _, r := range ranges {
+		if r.mediaType == "*/*" {
+			break
+		}
+		for _, key := range keys {
+			if openApiMediaMatch(r.mediaType, key) || openApiMediaMatch(key, r.mediaType) {
+				return key
+			}
+		}
+	}
+	for _, key := range keys {
+		if key == "application/json" {
+			return key
+		}
+	}
+	for _, key := range keys {
+		if openApiMediaJson(key) {
+			return key
+		}
+	}
+	return keys[0]
+}
+
+// 媒体类型是否匹配，pattern可包含通配符（如 application/*、*/*）
+func openApiMediaMatch(pattern, mediaType string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(strings.Split(pattern, ";")[0]))
+	mediaType = strings.ToLower(mediaType)
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// 是否为JSON类型，如 application/json、application/problem+json
+func openApiMediaJson(mediaType string) bool {
+	mediaType = strings.ToLower(strings.Split(mediaType, ";")[0])
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// 排序后的字段名，保证结果稳定
+func openApiMockSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 模拟服务自身的错误响应
+func openApiMockError(status int, message string, issues []OpenApiMockIssue) OpenApiMockResponse {
+	body, _ := json.Marshal(struct {
+		Message string             `json:"message"`
+		Issues  []OpenApiMockIssue `json:"issues,omitempty"`
+	}{message, issues})
+	return OpenApiMockResponse{Status: status, Header: map[string]string{"Content-Type": "application/json"}, Body: body}
+}
+
+// 按schema生成的响应超出限制
+func openApiMockTooLarge() OpenApiMockResponse {
+	return openApiMockError(http.StatusInternalServerError, "按schema生成的响应内容过多，请简化schema或填写示例", nil)
+}
+
+// schema的类型，未指定时按字段推断，多个类型时取第一个非null类型
+func openApiMockType(schema map[string]interface{}) string {
+	if t := StringValue(schema, "type"); t != "" {
+		return t
+	}
+	for _, v := range ListValue(schema, "type") {
+		if t := fmt.Sprint(v); t != "null" {
+			return t
+		}
+	}
+	switch {
+	case schema == nil:
+		return ""
+	case schema["properties"] != nil || schema["additionalProperties"] != nil:
+		return "object"
+	case schema["items"] != nil:
+		return "array"
+	}
+	return ""
+}
+
+// 获取数字类型的字段
+func openApiMockNumber(m map[string]interface{}, key string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	return openApiNumber(m[key])
+}
+
+// 转换数字，yaml解析出的整数为int，JSON解析出的为float64
+func openApiNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// 比较两个值是否相等，数字按数值比较
+func openApiMockEqual(a, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
+
+// 按schema生成示例值，超出节点数或字节数限制时返回false
+func (m *OpenApiMock) generate(schema map[string]interface{}) (interface{}, bool) {
+	g := &openApiMockGenerator{spec: m.spec}
+	value := g.generate(schema, 0)
+	return value, !g.exceeded
+}
+
+// 示例值生成器
+type openApiMockGenerator struct {
+	spec     map[string]interface{}
+	refs     []string // 正在生成的引用，出现循环引用时停止
+	nodes    int      // 已生成的节点数
+	bytes    int      // 已生成的字符串字节数
+	exceeded bool     // 已超出限制，停止生成
+}
+
+func (g *openApiMockGenerator) generate(schema map[string]interface{}, depth int) interface{} {
+	if schema == nil || depth > openApiMockMaxDepth || g.exceeded {
+		return nil
+	}
+	if g.nodes++; g.nodes > openApiMockMaxNodes {
+		g.exceeded = true
+		return nil
+	}
+	if ref := StringValue(schema, "$ref"); ref != "" {
+		if slices.Contains(g.refs, ref) {
+			return nil
+		}
+		g.refs = append(g.refs, ref)
+		defer func() { g.refs = g.refs[:len(g.refs)-1] }()
+		return g.generate(ResolveRef(g.spec, ref), depth+1)
+	}
+
+	for _, key := range []string{"example", "const", "default"} {
+		if value, ok := schema[key]; ok {
+			return value
+		}
+	}
+	if examples := ListValue(schema, "examples"); len(examples) > 0 {
+		return examples[0]
+	}
+	if enum := ListValue(schema, "enum"); len(enum) > 0 {
+		return enum[0]
+	}
+
+	// 组合schema：allOf合并各对象的字段，oneOf、anyOf取第一个
+	if list := ListValue(schema, "allOf"); len(list) > 0 {
+		result := map[string]interface{}{}
+		var first interface{}
+		for _, v := range list {
+			item, _ := v.(map[string]interface{})
+			value := g.generate(item, depth+1)
+			if object, ok := value.(map[string]interface{}); ok {
+				for key, field := range object {
+					result[key] = field
+				}
+			} else if first == nil {
+				first = value
+			}
+		}
+		if schema["properties"] != nil {
+			for key, field := range g.object(schema, depth) {
+				result[key] = field
+			}
+		}
+		if len(result) == 0 && first != nil {
+			return first
+		}
+		return result
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if list := ListValue(schema, key); len(list) > 0 {
+			item, _ := list[0].(map[string]interface{})
+			return g.generate(item, depth+1)
+		}
+	}
+
+	switch openApiMockType(schema) {
+	case "object":
+		return g.object(schema, depth)
+	case "array":
+		count := 1
+		if minItems, ok := openApiMockNumber(schema, "minItems"); ok && minItems > 1 {
+			count = int(math.Min(minItems, openApiMockMaxItems))
+		}
+		result := []interface{}{}
+		for i := 0; i < count; i++ {
+			item := g.generate(MapValue(schema, "items"), depth+1)
+			if item == nil {
+				break
+			}
+			result = append(result, item)
+		}
+		return result
+	case "string":
+		if minLength, ok := openApiMockNumber(schema, "minLength"); ok && minLength > openApiMockMaxBytes {
+			g.exceeded = true
+			return nil
+		}
+		value := openApiMockString(schema)
+		if g.bytes += len(value); g.bytes > openApiMockMaxBytes {
+			g.exceeded = true
+			return nil
+		}
+		return value
+	case "integer":
+		return int64(openApiMockNumberValue(schema, 1))
+	case "number":
+		return openApiMockNumberValue(schema, 0.1)
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+// 生成对象，响应中不包含只写字段
+func (g *openApiMockGenerator) object(schema map[string]interface{}, depth int) map[string]interface{} {
+	result := map[string]interface{}{}
+	properties := MapValue(schema, "properties")
+	for key := range properties {
+		property := MapValue(properties, key)
+		if BoolValue(Deref(g.spec, property), "writeOnly") {
+			continue
+		}
+		if value := g.generate(property, depth+1); value != nil {
+			result[key] = value
+		}
+	}
+	if additional := MapValue(schema, "additionalProperties"); additional != nil && len(properties) == 0 {
+		if value := g.generate(additional, depth+1); value != nil {
+			result["key"] = value
+		}
+	}
+	return result
+}
+
+// 按格式和长度限制生成字符串
+func openApiMockString(schema map[string]interface{}) string {
+	value := "string"
+	switch StringValue(schema, "format") {
+	case "date-time":
+		value = "2024-01-01T00:00:00Z"
+	case "date":
+		value = "2024-01-01"
+	case "time":
+		value = "12:00:00"
+	case "email":
+		value = "user@example.com"
+	case "uuid":
+		value = "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		value = "https://example.com"
+	case "hostname":
+		value = "example.com"
+	case "ipv4":
+		value = "192.168.0.1"
+	case "ipv6":
+		value = "::1"
+	case "byte":
+		value = "c3RyaW5n"
+	case "binary":
+		value = ""
+	}
+	if minLength, ok := openApiMockNumber(schema, "minLength"); ok {
+		if n := int(minLength) - utf8.RuneCountInString(value); n > 0 {
+			value += strings.Repeat("a", n)
+		}
+	}
+	if maxLength, ok := openApiMockNumber(schema, "maxLength"); ok && utf8.RuneCountInString(value) > int(maxLength) {
+		value = string([]rune(value)[:int(maxLength)])
+	}
+	return value
+}
+
+// 按最小值、最大值生成数字，step为排除边界值时的偏移量
+func openApiMockNumberValue(schema map[string]interface{}, step float64) float64 {
+	minimum, hasMinimum := openApiMockNumber(schema, "minimum")
+	maximum, hasMaximum := openApiMockNumber(schema, "maximum")
+	// OpenAPI 3.1中exclusiveMinimum、exclusiveMaximum为数字
+	if v, ok := openApiMockNumber(schema, "exclusiveMinimum"); ok {
+		minimum, hasMinimum = v+step, true
+	} else if hasMinimum && BoolValue(schema, "exclusiveMinimum") {
+		minimum += step
+	}
+	if v, ok := openApiMockNumber(schema, "exclusiveMaximum"); ok {
+		maximum, hasMaximum = v-step, true
+	} else if hasMaximum && BoolValue(schema, "exclusiveMaximum") {
+		maximum -= step
+	}
+	switch {
+	case hasMinimum:
+		return minimum
+	case hasMaximum && maximum < 0:
+		return maximum
+	}
+	return 0
+}
+
+// 请求校验器
+type openApiMockValidator struct {
+	spec     map[string]interface{}
+	location string
+	request  bool // 校验请求时不要求只读字段
+	coerce   bool // 参数、表单的值均为字符串，按schema类型转换后校验
+	issues   []OpenApiMockIssue
+}
+
+func (v *openApiMockValidator) add(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, OpenApiMockIssue{Location: v.location, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// 解析引用，限制层数防止循环引用
+func (v *openApiMockValidator) deref(schema map[string]interface{}) map[string]interface{} {
+	return Deref(v.spec, schema)
+}
+
+// 值是否满足schema，不记录问题
+func (v *openApiMockValidator) valid(value interface{}, schema map[string]interface{}, depth int) bool {
+	sub := &openApiMockValidator{spec: v.spec, location: v.location, request: v.request, coerce: v.coerce}
+	sub.check(value, schema, "", depth)
+	return len(sub.issues) == 0
+}
+
+// 按schema校验值，记录不满足的问题
+func (v *openApiMockValidator) check(value interface{}, schema map[string]interface{}, path string, depth int) {
+	if depth > openApiMockMaxDepth {
+		return
+	}
+	schema = v.deref(schema)
+	if schema == nil {
+		return
+	}
+
+	for _, item := range ListValue(schema, "allOf") {
+		sub, _ := item.(map[string]interface{})
+		v.check(value, sub, path, depth+1)
+	}
+	if list := ListValue(schema, "anyOf"); len(list) > 0 {
+		matched := false
+		for _, item := range list {
+			sub, _ := item.(map[string]interface{})
+			if v.valid(value, sub, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.add(path, "不满足anyOf中的任一schema")
+		}
+	}
+	if list := ListValue(schema, "oneOf"); len(list) > 0 {
+		count := 0
+		for _, item := range list {
+			sub, _ := item.(map[string]interface{})
+			if v.valid(value, sub, depth+1) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.add(path, "应满足且仅满足oneOf中的一个schema，实际满足%d个", count)
+		}
+	}
+
+	// 类型
+	var types []string
+	if t := StringValue(schema, "type"); t != "" {
+		types = []string{t}
+	}
+	for _, t := range ListValue(schema, "type") {
+		types = append(types, fmt.Sprint(t))
+	}
+	if BoolValue(schema, "nullable") {
+		types = append(types, "null")
+	}
+	if value == nil {
+		if len(types) > 0 && !slices.Contains(types, "null") {
+			v.add(path, "不可为null")
+		}
+		return
+	}
+	if len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if converted, ok := v.convert(value, t); ok {
+				value, matched = converted, true
+				break
+			}
+		}
+		if !matched {
+			v.add(path, "类型应为%s", strings.Join(types, "或"))
+			return
+		}
+	} else if v.coerce {
+		if s, ok := value.(string); ok {
+			if t := openApiMockType(schema); t != "" {
+				if converted, ok := v.convert(s, t); ok {
+					value = converted
+				}
+			}
+		}
+	}
+
+	if enum := ListValue(schema, "enum"); len(enum) > 0 {
+		matched := false
+		for _, item := range enum {
+			if openApiMockEqual(value, item) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.add(path, "值应为以下之一：%s", openApiMockJoin(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !openApiMockEqual(value, constant) {
+		v.add(path, "值应为%s", openApiMockJoin([]interface{}{constant}))
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.checkString(value, schema, path)
+	case float64:
+		v.checkNumber(value, schema, path)
+	case []interface{}:
+		v.checkArray(value, schema, path, depth)
+	case map[string]interface{}:
+		v.checkObject(value, schema, path, depth)
+	}
+}
+
+// 转换为指定类型，不可转换时返回false
+func (v *openApiMockValidator) convert(value interface{}, t string) (interface{}, bool) {
+	if s, ok := value.(string); ok && v.coerce && t != "string" {
+		switch t {
+		case "integer", "number":
+			number, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, false
+			}
+			value = number
+		case "boolean":
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, false
+			}
+			value = b
+		case "array":
+			value = []interface{}{s}
+		}
+	}
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return value, ok
+	case "number":
+		_, ok := value.(float64)
+		return value, ok
+	case "integer":
+		number, ok := value.(float64)
+		return value, ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return value, ok
+	case "array":
+		_, ok := value.([]interface{})
+		return value, ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return value, ok
+	}
+	return value, false
+}
+
+func (v *openApiMockValidator) checkString(value string, schema map[string]interface{}, path string) {
+	length := utf8.RuneCountInString(value)
+	if minLength, ok := openApiMockNumber(schema, "minLength"); ok && float64(length) < minLength {
+		v.add(path, "长度不能小于%v", minLength)
+	}
+	if maxLength, ok := openApiMockNumber(schema, "maxLength"); ok && float64(length) > maxLength {
+		v.add(path, "长度不能大于%v", maxLength)
+	}
+	if pattern := StringValue(schema, "pattern"); pattern != "" {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+			v.add(path, "应匹配正则表达式%s", pattern)
+		}
+	}
+
+	valid := true
+	switch format := StringValue(schema, "format"); format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		valid = err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, value)
+		valid = err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		valid = err == nil && address.Address == value
+	case "uuid":
+		valid = openApiMockUuid.MatchString(value)
+	case "uri":
+		u, err := url.Parse(value)
+		valid = err == nil && u.Scheme != ""
+	case "ipv4":
+		ip := net.ParseIP(value)
+		valid = ip != nil && ip.To4() != nil
+	case "ipv6":
+		ip := net.ParseIP(value)
+		valid = ip != nil && strings.Contains(value, ":")
+	}
+	if !valid {
+		v.add(path, "格式应为%s", StringValue(schema, "format"))
+	}
+}
+
+func (v *openApiMockValidator) checkNumber(value float64, schema map[string]interface{}, path string) {
+	if minimum, ok := openApiMockNumber(schema, "minimum"); ok {
+		if BoolValue(schema, "exclusiveMinimum") && value <= minimum {
+			v.add(path, "应大于%v", minimum)
+		} else if value < minimum {
+			v.add(path, "不能小于%v", minimum)
+		}
+	}
+	if maximum, ok := openApiMockNumber(schema, "maximum"); ok {
+		if BoolValue(schema, "exclusiveMaximum") && value >= maximum {
+			v.add(path, "应小于%v", maximum)
+		} else if value > maximum {
+			v.add(path, "不能大于%v", maximum)
+		}
+	}
+	// OpenAPI 3.1中exclusiveMinimum、exclusiveMaximum为数字
+	if minimum, ok := openApiMockNumber(schema, "exclusiveMinimum"); ok && value <= minimum {
+		v.add(path, "应大于%v", minimum)
+	}
+	if maximum, ok := openApiMockNumber(schema, "exclusiveMaximum"); ok && value >= maximum {
+		v.add(path, "应小于%v", maximum)
+	}
+	if multipleOf, ok := openApiMockNumber(schema, "multipleOf"); ok && multipleOf > 0 {
+		quotient := value / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.add(path, "应为%v的倍数", multipleOf)
+		}
+	}
+}
+
+func (v *openApiMockValidator) checkArray(value []interface{}, schema map[string]interface{}, path string, depth int) {
+	if minItems, ok := openApiMockNumber(schema, "minItems"); ok && float64(len(value)) < minItems {
+		v.add(path, "元素数量不能小于%v", minItems)
+	}
+	if maxItems, ok := openApiMockNumber(schema, "maxItems"); ok && float64(len(value)) > maxItems {
+		v.add(path, "元素数量不能大于%v", maxItems)
+	}
+	if BoolValue(schema, "uniqueItems") {
+		seen := map[string]bool{}
+		for _, item := range value {
+			data, _ := json.Marshal(item)
+			if seen[string(data)] {
+				v.add(path, "元素不能重复")
+				break
+			}
+			seen[string(data)] = true
+		}
+	}
+	if items := MapValue(schema, "items"); items != nil {
+		for i, item := range value {
+			v.check(item, items, path+"/"+strconv.Itoa(i), depth+1)
+		}
+	}
+}
+
+func (v *openApiMockValidator) checkObject(value map[string]interface{}, schema map[string]interface{}, path string, depth int) {
+	properties := MapValue(schema, "properties")
+	for _, item := range ListValue(schema, "required") {
+		name := fmt.Sprint(item)
+		if _, ok := value[name]; ok {
+			continue
+		}
+		// 请求中不需要传只读字段
+		if v.request && BoolValue(v.deref(MapValue(properties, name)), "readOnly") {
+			continue
+		}
+		v.add(path+"/"+pointerEscape(name), "缺少必填字段%s", name)
+	}
+	if minProperties, ok := openApiMockNumber(schema, "minProperties"); ok && float64(len(value)) < minProperties {
+		v.add(path, "字段数量不能小于%v", minProperties)
+	}
+	if maxProperties, ok := openApiMockNumber(schema, "maxProperties"); ok && float64(len(value)) > maxProperties {
+		v.add(path, "字段数量不能大于%v", maxProperties)
+	}
+
+	additional, hasAdditional := schema["additionalProperties"]
+	for _, key := range openApiMockSortedKeys(value) {
+		if property := MapValue(properties, key); property != nil {
+			v.check(value[key], property, path+"/"+pointerEscape(key), depth+1)
+			continue
+		}
+		if properties[key] != nil || !hasAdditional {
+			continue
+		}
+		switch additional := additional.(type) {
+		case bool:
+			if !additional {
+				v.add(path+"/"+pointerEscape(key), "不允许的字段%s", key)
+			}
+		case map[string]interface{}:
+			v.check(value[key], additional, path+"/"+pointerEscape(key), depth+1)
+		}
+	}
+}
+
+// 值列表的文本描述
+func openApiMockJoin(values []interface{}) string {
+	texts := make([]string, 0, len(values))
+	for _, value := range values {
+		data, _ := json.Marshal(value)
+		texts = append(texts, string(data))
+	}
+	return strings.Join(texts, "、")
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenApiMockUnsafeResponse(t *testing.T) {
+	mock, err := NewOpenApiMock(`
+openapi: 3.0.0
+info: {title: t, version: "1"}
+paths:
+  /page:
+    get:
+      responses:
+        "200":
+          description: ok
+          headers:
+            Set-Cookie: {schema: {type: string, example: "session=x"}}
+            Location: {schema: {type: string, example: "https://example.com"}}
+            Content-Security-Policy: {schema: {type: string, example: "default-src *"}}
+            "Bad Name": {schema: {type: string}}
+            X-Rate-Limit: {schema: {type: integer, example: 10}}
+          content:
+            text/html:
+              example: "<script>alert(1)</script>"
+  /svg:
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            image/svg+xml; charset=utf-8:
+              example: "<svg onload=alert(1)></svg>"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := mock.Serve(OpenApiMockRequest{Method: "GET", Path: "/page", Header: http.Header{}})
+	if response.Header["Content-Type"] != "text/plain; charset=utf-8" || string(response.Body) != "<script>alert(1)</script>" {
+		t.Fatalf("%+v", response)
+	}
+	if len(response.Header) != 2 || response.Header["X-Rate-Limit"] != "10" {
+		t.Fatalf("响应头未过滤：%v", response.Header)
+	}
+
+	response = mock.Serve(OpenApiMockRequest{Method: "GET", Path: "/svg", Header: http.Header{}})
+	if response.Header["Content-Type"] != "text/plain; charset=utf-8" {
+		t.Fatalf("%+v", response)
+	}
+}
+
+// 每个schema多次引用下一个schema，展开后节点数指数增长
+func TestOpenApiMockGenerateLimit(t *testing.T) {
+	var spec strings.Builder
+	spec.WriteString("openapi: 3.0.0\ninfo: {title: t, version: \"1\"}\npaths:\n  /a:\n    get:\n      responses:\n        \"200\":\n          description: ok\n          content:\n            application/json:\n              schema: {$ref: \"#/components/schemas/S0\"}\n")
+	spec.WriteString("  /b:\n    get:\n      responses:\n        \"200\":\n          description: ok\n          content:\n            application/json:\n              schema: {type: string, minLength: 1000000000}\n")
+	spec.WriteString("components:\n  schemas:\n")
+	for i := 0; i < 15; i++ {
+		spec.WriteString(fmt.Sprintf("    S%d:\n      type: object\n      properties:\n", i))
+		for j := 0; j < 5; j++ {
+			spec.WriteString(fmt.Sprintf("        p%d: {$ref: \"#/components/schemas/S%d\"}\n", j, i+1))
+		}
+	}
+	spec.WriteString("    S15: {type: string}\n")
+	mock, err := NewOpenApiMock(spec.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/a", "/b"} {
+		start := time.Now()
+		response := mock.Serve(OpenApiMockRequest{Method: "GET", Path: path, Header: http.Header{}})
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("%s 生成耗时过长：%s", path, elapsed)
+		}
+		if response.Status != http.StatusInternalServerError {
+			t.Fatalf("%s %d %.200s", path, response.Status, response.Body)
+		}
+	}
+}
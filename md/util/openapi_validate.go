@@ -0,0 +1,570 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI校验问题级别
+const (
+	OpenApiError   = "error"   // 错误，不符合规范
+	OpenApiWarning = "warning" // 警告，可能存在问题
+)
+
+// OpenAPI校验问题
+type OpenApiIssue struct {
+	Level   string `json:"level"`
+	Line    int    `json:"line"`   // 行号，从1开始，为0时未知
+	Column  int    `json:"column"` // 列号，从1开始，为0时未知
+	Path    string `json:"path"`   // 问题所在位置（JSON Pointer）
+	Message string `json:"message"`
+}
+
+// OpenAPI校验结果
+type OpenApiValidateResult struct {
+	Version string         `json:"version"` // 规范版本：2.0/3.0/3.1，无法识别时为空
+	Valid   bool           `json:"valid"`
+	Issues  []OpenApiIssue `json:"issues"`
+}
+
+var (
+	openApiYamlLineRegex   = regexp.MustCompile(`line (\d+)`)
+	openApiVersionRegex    = regexp.MustCompile(`^3\.([01])\.\d+(-.+)?$`)
+	openApiPathParamRegex  = regexp.MustCompile(`\{([^{}/]+)}`)
+	openApiStatusRegex     = regexp.MustCompile(`^[1-5](\d\d|XX)$`)
+	openApiComponentRegex  = regexp.MustCompile(`^[a-zA-Z0-9.\-_]+$`)
+	openApiRootFields3     = []string{"openapi", "info", "servers", "paths", "components", "security", "tags", "externalDocs"}
+	openApiRootFields31    = []string{"jsonSchemaDialect", "webhooks"}
+	openApiRootFields2     = []string{"swagger", "info", "host", "basePath", "schemes", "consumes", "produces", "paths", "definitions", "parameters", "responses", "securityDefinitions", "security", "tags", "externalDocs"}
+	openApiPathItemFields  = []string{"$ref", "summary", "description", "servers", "parameters"}
+	openApiOperationFields = []string{"tags", "summary", "description", "externalDocs", "operationId", "parameters", "requestBody", "responses", "callbacks", "deprecated", "security", "servers"}
+	openApiOperation2      = []string{"tags", "summary", "description", "externalDocs", "operationId", "consumes", "produces", "parameters", "responses", "schemes", "deprecated", "security"}
+	openApiParameterIn3    = []string{"query", "header", "path", "cookie"}
+	openApiParameterIn2    = []string{"query", "header", "path", "formData", "body"}
+)
+
+// 校验OpenAPI 3.0/3.1、Swagger 2.0文档（JSON或YAML），strict为true时警告也视为不通过
+func ValidateOpenApi(content string, strict bool) OpenApiValidateResult {
+	v := &openApiValidator{operationIds: map[string]string{}}
+	v.validate(content)
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		if v.issues[i].Line != v.issues[j].Line {
+			return v.issues[i].Line < v.issues[j].Line
+		}
+		return v.issues[i].Column < v.issues[j].Column
+	})
+	result := OpenApiValidateResult{Version: v.version, Valid: true, Issues: v.issues}
+	if result.Issues == nil {
+		result.Issues = []OpenApiIssue{}
+	}
+	for _, issue := range result.Issues {
+		if issue.Level == OpenApiError || strict {
+			result.Valid = false
+		}
+	}
+	return result
+}
+
+// OpenAPI校验器
+type openApiValidator struct {
+	root         *yaml.Node
+	version      string
+	issues       []OpenApiIssue
+	operationIds map[string]string   // operationId -> 首次出现的位置
+	refChecked   map[*yaml.Node]bool // 已校验引用的节点，别名指向的节点只校验一次
+}
+
+func (v *openApiValidator) validate(content string) {
+	if strings.TrimSpace(content) == "" {
+		v.add(OpenApiError, nil, "", "文档内容为空")
+		return
+	}
+	document := yaml.Node{}
+	err := yaml.Unmarshal([]byte(content), &document)
+	if err != nil {
+		issue := OpenApiIssue{Level: OpenApiError, Message: "解析失败：" + strings.TrimPrefix(err.Error(), "yaml: ")}
+		if match := openApiYamlLineRegex.FindStringSubmatch(err.Error()); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+		}
+		v.issues = append(v.issues, issue)
+		return
+	}
+	if len(document.Content) == 0 || resolveAlias(document.Content[0]).Kind != yaml.MappingNode {
+		v.add(OpenApiError, &document, "", "文档根节点应为对象")
+		return
+	}
+	v.root = resolveAlias(document.Content[0])
+	v.checkDuplicateKeys(v.root, "")
+
+	// 识别版本
+	openapi := nodeField(v.root, "openapi")
+	swagger := nodeField(v.root, "swagger")
+	switch {
+	case openapi != nil:
+		match := openApiVersionRegex.FindStringSubmatch(openapi.Value)
+		if openapi.Kind != yaml.ScalarNode || match == nil {
+			v.add(OpenApiError, openapi, "/openapi", "不支持的OpenAPI版本：%s，应为3.0.x或3.1.x", openapi.Value)
+		} else {
+			v.version = "3." + match[1]
+		}
+		if openapi.Tag != "!!str" {
+			v.add(OpenApiWarning, openapi, "/openapi", "版本号应为字符串")
+		}
+	case swagger != nil:
+		if swagger.Value != "2.0" {
+			v.add(OpenApiError, swagger, "/swagger", "不支持的Swagger版本：%s，应为2.0", swagger.Value)
+		} else {
+			v.version = "2.0"
+		}
+	default:
+		v.add(OpenApiError, v.root, "", "缺少openapi或swagger字段")
+	}
+
+	v.checkRefs(v.root, "")
+	if v.version == "" {
+		return
+	}
+	v.checkRoot()
+}
+
+// 校验根节点
+func (v *openApiValidator) checkRoot() {
+	allowed := openApiRootFields2
+	if v.version != "2.0" {
+		allowed = openApiRootFields3
+		if v.version == "3.1" {
+			allowed = append(slices.Clone(allowed), openApiRootFields31...)
+		}
+	}
+	v.checkFields(v.root, "", allowed)
+
+	// info
+	info := nodeField(v.root, "info")
+	if info == nil {
+		v.add(OpenApiError, v.root, "", "缺少info字段")
+	} else if v.expectObject(info, "/info") {
+		for _, key := range []string{"title", "version"} {
+			value := nodeField(info, key)
+			if value == nil {
+				v.add(OpenApiError, info, "/info", "缺少%s字段", key)
+			} else if value.Kind != yaml.ScalarNode {
+				v.add(OpenApiError, value, "/info/"+key, "应为字符串")
+			} else if value.Tag != "!!str" {
+				v.add(OpenApiWarning, value, "/info/"+key, "应为字符串，请加引号")
+			}
+		}
+	}
+
+	// paths
+	paths := nodeField(v.root, "paths")
+	if paths == nil {
+		if v.version != "3.1" {
+			v.add(OpenApiError, v.root, "", "缺少paths字段")
+		} else if nodeField(v.root, "components") == nil && nodeField(v.root, "webhooks") == nil {
+			v.add(OpenApiError, v.root, "", "paths、components、webhooks至少需填写一个")
+		}
+	} else if v.expectObject(paths, "/paths") {
+		v.checkPaths(paths)
+	}
+
+	// components中的名称
+	if v.version != "2.0" {
+		if components := nodeField(v.root, "components"); components != nil && v.expectObject(components, "/components") {
+			nodeEach(components, func(key, value *yaml.Node) {
+				if strings.HasPrefix(key.Value, "x-") || value.Kind != yaml.MappingNode {
+					return
+				}
+				nodeEach(value, func(name, _ *yaml.Node) {
+					if !openApiComponentRegex.MatchString(name.Value) {
+						v.add(OpenApiError, name, "/components/"+pointerEscape(key.Value)+"/"+pointerEscape(name.Value), "组件名称只能包含字母、数字和.-_")
+					}
+				})
+			})
+		}
+	}
+
+	// 全局安全要求
+	if security := nodeField(v.root, "security"); security != nil {
+		v.checkSecurity(security, "/security")
+	}
+}
+
+// 校验所有路径
+func (v *openApiValidator) checkPaths(paths *yaml.Node) {
+	templates := map[string]string{}
+	nodeEach(paths, func(key, item *yaml.Node) {
+		path := key.Value
+		pointer := "/paths/" + pointerEscape(path)
+		if strings.HasPrefix(path, "x-") {
+			return
+		}
+		if !strings.HasPrefix(path, "/") {
+			v.add(OpenApiError, key, pointer, "路径应以/开头")
+		}
+		// 参数名不同但结构相同的路径视为重复
+		template := openApiPathParamRegex.ReplaceAllString(path, "{}")
+		if first, ok := templates[template]; ok {
+			v.add(OpenApiError, key, pointer, "路径与%s重复", first)
+		} else {
+			templates[template] = path
+		}
+		if !v.expectObject(item, pointer) {
+			return
+		}
+		v.checkPathItem(path, item, pointer)
+	})
+}
+
+// 校验路径下的操作
+func (v *openApiValidator) checkPathItem(path string, item *yaml.Node, pointer string) {
+	allowed := append(slices.Clone(openApiPathItemFields), OpenApiMethods...)
+	v.checkFields(item, pointer, allowed)
+	pathParameters := v.checkParameters(nodeField(item, "parameters"), pointer+"/parameters")
+
+	names := []string{}
+	for _, match := range openApiPathParamRegex.FindAllStringSubmatch(path, -1) {
+		names = append(names, match[1])
+	}
+	for _, method := range OpenApiMethods {
+		operation := nodeField(item, method)
+		if operation == nil {
+			continue
+		}
+		operationPointer := pointer + "/" + method
+		if !v.expectObject(operation, operationPointer) {
+			continue
+		}
+		v.checkOperation(operation, operationPointer, names, pathParameters)
+	}
+}
+
+// 校验单个操作
+func (v *openApiValidator) checkOperation(operation *yaml.Node, pointer string, pathNames []string, pathParameters map[string]*yaml.Node) {
+	if v.version == "2.0" {
+		v.checkFields(operation, pointer, openApiOperation2)
+	} else {
+		v.checkFields(operation, pointer, openApiOperationFields)
+	}
+
+	// operationId唯一
+	if id := nodeField(operation, "operationId"); id != nil {
+		if first, ok := v.operationIds[id.Value]; ok {
+			v.add(OpenApiError, id, pointer+"/operationId", "operationId重复：%s，首次出现于%s", id.Value, first)
+		} else {
+			v.operationIds[id.Value] = fmt.Sprintf("%s（第%d行）", pointer, id.Line)
+		}
+	}
+
+	// 参数：操作中的参数覆盖路径中的同名参数
+	parameters := map[string]*yaml.Node{}
+	for key, value := range pathParameters {
+		parameters[key] = value
+	}
+	for key, value := range v.checkParameters(nodeField(operation, "parameters"), pointer+"/parameters") {
+		parameters[key] = value
+	}
+	for _, name := range pathNames {
+		if parameters["path:"+name] == nil {
+			v.add(OpenApiError, operation, pointer, "路径参数%s未定义", name)
+		}
+	}
+	body, form := false, false
+	for key, value := range parameters {
+		in, name, _ := strings.Cut(key, ":")
+		if in == "path" && !slices.Contains(pathNames, name) {
+			v.add(OpenApiError, value, pointer, "路径中不存在参数%s", name)
+		}
+		body = body || in == "body"
+		form = form || in == "formData"
+	}
+	if body && form {
+		v.add(OpenApiError, operation, pointer, "body参数和formData参数不可同时使用")
+	}
+
+	// 请求体
+	if requestBody := nodeField(operation, "requestBody"); requestBody != nil && v.expectObject(requestBody, pointer+"/requestBody") {
+		if nodeField(requestBody, "$ref") == nil && nodeField(requestBody, "content") == nil {
+			v.add(OpenApiError, requestBody, pointer+"/requestBody", "缺少content字段")
+		}
+	}
+
+	// 响应
+	responses := nodeField(operation, "responses")
+	if responses == nil {
+		if v.version != "3.1" {
+			v.add(OpenApiError, operation, pointer, "缺少responses字段")
+		}
+	} else if v.expectObject(responses, pointer+"/responses") {
+		count := 0
+		nodeEach(responses, func(key, response *yaml.Node) {
+			responsePointer := pointer + "/responses/" + pointerEscape(key.Value)
+			if strings.HasPrefix(key.Value, "x-") {
+				return
+			}
+			count++
+			if key.Value != "default" && !openApiStatusRegex.MatchString(key.Value) ||
+				v.version == "2.0" && strings.HasSuffix(key.Value, "XX") {
+				v.add(OpenApiError, key, responsePointer, "无效的响应状态码：%s", key.Value)
+			}
+			if v.expectObject(response, responsePointer) && nodeField(response, "$ref") == nil && nodeField(response, "description") == nil {
+				v.add(OpenApiError, response, responsePointer, "缺少description字段")
+			}
+		})
+		if count == 0 {
+			v.add(OpenApiError, responses, pointer+"/responses", "至少需定义一个响应")
+		}
+	}
+
+	if security := nodeField(operation, "security"); security != nil {
+		v.checkSecurity(security, pointer+"/security")
+	}
+}
+
+// 校验参数列表，返回 in:name -> 参数节点
+func (v *openApiValidator) checkParameters(list *yaml.Node, pointer string) map[string]*yaml.Node {
+	result := map[string]*yaml.Node{}
+	if list == nil {
+		return result
+	}
+	if list.Kind != yaml.SequenceNode {
+		v.add(OpenApiError, list, pointer, "应为数组")
+		return result
+	}
+	allowedIn := openApiParameterIn3
+	if v.version == "2.0" {
+		allowedIn = openApiParameterIn2
+	}
+	bodyCount := 0
+	for i, item := range list.Content {
+		itemPointer := pointer + "/" + strconv.Itoa(i)
+		item = resolveAlias(item)
+		if !v.expectObject(item, itemPointer) {
+			continue
+		}
+		// 引用的参数解析后校验，引用无效时已单独报告
+		parameter := item
+		if ref := nodeField(item, "$ref"); ref != nil {
+			parameter = v.resolveRef(ref.Value)
+			if parameter == nil || parameter.Kind != yaml.MappingNode {
+				continue
+			}
+		}
+		name, in := nodeField(parameter, "name"), nodeField(parameter, "in")
+		if name == nil || in == nil {
+			v.add(OpenApiError, item, itemPointer, "参数缺少name或in字段")
+			continue
+		}
+		if !slices.Contains(allowedIn, in.Value) {
+			v.add(OpenApiError, in, itemPointer+"/in", "无效的参数位置：%s，应为%s", in.Value, strings.Join(allowedIn, "、"))
+			continue
+		}
+		key := in.Value + ":" + name.Value
+		if result[key] != nil {
+			v.add(OpenApiError, item, itemPointer, "参数重复：%s（%s）", name.Value, in.Value)
+		}
+		result[key] = item
+		if in.Value == "path" {
+			if required := nodeField(parameter, "required"); required == nil || required.Value != "true" {
+				v.add(OpenApiError, item, itemPointer, "路径参数%s的required应为true", name.Value)
+			}
+		}
+		if in.Value == "body" {
+			bodyCount++
+			if bodyCount == 2 {
+				v.add(OpenApiError, item, itemPointer, "只能有一个body参数")
+			}
+		}
+		if v.version != "2.0" && parameter == item {
+			schema, content := nodeField(item, "schema"), nodeField(item, "content")
+			if schema == nil && content == nil || schema != nil && content != nil {
+				v.add(OpenApiError, item, itemPointer, "参数%s需填写schema或content其中之一", name.Value)
+			}
+		}
+	}
+	return result
+}
+
+// 校验安全要求引用的安全方案已定义
+func (v *openApiValidator) checkSecurity(security *yaml.Node, pointer string) {
+	if security.Kind != yaml.SequenceNode {
+		v.add(OpenApiError, security, pointer, "应为数组")
+		return
+	}
+	schemes := nodeField(v.root, "securityDefinitions")
+	if v.version != "2.0" {
+		schemes = nodeField(nodeField(v.root, "components"), "securitySchemes")
+	}
+	for i, requirement := range security.Content {
+		requirement = resolveAlias(requirement)
+		if !v.expectObject(requirement, pointer+"/"+strconv.Itoa(i)) {
+			continue
+		}
+		nodeEach(requirement, func(key, _ *yaml.Node) {
+			if nodeField(schemes, key.Value) == nil {
+				v.add(OpenApiError, key, pointer+"/"+strconv.Itoa(i)+"/"+pointerEscape(key.Value), "安全方案%s未定义", key.Value)
+			}
+		})
+	}
+}
+
+// 递归校验$ref引用，别名指向的节点只在首次出现的位置校验，避免多层别名展开后节点数指数增长
+func (v *openApiValidator) checkRefs(node *yaml.Node, pointer string) {
+	node = resolveAlias(node)
+	if node == nil || v.refChecked[node] {
+		return
+	}
+	if v.refChecked == nil {
+		v.refChecked = map[*yaml.Node]bool{}
+	}
+	v.refChecked[node] = true
+	switch node.Kind {
+	case yaml.MappingNode:
+		nodeEach(node, func(key, value *yaml.Node) {
+			childPointer := pointer + "/" + pointerEscape(key.Value)
+			if key.Value == "$ref" && value.Kind == yaml.ScalarNode {
+				switch {
+				case !strings.HasPrefix(value.Value, "#"):
+					v.add(OpenApiWarning, value, childPointer, "外部引用未校验：%s", value.Value)
+				case v.resolveRef(value.Value) == nil:
+					v.add(OpenApiError, value, childPointer, "引用不存在：%s", value.Value)
+				}
+				return
+			}
+			v.checkRefs(value, childPointer)
+		})
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			v.checkRefs(item, pointer+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+// 解析文档内的引用，不存在时返回nil
+func (v *openApiValidator) resolveRef(ref string) *yaml.Node {
+	if !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	node := v.root
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil
+	}
+	if fragment == "" {
+		return node
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return nil
+	}
+	for _, part := range strings.Split(fragment[1:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch node.Kind {
+		case yaml.MappingNode:
+			node = nodeField(node, part)
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			node = resolveAlias(node.Content[index])
+		default:
+			return nil
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// 递归检查对象中的重复字段
+func (v *openApiValidator) checkDuplicateKeys(node *yaml.Node, pointer string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		keys := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			childPointer := pointer + "/" + pointerEscape(key.Value)
+			if keys[key.Value] {
+				v.add(OpenApiError, key, childPointer, "字段重复：%s", key.Value)
+			}
+			keys[key.Value] = true
+			v.checkDuplicateKeys(node.Content[i+1], childPointer)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			v.checkDuplicateKeys(item, pointer+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+// 检查对象中不支持的字段，x-开头的扩展字段除外
+func (v *openApiValidator) checkFields(node *yaml.Node, pointer string, allowed []string) {
+	nodeEach(node, func(key, _ *yaml.Node) {
+		if !strings.HasPrefix(key.Value, "x-") && !slices.Contains(allowed, key.Value) {
+			v.add(OpenApiError, key, pointer+"/"+pointerEscape(key.Value), "不支持的字段：%s", key.Value)
+		}
+	})
+}
+
+// 检查节点是否为对象
+func (v *openApiValidator) expectObject(node *yaml.Node, pointer string) bool {
+	if resolveAlias(node).Kind != yaml.MappingNode {
+		v.add(OpenApiError, node, pointer, "应为对象")
+		return false
+	}
+	return true
+}
+
+// 添加问题，位置取节点所在行列
+func (v *openApiValidator) add(level string, node *yaml.Node, pointer, format string, args ...interface{}) {
+	issue := OpenApiIssue{Level: level, Path: pointer, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.Line, issue.Column = node.Line, node.Column
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// 获取对象中的字段值
+func nodeField(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// 遍历对象的字段
+func nodeEach(node *yaml.Node, fn func(key, value *yaml.Node)) {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i], resolveAlias(node.Content[i+1]))
+	}
+}
+
+// 解析yaml别名
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// JSON Pointer转义
+func pointerEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
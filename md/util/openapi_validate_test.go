@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 多层别名互相引用时不应重复展开
+func TestValidateOpenApiAliases(t *testing.T) {
+	lines := []string{"openapi: 3.0.0", "info: {title: t, version: '1'}", "paths: {}", "x-a0: &a0 {$ref: '#/missing'}"}
+	for i := 1; i <= 9; i++ {
+		lines = append(lines, fmt.Sprintf("x-a%d: &a%d [%s]", i, i, strings.TrimSuffix(strings.Repeat(fmt.Sprintf("*a%d, ", i-1), 9), ", ")))
+	}
+	lines = append(lines, "x-b0: &b0 {$ref: '#/missing'}")
+	for i := 1; i <= 9; i++ {
+		fields := []string{}
+		for j := 0; j < 9; j++ {
+			fields = append(fields, fmt.Sprintf("k%d: *b%d", j, i-1))
+		}
+		lines = append(lines, fmt.Sprintf("x-b%d: &b%d {%s}", i, i, strings.Join(fields, ", ")))
+	}
+
+	start := time.Now()
+	result := ValidateOpenApi(strings.Join(lines, "\n"), false)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("校验耗时过长：%s", elapsed)
+	}
+	// 别名指向的节点只报告一次
+	count := 0
+	for _, issue := range result.Issues {
+		if strings.Contains(issue.Message, "#/missing") {
+			count++
+		}
+	}
+	if result.Valid || count != 2 {
+		t.Fatalf("问题数量错误：%d %+v", count, result.Issues)
+	}
+}
@@ -0,0 +1,256 @@
+// 文本协同编辑的操作转换（OT），操作格式与ot.js的TextOperation一致：
+// [保留字符数（正整数）, "插入的文本", 删除字符数（负整数）...]，长度按UTF-16编码单元计算
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// 文本操作
+type TextOperation struct {
+	Ops          []OtComponent // 操作分量
+	BaseLength   int           // 操作前的文本长度
+	TargetLength int           // 操作后的文本长度
+}
+
+// 操作分量，三个字段只有一个有效
+type OtComponent struct {
+	Retain int    // 保留
+	Insert string // 插入
+	Delete int    // 删除
+}
+
+// 保留n个字符
+func (o *TextOperation) Retain(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	o.TargetLength += n
+	if last := len(o.Ops) - 1; last >= 0 && o.Ops[last].Retain > 0 {
+		o.Ops[last].Retain += n
+	} else {
+		o.Ops = append(o.Ops, OtComponent{Retain: n})
+	}
+	return o
+}
+
+// 插入文本，相邻的插入和删除统一为先插入后删除
+func (o *TextOperation) Insert(s string) *TextOperation {
+	if s == "" {
+		return o
+	}
+	o.TargetLength += Utf16Length(s)
+	last := len(o.Ops) - 1
+	if last >= 0 && o.Ops[last].Insert != "" {
+		o.Ops[last].Insert += s
+	} else if last >= 0 && o.Ops[last].Delete > 0 {
+		if last > 0 && o.Ops[last-1].Insert != "" {
+			o.Ops[last-1].Insert += s
+		} else {
+			o.Ops = append(o.Ops, o.Ops[last])
+			o.Ops[last] = OtComponent{Insert: s}
+		}
+	} else {
+		o.Ops = append(o.Ops, OtComponent{Insert: s})
+	}
+	return o
+}
+
+// 删除n个字符
+func (o *TextOperation) Delete(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	if last := len(o.Ops) - 1; last >= 0 && o.Ops[last].Delete > 0 {
+		o.Ops[last].Delete += n
+	} else {
+		o.Ops = append(o.Ops, OtComponent{Delete: n})
+	}
+	return o
+}
+
+// 是否不改变文本
+func (o *TextOperation) IsNoop() bool {
+	return len(o.Ops) == 0 || len(o.Ops) == 1 && o.Ops[0].Retain > 0
+}
+
+// 应用到文本
+func (o *TextOperation) Apply(text []uint16) ([]uint16, error) {
+	if len(text) != o.BaseLength {
+		return nil, fmt.Errorf("操作的基础长度（%d）与文本长度（%d）不一致", o.BaseLength, len(text))
+	}
+	result := make([]uint16, 0, o.TargetLength)
+	index := 0
+	for _, v := range o.Ops {
+		switch {
+		case v.Retain > 0:
+			result = append(result, text[index:index+v.Retain]...)
+			index += v.Retain
+		case v.Insert != "":
+			result = append(result, utf16.Encode([]rune(v.Insert))...)
+		default:
+			index += v.Delete
+		}
+	}
+	return result, nil
+}
+
+// 生成将文本a修改为b的操作：保留相同的开头、结尾，替换中间不同的部分，不拆分代理对
+func TextOperationDiff(a, b []uint16) *TextOperation {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	if prefix > 0 && a[prefix-1] >= 0xd800 && a[prefix-1] < 0xdc00 {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	if suffix > 0 && a[len(a)-suffix] >= 0xdc00 && a[len(a)-suffix] < 0xe000 {
+		suffix--
+	}
+	operation := &TextOperation{}
+	operation.Retain(prefix)
+	operation.Insert(string(utf16.Decode(b[prefix : len(b)-suffix])))
+	operation.Delete(len(a) - prefix - suffix)
+	operation.Retain(suffix)
+	return operation
+}
+
+// 转换两个基于同一文本的并发操作，返回a'和b'，满足 apply(apply(s, a), b') == apply(apply(s, b), a')
+func TransformOperation(a, b *TextOperation) (*TextOperation, *TextOperation, error) {
+	if a.BaseLength != b.BaseLength {
+		return nil, nil, errors.New("并发操作的基础长度不一致")
+	}
+	a1, b1 := &TextOperation{}, &TextOperation{}
+	ops1, ops2 := a.Ops, b.Ops
+	i1, i2 := 0, 0
+	var op1, op2 *OtComponent
+	next := func(ops []OtComponent, i *int) *OtComponent {
+		if *i >= len(ops) {
+			return nil
+		}
+		c := ops[*i]
+		*i++
+		return &c
+	}
+	op1, op2 = next(ops1, &i1), next(ops2, &i2)
+	for op1 != nil || op2 != nil {
+		// 插入优先，a的插入排在b之前
+		if op1 != nil && op1.Insert != "" {
+			a1.Insert(op1.Insert)
+			b1.Retain(Utf16Length(op1.Insert))
+			op1 = next(ops1, &i1)
+			continue
+		}
+		if op2 != nil && op2.Insert != "" {
+			a1.Retain(Utf16Length(op2.Insert))
+			b1.Insert(op2.Insert)
+			op2 = next(ops2, &i2)
+			continue
+		}
+		if op1 == nil || op2 == nil {
+			return nil, nil, errors.New("并发操作的长度不一致")
+		}
+
+		switch {
+		case op1.Retain > 0 && op2.Retain > 0:
+			n := min(op1.Retain, op2.Retain)
+			a1.Retain(n)
+			b1.Retain(n)
+			op1.Retain -= n
+			op2.Retain -= n
+		case op1.Delete > 0 && op2.Delete > 0:
+			// 双方删除同一段，无需再删除
+			n := min(op1.Delete, op2.Delete)
+			op1.Delete -= n
+			op2.Delete -= n
+		case op1.Delete > 0 && op2.Retain > 0:
+			n := min(op1.Delete, op2.Retain)
+			a1.Delete(n)
+			op1.Delete -= n
+			op2.Retain -= n
+		case op1.Retain > 0 && op2.Delete > 0:
+			n := min(op1.Retain, op2.Delete)
+			b1.Delete(n)
+			op1.Retain -= n
+			op2.Delete -= n
+		default:
+			return nil, nil, errors.New("无效的操作分量")
+		}
+		if op1.Retain == 0 && op1.Delete == 0 {
+			op1 = next(ops1, &i1)
+		}
+		if op2.Retain == 0 && op2.Delete == 0 {
+			op2 = next(ops2, &i2)
+		}
+	}
+	return a1, b1, nil
+}
+
+// 序列化为ot.js格式的数组
+func (o TextOperation) MarshalJSON() ([]byte, error) {
+	result := make([]interface{}, 0, len(o.Ops))
+	for _, v := range o.Ops {
+		switch {
+		case v.Retain > 0:
+			result = append(result, v.Retain)
+		case v.Insert != "":
+			result = append(result, v.Insert)
+		default:
+			result = append(result, -v.Delete)
+		}
+	}
+	return json.Marshal(result)
+}
+
+// 从ot.js格式的数组解析
+func (o *TextOperation) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*o = TextOperation{}
+	for _, v := range raw {
+		switch v := v.(type) {
+		case float64:
+			if v != float64(int(v)) || v == 0 {
+				return fmt.Errorf("无效的操作分量：%v", v)
+			}
+			if v > 0 {
+				o.Retain(int(v))
+			} else {
+				o.Delete(int(-v))
+			}
+		case string:
+			if v == "" {
+				return errors.New("无效的操作分量：空字符串")
+			}
+			o.Insert(v)
+		default:
+			return fmt.Errorf("无效的操作分量：%v", v)
+		}
+	}
+	return nil
+}
+
+// 字符串按UTF-16编码的长度，与JavaScript的String.length一致
+func Utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 解析ot.js格式的操作
+func otParse(t *testing.T, s string) *TextOperation {
+	t.Helper()
+	operation := &TextOperation{}
+	if err := json.Unmarshal([]byte(s), operation); err != nil {
+		t.Fatal(s, err)
+	}
+	return operation
+}
+
+// 应用操作并返回文本
+func otApply(t *testing.T, text string, operation *TextOperation) string {
+	t.Helper()
+	result, err := operation.Apply(utf16.Encode([]rune(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(utf16.Decode(result))
+}
+
+func TestTransformOperation(t *testing.T) {
+	cases := []struct {
+		text, a, b, expect string
+	}{
+		{"abc", `[3,"1"]`, `["0",3]`, "0abc1"},
+		{"abc", `[1,"x",2]`, `[1,"y",2]`, "axybc"}, // 同一位置插入，a在前
+		{"abcdef", `[1,-3,2]`, `[2,-3,1]`, "af"},   // 删除范围重叠
+		{"abcdef", `[-6]`, `[3,"x",3]`, "x"},       // 删除全部时保留对方插入
+		{"a😀b", `[1,-2,1]`, `[4,"c"]`, "abc"},      // 代理对按两个UTF-16单元计算
+		{"hello", `[5," world"]`, `[-1,"H",4]`, "Hello world"},
+	}
+	for _, c := range cases {
+		a, b := otParse(t, c.a), otParse(t, c.b)
+		a1, b1, err := TransformOperation(a, b)
+		if err != nil {
+			t.Fatal(c, err)
+		}
+		left := otApply(t, otApply(t, c.text, a), b1)
+		right := otApply(t, otApply(t, c.text, b), a1)
+		if left != c.expect || right != c.expect {
+			t.Errorf("%v: got %q / %q", c, left, right)
+		}
+	}
+}
+
+func TestTransformOperationInvalid(t *testing.T) {
+	if _, _, err := TransformOperation(otParse(t, `[3]`), otParse(t, `[4]`)); err == nil {
+		t.Error("expected length error")
+	}
+	if _, err := otParse(t, `[2,"x"]`).Apply(utf16.Encode([]rune("abc"))); err == nil {
+		t.Error("expected apply error")
+	}
+}
+
+func TestTextOperationJson(t *testing.T) {
+	operation := otParse(t, `[2,"x",-1,3]`)
+	if operation.BaseLength != 6 || operation.TargetLength != 6 {
+		t.Fatal(operation.BaseLength, operation.TargetLength)
+	}
+	data, err := json.Marshal(operation)
+	if err != nil || string(data) != `[2,"x",-1,3]` {
+		t.Fatal(string(data), err)
+	}
+}
+
+func TestTextOperationDiff(t *testing.T) {
+	cases := [][2]string{
+		{"abc", "abc"},
+		{"", "abc"},
+		{"abc", ""},
+		{"hello world", "hello, world!"},
+		{"a😀b", "a😁b"}, // 代理对只有低位不同
+		{"😀😀", "😀"},
+	}
+	for _, c := range cases {
+		a, b := utf16.Encode([]rune(c[0])), utf16.Encode([]rune(c[1]))
+		operation := TextOperationDiff(a, b)
+		if result := otApply(t, c[0], operation); result != c[1] {
+			t.Errorf("%q -> %q: got %q", c[0], c[1], result)
+		}
+		for _, v := range operation.Ops {
+			if v.Insert != "" && !utf8.ValidString(v.Insert) || strings.ContainsRune(v.Insert, utf8.RuneError) {
+				t.Errorf("%q -> %q: 插入内容拆分了代理对", c[0], c[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// 密码哈希工具类
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id参数
+type Argon2Params struct {
+	Memory  uint32 // 内存开销（KiB）
+	Time    uint32 // 迭代次数
+	Threads uint8  // 并行度
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// 生成argon2id密码哈希，格式：$argon2id$v=19$m=65536,t=3,p=2$salt$hash（salt和hash为无填充base64）
+func HashPassword(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// 是否为argon2id密码哈希
+func IsPasswordHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// 校验argon2id密码哈希，needRehash表示哈希使用的参数与当前参数不一致
+func VerifyPassword(password, encoded string, params Argon2Params) (match bool, needRehash bool, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false, errors.New("不支持的密码哈希格式")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, err
+	}
+	if version != argon2.Version {
+		return false, false, errors.New("不支持的argon2版本")
+	}
+	hashParams := Argon2Params{}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hashParams.Memory, &hashParams.Time, &hashParams.Threads); err != nil {
+		return false, false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, hashParams.Time, hashParams.Memory, hashParams.Threads, uint32(len(key)))
+	match = subtle.ConstantTimeCompare(key, other) == 1
+	return match, match && hashParams != params, nil
+}
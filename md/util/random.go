@@ -2,6 +2,8 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"strings"
 
@@ -41,6 +43,15 @@ func RandomNumber(length int) string {
 	return random(length, numbers)
 }
 
+// 使用安全随机数生成指定字节数的十六进制字符串，用于令牌等敏感场景
+func RandomSecureHex(byteLength int) (string, error) {
+	b := make([]byte, byteLength)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // 从对应字符集中随机生成指定长度字符串
 func random(length int, arr []rune) string {
 	b := make([]rune, length)
@@ -0,0 +1,132 @@
+// 全文检索分词工具类
+package util
+
+import (
+	"html"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 是否为中日韩文字
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// 全文检索分词：字母数字按单词切分，中日韩文字按相邻两字切分（单独一个字时保留单字），统一转为小写
+func SearchTokens(text string) []string {
+	tokens := []string{}
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case IsCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// 去除重复的分词，保持原有顺序
+func UniqueTokens(tokens []string) []string {
+	exists := make(map[string]bool, len(tokens))
+	result := make([]string, 0, len(tokens))
+	for _, v := range tokens {
+		if !exists[v] {
+			exists[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// 截取包含关键词的片段并高亮，返回html转义后的文本，关键词使用<mark>包裹
+// radius为0时返回完整文本
+func HighlightSnippet(text string, terms []string, radius int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// 查找所有关键词出现的位置
+	type interval struct{ start, end int }
+	var matches []interval
+	for _, term := range terms {
+		termRunes := []rune(strings.ToLower(term))
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(termRunes)], termRunes) {
+				matches = append(matches, interval{i, i + len(termRunes)})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	// 确定片段范围
+	start, end := 0, len(runes)
+	if radius > 0 {
+		center := 0
+		if len(matches) > 0 {
+			center = matches[0].start
+		}
+		start = max(center-radius, 0)
+		end = min(center+radius*2, len(runes))
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("...")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < pos || m.start >= end {
+			continue
+		}
+		builder.WriteString(snippetText(runes[pos:m.start]))
+		builder.WriteString("<mark>")
+		builder.WriteString(snippetText(runes[m.start:min(m.end, end)]))
+		builder.WriteString("</mark>")
+		pos = min(m.end, end)
+	}
+	builder.WriteString(snippetText(runes[pos:end]))
+	if end < len(runes) {
+		builder.WriteString("...")
+	}
+	return builder.String()
+}
+
+// 片段文本：换行替换为空格并转义
+func snippetText(runes []rune) string {
+	text := strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(string(runes))
+	return html.EscapeString(text)
+}
@@ -0,0 +1,917 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// WebP有损编码（VP8关键帧，RFC 6386），亮度、色度均使用整块预测，
+// 重建过程与解码器一致，透明度使用未压缩的ALPH块
+
+// 预测模式，编号与解码器一致
+const (
+	vp8PredDC = iota
+	vp8PredTM
+	vp8PredVE
+	vp8PredHE
+)
+
+// 系数类型（token概率表的第一维）
+const (
+	vp8PlaneYAfterY2 = iota
+	vp8PlaneY2
+	vp8PlaneUV
+)
+
+const vp8MaxLevel = 2048
+
+var (
+	// 系数位置对应的概率分组
+	vp8Bands = [17]uint8{0, 1, 2, 3, 6, 4, 5, 6, 6, 6, 6, 6, 6, 6, 6, 7, 0}
+	// 系数的zigzag顺序
+	vp8Zigzag = [16]uint8{0, 1, 4, 8, 5, 2, 3, 6, 9, 12, 13, 10, 7, 11, 14, 15}
+	// 类别3-6附加位的概率
+	vp8Cat3456 = [4][]uint8{
+		{173, 148, 140},
+		{176, 155, 140, 135},
+		{180, 157, 141, 134, 130},
+		{254, 254, 243, 230, 196, 177, 153, 140, 133, 130, 129},
+	}
+)
+
+// EncodeWebP 将图片编码为有损WebP，quality为1-100，包含透明像素时保存透明度
+func EncodeWebP(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || width > 16383 || height > 16383 {
+		return errors.New("WebP图片尺寸超出范围")
+	}
+	rgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+
+	frame := newVP8Encoder(rgba, quality).encode()
+	var alpha []byte
+	for i := 3; i < len(rgba.Pix); i += 4 {
+		if rgba.Pix[i] != 0xff {
+			alpha = webpAlpha(rgba)
+			break
+		}
+	}
+
+	// RIFF容器，带透明度时使用扩展格式
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if alpha != nil {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		webpPutUint24(vp8x[4:], width-1)
+		webpPutUint24(vp8x[7:], height-1)
+		webpChunk(&body, "VP8X", vp8x)
+		webpChunk(&body, "ALPH", alpha)
+	}
+	webpChunk(&body, "VP8 ", frame)
+	header := make([]byte, 8)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(body.Len()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// 未压缩的透明度数据
+func webpAlpha(img *image.NRGBA) []byte {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	alpha := make([]byte, 1, 1+width*height)
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			alpha = append(alpha, row[x*4+3])
+		}
+	}
+	return alpha
+}
+
+// 写入RIFF块，长度为奇数时补齐
+func webpChunk(w *bytes.Buffer, name string, data []byte) {
+	header := make([]byte, 8)
+	copy(header, name)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	w.Write(header)
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func webpPutUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// 布尔熵编码器，见RFC 6386第7章
+type vp8BoolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newVP8BoolEncoder() *vp8BoolEncoder {
+	return &vp8BoolEncoder{rng: 255, bitCount: 24}
+}
+
+func (e *vp8BoolEncoder) put(bit bool, prob uint8) {
+	split := 1 + ((e.rng-1)*uint32(prob))>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// 进位传递到已输出的字节
+func (e *vp8BoolEncoder) carry() {
+	i := len(e.buf) - 1
+	for i >= 0 && e.buf[i] == 0xff {
+		e.buf[i] = 0
+		i--
+	}
+	if i >= 0 {
+		e.buf[i]++
+	}
+}
+
+// 以均匀概率写入n位无符号整数
+func (e *vp8BoolEncoder) putUint(v uint32, n int) {
+	for n > 0 {
+		n--
+		e.put(v>>n&1 == 1, 128)
+	}
+}
+
+// 可选的有符号整数，为0时只写一位
+func (e *vp8BoolEncoder) putOptionalInt(v int32, n int) {
+	if v == 0 {
+		e.put(false, 128)
+		return
+	}
+	e.put(true, 128)
+	if v < 0 {
+		e.putUint(uint32(-v), n)
+		e.put(true, 128)
+	} else {
+		e.putUint(uint32(v), n)
+		e.put(false, 128)
+	}
+}
+
+func (e *vp8BoolEncoder) flush() []byte {
+	c := e.bitCount
+	v := e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= uint(c & 7)
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for i := 0; i < 4; i++ {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+	return e.buf
+}
+
+// 量化步长
+type vp8Quant struct {
+	y1, y2, uv [2]int32 // DC、AC
+}
+
+// 宏块的编码结果
+type vp8Macroblock struct {
+	yMode, uvMode uint8
+	skip          bool
+	y2            [16]int16     // Y2（亮度DC）系数等级
+	y             [16][16]int16 // 16个亮度块，第一个系数在Y2中
+	uv            [8][16]int16  // 4个U块、4个V块
+}
+
+// 熵编码前记录的布尔值，概率为token概率表中的序号或固定概率
+type vp8Bit struct {
+	index uint16 // 小于vp8TokenProbCount时为概率表序号，否则减去该值为固定概率
+	bit   bool
+}
+
+const vp8TokenProbCount = 4 * 8 * 3 * 11
+
+type vp8Encoder struct {
+	mbw, mbh    int
+	width       int
+	height      int
+	yStride     int
+	uvStride    int
+	srcY        []uint8
+	srcU, srcV  []uint8
+	recY        []uint8
+	recU, recV  []uint8
+	qIndex      int
+	quant       vp8Quant
+	filterLevel int
+	mbs         []vp8Macroblock
+}
+
+func newVP8Encoder(img *image.NRGBA, quality int) *vp8Encoder {
+	quality = max(1, min(100, quality))
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	e := &vp8Encoder{width: width, height: height, mbw: (width + 15) / 16, mbh: (height + 15) / 16}
+	e.yStride, e.uvStride = e.mbw*16, e.mbw*8
+	e.srcY = make([]uint8, e.yStride*e.mbh*16)
+	e.srcU = make([]uint8, e.uvStride*e.mbh*8)
+	e.srcV = make([]uint8, e.uvStride*e.mbh*8)
+	e.recY = make([]uint8, len(e.srcY))
+	e.recU = make([]uint8, len(e.srcU))
+	e.recV = make([]uint8, len(e.srcV))
+	e.mbs = make([]vp8Macroblock, e.mbw*e.mbh)
+
+	// RGB转为BT.601 YUV（有限范围），边缘像素复制到宏块边界
+	pixel := func(x, y int) (int32, int32, int32) {
+		x, y = min(x, width-1), min(y, height-1)
+		p := img.Pix[y*img.Stride+x*4:]
+		return int32(p[0]), int32(p[1]), int32(p[2])
+	}
+	for y := 0; y < e.mbh*16; y++ {
+		for x := 0; x < e.yStride; x++ {
+			r, g, b := pixel(x, y)
+			e.srcY[y*e.yStride+x] = uint8((16839*r + 33059*g + 6420*b + 16<<16 + 1<<15) >> 16)
+		}
+	}
+	for y := 0; y < e.mbh*8; y++ {
+		for x := 0; x < e.uvStride; x++ {
+			var r, g, b int32
+			for i := 0; i < 4; i++ {
+				pr, pg, pb := pixel(x*2+i%2, y*2+i/2)
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.srcU[y*e.uvStride+x] = uint8(vp8Clip((-9719*r-19081*g+28800*b+128<<18+1<<17)>>18, 0, 255))
+			e.srcV[y*e.uvStride+x] = uint8(vp8Clip((28800*r-24116*g-4684*b+128<<18+1<<17)>>18, 0, 255))
+		}
+	}
+
+	// 质量对应的量化索引，质量越低量化步长越大
+	e.qIndex = int(math.Round(127 * math.Pow(float64(100-quality)/100, 1.2)))
+	q := e.qIndex
+	e.quant.y1 = [2]int32{int32(vp8DequantDC[q]), int32(vp8DequantAC[q])}
+	e.quant.y2 = [2]int32{int32(vp8DequantDC[q]) * 2, max(8, int32(vp8DequantAC[q])*155/100)}
+	e.quant.uv = [2]int32{int32(vp8DequantDC[min(q, 117)]), int32(vp8DequantAC[q])}
+	e.filterLevel = min(63, q/2+4)
+	return e
+}
+
+func vp8Clip(v, low, high int32) int32 {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
+	}
+	return v
+}
+
+// 编码并返回VP8帧数据
+func (e *vp8Encoder) encode() []byte {
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+
+	// 系数按上下文转为布尔值，统计后更新概率
+	bits := e.tokens()
+	var counts [vp8TokenProbCount][2]int
+	for _, v := range bits {
+		if v.index < vp8TokenProbCount {
+			counts[v.index][btoi(v.bit)]++
+		}
+	}
+	probs := vp8DefaultTokenProb
+	update := map[int]uint8{}
+	for i := 0; i < vp8TokenProbCount; i++ {
+		p := &probs[i/264][i/33%8][i/11%3][i%11]
+		updateProb := vp8TokenUpdateProb[i/264][i/33%8][i/11%3][i%11]
+		n0, n1 := counts[i][0], counts[i][1]
+		if n0+n1 == 0 {
+			continue
+		}
+		newProb := uint8(vp8Clip(int32((n0*255+(n0+n1)/2)/(n0+n1)), 1, 255))
+		saving := vp8BitCost(n0, n1, *p) - vp8BitCost(n0, n1, newProb) - 8 - vp8BitCost(0, 1, updateProb) + vp8BitCost(1, 0, updateProb)
+		if saving > 0 {
+			update[i] = newProb
+			*p = newProb
+		}
+	}
+	flat := func(index uint16) uint8 {
+		i := int(index)
+		return probs[i/264][i/33%8][i/11%3][i%11]
+	}
+
+	// 系数分区
+	tokenEncoder := newVP8BoolEncoder()
+	for _, v := range bits {
+		if v.index < vp8TokenProbCount {
+			tokenEncoder.put(v.bit, flat(v.index))
+		} else {
+			tokenEncoder.put(v.bit, uint8(v.index-vp8TokenProbCount))
+		}
+	}
+	tokenPartition := tokenEncoder.flush()
+
+	// 第一分区：帧头及各宏块的预测模式
+	skipped := 0
+	for _, mb := range e.mbs {
+		if mb.skip {
+			skipped++
+		}
+	}
+	header := newVP8BoolEncoder()
+	header.put(false, 128) // 色彩空间
+	header.put(false, 128) // 像素截断
+	header.put(false, 128) // 不分段
+	header.put(false, 128) // 普通环路滤波
+	header.putUint(uint32(e.filterLevel), 6)
+	header.putUint(0, 3) // 锐度
+	header.put(false, 128)
+	header.putUint(0, 2) // 一个系数分区
+	header.putUint(uint32(e.qIndex), 7)
+	for i := 0; i < 5; i++ {
+		header.putOptionalInt(0, 4)
+	}
+	header.put(false, 128) // 不保留概率
+	for i := 0; i < vp8TokenProbCount; i++ {
+		updateProb := vp8TokenUpdateProb[i/264][i/33%8][i/11%3][i%11]
+		if p, ok := update[i]; ok {
+			header.put(true, updateProb)
+			header.putUint(uint32(p), 8)
+		} else {
+			header.put(false, updateProb)
+		}
+	}
+	skipProb := uint8(0)
+	if skipped > 0 {
+		skipProb = uint8(vp8Clip(int32(255*(len(e.mbs)-skipped)/len(e.mbs)), 1, 254))
+		header.put(true, 128)
+		header.putUint(uint32(skipProb), 8)
+	} else {
+		header.put(false, 128)
+	}
+	for _, mb := range e.mbs {
+		if skipped > 0 {
+			header.put(mb.skip, skipProb)
+		}
+		header.put(true, 145) // 16x16亮度预测
+		switch mb.yMode {
+		case vp8PredDC:
+			header.put(false, 156)
+			header.put(false, 163)
+		case vp8PredVE:
+			header.put(false, 156)
+			header.put(true, 163)
+		case vp8PredHE:
+			header.put(true, 156)
+			header.put(false, 128)
+		case vp8PredTM:
+			header.put(true, 156)
+			header.put(true, 128)
+		}
+		header.put(mb.uvMode != vp8PredDC, 142)
+		if mb.uvMode != vp8PredDC {
+			header.put(mb.uvMode != vp8PredVE, 114)
+			if mb.uvMode != vp8PredVE {
+				header.put(mb.uvMode != vp8PredHE, 183)
+			}
+		}
+	}
+	firstPartition := header.flush()
+
+	// 帧标记及关键帧头
+	size := len(firstPartition)
+	frame := make([]byte, 10, 10+len(firstPartition)+len(tokenPartition))
+	frame[0] = 0x10 | byte(size&7)<<5
+	frame[1] = byte(size >> 3)
+	frame[2] = byte(size >> 11)
+	frame[3], frame[4], frame[5] = 0x9d, 0x01, 0x2a
+	binary.LittleEndian.PutUint16(frame[6:], uint16(e.width))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(e.height))
+	frame = append(frame, firstPartition...)
+	return append(frame, tokenPartition...)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 按概率编码n0个0、n1个1所需的位数
+func vp8BitCost(n0, n1 int, prob uint8) float64 {
+	p := float64(prob) / 256
+	return -float64(n0)*math.Log2(p) - float64(n1)*math.Log2(1-p)
+}
+
+// 预测所需的上方、左侧像素，边缘使用固定值
+type vp8Border struct {
+	top, left   [16]uint8
+	corner      uint8
+	hasTop      bool
+	hasLeft     bool
+	size        int
+	plane       []uint8
+	stride      int
+	originX     int
+	originY     int
+	predictions [4][]uint8
+}
+
+func (e *vp8Encoder) border(plane []uint8, stride, size, mbx, mby int) *vp8Border {
+	b := &vp8Border{size: size, plane: plane, stride: stride, originX: mbx * size, originY: mby * size, hasTop: mby > 0, hasLeft: mbx > 0}
+	for i := 0; i < size; i++ {
+		b.top[i], b.left[i] = 0x7f, 0x81
+		if b.hasTop {
+			b.top[i] = plane[(b.originY-1)*stride+b.originX+i]
+		}
+		if b.hasLeft {
+			b.left[i] = plane[(b.originY+i)*stride+b.originX-1]
+		}
+	}
+	switch {
+	case !b.hasTop:
+		b.corner = 0x7f
+	case !b.hasLeft:
+		b.corner = 0x81
+	default:
+		b.corner = plane[(b.originY-1)*stride+b.originX-1]
+	}
+	for mode := range b.predictions {
+		b.predictions[mode] = b.predict(uint8(mode))
+	}
+	return b
+}
+
+// 整块预测，DC模式在图像边缘只使用存在的一侧
+func (b *vp8Border) predict(mode uint8) []uint8 {
+	n := b.size
+	pred := make([]uint8, n*n)
+	switch mode {
+	case vp8PredDC:
+		var sum, count int
+		if b.hasTop {
+			for i := 0; i < n; i++ {
+				sum += int(b.top[i])
+			}
+			count += n
+		}
+		if b.hasLeft {
+			for i := 0; i < n; i++ {
+				sum += int(b.left[i])
+			}
+			count += n
+		}
+		avg := uint8(0x80)
+		if count > 0 {
+			avg = uint8((sum + count/2) / count)
+		}
+		for i := range pred {
+			pred[i] = avg
+		}
+	case vp8PredTM:
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				pred[y*n+x] = uint8(vp8Clip(int32(b.left[y])+int32(b.top[x])-int32(b.corner), 0, 255))
+			}
+		}
+	case vp8PredVE:
+		for y := 0; y < n; y++ {
+			copy(pred[y*n:], b.top[:n])
+		}
+	case vp8PredHE:
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				pred[y*n+x] = b.left[y]
+			}
+		}
+	}
+	return pred
+}
+
+// 源图像与预测值的差异
+func (b *vp8Border) cost(src []uint8, mode int) int {
+	total := 0
+	pred := b.predictions[mode]
+	for y := 0; y < b.size; y++ {
+		row := src[(b.originY+y)*b.stride+b.originX:]
+		for x := 0; x < b.size; x++ {
+			d := int(row[x]) - int(pred[y*b.size+x])
+			if d < 0 {
+				d = -d
+			}
+			total += d
+		}
+	}
+	return total
+}
+
+// 计算4x4块的残差并做DCT变换
+func (b *vp8Border) residual(src []uint8, mode uint8, bx, by int) [16]int32 {
+	var block [16]int32
+	pred := b.predictions[mode]
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			px, py := bx*4+x, by*4+y
+			block[y*4+x] = int32(src[(b.originY+py)*b.stride+b.originX+px]) - int32(pred[py*b.size+px])
+		}
+	}
+	return vp8ForwardDCT(block)
+}
+
+// 写入预测值，之后叠加反变换的残差
+func (b *vp8Border) reconstruct(rec []uint8, mode uint8) {
+	pred := b.predictions[mode]
+	for y := 0; y < b.size; y++ {
+		copy(rec[(b.originY+y)*b.stride+b.originX:], pred[y*b.size:(y+1)*b.size])
+	}
+}
+
+func (b *vp8Border) addInverseDCT(rec []uint8, coeff [16]int16, bx, by int) {
+	out := vp8InverseDCT(coeff)
+	for y := 0; y < 4; y++ {
+		row := rec[(b.originY+by*4+y)*b.stride+b.originX+bx*4:]
+		for x := 0; x < 4; x++ {
+			row[x] = uint8(vp8Clip(int32(row[x])+out[y*4+x], 0, 255))
+		}
+	}
+}
+
+// 编码一个宏块：选择预测模式、量化并重建
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	mb := &e.mbs[mby*e.mbw+mbx]
+
+	// 亮度
+	yBorder := e.border(e.recY, e.yStride, 16, mbx, mby)
+	mb.yMode = vp8BestMode(func(mode int) int { return yBorder.cost(e.srcY, mode) })
+	var dcs [16]int32
+	var acs [16][16]int32
+	for n := 0; n < 16; n++ {
+		acs[n] = yBorder.residual(e.srcY, mb.yMode, n%4, n/4)
+		dcs[n] = acs[n][0]
+	}
+	y2 := vp8ForwardWHT(dcs)
+	nonzero := false
+	for i := 0; i < 16; i++ {
+		mb.y2[i] = vp8Quantize(y2[i], e.quant.y2[btoi(i > 0)], i == 0)
+		nonzero = nonzero || mb.y2[i] != 0
+	}
+	for n := 0; n < 16; n++ {
+		for i := 1; i < 16; i++ {
+			mb.y[n][i] = vp8Quantize(acs[n][i], e.quant.y1[1], false)
+			nonzero = nonzero || mb.y[n][i] != 0
+		}
+	}
+
+	// 色度，U、V使用相同的预测模式
+	uBorder := e.border(e.recU, e.uvStride, 8, mbx, mby)
+	vBorder := e.border(e.recV, e.uvStride, 8, mbx, mby)
+	mb.uvMode = vp8BestMode(func(mode int) int { return uBorder.cost(e.srcU, mode) + vBorder.cost(e.srcV, mode) })
+	for n := 0; n < 8; n++ {
+		border, src := uBorder, e.srcU
+		if n >= 4 {
+			border, src = vBorder, e.srcV
+		}
+		coeff := border.residual(src, mb.uvMode, n%2, n%4/2)
+		for i := 0; i < 16; i++ {
+			mb.uv[n][i] = vp8Quantize(coeff[i], e.quant.uv[btoi(i > 0)], i == 0)
+			nonzero = nonzero || mb.uv[n][i] != 0
+		}
+	}
+	mb.skip = !nonzero
+
+	// 按解码器的方式重建，作为后续宏块的预测依据
+	yBorder.reconstruct(e.recY, mb.yMode)
+	uBorder.reconstruct(e.recU, mb.uvMode)
+	vBorder.reconstruct(e.recV, mb.uvMode)
+	if mb.skip {
+		return
+	}
+	var y2Coeff [16]int16
+	for i := 0; i < 16; i++ {
+		y2Coeff[i] = int16(int32(mb.y2[i]) * e.quant.y2[btoi(i > 0)])
+	}
+	dc := vp8InverseWHT(y2Coeff)
+	for n := 0; n < 16; n++ {
+		var coeff [16]int16
+		coeff[0] = dc[n]
+		for i := 1; i < 16; i++ {
+			coeff[i] = int16(int32(mb.y[n][i]) * e.quant.y1[1])
+		}
+		yBorder.addInverseDCT(e.recY, coeff, n%4, n/4)
+	}
+	for n := 0; n < 8; n++ {
+		var coeff [16]int16
+		for i := 0; i < 16; i++ {
+			coeff[i] = int16(int32(mb.uv[n][i]) * e.quant.uv[btoi(i > 0)])
+		}
+		if n < 4 {
+			uBorder.addInverseDCT(e.recU, coeff, n%2, n/2)
+		} else {
+			vBorder.addInverseDCT(e.recV, coeff, n%2, n%4/2)
+		}
+	}
+}
+
+// 差异最小的预测模式
+func vp8BestMode(cost func(mode int) int) uint8 {
+	best, bestCost := 0, math.MaxInt
+	for _, mode := range []int{vp8PredDC, vp8PredVE, vp8PredHE, vp8PredTM} {
+		if c := cost(mode); c < bestCost {
+			best, bestCost = mode, c
+		}
+	}
+	return uint8(best)
+}
+
+// 量化，AC系数使用较小的舍入偏移（死区）以减少小系数
+func vp8Quantize(v, q int32, dc bool) int16 {
+	sign := int32(1)
+	if v < 0 {
+		sign, v = -1, -v
+	}
+	bias := q * 3 / 8
+	if dc {
+		bias = q / 2
+	}
+	level := min((v+bias)/q, vp8MaxLevel)
+	return int16(sign * level)
+}
+
+// 按解码顺序生成系数的布尔值序列，上下文为左侧、上方的块是否有非零系数
+func (e *vp8Encoder) tokens() []vp8Bit {
+	var bits []vp8Bit
+	upY := make([][4]uint8, e.mbw)
+	upUV := make([][4]uint8, e.mbw)
+	upY2 := make([]uint8, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		var leftY, leftUV [4]uint8
+		var leftY2 uint8
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &e.mbs[mby*e.mbw+mbx]
+			if mb.skip {
+				leftY, leftUV, leftY2 = [4]uint8{}, [4]uint8{}, 0
+				upY[mbx], upUV[mbx], upY2[mbx] = [4]uint8{}, [4]uint8{}, 0
+				continue
+			}
+			nz := vp8TokenBlock(&bits, vp8PlaneY2, leftY2+upY2[mbx], mb.y2, 0)
+			leftY2, upY2[mbx] = nz, nz
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 4; x++ {
+					nz = vp8TokenBlock(&bits, vp8PlaneYAfterY2, leftY[y]+upY[mbx][x], mb.y[y*4+x], 1)
+					leftY[y], upY[mbx][x] = nz, nz
+				}
+			}
+			// U、V各2x2个块，上下文分别位于第0-1、2-3位
+			for c := 0; c < 4; c += 2 {
+				for y := 0; y < 2; y++ {
+					for x := 0; x < 2; x++ {
+						nz = vp8TokenBlock(&bits, vp8PlaneUV, leftUV[c+y]+upUV[mbx][c+x], mb.uv[c*2+y*2+x], 0)
+						leftUV[c+y], upUV[mbx][c+x] = nz, nz
+					}
+				}
+			}
+		}
+	}
+	return bits
+}
+
+// 编码一个4x4块的系数，返回是否有非零系数
+func vp8TokenBlock(bits *[]vp8Bit, plane int, context uint8, levels [16]int16, first int) uint8 {
+	probIndex := func(band uint8, ctx uint8, i int) uint16 {
+		return uint16(plane*264 + int(band)*33 + int(ctx)*11 + i)
+	}
+	put := func(index uint16, bit bool) {
+		*bits = append(*bits, vp8Bit{index: index, bit: bit})
+	}
+	putFixed := func(prob uint8, bit bool) {
+		put(vp8TokenProbCount+uint16(prob), bit)
+	}
+
+	last := -1
+	for i := first; i < 16; i++ {
+		if levels[vp8Zigzag[i]] != 0 {
+			last = i
+		}
+	}
+	band, ctx := vp8Bands[first], context
+	if last < 0 {
+		put(probIndex(band, ctx, 0), false)
+		return 0
+	}
+	put(probIndex(band, ctx, 0), true)
+	for i := first; i <= last; i++ {
+		level := int32(levels[vp8Zigzag[i]])
+		v := level
+		if v < 0 {
+			v = -v
+		}
+		if v == 0 {
+			put(probIndex(band, ctx, 1), false)
+			band, ctx = vp8Bands[i+1], 0
+			continue
+		}
+		put(probIndex(band, ctx, 1), true)
+		switch {
+		case v == 1:
+			put(probIndex(band, ctx, 2), false)
+		case v <= 4:
+			put(probIndex(band, ctx, 2), true)
+			put(probIndex(band, ctx, 3), false)
+			if v == 2 {
+				put(probIndex(band, ctx, 4), false)
+			} else {
+				put(probIndex(band, ctx, 4), true)
+				put(probIndex(band, ctx, 5), v == 4)
+			}
+		case v <= 10:
+			put(probIndex(band, ctx, 2), true)
+			put(probIndex(band, ctx, 3), true)
+			put(probIndex(band, ctx, 6), false)
+			if v <= 6 {
+				put(probIndex(band, ctx, 7), false)
+				putFixed(159, v == 6)
+			} else {
+				put(probIndex(band, ctx, 7), true)
+				putFixed(165, (v-7)&2 != 0)
+				putFixed(145, (v-7)&1 != 0)
+			}
+		default:
+			put(probIndex(band, ctx, 2), true)
+			put(probIndex(band, ctx, 3), true)
+			put(probIndex(band, ctx, 6), true)
+			cat := 3
+			for cat > 0 && v < 3+8<<cat {
+				cat--
+			}
+			put(probIndex(band, ctx, 8), cat >= 2)
+			put(probIndex(band, ctx, 9+cat/2), cat%2 == 1)
+			extra := v - (3 + 8<<cat)
+			table := vp8Cat3456[cat]
+			for j, prob := range table {
+				putFixed(prob, extra>>(len(table)-1-j)&1 == 1)
+			}
+		}
+		putFixed(128, level < 0)
+		if v == 1 {
+			ctx = 1
+		} else {
+			ctx = 2
+		}
+		band = vp8Bands[i+1]
+		if i == 15 {
+			break
+		}
+		put(probIndex(band, ctx, 0), i != last)
+	}
+	return 1
+}
+
+// 4x4正向DCT
+func vp8ForwardDCT(in [16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		a := (in[i*4+0] + in[i*4+3]) * 8
+		b := (in[i*4+1] + in[i*4+2]) * 8
+		c := (in[i*4+1] - in[i*4+2]) * 8
+		d := (in[i*4+0] - in[i*4+3]) * 8
+		tmp[i*4+0] = a + b
+		tmp[i*4+2] = a - b
+		tmp[i*4+1] = (c*2217 + d*5352 + 14500) >> 12
+		tmp[i*4+3] = (d*2217 - c*5352 + 7500) >> 12
+	}
+	for i := 0; i < 4; i++ {
+		a := tmp[i] + tmp[12+i]
+		b := tmp[4+i] + tmp[8+i]
+		c := tmp[4+i] - tmp[8+i]
+		d := tmp[i] - tmp[12+i]
+		out[i] = (a + b + 7) >> 4
+		out[8+i] = (a - b + 7) >> 4
+		out[4+i] = (c*2217+d*5352+12000)>>16 + int32(btoi(d != 0))
+		out[12+i] = (d*2217 - c*5352 + 51000) >> 16
+	}
+	return out
+}
+
+// 4x4反向DCT，与解码器一致
+func vp8InverseDCT(in [16]int16) [16]int32 {
+	const (
+		c1 = 85627
+		c2 = 35468
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := int32(in[i]) + int32(in[8+i])
+		b := int32(in[i]) - int32(in[8+i])
+		c := (int32(in[4+i])*c2)>>16 - (int32(in[12+i])*c1)>>16
+		d := (int32(in[4+i])*c1)>>16 + (int32(in[12+i])*c2)>>16
+		m[i][0] = a + d
+		m[i][1] = b + c
+		m[i][2] = b - c
+		m[i][3] = a - d
+	}
+	var out [16]int32
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		out[j*4+0] = (a + d) >> 3
+		out[j*4+1] = (b + c) >> 3
+		out[j*4+2] = (b - c) >> 3
+		out[j*4+3] = (a - d) >> 3
+	}
+	return out
+}
+
+// 16个亮度块DC系数的正向WHT
+func vp8ForwardWHT(in [16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		a := (in[i*4+0] + in[i*4+2]) * 4
+		d := (in[i*4+1] + in[i*4+3]) * 4
+		c := (in[i*4+1] - in[i*4+3]) * 4
+		b := (in[i*4+0] - in[i*4+2]) * 4
+		tmp[i*4+0] = a + d + int32(btoi(a != 0))
+		tmp[i*4+1] = b + c
+		tmp[i*4+2] = b - c
+		tmp[i*4+3] = a - d
+	}
+	for i := 0; i < 4; i++ {
+		a := tmp[i] + tmp[8+i]
+		d := tmp[4+i] + tmp[12+i]
+		c := tmp[4+i] - tmp[12+i]
+		b := tmp[i] - tmp[8+i]
+		values := [4]int32{a + d, b + c, b - c, a - d}
+		for j, v := range values {
+			if v < 0 {
+				v++
+			}
+			out[j*4+i] = (v + 3) >> 3
+		}
+	}
+	return out
+}
+
+// 反向WHT，与解码器一致，返回16个亮度块的DC系数
+func vp8InverseWHT(in [16]int16) [16]int16 {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := int32(in[i]) + int32(in[12+i])
+		a1 := int32(in[4+i]) + int32(in[8+i])
+		a2 := int32(in[4+i]) - int32(in[8+i])
+		a3 := int32(in[i]) - int32(in[12+i])
+		m[i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	var out [16]int16
+	for i := 0; i < 4; i++ {
+		dc := m[i*4] + 3
+		a0 := dc + m[3+i*4]
+		a1 := m[1+i*4] + m[2+i*4]
+		a2 := m[1+i*4] - m[2+i*4]
+		a3 := dc - m[3+i*4]
+		out[i*4+0] = int16((a0 + a1) >> 3)
+		out[i*4+1] = int16((a3 + a2) >> 3)
+		out[i*4+2] = int16((a0 - a1) >> 3)
+		out[i*4+3] = int16((a3 - a2) >> 3)
+	}
+	return out
+}
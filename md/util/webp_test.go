@@ -0,0 +1,259 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// 编码测试用的图片集：渐变、噪声、锐利边缘、纯色、细条纹，包含非16倍数的尺寸和透明度
+func webpTestCorpus() map[string]*image.NRGBA {
+	corpus := map[string]*image.NRGBA{}
+	random := rand.New(rand.NewSource(1))
+	fill := func(name string, width, height int, pixel func(x, y int) color.NRGBA) {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.SetNRGBA(x, y, pixel(x, y))
+			}
+		}
+		corpus[name] = img
+	}
+	corpus["gradient"] = imageTestGradient(64, 48, false)
+	corpus["gradient-alpha"] = imageTestGradient(37, 21, true)
+	fill("noise", 45, 33, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255}
+	})
+	fill("noise-alpha", 17, 17, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256))}
+	})
+	fill("checker", 64, 64, func(x, y int) color.NRGBA {
+		if (x/3+y/5)%2 == 0 {
+			return color.NRGBA{0, 0, 0, 255}
+		}
+		return color.NRGBA{255, 255, 255, 255}
+	})
+	fill("solid", 16, 16, func(x, y int) color.NRGBA { return color.NRGBA{200, 30, 90, 255} })
+	fill("stripes", 300, 7, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x % 2 * 255), uint8(y * 36), 255 - uint8(x%256), 255}
+	})
+	fill("saturated", 33, 65, func(x, y int) color.NRGBA {
+		return [...]color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}}[(x/8+y/8)%4]
+	})
+	fill("pixel", 1, 1, func(x, y int) color.NRGBA { return color.NRGBA{10, 20, 30, 255} })
+	return corpus
+}
+
+// 解码结果的YUV平面，带透明度时为*image.NYCbCrA
+func webpTestYCbCr(t *testing.T, img image.Image) *image.YCbCr {
+	t.Helper()
+	switch v := img.(type) {
+	case *image.YCbCr:
+		return v
+	case *image.NYCbCrA:
+		return &v.YCbCr
+	}
+	t.Fatalf("解码结果类型错误 %T", img)
+	return nil
+}
+
+// 亮度的峰值信噪比。WebP使用BT.601有限范围的YUV，而image.YCbCr按全范围转换为RGB，
+// 因此直接比较解码的亮度平面与按编码器公式计算的原图亮度，跳过全透明像素
+func webpTestPSNR(src *image.NRGBA, decoded *image.YCbCr) float64 {
+	var total float64
+	var count int
+	for y := 0; y < src.Rect.Dy(); y++ {
+		for x := 0; x < src.Rect.Dx(); x++ {
+			c := src.NRGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+			want := (16839*int32(c.R) + 33059*int32(c.G) + 6420*int32(c.B) + 16<<16 + 1<<15) >> 16
+			d := float64(decoded.Y[decoded.YOffset(x, y)]) - float64(want)
+			total += d * d
+			count++
+		}
+	}
+	if total == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255*float64(count)/total)
+}
+
+// 不使用环路滤波编码时，x/image/webp解码得到的YUV与编码器的重建结果逐像素一致
+func TestEncodeWebPReconstruction(t *testing.T) {
+	for name, src := range webpTestCorpus() {
+		for _, quality := range []int{1, 50, 90, 100} {
+			e := newVP8Encoder(src, quality)
+			e.filterLevel = 0
+			var body bytes.Buffer
+			body.WriteString("WEBP")
+			webpChunk(&body, "VP8 ", e.encode())
+			data := append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(body.Len())), body.Bytes()...)
+			img, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%s %d：%v", name, quality, err)
+			}
+			decoded := webpTestYCbCr(t, img)
+			width, height := src.Rect.Dx(), src.Rect.Dy()
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					if got, want := decoded.Y[decoded.YOffset(x, y)], e.recY[y*e.yStride+x]; got != want {
+						t.Fatalf("%s %d：亮度(%d,%d)不一致 %d %d", name, quality, x, y, got, want)
+					}
+					offset := decoded.COffset(x, y)
+					if got, want := decoded.Cb[offset], e.recU[y/2*e.uvStride+x/2]; got != want {
+						t.Fatalf("%s %d：色度(%d,%d)不一致 %d %d", name, quality, x, y, got, want)
+					}
+					if got, want := decoded.Cr[offset], e.recV[y/2*e.uvStride+x/2]; got != want {
+						t.Fatalf("%s %d：色度(%d,%d)不一致 %d %d", name, quality, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// x/image/webp可解码全部图片，尺寸、透明度一致，质量越高误差越小
+func TestEncodeWebPCorpus(t *testing.T) {
+	for name, src := range webpTestCorpus() {
+		previous := 0.0
+		for _, quality := range []int{10, 50, 90} {
+			var buffer bytes.Buffer
+			if err := EncodeWebP(&buffer, src, quality); err != nil {
+				t.Fatal(name, err)
+			}
+			img, err := webp.Decode(bytes.NewReader(buffer.Bytes()))
+			if err != nil {
+				t.Fatalf("%s %d：%v", name, quality, err)
+			}
+			if img.Bounds() != src.Bounds() {
+				t.Fatalf("%s %d：尺寸错误 %v", name, quality, img.Bounds())
+			}
+			for y := 0; y < src.Rect.Dy(); y++ {
+				for x := 0; x < src.Rect.Dx(); x++ {
+					if _, _, _, a := img.At(x, y).RGBA(); uint8(a>>8) != src.NRGBAAt(x, y).A {
+						t.Fatalf("%s %d：透明度(%d,%d)错误", name, quality, x, y)
+					}
+				}
+			}
+			psnr := webpTestPSNR(src, webpTestYCbCr(t, img))
+			t.Logf("%s %d：%.1fdB %d字节", name, quality, psnr, buffer.Len())
+			if psnr+0.5 < previous {
+				t.Errorf("%s：质量%d的误差大于更低的质量 %.1f < %.1f", name, quality, psnr, previous)
+			}
+			previous = psnr
+		}
+		if previous < 40 {
+			t.Errorf("%s：质量90的信噪比过低 %.1fdB", name, previous)
+		}
+	}
+}
+
+// 任意像素、尺寸和质量都能编码，x/image/webp可解码且尺寸、透明度一致
+func FuzzEncodeWebP(f *testing.F) {
+	f.Add([]byte{}, uint8(0), uint8(0), 80)
+	f.Add([]byte{255, 0, 0, 255, 0, 255, 0, 128}, uint8(16), uint8(15), 1)
+	f.Add([]byte{0, 0, 0, 0}, uint8(40), uint8(3), 100)
+	f.Add([]byte("webp fuzz seed with some bytes"), uint8(255), uint8(1), -5)
+	f.Fuzz(func(t *testing.T, pixels []byte, width, height uint8, quality int) {
+		src := image.NewNRGBA(image.Rect(0, 0, int(width)+1, int(height)+1))
+		if len(pixels) > 0 {
+			for i := range src.Pix {
+				src.Pix[i] = pixels[i%len(pixels)]
+			}
+		}
+		var buffer bytes.Buffer
+		if err := EncodeWebP(&buffer, src, quality); err != nil {
+			t.Fatal(err)
+		}
+		img, err := webp.Decode(bytes.NewReader(buffer.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != src.Bounds() {
+			t.Fatalf("尺寸错误 %v", img.Bounds())
+		}
+		for y := 0; y < src.Rect.Dy(); y++ {
+			for x := 0; x < src.Rect.Dx(); x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); uint8(a>>8) != src.NRGBAAt(x, y).A {
+					t.Fatalf("透明度(%d,%d)错误", x, y)
+				}
+			}
+		}
+	})
+}
+
+// 截断或格式错误的图片不会导致去除元数据等处理出错，可解码的WebP去除元数据后仍可解码
+func FuzzImageStripMetadata(f *testing.F) {
+	var buffer bytes.Buffer
+	if err := EncodeWebP(&buffer, imageTestGradient(16, 8, true), 80); err != nil {
+		f.Fatal(err)
+	}
+	extended := buffer.Bytes()
+	buffer = bytes.Buffer{}
+	if err := EncodeWebP(&buffer, imageTestGradient(16, 8, false), 80); err != nil {
+		f.Fatal(err)
+	}
+	simple := buffer.Bytes()
+	riff := func(chunks ...[]byte) []byte {
+		body := append([]byte("WEBP"), bytes.Join(chunks, nil)...)
+		return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+	}
+	exif := imageTestRiffChunk("EXIF", append([]byte("Exif\x00\x00"), imageTestExif(6)...))
+	seeds := [][]byte{
+		simple,
+		extended,
+		riff(imageTestRiffChunk("VP8X", []byte{0x08, 0, 0, 0, 15, 0, 0, 7, 0, 0}), simple[12:], exif),
+		// VP8X块长度不足、块长度超出文件
+		riff(imageTestRiffChunk("VP8X", nil), exif),
+		riff(imageTestRiffChunk("VP8X", []byte{0x08, 0, 0, 0}), exif),
+		riff(imageTestRiffChunk("VP8X", []byte{0x08, 0, 0, 0, 15, 0, 0, 7, 0, 0}), []byte("EXIF\xff\xff\xff\xff")),
+		// EXIF中的IFD偏移和数量超出范围
+		riff(imageTestRiffChunk("VP8X", []byte{0x08, 0, 0, 0, 15, 0, 0, 7, 0, 0}), imageTestRiffChunk("EXIF", []byte("II*\x00\xff\xff\xff\x7f"))),
+		riff(imageTestRiffChunk("VP8X", []byte{0x08, 0, 0, 0, 15, 0, 0, 7, 0, 0}), imageTestRiffChunk("EXIF", []byte("MM\x00*\x00\x00\x00\x08\xff\xff"))),
+	}
+	buffer = bytes.Buffer{}
+	if err := jpeg.Encode(&buffer, imageTestGradient(8, 8, false), nil); err != nil {
+		f.Fatal(err)
+	}
+	seeds = append(seeds, buffer.Bytes())
+	buffer = bytes.Buffer{}
+	if err := png.Encode(&buffer, imageTestGradient(8, 8, true)); err != nil {
+		f.Fatal(err)
+	}
+	seeds = append(seeds, buffer.Bytes())
+	for _, seed := range seeds {
+		for _, n := range []int{len(seed), len(seed) - 1, len(seed) / 2, 30, 21, 20, 12, 3} {
+			if n >= 0 && n <= len(seed) {
+				f.Add(seed[:n])
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ImageFormat(data)
+		ImageAnimated(data)
+		stripped, orientation := ImageStripMetadata(data)
+		if orientation < 1 || orientation > 8 {
+			t.Fatalf("方向错误：%d", orientation)
+		}
+		// 与上传时一致，经ImageDecode限制像素数后解码
+		if ImageFormat(data) != "webp" {
+			return
+		}
+		if _, err := ImageDecode(data); err != nil {
+			return
+		}
+		if _, err := ImageDecode(stripped); err != nil {
+			t.Fatalf("去除元数据后无法解码：%v", err)
+		}
+	})
+}
@@ -1,4 +1,6 @@
 import request from "@/utils/request";
+import { host, context } from "@/config";
+import Token from "@/store/token";
 
 class AIApi {
   /**
@@ -11,16 +13,6 @@ class AIApi {
     });
   }
 
-  /**
-   * 获取AI配置（完整版，包含完整API Key，用于同步）
-   */
-  getConfigFull() {
-    return request<AIConfig>({
-      method: "get",
-      url: "/ai/config/full",
-    });
-  }
-
   /**
    * 保存AI配置（到服务器）
    */
@@ -131,142 +123,42 @@ class AIApi {
   }
 
   /**
-   * 直接获取模型列表（前端直接调用AI API）
+   * 获取模型列表（由服务器请求AI API），API Key为空或脱敏时使用服务器保存的Key
    */
-  async getModelsDirect(baseUrl: string, apiKey: string): Promise<ModelListResponse> {
-    const url = baseUrl.replace(/\/$/, "") + "/v1/models";
-    const response = await fetch(url, {
-      method: "GET",
-      headers: {
-        Authorization: `Bearer ${apiKey}`,
-      },
+  getModels(baseUrl: string, apiKey: string) {
+    return request<ModelInfo[]>({
+      method: "post",
+      url: "/ai/models",
+      data: { baseUrl, apiKey },
+      timeout: 30000,
     });
-
-    if (!response.ok) {
-      const text = await response.text();
-      throw new Error(`获取模型列表失败: ${response.status} - ${text}`);
-    }
-
-    return response.json();
   }
 
   /**
-   * 直接检查配置有效性（前端直接调用AI API）
+   * 发送聊天请求并返回完整回复（由服务器代理）
    */
-  async checkConfigDirect(baseUrl: string, apiKey: string): Promise<CheckConfigResponse> {
-    const startTime = Date.now();
-    const url = baseUrl.replace(/\/$/, "") + "/v1/models";
-
-    try {
-      const response = await fetch(url, {
-        method: "GET",
-        headers: {
-          Authorization: `Bearer ${apiKey}`,
-        },
-        signal: AbortSignal.timeout(15000),
-      });
-
-      const latency = Date.now() - startTime;
-
-      if (response.ok) {
-        return { valid: true, message: "配置有效", latency };
-      }
-
-      switch (response.status) {
-        case 401:
-        case 403:
-          return { valid: false, message: "API Key 无效或无权限", latency };
-        case 404:
-          return { valid: false, message: "Base URL 不正确或不兼容", latency };
-        case 429:
-          return { valid: false, message: "请求频率过高或额度不足", latency };
-        default:
-          const text = await response.text();
-          return { valid: false, message: `上游错误 (${response.status}): ${text}`, latency };
-      }
-    } catch (err: any) {
-      const latency = Date.now() - startTime;
-      if (err.name === "TimeoutError" || err.message?.includes("timeout")) {
-        return { valid: false, message: "请求超时，请检查网络或代理设置", latency };
-      }
-      return { valid: false, message: `请求失败: ${err.message}`, latency };
-    }
-  }
-
-  /**
-   * 直接发送聊天请求（前端直接调用AI API）
-   */
-  async chatDirect(
-    config: AIConfig,
-    messages: ChatMessage[],
-    options?: {
-      docTitle?: string;
-      docContent?: string;
-      signal?: AbortSignal;
-    }
-  ): Promise<ChatResponse> {
-    const { docTitle, docContent, signal } = options || {};
-
-    // 构建完整的消息列表
-    const fullMessages: ChatMessage[] = [];
-
-    // 注入系统提示词（使用用户配置的提示词）
-    let systemContent = "";
-    if (config.systemPromptEnabled) {
-      const activePrompt = config.systemPrompts.find((p) => p.id === config.currentPromptId);
-      if (activePrompt?.content) {
-        systemContent = activePrompt.content;
-      }
-    }
-
-    // 注入文档上下文
-    if (config.docContextEnabled && docContent) {
-      let docContext = "\n\n---\n## 文档上下文\n\n";
-      docContext += "以下是用户当前正在编辑的文档内容，仅供参考。\n";
-      docContext += "**重要**：只有当用户明确提及文档、询问文档相关问题、或请求对文档进行操作时，你才应该引用或分析文档内容。";
-      docContext += "对于普通对话（如打招呼、闲聊、与文档无关的问题），请正常回复，不要主动提及或评价文档内容。\n\n";
-      if (docTitle) {
-        docContext += `文档标题：${docTitle}\n`;
-      }
-      docContext += `文档内容：\n\`\`\`markdown\n${docContent}\n\`\`\``;
-      systemContent += docContext;
-    }
-
-    if (systemContent) {
-      fullMessages.push({ role: "system", content: systemContent });
-    }
-
-    // 添加用户消息
-    fullMessages.push(...messages);
-
-    // 发送请求
-    const url = config.baseUrl.replace(/\/$/, "") + "/v1/chat/completions";
-    const response = await fetch(url, {
-      method: "POST",
-      headers: {
-        Authorization: `Bearer ${config.apiKey}`,
-        "Content-Type": "application/json",
+  async chat(model: string, messages: ChatMessage[]): Promise<string> {
+    let output = "";
+    let error: Error | null = null;
+    await this.chatStream(model, messages, {
+      onContent: (content) => {
+        output += content;
+      },
+      onError: (err) => {
+        error = err;
       },
-      body: JSON.stringify({
-        model: config.model,
-        messages: fullMessages,
-        stream: false,
-      }),
     });
-
-    if (!response.ok) {
-      const text = await response.text();
-      throw new Error(`AI请求失败 (${response.status}): ${text}`);
+    if (error) {
+      throw error;
     }
-
-    return response.json();
+    return output;
   }
 
   /**
-   * 流式发送聊天请求（前端直接调用AI API）
+   * 流式发送聊天请求（由服务器代理，使用服务器保存的配置，SSE流式返回）
    */
   async chatStream(
-    config: AIConfig,
+    model: string,
     messages: ChatMessage[],
     options: {
       docTitle?: string;
@@ -279,58 +171,34 @@ class AIApi {
     }
   ): Promise<void> {
     const { docTitle, docContent, onContent, onReasoning, onDone, onError, signal } = options;
-
-    // 构建完整的消息列表
-    const fullMessages: ChatMessage[] = [];
-
-    // 注入系统提示词（使用用户配置的提示词）
-    let systemContent = "";
-    if (config.systemPromptEnabled) {
-      const activePrompt = config.systemPrompts.find((p) => p.id === config.currentPromptId);
-      if (activePrompt?.content) {
-        systemContent = activePrompt.content;
-      }
-    }
-
-    // 注入文档上下文
-    if (config.docContextEnabled && docContent) {
-      let docContext = "\n\n---\n## 文档上下文\n\n";
-      docContext += "以下是用户当前正在编辑的文档内容，仅供参考。\n";
-      docContext += "**重要**：只有当用户明确提及文档、询问文档相关问题、或请求对文档进行操作时，你才应该引用或分析文档内容。";
-      docContext += "对于普通对话（如打招呼、闲聊、与文档无关的问题），请正常回复，不要主动提及或评价文档内容。\n\n";
-      if (docTitle) {
-        docContext += `文档标题：${docTitle}\n`;
-      }
-      docContext += `文档内容：\n\`\`\`markdown\n${docContent}\n\`\`\``;
-      systemContent += docContext;
-    }
-
-    if (systemContent) {
-      fullMessages.push({ role: "system", content: systemContent });
-    }
-
-    fullMessages.push(...messages);
-
-    const url = config.baseUrl.replace(/\/$/, "") + "/v1/chat/completions";
-
-    try {
-      const response = await fetch(url, {
+    const send = () =>
+      fetch(host + context + "/data/ai/chat", {
         method: "POST",
         headers: {
-          Authorization: `Bearer ${config.apiKey}`,
+          Authorization: "Bearer " + Token.getAccessToken(),
           "Content-Type": "application/json",
         },
-        body: JSON.stringify({
-          model: config.model,
-          messages: fullMessages,
-          stream: true,
-        }),
+        body: JSON.stringify({ model, messages, docTitle, docContent }),
         signal,
       });
 
+    try {
+      let response = await send();
       if (!response.ok) {
-        const text = await response.text();
-        throw new Error(`AI请求失败 (${response.status}): ${text}`);
+        throw new Error(`AI请求失败 (${response.status})`);
+      }
+
+      // 开始流式返回前的错误以json返回，认证失败时通过普通请求刷新token后重试
+      if (!response.headers.get("Content-Type")?.includes("text/event-stream")) {
+        let result = await response.json();
+        if (result.code === 401) {
+          await this.configExists();
+          response = await send();
+          result = response.headers.get("Content-Type")?.includes("text/event-stream") ? null : await response.json();
+        }
+        if (result) {
+          throw new Error(result.message || "AI请求失败");
+        }
       }
 
       const reader = response.body?.getReader();
@@ -340,6 +208,7 @@ class AIApi {
 
       const decoder = new TextDecoder();
       let buffer = "";
+      let event = "";
 
       while (true) {
         const { done, value } = await reader.read();
@@ -351,30 +220,41 @@ class AIApi {
 
         for (const line of lines) {
           const trimmed = line.trim();
-          if (!trimmed || trimmed === "data: [DONE]") continue;
-          if (!trimmed.startsWith("data: ")) continue;
+          if (!trimmed) {
+            event = "";
+            continue;
+          }
+          if (trimmed.startsWith("event:")) {
+            event = trimmed.slice(6).trim();
+            continue;
+          }
+          if (!trimmed.startsWith("data:")) continue;
+          const data = trimmed.slice(5).trim();
+          if (data === "[DONE]") {
+            onDone?.();
+            return;
+          }
 
+          let json: any;
           try {
-            const json = JSON.parse(trimmed.slice(6));
-            const delta = json.choices?.[0]?.delta;
-
-            if (delta) {
-              // 处理思考内容 (reasoning_content 是一些模型如 DeepSeek 的字段)
-              if (delta.reasoning_content && onReasoning) {
-                onReasoning(delta.reasoning_content);
-              }
-              // 处理正常内容
-              if (delta.content && onContent) {
-                onContent(delta.content);
-              }
-            }
+            json = JSON.parse(data);
           } catch {
             // 忽略解析错误
+            continue;
+          }
+          if (event === "error") {
+            throw new Error(json.message || "AI请求失败");
+          }
+          // 思考内容（如 DeepSeek 等模型）
+          if (json.reasoning && onReasoning) {
+            onReasoning(json.reasoning);
+          }
+          if (json.content && onContent) {
+            onContent(json.content);
           }
         }
       }
-
-      onDone?.();
+      throw new Error("AI响应意外中断");
     } catch (err: any) {
       if (err.name === "AbortError") {
         return;
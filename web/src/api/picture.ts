@@ -17,12 +17,26 @@ class PictureApi {
   /**
    * 根据id删除图片
    * @param id
+   * @param force 图片仍被文档引用时是否删除
    * @returns
    */
-  delete(id: string) {
+  delete(id: string, force: boolean) {
     return request({
       method: "post",
       url: "/pic/delete",
+      data: { id: id, force: force },
+    });
+  }
+
+  /**
+   * 查询引用图片的文档
+   * @param id
+   * @returns
+   */
+  references(id: string) {
+    return request<PictureReference[]>({
+      method: "post",
+      url: "/pic/references",
       data: { id: id },
     });
   }
@@ -14,6 +14,8 @@ const routes = [
       { path: "/tool", name: "tool", component: () => import("@/views/tool/index.vue") },
     ],
   },
+  // 文档链接，导入的笔记之间的链接为此格式
+  { path: "/doc/:id", redirect: (to: any) => ({ name: "document", query: { id: to.params.id } }) },
   { path: "/open/document", name: "openDocument", component: () => import("@/views/open/doc.vue") },
   { path: "/open/publish", name: "openPublish", component: () => import("@/views/open/publish.vue") },
 ];
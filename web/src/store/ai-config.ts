@@ -148,29 +148,6 @@ class AIConfigStore {
     return store.removeItem(getChatHistoryKey());
   }
 
-  /**
-   * 导出配置为JSON
-   */
-  async exportConfig(): Promise<AIConfigExport> {
-    const config = await this.getConfig();
-    return {
-      version: "1.0",
-      exportTime: Date.now(),
-      config,
-    };
-  }
-
-  /**
-   * 从JSON导入配置
-   */
-  async importConfig(data: AIConfigExport): Promise<void> {
-    if (data.version && data.config) {
-      await this.setConfig(data.config);
-    } else {
-      throw new Error("无效的配置文件格式");
-    }
-  }
-
   /**
    * 清除所有AI相关缓存（用于登出/登录时）
    */
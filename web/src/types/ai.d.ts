@@ -24,37 +24,6 @@ interface ChatMessage {
   content: string;
 }
 
-// 聊天请求（前端直接调用 AI API 使用）
-interface ChatRequest {
-  messages: ChatMessage[];
-  docTitle?: string;
-  docContent?: string;
-}
-
-// 聊天响应选项
-interface ChatChoice {
-  index: number;
-  message: {
-    role: string;
-    content: string;
-  };
-  finish_reason: string;
-}
-
-// 聊天响应
-interface ChatResponse {
-  id: string;
-  object: string;
-  created: number;
-  model: string;
-  choices: ChatChoice[];
-  usage: {
-    prompt_tokens: number;
-    completion_tokens: number;
-    total_tokens: number;
-  };
-}
-
 // 模型信息
 interface ModelInfo {
   id: string;
@@ -63,18 +32,6 @@ interface ModelInfo {
   owned_by: string;
 }
 
-// 模型列表响应
-interface ModelListResponse {
-  object: string;
-  data: ModelInfo[];
-}
-
-// 检查配置请求
-interface CheckConfigRequest {
-  baseUrl: string;
-  apiKey: string;
-}
-
 // 检查配置响应
 interface CheckConfigResponse {
   valid: boolean;
@@ -7,3 +7,12 @@ interface PicturePageResult {
   picturePrefix: string;
   thumbnailPrefix: string;
 }
+
+interface PictureReference {
+  id: string;
+  name: string;
+  bookId: string;
+  bookName: string;
+  updateTime: number;
+  other: boolean;
+}